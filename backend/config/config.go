@@ -8,6 +8,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config 应用配置结构�?
@@ -17,6 +18,8 @@ type Config struct {
 	JWT        JWTConfig
 	CORS       CORSConfig
 	Monitoring MonitoringConfig
+	Reminder   ReminderConfig
+	Automation AutomationConfig
 }
 
 // ServerConfig 服务器配�?
@@ -50,6 +53,19 @@ type MonitoringConfig struct {
 	DataRetentionDays int // 数据保留天数
 }
 
+// ReminderConfig 截止日期提醒配置
+type ReminderConfig struct {
+	Enabled        bool
+	Interval       int             // 扫描间隔（分钟）
+	Offsets        []time.Duration // 截止前提醒的提前量，如 24h、1h
+	EscalationDays int             // 逾期多少天后通知项目管理员，0 表示不升级
+}
+
+// AutomationConfig 自动化规则配置
+type AutomationConfig struct {
+	WebhookAllowlist []string // webhook 允许访问的内网地址或网段，默认拒绝所有回环、私有和链路本地地址
+}
+
 // LoadConfig 加载配置
 func LoadConfig() *Config {
 	config := &Config{
@@ -74,6 +90,15 @@ func LoadConfig() *Config {
 			CleanupInterval:   getEnvAsInt("MONITORING_CLEANUP_INTERVAL", 24),
 			DataRetentionDays: getEnvAsInt("MONITORING_DATA_RETENTION_DAYS", 7),
 		},
+		Reminder: ReminderConfig{
+			Enabled:        getEnvAsBool("REMINDER_ENABLED", true),
+			Interval:       getEnvAsInt("REMINDER_INTERVAL_MINUTES", 5),
+			Offsets:        getEnvAsDurations("REMINDER_OFFSETS", []time.Duration{24 * time.Hour, time.Hour}),
+			EscalationDays: getEnvAsInt("REMINDER_ESCALATION_DAYS", 3),
+		},
+		Automation: AutomationConfig{
+			WebhookAllowlist: getEnvAsList("AUTOMATION_WEBHOOK_ALLOWLIST"),
+		},
 	}
 
 	if config.JWT.Secret == "" {
@@ -114,6 +139,34 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvAsDurations 获取逗号分隔的时长列表（如 24h,1h），格式错误时使用默认值
+func getEnvAsDurations(key string, defaultValue []time.Duration) []time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var durations []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		duration, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || duration <= 0 {
+			return defaultValue
+		}
+		durations = append(durations, duration)
+	}
+	return durations
+}
+
+// getEnvAsList 获取逗号分隔的列表，忽略空项
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
 func generateLocalSecret() string {
 	hostname, _ := os.Hostname()
 
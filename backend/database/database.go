@@ -39,6 +39,10 @@ func InitDatabase(config *config.Config) {
 		log.Fatal("Failed to open database:", err)
 	}
 
+	// 注册 "sqlite" 方言（见 dialect.go），否则 GORM v1 会退回到通用方言，
+	// 自动迁移无法识别已有表，也不会为已有表补充新字段
+	registerSQLiteDialect()
+
 	// 使用 GORM v1 的方式：通过 sql.DB 创建 GORM 实例
 	// GORM v1 支持通过 sql.DB 创建实例
 	DB, err = gorm.Open("sqlite", sqlDB)
@@ -66,7 +70,9 @@ func InitDatabase(config *config.Config) {
 	}
 
 	// 自动迁移表结构
-	AutoMigrate()
+	if err := AutoMigrate(); err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
 
 	// 初始化种子数据（示例项目）
 	SeedDatabase()
@@ -75,8 +81,8 @@ func InitDatabase(config *config.Config) {
 }
 
 // AutoMigrate 自动迁移数据库表
-func AutoMigrate() {
-	DB.AutoMigrate(
+func AutoMigrate() error {
+	tables := []interface{}{
 		// 基础表
 		&models.User{},
 		&models.Project{},
@@ -103,8 +109,56 @@ func AutoMigrate() {
 
 		// 文件管理和任务活动记录表
 		&models.TaskActivity{},
-	)
+		&models.TaskKeyAlias{},
+
+		// 任务排期相关表
+		&models.TaskDependency{},
+
+		// 通知提醒相关表
+		&models.Notification{},
+		&models.TaskReminderLog{},
+
+		// 保存的视图相关表
+		&models.SavedView{},
+		&models.SavedViewDefault{},
+
+		// 工作流相关表
+		&models.WorkflowStatus{},
+		&models.WorkflowPriority{},
+		&models.StageTransition{},
+
+		// 标签相关表
+		&models.Label{},
+		&models.TaskLabel{},
+
+		// 自动化相关表
+		&models.AutomationRule{},
+
+		// 在制品限制相关表
+		&models.WIPViolation{},
+
+		// 项目模板相关表
+		&models.ProjectTemplate{},
+	}
+
+	// 重建早期版本主键定义有问题的表（见 legacy_ids.go）
+	backupLegacyIDTables(tables...)
+	DB.AutoMigrate(tables...)
+	if err := restoreLegacyIDTables(tables...); err != nil {
+		return err
+	}
+
+	// 旧版本的截止时间只能按天设置，新增列后将这些任务标记为全天任务
+	DB.Exec("UPDATE tasks SET due_all_day = 1 WHERE due_all_day IS NULL")
+
+	// 旧版本的阶段有 task_limit 和 max_tasks 两个上限字段，只有 max_tasks 生效；合并到 max_tasks
+	if DB.Dialect().HasColumn("stages", "task_limit") {
+		DB.Exec("UPDATE stages SET max_tasks = task_limit WHERE COALESCE(max_tasks, 0) = 0 AND task_limit > 0")
+		DB.Exec("UPDATE stages SET task_limit = NULL")
+	}
+	DB.Exec("UPDATE stages SET wip_mode = ? WHERE wip_mode IS NULL OR wip_mode = ''", models.WIPModeHard)
 	log.Println("Database tables migrated successfully")
+	return nil
 }
 
 // testTransactionSupport 测试数据库事务支持
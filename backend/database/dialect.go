@@ -0,0 +1,42 @@
+package database
+
+import (
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+)
+
+// sqliteDialect 供 modernc.org/sqlite 驱动使用的 GORM 方言
+// 复用 GORM 内置的 sqlite3 方言，只是按索引名判断索引是否存在：
+// 内置实现通过匹配建表语句判断，无法识别手工创建的带引号索引，重复建索引的错误会中断后续表的迁移
+type sqliteDialect struct {
+	gorm.Dialect
+	db gorm.SQLCommon
+}
+
+// registerSQLiteDialect 注册 "sqlite" 方言
+func registerSQLiteDialect() {
+	if _, ok := gorm.GetDialect("sqlite3"); ok {
+		gorm.RegisterDialect("sqlite", &sqliteDialect{})
+	}
+}
+
+// SetDB 设置数据库连接（GORM 为每个连接创建新的方言实例）
+func (d *sqliteDialect) SetDB(db gorm.SQLCommon) {
+	base, _ := gorm.GetDialect("sqlite3")
+	d.Dialect = reflect.New(reflect.TypeOf(base).Elem()).Interface().(gorm.Dialect)
+	d.Dialect.SetDB(db)
+	d.db = db
+}
+
+// HasIndex 检查索引是否存在
+func (d *sqliteDialect) HasIndex(tableName string, indexName string) bool {
+	var count int
+	d.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?", tableName, indexName).Scan(&count)
+	return count > 0
+}
+
+// GetName 返回方言名称（GORM 克隆连接时按名称重新创建方言）
+func (d *sqliteDialect) GetName() string {
+	return "sqlite"
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 早期版本使用通用方言建表，自增主键被建成 "INTEGER AUTO_INCREMENT" 列，
+// SQLite 不会把这种列当作 rowid 的别名，插入的记录 id 为空，无法按 id 查询、排序或分页。
+// 自动迁移前先备份并删除这些表，由 AutoMigrate 按正确的结构重建，再把数据复制回去（空 id 用 rowid 补齐）。
+// 备份表在恢复成功后才删除；恢复失败时服务拒绝启动，修复问题后下次启动会继续恢复。
+
+const legacyIDBackupSuffix = "_legacy_ids"
+
+// backupLegacyIDTables 备份并删除主键定义有问题的表
+func backupLegacyIDTables(values ...interface{}) {
+	for _, value := range values {
+		table := DB.NewScope(value).TableName()
+		backup := table + legacyIDBackupSuffix
+		if DB.HasTable(backup) {
+			continue // 上次没有恢复完成，等待恢复
+		}
+
+		var ddl string
+		if err := DB.DB().QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&ddl); err != nil {
+			continue
+		}
+		if !strings.Contains(strings.ToUpper(ddl), "AUTO_INCREMENT") {
+			continue
+		}
+
+		tx := DB.Begin()
+		if err := tx.Exec(fmt.Sprintf(`CREATE TABLE "%s" AS SELECT rowid AS legacy_rowid, * FROM "%s" ORDER BY rowid`, backup, table)).Error; err != nil {
+			tx.Rollback()
+			log.Printf("Failed to back up table %s: %v", table, err)
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf(`DROP TABLE "%s"`, table)).Error; err != nil {
+			tx.Rollback()
+			log.Printf("Failed to drop table %s: %v", table, err)
+			continue
+		}
+		if err := tx.Commit().Error; err != nil {
+			log.Printf("Failed to back up table %s: %v", table, err)
+			continue
+		}
+		log.Printf("Rebuilding table %s with an auto-increment primary key", table)
+	}
+}
+
+// restoreLegacyIDTables 将备份的数据复制到重建后的表并删除备份。
+// 恢复失败时返回错误：此时重建后的表是空的，不能继续启动服务
+func restoreLegacyIDTables(values ...interface{}) error {
+	for _, value := range values {
+		table := DB.NewScope(value).TableName()
+		backup := table + legacyIDBackupSuffix
+		if !DB.HasTable(backup) {
+			continue
+		}
+
+		// 只复制新旧表都有的列
+		existing := make(map[string]bool)
+		for _, column := range tableColumns(backup) {
+			existing[column] = true
+		}
+		columns := []string{`"id"`}
+		selects := []string{`COALESCE("id", legacy_rowid)`}
+		for _, column := range tableColumns(table) {
+			if column != "id" && existing[column] {
+				columns = append(columns, `"`+column+`"`)
+				selects = append(selects, `"`+column+`"`)
+			}
+		}
+
+		tx := DB.Begin()
+		if err := tx.Exec(fmt.Sprintf(`INSERT INTO "%s" (%s) SELECT %s FROM "%s" ORDER BY legacy_rowid`,
+			table, strings.Join(columns, ", "), strings.Join(selects, ", "), backup)).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("restore table %s from %s: %v", table, backup, err)
+		}
+		if err := tx.Exec(fmt.Sprintf(`DROP TABLE "%s"`, backup)).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("drop backup table %s: %v", backup, err)
+		}
+		if err := tx.Commit().Error; err != nil {
+			return fmt.Errorf("restore table %s: %v", table, err)
+		}
+	}
+	return nil
+}
+
+// tableColumns 返回表的列名
+func tableColumns(table string) []string {
+	rows, err := DB.DB().Query(fmt.Sprintf(`PRAGMA table_info("%s")`, table))
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal interface{}
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &pk); err == nil {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
@@ -14,7 +14,10 @@ require (
 	gorm.io/gorm v1.31.0
 )
 
-require github.com/glebarez/sqlite v1.11.0
+require (
+	github.com/glebarez/sqlite v1.11.0
+	modernc.org/sqlite v1.40.0
+)
 
 require (
 	github.com/bytedance/sonic v1.9.1 // indirect
@@ -51,5 +54,4 @@ require (
 	modernc.org/libc v1.66.10 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.40.0 // indirect
 )
@@ -3,6 +3,7 @@ package handlers
 import (
 	"project-manager-backend/database"
 	"project-manager-backend/models"
+	"project-manager-backend/services"
 	"project-manager-backend/utils"
 	"strconv"
 	"time"
@@ -21,6 +22,8 @@ type ProjectStats struct {
 	InProgressTasks int64     `json:"in_progress_tasks"`
 	TodoTasks       int64     `json:"todo_tasks"`
 	OverdueTasks    int64     `json:"overdue_tasks"`
+	DueTodayTasks   int64     `json:"due_today_tasks"`
+	Timezone        string    `json:"timezone"` // 计算逾期和今日到期所用的时区
 	CompletionRate  float64   `json:"completion_rate"`
 	TotalMembers    int64     `json:"total_members"`
 	ActiveMembers   int64     `json:"active_members"`
@@ -31,13 +34,25 @@ type ProjectStats struct {
 
 // TaskStats 任务统计
 type TaskStats struct {
-	TotalTasks        int64   `json:"total_tasks"`
-	CompletedTasks    int64   `json:"completed_tasks"`
-	InProgressTasks   int64   `json:"in_progress_tasks"`
-	TodoTasks         int64   `json:"todo_tasks"`
-	OverdueTasks      int64   `json:"overdue_tasks"`
-	CompletionRate    float64 `json:"completion_rate"`
-	AvgCompletionTime float64 `json:"avg_completion_time"` // 平均完成时间（小时）
+	TotalTasks        int64         `json:"total_tasks"`
+	CompletedTasks    int64         `json:"completed_tasks"`
+	InProgressTasks   int64         `json:"in_progress_tasks"`
+	TodoTasks         int64         `json:"todo_tasks"`
+	OverdueTasks      int64         `json:"overdue_tasks"`
+	DueTodayTasks     int64         `json:"due_today_tasks"` // 今天到期且未完成的任务数
+	Timezone          string        `json:"timezone"`        // 计算逾期和今日到期所用的时区
+	CompletionRate    float64       `json:"completion_rate"`
+	AvgCompletionTime float64       `json:"avg_completion_time"` // 平均完成时间（小时）
+	StatusCounts      []StatusCount `json:"status_counts"`       // 按项目定义的各状态任务数
+}
+
+// StatusCount 单个状态的任务数
+type StatusCount struct {
+	Key      string                `json:"key"`
+	Name     string                `json:"name"`
+	Category models.StatusCategory `json:"category"`
+	Color    string                `json:"color"`
+	Count    int64                 `json:"count"`
 }
 
 // UserStats 用户统计
@@ -87,8 +102,12 @@ func (h *AnalyticsHandler) GetProjectStats(c *gin.Context) {
 	}
 
 	// 获取任务统计
+	loc, ok := h.statsTimezone(c, userID, uint(projectID))
+	if !ok {
+		return
+	}
 	var taskStats TaskStats
-	if err := h.getTaskStats(uint(projectID), &taskStats); err != nil {
+	if err := h.getTaskStats(uint(projectID), &taskStats, loc); err != nil {
 		utils.InternalServerError(c, "获取任务统计失败")
 		return
 	}
@@ -128,6 +147,8 @@ func (h *AnalyticsHandler) GetProjectStats(c *gin.Context) {
 		InProgressTasks: taskStats.InProgressTasks,
 		TodoTasks:       taskStats.TodoTasks,
 		OverdueTasks:    taskStats.OverdueTasks,
+		DueTodayTasks:   taskStats.DueTodayTasks,
+		Timezone:        taskStats.Timezone,
 		CompletionRate:  taskStats.CompletionRate,
 		TotalMembers:    totalMembers,
 		ActiveMembers:   activeMembers,
@@ -154,8 +175,12 @@ func (h *AnalyticsHandler) GetTaskStats(c *gin.Context) {
 		return
 	}
 
+	loc, ok := h.statsTimezone(c, userID, uint(projectID))
+	if !ok {
+		return
+	}
 	var taskStats TaskStats
-	if err := h.getTaskStats(uint(projectID), &taskStats); err != nil {
+	if err := h.getTaskStats(uint(projectID), &taskStats, loc); err != nil {
 		utils.InternalServerError(c, "获取任务统计失败")
 		return
 	}
@@ -190,12 +215,12 @@ func (h *AnalyticsHandler) GetStageStats(c *gin.Context) {
 		var totalTasks, completedTasks int64
 
 		// 获取阶段任务总数
-		if err := database.DB.Model(&models.Task{}).Where("stage_id = ?", stage.ID).Count(&totalTasks).Error; err != nil {
+		if err := database.DB.Model(&models.Task{}).Where("stage_id = ? AND "+services.TaskNotArchivedCondition, stage.ID).Count(&totalTasks).Error; err != nil {
 			continue
 		}
 
 		// 获取已完成任务数
-		if err := database.DB.Model(&models.Task{}).Where("stage_id = ? AND status = ?", stage.ID, "done").Count(&completedTasks).Error; err != nil {
+		if err := database.DB.Model(&models.Task{}).Where("stage_id = ? AND "+services.TaskNotArchivedCondition+" AND "+services.TaskStatusCategoryCondition(models.StatusCategoryDone), stage.ID).Count(&completedTasks).Error; err != nil {
 			continue
 		}
 
@@ -301,32 +326,71 @@ func (h *AnalyticsHandler) GetTaskTrend(c *gin.Context) {
 }
 
 // getTaskStats 获取任务统计（内部方法）
-func (h *AnalyticsHandler) getTaskStats(projectID uint, stats *TaskStats) error {
-	// 获取总任务数
-	if err := database.DB.Model(&models.Task{}).Where("project_id = ?", projectID).Count(&stats.TotalTasks).Error; err != nil {
+// 逾期和今日到期按 loc 计算：全天任务在当地截止日期结束后才算逾期
+func (h *AnalyticsHandler) getTaskStats(projectID uint, stats *TaskStats, loc *time.Location) error {
+	// 获取总任务数（不含已归档的任务）
+	projectTasks := "project_id = ? AND " + services.TaskNotArchivedCondition
+	if err := database.DB.Model(&models.Task{}).Where(projectTasks, projectID).Count(&stats.TotalTasks).Error; err != nil {
 		return err
 	}
 
-	// 获取已完成任务数
-	if err := database.DB.Model(&models.Task{}).Where("project_id = ? AND status = ?", projectID, "done").Count(&stats.CompletedTasks).Error; err != nil {
+	// 按状态分类统计：已完成、进行中、待办
+	doneCondition := services.TaskStatusCategoryCondition(models.StatusCategoryDone)
+	if err := database.DB.Model(&models.Task{}).Where(projectTasks+" AND "+doneCondition, projectID).Count(&stats.CompletedTasks).Error; err != nil {
 		return err
 	}
-
-	// 获取进行中任务数
-	if err := database.DB.Model(&models.Task{}).Where("project_id = ? AND status = ?", projectID, "in_progress").Count(&stats.InProgressTasks).Error; err != nil {
+	if err := database.DB.Model(&models.Task{}).Where(projectTasks+" AND "+services.TaskStatusCategoryCondition(models.StatusCategoryInProgress), projectID).Count(&stats.InProgressTasks).Error; err != nil {
+		return err
+	}
+	if err := database.DB.Model(&models.Task{}).Where(projectTasks+" AND "+services.TaskStatusCategoryCondition(models.StatusCategoryTodo), projectID).Count(&stats.TodoTasks).Error; err != nil {
 		return err
 	}
 
-	// 获取待办任务数
-	if err := database.DB.Model(&models.Task{}).Where("project_id = ? AND status = ?", projectID, "todo").Count(&stats.TodoTasks).Error; err != nil {
+	// 按项目定义的状态逐一统计
+	workflow, err := services.NewWorkflowService().GetWorkflow(database.DB, projectID)
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := database.DB.Model(&models.Task{}).Select("status, COUNT(*) AS count").
+		Where(projectTasks, projectID).Group("status").Scan(&rows).Error; err != nil {
 		return err
 	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	stats.StatusCounts = make([]StatusCount, 0, len(workflow.Statuses))
+	for _, status := range workflow.Statuses {
+		stats.StatusCounts = append(stats.StatusCounts, StatusCount{
+			Key:      status.Key,
+			Name:     status.Name,
+			Category: status.Category,
+			Color:    status.Color,
+			Count:    counts[status.Key],
+		})
+	}
 
-	// 获取逾期任务数
-	now := time.Now()
-	if err := database.DB.Model(&models.Task{}).Where("project_id = ? AND due_date < ? AND status != ?", projectID, now, "done").Count(&stats.OverdueTasks).Error; err != nil {
+	// 获取逾期任务数和今日到期任务数
+	var dueTasks []models.Task
+	if err := database.DB.Select("id, due_date, due_all_day").
+		Where(projectTasks+" AND due_date IS NOT NULL AND NOT "+doneCondition, projectID).
+		Find(&dueTasks).Error; err != nil {
 		return err
 	}
+	now := time.Now()
+	for _, task := range dueTasks {
+		if utils.IsOverdue(*task.DueDate, task.DueAllDay, now, loc) {
+			stats.OverdueTasks++
+		}
+		if utils.IsDueToday(*task.DueDate, task.DueAllDay, now, loc) {
+			stats.DueTodayTasks++
+		}
+	}
+	stats.Timezone = loc.String()
 
 	// 计算完成率
 	if stats.TotalTasks > 0 {
@@ -338,3 +402,16 @@ func (h *AnalyticsHandler) getTaskStats(projectID uint, stats *TaskStats) error
 
 	return nil
 }
+
+// statsTimezone 确定统计使用的时区：查询参数 tz，否则与任务截止时间一致（见 utils.TaskTimezone）
+func (h *AnalyticsHandler) statsTimezone(c *gin.Context, userID, projectID uint) (*time.Location, bool) {
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			utils.BadRequest(c, "无效的时区: "+tz)
+			return nil, false
+		}
+		return loc, true
+	}
+	return utils.TaskTimezone(userID, projectID), true
+}
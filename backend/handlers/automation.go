@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AutomationHandler 项目自动化规则处理器
+type AutomationHandler struct {
+	AutomationService *services.AutomationService
+}
+
+// NewAutomationHandler 创建项目自动化规则处理器
+func NewAutomationHandler() *AutomationHandler {
+	return &AutomationHandler{
+		AutomationService: services.NewAutomationService(),
+	}
+}
+
+// AutomationRuleRequest 创建或更新自动化规则请求
+type AutomationRuleRequest struct {
+	Name           string                       `json:"name" binding:"required,max=100"`
+	Enabled        *bool                        `json:"enabled"` // 默认启用
+	Trigger        models.AutomationTrigger     `json:"trigger" binding:"required"`
+	TriggerStageID *uint                        `json:"trigger_stage_id"`
+	Conditions     []models.AutomationCondition `json:"conditions"`
+	Actions        []models.AutomationAction    `json:"actions" binding:"required"`
+}
+
+// GetAutomationRules 获取项目的自动化规则
+func (h *AutomationHandler) GetAutomationRules(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	var rules []models.AutomationRule
+	if err := database.DB.Where("project_id = ?", projectID).Order("id ASC").Find(&rules).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch automation rules")
+		return
+	}
+
+	utils.Success(c, gin.H{"rules": rules})
+}
+
+// CreateAutomationRule 创建自动化规则，规则产生的活动和评论记在创建者名下
+func (h *AutomationHandler) CreateAutomationRule(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CanManageProject(userID, uint(projectID)) {
+		utils.Forbidden(c, "Insufficient permissions to manage automation rules")
+		return
+	}
+	if !utils.CheckProjectWritable(c, uint(projectID)) {
+		return
+	}
+
+	var req AutomationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	rule := models.AutomationRule{
+		ProjectID: uint(projectID),
+		Enabled:   true,
+		CreatedBy: userID,
+	}
+	if !h.applyRequest(c, &rule, &req) {
+		return
+	}
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create automation rule")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"rule":    rule,
+		"message": "Automation rule created successfully",
+	})
+}
+
+// UpdateAutomationRule 更新自动化规则
+func (h *AutomationHandler) UpdateAutomationRule(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	rule, ok := h.findRule(c, userID)
+	if !ok {
+		return
+	}
+
+	var req AutomationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	if !h.applyRequest(c, rule, &req) {
+		return
+	}
+
+	if err := database.DB.Save(rule).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update automation rule")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"rule":    rule,
+		"message": "Automation rule updated successfully",
+	})
+}
+
+// DeleteAutomationRule 删除自动化规则
+func (h *AutomationHandler) DeleteAutomationRule(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	rule, ok := h.findRule(c, userID)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(rule).Error; err != nil {
+		utils.InternalServerError(c, "Failed to delete automation rule")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Automation rule deleted successfully"})
+}
+
+// applyRequest 将请求写入规则并校验
+func (h *AutomationHandler) applyRequest(c *gin.Context, rule *models.AutomationRule, req *AutomationRuleRequest) bool {
+	rule.Name = req.Name
+	rule.Trigger = req.Trigger
+	rule.TriggerStageID = req.TriggerStageID
+	rule.Conditions = req.Conditions
+	rule.Actions = req.Actions
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if rule.Conditions == nil {
+		rule.Conditions = []models.AutomationCondition{}
+	}
+
+	if err := h.AutomationService.ValidateRule(database.DB, rule); err != nil {
+		utils.BadRequest(c, err.Error())
+		return false
+	}
+	return true
+}
+
+// findRule 查找路由中的规则并检查管理权限
+func (h *AutomationHandler) findRule(c *gin.Context, userID uint) (*models.AutomationRule, bool) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return nil, false
+	}
+	ruleID, err := strconv.ParseUint(c.Param("ruleId"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid rule ID")
+		return nil, false
+	}
+
+	if !utils.CanManageProject(userID, uint(projectID)) {
+		utils.Forbidden(c, "Insufficient permissions to manage automation rules")
+		return nil, false
+	}
+	if !utils.CheckProjectWritable(c, uint(projectID)) {
+		return nil, false
+	}
+
+	var rule models.AutomationRule
+	if err := database.DB.Where("id = ? AND project_id = ?", ruleID, projectID).First(&rule).Error; err != nil {
+		utils.NotFound(c, "Automation rule not found")
+		return nil, false
+	}
+	return &rule, true
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"log"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BoardHandler 泳道看板处理器
+type BoardHandler struct {
+	BoardService *services.BoardService
+}
+
+// NewBoardHandler 创建泳道看板处理器
+func NewBoardHandler() *BoardHandler {
+	return &BoardHandler{
+		BoardService: services.NewBoardService(),
+	}
+}
+
+// BoardMoveRequest 在泳道看板上移动任务请求
+type BoardMoveRequest struct {
+	StageID      uint              `json:"stage_id" binding:"required"` // 目标阶段，可以与当前阶段相同
+	LaneBy       string            `json:"lane_by" binding:"required"`  // assignee/priority/label
+	FromLane     *string           `json:"from_lane"`                   // 拖动前所在的泳道；按标签分组时移除该标签，不传表示不移除
+	ToLane       *string           `json:"to_lane"`                     // 目标泳道，不传表示不修改泳道取值
+	AfterTaskID  *services.TaskRef `json:"after_task_id"`               // 插入到该任务之后（任务ID或编号）
+	BeforeTaskID *services.TaskRef `json:"before_task_id"`              // 插入到该任务之前（任务ID或编号）
+}
+
+// GetBoard 获取按泳道分组的项目看板
+// 查询参数：lane_by（assignee/priority/label，默认 assignee）、q（任务查询语言）；已归档的任务不显示
+func (h *BoardHandler) GetBoard(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	var project models.Project
+	// 已归档的项目可以只读访问
+	if err := database.DB.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	laneBy := c.DefaultQuery("lane_by", services.LaneByAssignee)
+	if !services.ValidLaneBy(laneBy) {
+		utils.BadRequest(c, "Invalid lane_by: must be assignee, priority or label")
+		return
+	}
+
+	query := database.DB.Preload("Assignee").
+		Where("tasks.project_id = ? AND "+services.TaskNotArchivedCondition, projectID)
+	query, _, ok := applyTaskQuery(c, query, userID, uint(projectID))
+	if !ok {
+		return
+	}
+
+	var tasks []models.Task
+	if err := query.Order("tasks.rank ASC, tasks.id ASC").Find(&tasks).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch tasks")
+		return
+	}
+	if err := fillStagePositions(tasks); err != nil {
+		utils.InternalServerError(c, "Failed to fetch task positions")
+		return
+	}
+
+	board, err := h.BoardService.BuildBoard(database.DB, uint(projectID), laneBy, tasks)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to build board")
+		return
+	}
+
+	utils.Success(c, gin.H{"board": board})
+}
+
+// MoveBoardTask 在泳道看板上移动任务，同时修改阶段和泳道取值
+// 拖到其他负责人的泳道会改派任务，拖到其他优先级的泳道会修改优先级；
+// 按标签分组时移除 from_lane 的标签并添加 to_lane 的标签（没有标签的泳道不添加）
+func (h *TaskHandler) MoveBoardTask(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return
+	}
+
+	var req BoardMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+	afterTaskID, ok := resolveOptionalTaskRef(c, req.AfterTaskID)
+	if !ok {
+		return
+	}
+	beforeTaskID, ok := resolveOptionalTaskRef(c, req.BeforeTaskID)
+	if !ok {
+		return
+	}
+	if !services.ValidLaneBy(req.LaneBy) {
+		utils.BadRequest(c, "Invalid lane_by: must be assignee, priority or label")
+		return
+	}
+
+	var task models.Task
+	if err := database.DB.Preload("Stage").First(&task, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	if !utils.CanManageTasks(userID, task.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to move task")
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	if task.ArchivedAt != nil {
+		utils.BadRequest(c, "Task is archived, restore it before moving")
+		return
+	}
+
+	var stage models.Stage
+	if err := database.DB.Where("id = ? AND project_id = ?", req.StageID, task.ProjectID).First(&stage).Error; err != nil {
+		utils.NotFound(c, "Target stage not found")
+		return
+	}
+	stageChanged := task.StageID != stage.ID
+	if stageChanged && !stage.AllowTaskMovement {
+		utils.BadRequest(c, "Task movement is not allowed to this stage")
+		return
+	}
+
+	workflow, err := h.WorkflowService.GetWorkflow(database.DB, task.ProjectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return
+	}
+
+	// 计算泳道变化
+	assignee := task.AssigneeID
+	assigneeChanged := false
+	priority := task.Priority
+	var removeLabelID, addLabelID uint
+	if req.ToLane != nil {
+		switch req.LaneBy {
+		case services.LaneByAssignee:
+			if assignee, err = services.ParseLaneUserID(*req.ToLane); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+			if assignee != nil && !utils.CheckProjectMember(*assignee, task.ProjectID) && !utils.CheckProjectOwner(*assignee, task.ProjectID) {
+				utils.BadRequest(c, "Assignee is not a member of this project")
+				return
+			}
+			assigneeChanged = !utils.SameUserID(task.AssigneeID, assignee)
+		case services.LaneByPriority:
+			if err := workflow.ValidatePriority(*req.ToLane); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+			priority = *req.ToLane
+		case services.LaneByLabel:
+			if addLabelID, err = services.ParseLaneLabelID(*req.ToLane); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+			if req.FromLane != nil {
+				if removeLabelID, err = services.ParseLaneLabelID(*req.FromLane); err != nil {
+					utils.BadRequest(c, err.Error())
+					return
+				}
+			}
+			if removeLabelID == addLabelID {
+				removeLabelID, addLabelID = 0, 0
+			}
+		}
+	}
+	priorityChanged := priority != task.Priority
+
+	// 跨阶段移动时检查流转规则，并按改派后的负责人检查在制品上限
+	if stageChanged && !h.checkTransitions(c, task.ProjectID, []*models.Task{&task}, stage.ID, userID) {
+		return
+	}
+	var wip *services.WIPResult
+	if stageChanged {
+		if wip, ok = h.checkStageWIP(c, &stage, []*uint{assignee}, "move", userID, task.ID); !ok {
+			return
+		}
+	} else if assigneeChanged {
+		if wip, ok = h.checkAssigneeWIP(c, &stage, assignee, userID, task.ID); !ok {
+			return
+		}
+	}
+
+	var entry *services.StageEntry
+	if stageChanged {
+		entry = services.ComputeStageEntry(workflow, &task, task.Stage, &stage, time.Now())
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	updates := map[string]interface{}{}
+	// 只换泳道且没有指定相邻任务时保持阶段内的顺序，否则插入到相邻任务之间或追加到阶段末尾
+	if stageChanged || afterTaskID != nil || beforeTaskID != nil {
+		rank, err := h.RankService.RankForMove(tx, stage.ID, task.ID, afterTaskID, beforeTaskID, -1)
+		if err != nil {
+			tx.Rollback()
+			utils.BadRequest(c, "Failed to compute task position: "+err.Error())
+			return
+		}
+		updates["stage_id"] = stage.ID
+		updates["rank"] = rank
+	}
+	if entry != nil {
+		for field, value := range entry.Updates {
+			updates[field] = value
+		}
+	}
+	if assigneeChanged {
+		updates["assignee_id"] = assignee
+	}
+	if priorityChanged {
+		updates["priority"] = priority
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to move task: "+err.Error())
+			return
+		}
+	}
+
+	labelService := services.NewLabelService()
+	var removedLabel, addedLabel *models.Label
+	if removeLabelID != 0 {
+		label, removed, err := labelService.RemoveTaskLabel(tx, &task, removeLabelID)
+		if err != nil {
+			tx.Rollback()
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		if removed {
+			removedLabel = label
+		}
+	}
+	if addLabelID != 0 {
+		label, added, err := labelService.AddTaskLabel(tx, &task, addLabelID)
+		if err != nil {
+			tx.Rollback()
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		if added {
+			addedLabel = label
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.recordWIPWarnings(wip, task.ID)
+
+	if h.ActivityService != nil {
+		if stageChanged {
+			if err := h.ActivityService.LogTaskMoved(
+				task.ID, userID, task.ProjectID,
+				task.StageID, stage.ID,
+				task.Stage.Name, stage.Name,
+				c,
+			); err != nil {
+				log.Printf("Failed to log task move activity: %v", err)
+			}
+		}
+		if assigneeChanged {
+			if err := h.ActivityService.LogTaskUpdated(
+				task.ID, userID, task.ProjectID,
+				"assignee_id", laneUserID(task.AssigneeID), laneUserID(assignee),
+				c,
+			); err != nil {
+				log.Printf("Failed to log task update activity for field assignee_id: %v", err)
+			}
+		}
+		if priorityChanged {
+			if err := h.ActivityService.LogTaskUpdated(
+				task.ID, userID, task.ProjectID,
+				"priority", task.Priority, priority,
+				c,
+			); err != nil {
+				log.Printf("Failed to log task update activity for field priority: %v", err)
+			}
+		}
+		for _, change := range []struct {
+			label *models.Label
+			added bool
+		}{{removedLabel, false}, {addedLabel, true}} {
+			if change.label == nil {
+				continue
+			}
+			if err := h.ActivityService.LogTaskLabeled(task.ID, userID, task.ProjectID, change.label.Name, change.added, c); err != nil {
+				log.Printf("Failed to log task label activity: %v", err)
+			}
+		}
+	}
+	h.logStageEntry(&task, userID, entry, c)
+
+	// 执行自动化规则
+	if stageChanged {
+		h.dispatchAutomation(services.AutomationEvent{
+			Trigger:   models.TriggerTaskMoved,
+			ProjectID: task.ProjectID,
+			TaskID:    task.ID,
+			ActorID:   userID,
+			StageID:   stage.ID,
+		})
+	}
+	if assigneeChanged {
+		h.dispatchAutomation(services.AutomationEvent{
+			Trigger:   models.TriggerAssigneeChanged,
+			ProjectID: task.ProjectID,
+			TaskID:    task.ID,
+			ActorID:   userID,
+		})
+	}
+
+	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, task.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload task data")
+		return
+	}
+	if index, err := h.RankService.IndexOfRank(database.DB, task.StageID, task.Rank); err == nil {
+		task.Position = index
+	}
+	h.indexTask(&task)
+
+	utils.Success(c, withWIPWarnings(gin.H{
+		"task":    task,
+		"message": "Task moved successfully",
+	}, wip))
+}
+
+// laneUserID 活动记录中负责人的取值，未分配为空
+func laneUserID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
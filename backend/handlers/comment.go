@@ -4,6 +4,7 @@ import (
 	"log"
 	"project-manager-backend/database"
 	"project-manager-backend/models"
+	"project-manager-backend/services"
 	"project-manager-backend/utils"
 	"strconv"
 
@@ -57,15 +58,48 @@ func (h *CommentHandler) GetTaskComments(c *gin.Context) {
 		return
 	}
 
+	// 分页参数（按顶层评论分页，回复跟随所属评论返回）
+	page, err := utils.ParsePageParams(c, commentSort, 0, 100)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return
+	}
+	rootQuery := database.DB.Where("task_id = ? AND parent_comment_id IS NULL", taskID)
+
+	var total *int
+	if page.WithTotal {
+		var count int
+		if err := rootQuery.Model(&models.Comment{}).Count(&count).Error; err != nil {
+			utils.InternalServerError(c, "Failed to count task comments: "+err.Error())
+			return
+		}
+		total = &count
+	}
+
 	// 获取评论列表
+	listQuery, err := page.Apply(rootQuery, commentKeyset)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return
+	}
 	var comments []models.Comment
-	if err := database.DB.Where("task_id = ? AND parent_comment_id IS NULL", taskID).
-		Order("created_at ASC").
-		Find(&comments).Error; err != nil {
+	if err := listQuery.Find(&comments).Error; err != nil {
 		utils.InternalServerError(c, "Failed to fetch task comments: "+err.Error())
 		return
 	}
 
+	n, hasMore := page.Trim(len(comments))
+	comments = comments[:n]
+	var lastID interface{}
+	if n > 0 {
+		lastID = comments[n-1].ID
+	}
+	pageInfo, err := page.Info(database.DB, commentKeyset, hasMore, lastID, total)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
 	// 手动加载用户信息
 	for i := range comments {
 		var user models.User
@@ -124,11 +158,22 @@ func (h *CommentHandler) GetTaskComments(c *gin.Context) {
 		}
 	}
 
-	utils.Success(c, gin.H{
-		"task_id":  taskID,
-		"comments": rootComments,
-		"total":    len(rootComments),
-	})
+	utils.Success(c, withListTotal(c, gin.H{
+		"task_id":    taskID,
+		"comments":   rootComments,
+		"pagination": pageInfo,
+	}, len(rootComments), pageInfo))
+}
+
+// 评论列表按发表时间排序
+const commentSort = "created_at:asc"
+
+var commentKeyset = utils.Keyset{
+	Table: "comments",
+	Columns: []utils.SortColumn{
+		{Expr: "comments.created_at"},
+		{Expr: "comments.id"},
+	},
 }
 
 // CreateComment 创建评论
@@ -178,6 +223,9 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		utils.Forbidden(c, "Insufficient permissions to create comment")
 		return
 	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
 
 	// 如果是回复评论，检查父评论是否存在
 	if req.ParentCommentID != nil {
@@ -232,6 +280,20 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 		return
 	}
 
+	// 更新搜索索引
+	if err := services.NewSearchService().IndexComment(database.DB, &comment); err != nil {
+		log.Printf("Failed to index comment %d: %v", comment.ID, err)
+	}
+
+	// 执行自动化规则
+	services.NewAutomationService().Dispatch(database.DB, services.AutomationEvent{
+		Trigger:   models.TriggerCommentAdded,
+		ProjectID: task.ProjectID,
+		TaskID:    task.ID,
+		ActorID:   userID,
+		CommentID: comment.ID,
+	})
+
 	// 重新加载评论信息（手动加载关联数据）
 	var user models.User
 	if err := database.DB.First(&user, comment.UserID).Error; err == nil {
@@ -299,6 +361,9 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		utils.Forbidden(c, "Insufficient permissions to update comment")
 		return
 	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
 
 	var req UpdateCommentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -312,6 +377,11 @@ func (h *CommentHandler) UpdateComment(c *gin.Context) {
 		return
 	}
 
+	// 更新搜索索引
+	if err := services.NewSearchService().IndexComment(database.DB, &comment); err != nil {
+		log.Printf("Failed to index comment %d: %v", comment.ID, err)
+	}
+
 	// 重新加载评论信息
 	if err := database.DB.Preload("User").Preload("ReplyTo.User").First(&comment, commentID).Error; err != nil {
 		utils.InternalServerError(c, "Failed to reload comment data")
@@ -356,6 +426,9 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		utils.Forbidden(c, "Insufficient permissions to delete comment")
 		return
 	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
 
 	// 开始事务
 	tx := database.DB.Begin()
@@ -365,6 +438,11 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 		}
 	}()
 
+	// 删除评论及其所有回复的搜索索引
+	if err := services.NewSearchService().RemoveComment(tx, uint(commentID)); err != nil {
+		log.Printf("Failed to remove comment %d from search index: %v", commentID, err)
+	}
+
 	// 删除评论及其所有回复
 	if err := tx.Where("id = ? OR parent_comment_id = ?", commentID, commentID).Delete(&models.Comment{}).Error; err != nil {
 		tx.Rollback()
@@ -415,6 +493,9 @@ func (h *CommentHandler) DeleteTaskComment(c *gin.Context) {
 		utils.Forbidden(c, "Insufficient permissions to delete comment")
 		return
 	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
 
 	// 开始事务
 	tx := database.DB.Begin()
@@ -424,6 +505,11 @@ func (h *CommentHandler) DeleteTaskComment(c *gin.Context) {
 		}
 	}()
 
+	// 删除评论及其所有回复的搜索索引
+	if err := services.NewSearchService().RemoveComment(tx, uint(commentID)); err != nil {
+		log.Printf("Failed to remove comment %d from search index: %v", commentID, err)
+	}
+
 	// 删除评论及其所有回复
 	if err := tx.Where("id = ? OR parent_comment_id = ?", commentID, commentID).Delete(&models.Comment{}).Error; err != nil {
 		tx.Rollback()
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LabelHandler 任务标签处理器
+type LabelHandler struct {
+	LabelService    *services.LabelService
+	ActivityService *services.TaskActivityService
+}
+
+// NewLabelHandler 创建任务标签处理器
+func NewLabelHandler() *LabelHandler {
+	return &LabelHandler{
+		LabelService:    services.NewLabelService(),
+		ActivityService: services.NewTaskActivityService(),
+	}
+}
+
+// CreateLabelRequest 创建标签请求
+type CreateLabelRequest struct {
+	Name  string `json:"name" binding:"required,max=50"`
+	Color string `json:"color"`
+}
+
+// TaskLabelRequest 给任务添加标签请求
+type TaskLabelRequest struct {
+	LabelID uint `json:"label_id" binding:"required"`
+}
+
+// GetProjectLabels 获取项目的标签
+func (h *LabelHandler) GetProjectLabels(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	labels, err := h.LabelService.ProjectLabels(database.DB, uint(projectID))
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch labels")
+		return
+	}
+
+	utils.Success(c, gin.H{"labels": labels})
+}
+
+// CreateLabel 创建项目标签，同一项目内名称不能重复
+func (h *LabelHandler) CreateLabel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CanManageProject(userID, uint(projectID)) {
+		utils.Forbidden(c, "Insufficient permissions to create label")
+		return
+	}
+	if !utils.CheckProjectWritable(c, uint(projectID)) {
+		return
+	}
+
+	var req CreateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.BadRequest(c, "Label name is required")
+		return
+	}
+
+	var count int
+	database.DB.Model(&models.Label{}).Where("project_id = ? AND name = ?", projectID, req.Name).Count(&count)
+	if count > 0 {
+		utils.Error(c, http.StatusConflict, "Label already exists")
+		return
+	}
+
+	label := models.Label{
+		ProjectID: uint(projectID),
+		Name:      req.Name,
+		Color:     req.Color,
+	}
+	if label.Color == "" {
+		label.Color = "#909399"
+	}
+	if err := database.DB.Create(&label).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create label")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"label":   label,
+		"message": "Label created successfully",
+	})
+}
+
+// DeleteLabel 删除项目标签，同时从所有任务上移除
+func (h *LabelHandler) DeleteLabel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+	labelID, err := strconv.ParseUint(c.Param("labelId"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid label ID")
+		return
+	}
+
+	if !utils.CanManageProject(userID, uint(projectID)) {
+		utils.Forbidden(c, "Insufficient permissions to delete label")
+		return
+	}
+	if !utils.CheckProjectWritable(c, uint(projectID)) {
+		return
+	}
+
+	var label models.Label
+	if err := database.DB.Where("id = ? AND project_id = ?", labelID, projectID).First(&label).Error; err != nil {
+		utils.NotFound(c, "Label not found")
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := h.LabelService.DeleteLabel(tx, label.ID); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to delete label")
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Label deleted successfully"})
+}
+
+// GetTaskLabels 获取任务的标签
+func (h *LabelHandler) GetTaskLabels(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	task, ok := h.findTask(c, userID)
+	if !ok {
+		return
+	}
+
+	labels, err := h.LabelService.TaskLabels(database.DB, task.ID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch task labels")
+		return
+	}
+
+	utils.Success(c, gin.H{"labels": labels})
+}
+
+// AddTaskLabel 给任务添加标签
+func (h *LabelHandler) AddTaskLabel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	task, ok := h.findTask(c, userID)
+	if !ok {
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	var req TaskLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	label, added, err := h.LabelService.AddTaskLabel(database.DB, task, req.LabelID)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if added {
+		if err := h.ActivityService.LogTaskLabeled(task.ID, userID, task.ProjectID, label.Name, true, c); err != nil {
+			log.Printf("Failed to log task label activity: %v", err)
+		}
+	}
+
+	h.respondTaskLabels(c, task.ID, "Label added successfully")
+}
+
+// RemoveTaskLabel 移除任务的标签
+func (h *LabelHandler) RemoveTaskLabel(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	task, ok := h.findTask(c, userID)
+	if !ok {
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+	labelID, err := strconv.ParseUint(c.Param("labelId"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid label ID")
+		return
+	}
+
+	label, removed, err := h.LabelService.RemoveTaskLabel(database.DB, task, uint(labelID))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	if removed {
+		if err := h.ActivityService.LogTaskLabeled(task.ID, userID, task.ProjectID, label.Name, false, c); err != nil {
+			log.Printf("Failed to log task label activity: %v", err)
+		}
+	}
+
+	h.respondTaskLabels(c, task.ID, "Label removed successfully")
+}
+
+// findTask 查找路由中的任务并检查项目权限
+func (h *LabelHandler) findTask(c *gin.Context, userID uint) (*models.Task, bool) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return nil, false
+	}
+
+	var task models.Task
+	if err := database.DB.First(&task, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return nil, false
+	}
+
+	if !utils.CanManageTasks(userID, task.ProjectID) {
+		utils.Forbidden(c, "Access denied to this project")
+		return nil, false
+	}
+	return &task, true
+}
+
+func (h *LabelHandler) respondTaskLabels(c *gin.Context, taskID uint, message string) {
+	labels, err := h.LabelService.TaskLabels(database.DB, taskID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch task labels")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"labels":  labels,
+		"message": message,
+	})
+}
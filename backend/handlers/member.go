@@ -83,10 +83,13 @@ func (h *MemberHandler) AddProjectMember(c *gin.Context) {
 
 	// 检查项目是否存在
 	var project models.Project
-	if err := database.DB.Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).First(&project).Error; err != nil {
+	if err := database.DB.First(&project, projectID).Error; err != nil {
 		utils.NotFound(c, "Project not found")
 		return
 	}
+	if !utils.CheckProjectWritable(c, project.ID) {
+		return
+	}
 
 	// 检查用户是否存在
 	var user models.User
@@ -161,10 +164,13 @@ func (h *MemberHandler) UpdateMemberRole(c *gin.Context) {
 
 	// 检查项目是否存在
 	var project models.Project
-	if err := database.DB.Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).First(&project).Error; err != nil {
+	if err := database.DB.First(&project, projectID).Error; err != nil {
 		utils.NotFound(c, "Project not found")
 		return
 	}
+	if !utils.CheckProjectWritable(c, project.ID) {
+		return
+	}
 
 	// 检查要更新的成员是否存在
 	var member models.ProjectMember
@@ -220,10 +226,13 @@ func (h *MemberHandler) RemoveProjectMember(c *gin.Context) {
 
 	// 检查项目是否存在
 	var project models.Project
-	if err := database.DB.Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).First(&project).Error; err != nil {
+	if err := database.DB.First(&project, projectID).Error; err != nil {
 		utils.NotFound(c, "Project not found")
 		return
 	}
+	if !utils.CheckProjectWritable(c, project.ID) {
+		return
+	}
 
 	// 检查要移除的成员是否存在
 	var member models.ProjectMember
@@ -273,10 +282,13 @@ func (h *MemberHandler) BatchAddMembers(c *gin.Context) {
 
 	// 检查项目是否存在
 	var project models.Project
-	if err := database.DB.Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).First(&project).Error; err != nil {
+	if err := database.DB.First(&project, projectID).Error; err != nil {
 		utils.NotFound(c, "Project not found")
 		return
 	}
+	if !utils.CheckProjectWritable(c, project.ID) {
+		return
+	}
 
 	// 开始事务
 	tx := database.DB.Begin()
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationHandler 通知处理器
+type NotificationHandler struct {
+	ReminderService *services.ReminderService // 截止日期提醒服务
+}
+
+// NewNotificationHandler 创建通知处理器
+func NewNotificationHandler(reminderService *services.ReminderService) *NotificationHandler {
+	return &NotificationHandler{ReminderService: reminderService}
+}
+
+// GetNotifications 获取当前用户的通知
+// 查询参数 unread=true 只返回未读通知，type 按通知类型过滤，limit/offset 分页
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // 限制最大数量
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	query := database.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("is_read = ?", false)
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	var total int
+	if err := query.Count(&total).Error; err != nil {
+		utils.InternalServerError(c, "Failed to count notifications")
+		return
+	}
+
+	var notifications []models.Notification
+	if err := query.Preload("Task").Order("created_at DESC, id DESC").Limit(limit).Offset(offset).
+		Find(&notifications).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch notifications")
+		return
+	}
+
+	var unread int
+	database.DB.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&unread)
+
+	utils.Success(c, gin.H{
+		"notifications": notifications,
+		"total":         total,
+		"unread":        unread,
+		"limit":         limit,
+		"offset":        offset,
+	})
+}
+
+// MarkNotificationRead 将通知标记为已读
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	notificationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid notification ID")
+		return
+	}
+
+	var notification models.Notification
+	if err := database.DB.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		utils.NotFound(c, "Notification not found")
+		return
+	}
+
+	if !notification.IsRead {
+		now := time.Now()
+		if err := database.DB.Model(&notification).Updates(map[string]interface{}{"is_read": true, "read_at": now}).Error; err != nil {
+			utils.InternalServerError(c, "Failed to update notification")
+			return
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"notification": notification,
+		"message":      "Notification marked as read",
+	})
+}
+
+// MarkAllNotificationsRead 将当前用户的所有通知标记为已读
+func (h *NotificationHandler) MarkAllNotificationsRead(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	result := database.DB.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).
+		Updates(map[string]interface{}{"is_read": true, "read_at": time.Now()})
+	if result.Error != nil {
+		utils.InternalServerError(c, "Failed to update notifications")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"updated": result.RowsAffected,
+		"message": "All notifications marked as read",
+	})
+}
+
+// RunReminders 立即执行一次截止日期提醒扫描（系统管理员功能）
+func (h *NotificationHandler) RunReminders(c *gin.Context) {
+	userRole := c.MustGet("user_role").(string)
+	if userRole != "admin" {
+		utils.Forbidden(c, "Admin access required")
+		return
+	}
+
+	result, err := h.ReminderService.Run(database.DB, time.Now())
+	if err != nil {
+		utils.InternalServerError(c, "Failed to run reminders: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"result":  result,
+		"message": "Reminder scan completed",
+	})
+}
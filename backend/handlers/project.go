@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"log"
 	"project-manager-backend/database"
 	"project-manager-backend/models"
+	"project-manager-backend/services"
 	"project-manager-backend/utils"
 	"strconv"
 	"time"
@@ -17,7 +19,10 @@ type ProjectHandler struct{}
 type CreateProjectRequest struct {
 	Name        string                 `json:"name" binding:"required"`
 	Description string                 `json:"description"`
+	KeyPrefix   string                 `json:"key_prefix"`        // 任务编号前缀（可选，默认根据项目名称生成）
+	Timezone    string                 `json:"timezone"`          // 项目时区（可选，IANA 名称）
 	Members     []ProjectMemberRequest `json:"members,omitempty"` // 协作人员列表（可选）
+	TemplateID  *uint                  `json:"template_id"`       // 项目模板（可选），创建阶段、标签、流转规则、自动化规则和初始任务
 }
 
 // ProjectMemberRequest 项目成员请求
@@ -32,7 +37,9 @@ type UpdateProjectRequest struct {
 	Description string `json:"description"`
 	Status      string `json:"status"`
 	EndDate     string `json:"endDate"`
-	MemberIds   []uint `json:"memberIds"` // 项目成员ID列表
+	KeyPrefix   string `json:"key_prefix"` // 任务编号前缀，修改后旧编号仍可访问
+	Timezone    string `json:"timezone"`   // 项目时区（IANA 名称）
+	MemberIds   []uint `json:"memberIds"`  // 项目成员ID列表
 }
 
 // CreateProject 创建项目
@@ -44,6 +51,21 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		utils.BadRequest(c, "Invalid request data: "+err.Error())
 		return
 	}
+	if !utils.IsValidTimezone(req.Timezone) {
+		utils.BadRequest(c, "Invalid timezone: "+req.Timezone)
+		return
+	}
+
+	// 查找项目模板
+	templateService := services.NewProjectTemplateService()
+	var template *models.ProjectTemplate
+	if req.TemplateID != nil {
+		var err error
+		if template, err = templateService.FindVisible(database.DB, *req.TemplateID, userID); err != nil {
+			utils.NotFound(c, "Project template not found")
+			return
+		}
+	}
 
 	// 开始事务
 	tx := database.DB.Begin()
@@ -53,6 +75,17 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		}
 	}()
 
+	// 确定任务编号前缀
+	keyService := services.NewTaskKeyService()
+	keyPrefix := services.NormalizeKeyPrefix(req.KeyPrefix)
+	if keyPrefix == "" {
+		keyPrefix = keyService.GenerateKeyPrefix(tx, req.Name, 0)
+	} else if err := keyService.ValidateKeyPrefix(tx, keyPrefix, 0); err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
 	// 创建项目
 	now := time.Now()
 	project := models.Project{
@@ -61,6 +94,8 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		OwnerID:     userID,
 		Status:      models.ProjectStatusActive,
 		StartDate:   &now,
+		Timezone:    req.Timezone,
+		KeyPrefix:   keyPrefix,
 		CreatedBy:   userID,
 	}
 
@@ -90,6 +125,13 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		return
 	}
 
+	// 创建默认的任务状态和优先级定义
+	if err := services.NewWorkflowService().EnsureWorkflow(tx, project.ID); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to create project workflow: "+err.Error())
+		return
+	}
+
 	// 添加协作人员
 	for _, memberReq := range req.Members {
 		// 跳过无效的用户ID（0 或未设置）
@@ -133,12 +175,33 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		}
 	}
 
+	// 应用项目模板（在添加成员之后，以便模板中的自动化规则可以指派给成员）
+	var templateResult *services.TemplateApplyResult
+	if template != nil {
+		var err error
+		if templateResult, err = templateService.ApplyTemplate(tx, &project, &template.Definition, userID); err != nil {
+			tx.Rollback()
+			utils.BadRequest(c, "Failed to apply project template: "+err.Error())
+			return
+		}
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		utils.InternalServerError(c, "Failed to commit transaction: "+err.Error())
 		return
 	}
 
+	// 模板创建的初始任务加入搜索索引
+	if templateResult != nil {
+		searchService := services.NewSearchService()
+		for i := range templateResult.Tasks {
+			if err := searchService.IndexTask(database.DB, &templateResult.Tasks[i]); err != nil {
+				log.Printf("Failed to index task %d: %v", templateResult.Tasks[i].ID, err)
+			}
+		}
+	}
+
 	// 加载项目所有者信息
 	var owner models.User
 	if err := database.DB.First(&owner, userID).Error; err == nil {
@@ -154,23 +217,76 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		}
 	}
 
-	utils.Success(c, gin.H{
+	response := gin.H{
 		"project": project,
 		"message": "Project created successfully",
-	})
+	}
+	if templateResult != nil {
+		response["template"] = templateResult
+	}
+	utils.Success(c, response)
 }
 
 // GetProjects 获取项目列表
 // 单机版：所有用户都可以看到所有活跃项目
 func (h *ProjectHandler) GetProjects(c *gin.Context) {
+	h.listProjects(c, models.ProjectStatusActive)
+}
+
+// GetArchivedProjects 获取已归档的项目列表，分页和排序参数与项目列表相同
+func (h *ProjectHandler) GetArchivedProjects(c *gin.Context) {
+	h.listProjects(c, models.ProjectStatusArchived)
+}
+
+// listProjects 按状态分页获取项目列表，并加载所有者和成员信息
+func (h *ProjectHandler) listProjects(c *gin.Context, status models.ProjectStatus) {
+	columns, sort, err := utils.ParseSort(c.Query("sort"), "created_at", projectSortFields, "projects.id")
+	if err != nil {
+		utils.BadRequest(c, "Invalid sort: "+err.Error())
+		return
+	}
+	page, err := utils.ParsePageParams(c, sort, 0, 200)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return
+	}
+
 	var projects []models.Project
-	query := database.DB.Where("status = ?", models.ProjectStatusActive)
+	query := database.DB.Where("status = ?", status)
 
-	if err := query.Find(&projects).Error; err != nil {
+	var total *int
+	if page.WithTotal {
+		var count int
+		if err := query.Model(&models.Project{}).Count(&count).Error; err != nil {
+			utils.InternalServerError(c, "Failed to count projects: "+err.Error())
+			return
+		}
+		total = &count
+	}
+
+	keyset := utils.Keyset{Table: "projects", Columns: columns}
+	listQuery, err := page.Apply(query, keyset)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return
+	}
+	if err := listQuery.Find(&projects).Error; err != nil {
 		utils.InternalServerError(c, "Failed to fetch projects: "+err.Error())
 		return
 	}
 
+	n, hasMore := page.Trim(len(projects))
+	projects = projects[:n]
+	var lastID interface{}
+	if n > 0 {
+		lastID = projects[n-1].ID
+	}
+	pageInfo, err := page.Info(database.DB, keyset, hasMore, lastID, total)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
 	// 为每个项目加载 Owner 和 Members 信息
 	for i := range projects {
 		// 加载 Owner
@@ -191,10 +307,17 @@ func (h *ProjectHandler) GetProjects(c *gin.Context) {
 		}
 	}
 
-	utils.Success(c, gin.H{
-		"projects": projects,
-		"total":    len(projects),
-	})
+	utils.Success(c, withListTotal(c, gin.H{
+		"projects":   projects,
+		"pagination": pageInfo,
+	}, len(projects), pageInfo))
+}
+
+// 项目列表可排序字段
+var projectSortFields = map[string]string{
+	"name":       "projects.name",
+	"created_at": "projects.created_at",
+	"updated_at": "projects.updated_at",
 }
 
 // GetProject 获取项目详情
@@ -209,8 +332,7 @@ func (h *ProjectHandler) GetProject(c *gin.Context) {
 	// 获取项目详情
 	var project models.Project
 	if err := database.DB.Preload("Owner").Preload("Members.User").Preload("Stages").
-		Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).
-		First(&project).Error; err != nil {
+		First(&project, projectID).Error; err != nil {
 		utils.NotFound(c, "Project not found")
 		return
 	}
@@ -241,7 +363,7 @@ func (h *ProjectHandler) GetProjectCollaborators(c *gin.Context) {
 
 	// 检查项目是否存在
 	var project models.Project
-	if err := database.DB.Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).First(&project).Error; err != nil {
+	if err := database.DB.First(&project, projectID).Error; err != nil {
 		utils.NotFound(c, "Project not found")
 		return
 	}
@@ -311,10 +433,19 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 
 	// 查找项目
 	var project models.Project
-	if err := database.DB.Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).First(&project).Error; err != nil {
+	if err := database.DB.First(&project, projectID).Error; err != nil {
 		utils.NotFound(c, "Project not found")
 		return
 	}
+	if !utils.CheckProjectWritable(c, project.ID) {
+		return
+	}
+
+	// 归档需要通过归档接口，以便记录归档时间
+	if req.Status != "" && models.ProjectStatus(req.Status) != models.ProjectStatusActive {
+		utils.BadRequest(c, "Invalid status: use the archive endpoint to archive a project")
+		return
+	}
 
 	// 开始事务，将所有更新操作放在同一个事务中
 	tx := database.DB.Begin()
@@ -346,6 +477,14 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 			return
 		}
 	}
+	if req.Timezone != "" {
+		if !utils.IsValidTimezone(req.Timezone) {
+			tx.Rollback()
+			utils.BadRequest(c, "Invalid timezone: "+req.Timezone)
+			return
+		}
+		updates["timezone"] = req.Timezone
+	}
 
 	// 执行项目信息更新
 	if len(updates) > 0 {
@@ -356,6 +495,19 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		}
 	}
 
+	// 修改任务编号前缀（任务重新编号，旧编号保留为别名）
+	if req.KeyPrefix != "" {
+		if err := services.NewTaskKeyService().ChangeKeyPrefix(tx, &project, req.KeyPrefix); err != nil {
+			tx.Rollback()
+			if err == services.ErrInvalidKeyPrefix || err == services.ErrKeyPrefixTaken {
+				utils.BadRequest(c, err.Error())
+			} else {
+				utils.InternalServerError(c, "Failed to update key prefix: "+err.Error())
+			}
+			return
+		}
+	}
+
 	// 处理项目成员更新
 	if req.MemberIds != nil {
 		// 验证成员是否是当前用户的协作人员
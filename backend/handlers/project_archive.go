@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveProject 归档项目
+// 归档后项目不在项目列表中显示，看板、任务和统计可以只读访问，修改任务、阶段、评论和成员时返回 409
+func (h *ProjectHandler) ArchiveProject(c *gin.Context) {
+	h.setProjectArchived(c, true)
+}
+
+// UnarchiveProject 恢复已归档的项目
+func (h *ProjectHandler) UnarchiveProject(c *gin.Context) {
+	h.setProjectArchived(c, false)
+}
+
+// setProjectArchived 修改项目的归档状态
+func (h *ProjectHandler) setProjectArchived(c *gin.Context, archived bool) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	if !utils.CanManageProject(userID, project.ID) {
+		utils.Forbidden(c, "Insufficient permissions to archive project")
+		return
+	}
+
+	isArchived := project.Status == models.ProjectStatusArchived
+	if archived && isArchived {
+		utils.BadRequest(c, "Project is already archived")
+		return
+	}
+	if !archived && !isArchived {
+		utils.BadRequest(c, "Project is not archived")
+		return
+	}
+
+	updates := map[string]interface{}{
+		"status":      models.ProjectStatusActive,
+		"archived_at": nil,
+	}
+	message := "Project restored successfully"
+	if archived {
+		updates["status"] = models.ProjectStatusArchived
+		updates["archived_at"] = time.Now()
+		message = "Project archived successfully"
+	}
+	if err := database.DB.Model(&project).Updates(updates).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update project status")
+		return
+	}
+
+	if err := database.DB.Preload("Owner").First(&project, project.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload project data")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project": project,
+		"message": message,
+	})
+}
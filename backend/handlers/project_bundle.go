@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bundleFileNamePattern 导出文件名中不允许的字符
+var bundleFileNamePattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// projectImportMaxSize 导入的项目包和 Trello 导出 JSON 的请求体大小上限
+const projectImportMaxSize = 20 << 20
+
+// bindImportJSON 限制请求体大小后解析导入的 JSON，失败时直接返回 400
+func bindImportJSON(c *gin.Context, obj interface{}, invalidMessage string) bool {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, projectImportMaxSize)
+	if err := c.ShouldBindJSON(obj); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			utils.BadRequest(c, fmt.Sprintf("Request body is larger than %d MB", projectImportMaxSize>>20))
+		} else {
+			utils.BadRequest(c, invalidMessage+err.Error())
+		}
+		return false
+	}
+	return true
+}
+
+// ExportProject 把项目导出为 JSON 导出包（阶段、工作流、标签、规则、任务、评论、活动和成员）
+// 导出包中的用户用邮箱表示；评论的附件只导出媒体引用，不包含文件内容
+func (h *ProjectHandler) ExportProject(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	var project models.Project
+	// 已归档的项目也可以导出
+	if err := database.DB.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, project.ID) && !utils.CheckProjectOwner(userID, project.ID) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	bundle, err := services.NewProjectBundleService().ExportProject(database.DB, project.ID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to export project: "+err.Error())
+		return
+	}
+
+	name := strings.Trim(bundleFileNamePattern.ReplaceAllString(project.Name, "-"), "-")
+	if name == "" {
+		name = "project-" + strconv.FormatUint(uint64(project.ID), 10)
+	}
+	filename := fmt.Sprintf("%s-%s.json", name, time.Now().Format("20060102"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportProject 从导出包创建新项目，导入人成为项目所有者
+// 可选查询参数：name 覆盖项目名称，key_prefix 覆盖任务编号前缀，dry_run=true 只校验并返回导入报告
+// 用户按邮箱匹配，找不到的用户在报告的 unresolved_users 中列出
+func (h *ProjectHandler) ImportProject(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var bundle services.ProjectBundle
+	if !bindImportJSON(c, &bundle, "Invalid bundle: ") {
+		return
+	}
+
+	bundleService := services.NewProjectBundleService()
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		bundle.Project.Name = name
+	}
+	if err := bundleService.ValidateBundle(&bundle); err != nil {
+		utils.BadRequest(c, "Invalid bundle: "+err.Error())
+		return
+	}
+
+	users, unresolved, err := bundleService.ResolveUsers(database.DB, &bundle)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to match bundle users")
+		return
+	}
+
+	if c.Query("dry_run") == "true" {
+		utils.Success(c, gin.H{
+			"report": bundleService.DryRunReport(&bundle, unresolved),
+		})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 编号前缀：优先使用参数，其次沿用导出包中的前缀（未被占用时），否则根据名称生成
+	keyService := services.NewTaskKeyService()
+	keyPrefix := services.NormalizeKeyPrefix(c.Query("key_prefix"))
+	if keyPrefix != "" {
+		if err := keyService.ValidateKeyPrefix(tx, keyPrefix, 0); err != nil {
+			tx.Rollback()
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	} else {
+		keyPrefix = services.NormalizeKeyPrefix(bundle.Project.KeyPrefix)
+		if keyPrefix == "" || keyService.ValidateKeyPrefix(tx, keyPrefix, 0) != nil {
+			keyPrefix = keyService.GenerateKeyPrefix(tx, bundle.Project.Name, 0)
+		}
+	}
+
+	startDate := time.Now()
+	if bundle.Project.StartDate != nil {
+		startDate = *bundle.Project.StartDate
+	}
+	project := models.Project{
+		Name:        bundle.Project.Name,
+		Description: bundle.Project.Description,
+		OwnerID:     userID,
+		Status:      models.ProjectStatusActive,
+		StartDate:   &startDate,
+		EndDate:     bundle.Project.EndDate,
+		Timezone:    bundle.Project.Timezone,
+		KeyPrefix:   keyPrefix,
+		CreatedBy:   userID,
+	}
+
+	if err := tx.Create(&project).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to create project: "+err.Error())
+		return
+	}
+
+	if err := tx.Create(&models.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    userID,
+		Role:      models.ProjectMemberRoleOwner,
+	}).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to add project owner as member")
+		return
+	}
+
+	result, err := bundleService.ImportProject(tx, &bundle, &project, users, unresolved, userID)
+	if err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, "Failed to import project: "+err.Error())
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	// 导入的任务和评论加入搜索索引
+	searchService := services.NewSearchService()
+	for i := range result.Tasks {
+		if err := searchService.IndexTask(database.DB, &result.Tasks[i]); err != nil {
+			log.Printf("Failed to index task %d: %v", result.Tasks[i].ID, err)
+		}
+		if err := searchService.IndexTaskComments(database.DB, &result.Tasks[i]); err != nil {
+			log.Printf("Failed to index comments of task %d: %v", result.Tasks[i].ID, err)
+		}
+	}
+
+	if err := database.DB.Preload("Owner").First(&project, project.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload project data")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project": project,
+		"report":  result.Report,
+		"message": "Project imported successfully",
+	})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"log"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CloneProjectRequest 复制项目请求
+// 阶段（含全部设置）、阶段流转规则、自动化规则和任务状态/优先级定义总是复制
+type CloneProjectRequest struct {
+	Name           string  `json:"name" binding:"required"`
+	Description    *string `json:"description"`      // 默认为原项目的描述
+	KeyPrefix      string  `json:"key_prefix"`       // 任务编号前缀（可选，默认根据项目名称生成）
+	IncludeTasks   bool    `json:"include_tasks"`    // 是否复制未完成且未归档的任务
+	IncludeLabels  bool    `json:"include_labels"`   // 是否复制标签
+	IncludeMembers bool    `json:"include_members"`  // 是否复制成员和角色
+	DateOffsetDays int     `json:"date_offset_days"` // 任务和项目日期平移的天数，可以为负数
+}
+
+// CloneProject 深度复制项目，在一个事务中完成，返回复制结果和新旧ID的对应关系
+func (h *ProjectHandler) CloneProject(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	var req CloneProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	var source models.Project
+	// 已归档的项目也可以作为复制的来源
+	if err := database.DB.First(&source, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, source.ID) && !utils.CheckProjectOwner(userID, source.ID) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	description := source.Description
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	keyService := services.NewTaskKeyService()
+	keyPrefix := services.NormalizeKeyPrefix(req.KeyPrefix)
+	if keyPrefix == "" {
+		keyPrefix = keyService.GenerateKeyPrefix(tx, req.Name, 0)
+	} else if err := keyService.ValidateKeyPrefix(tx, keyPrefix, 0); err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	startDate := time.Now()
+	if source.StartDate != nil {
+		startDate = source.StartDate.AddDate(0, 0, req.DateOffsetDays)
+	}
+	project := models.Project{
+		Name:        req.Name,
+		Description: description,
+		OwnerID:     userID,
+		Status:      models.ProjectStatusActive,
+		StartDate:   &startDate,
+		Timezone:    source.Timezone,
+		KeyPrefix:   keyPrefix,
+		CreatedBy:   userID,
+	}
+	if source.EndDate != nil {
+		endDate := source.EndDate.AddDate(0, 0, req.DateOffsetDays)
+		project.EndDate = &endDate
+	}
+
+	if err := tx.Create(&project).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to create project: "+err.Error())
+		return
+	}
+
+	if err := tx.Create(&models.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    userID,
+		Role:      models.ProjectMemberRoleOwner,
+	}).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to add project owner as member")
+		return
+	}
+
+	report, tasks, err := services.NewProjectCloneService().CloneProject(tx, &source, &project, services.ProjectCloneOptions{
+		IncludeTasks:   req.IncludeTasks,
+		IncludeLabels:  req.IncludeLabels,
+		IncludeMembers: req.IncludeMembers,
+		DateOffsetDays: req.DateOffsetDays,
+	}, userID)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to clone project: "+err.Error())
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	// 复制出的任务加入搜索索引
+	searchService := services.NewSearchService()
+	for i := range tasks {
+		if err := searchService.IndexTask(database.DB, &tasks[i]); err != nil {
+			log.Printf("Failed to index task %d: %v", tasks[i].ID, err)
+		}
+	}
+
+	if err := database.DB.Preload("Owner").First(&project, project.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload project data")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project": project,
+		"report":  report,
+		"message": "Project cloned successfully",
+	})
+}
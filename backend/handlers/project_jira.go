@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportJiraCSV 从 Jira 导出的 CSV 创建项目，导入人成为项目所有者
+// Jira 状态→阶段，问题→任务；阶段和任务的状态按 Jira 状态分类（To Do / In Progress / Done）取工作流中对应分类的状态；优先级、负责人、报告人、创建时间、截止日期、原始估算和已用工时、标签和评论一并导入。
+// Jira 编号中的序号尽量保留，原编号与新编号不同时保存为任务编号别名；父问题以新编号写入任务描述。
+// CSV 只能包含一个 Jira 项目，可以作为 multipart 的 file 字段上传，也可以直接作为请求体；参数可以放在表单或查询参数中：
+//   - name：项目名称（默认为 Project name 列）
+//   - key_prefix：任务编号前缀（默认沿用 Jira 的前缀，已被占用时根据项目名称生成）
+//   - status_map：JSON 对象，Jira 状态到阶段名称的映射，未映射的状态创建同名阶段
+//   - priority_map：JSON 对象，Jira 优先级到 P0–P3 的映射，覆盖默认映射
+//   - user_map：JSON 对象，Jira 用户到本地邮箱或用户名的映射，未映射的按邮箱或用户名匹配
+//   - dry_run=true：只返回导入预览，不创建项目
+//   - skip_invalid=true：跳过有错误的行；否则有任意一行错误时不导入
+func (h *ProjectHandler) ImportJiraCSV(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	if err := limitTaskCSVUpload(c); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	opts := services.JiraImportOptions{Location: utils.TaskTimezone(userID, 0)}
+	for key, target := range map[string]*map[string]string{
+		"status_map":   &opts.StatusMap,
+		"priority_map": &opts.PriorityMap,
+		"user_map":     &opts.UserMap,
+	} {
+		if raw := csvImportParam(c, key); raw != "" {
+			if err := json.Unmarshal([]byte(raw), target); err != nil {
+				utils.BadRequest(c, "Invalid "+key+": "+err.Error())
+				return
+			}
+		}
+	}
+
+	content, err := readTaskCSVUpload(c)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	header, rows, err := services.ReadTaskCSV(bytes.NewReader(content))
+	if err != nil {
+		utils.BadRequest(c, "Invalid CSV: "+err.Error())
+		return
+	}
+
+	jiraService := services.NewJiraImportService()
+	issues, jiraPrefix, err := jiraService.ParseJiraCSV(header, rows, opts.Location)
+	if err != nil {
+		utils.BadRequest(c, "Invalid Jira CSV: "+err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(csvImportParam(c, "name"))
+	for _, issue := range issues {
+		if name != "" {
+			break
+		}
+		name = issue.Project
+	}
+	if name == "" {
+		utils.BadRequest(c, "Project name is required (name parameter or Project name column)")
+		return
+	}
+
+	summary, err := jiraService.PlanImport(database.DB, issues, jiraPrefix, opts)
+	if err != nil {
+		utils.BadRequest(c, "Invalid Jira CSV: "+err.Error())
+		return
+	}
+	summary.Project = name
+
+	if csvImportParam(c, "dry_run") == "true" {
+		summary.DryRun = true
+		utils.Success(c, gin.H{"summary": summary})
+		return
+	}
+	if summary.Invalid > 0 && csvImportParam(c, "skip_invalid") != "true" {
+		utils.ErrorWithData(c, http.StatusBadRequest, fmt.Sprintf("CSV contains %d invalid rows, fix them or import with skip_invalid=true", summary.Invalid), gin.H{
+			"summary": summary,
+		})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 编号前缀：指定的前缀，否则沿用 Jira 前缀，已被占用时根据项目名称生成
+	keyService := services.NewTaskKeyService()
+	keyPrefix := services.NormalizeKeyPrefix(csvImportParam(c, "key_prefix"))
+	if keyPrefix != "" {
+		if err := keyService.ValidateKeyPrefix(tx, keyPrefix, 0); err != nil {
+			tx.Rollback()
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	} else if jiraPrefix != "" && keyService.ValidateKeyPrefix(tx, jiraPrefix, 0) == nil {
+		keyPrefix = jiraPrefix
+	} else {
+		keyPrefix = keyService.GenerateKeyPrefix(tx, name, 0)
+	}
+
+	startDate := time.Now()
+	project := models.Project{
+		Name:      name,
+		OwnerID:   userID,
+		Status:    models.ProjectStatusActive,
+		StartDate: &startDate,
+		KeyPrefix: keyPrefix,
+		CreatedBy: userID,
+	}
+
+	if err := tx.Create(&project).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to create project: "+err.Error())
+		return
+	}
+
+	if err := tx.Create(&models.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    userID,
+		Role:      models.ProjectMemberRoleOwner,
+	}).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to add project owner as member")
+		return
+	}
+
+	tasks, err := jiraService.ImportIssues(tx, summary, &project, userID)
+	if err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, "Failed to import Jira issues: "+err.Error())
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	// 导入的任务和评论加入搜索索引
+	searchService := services.NewSearchService()
+	for i := range tasks {
+		if err := searchService.IndexTask(database.DB, &tasks[i]); err != nil {
+			log.Printf("Failed to index task %d: %v", tasks[i].ID, err)
+		}
+		if err := searchService.IndexTaskComments(database.DB, &tasks[i]); err != nil {
+			log.Printf("Failed to index comments of task %d: %v", tasks[i].ID, err)
+		}
+	}
+
+	if err := database.DB.Preload("Owner").First(&project, project.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload project data")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project": project,
+		"summary": summary,
+		"message": fmt.Sprintf("%d Jira issues imported successfully", summary.Created),
+	})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectTemplateHandler 项目模板处理器
+type ProjectTemplateHandler struct {
+	TemplateService *services.ProjectTemplateService
+}
+
+// NewProjectTemplateHandler 创建项目模板处理器
+func NewProjectTemplateHandler() *ProjectTemplateHandler {
+	return &ProjectTemplateHandler{
+		TemplateService: services.NewProjectTemplateService(),
+	}
+}
+
+// CreateTemplateRequest 创建项目模板请求
+type CreateTemplateRequest struct {
+	Name        string                    `json:"name" binding:"required,max=100"`
+	Description string                    `json:"description"`
+	Shared      bool                      `json:"shared"` // 是否允许其他用户使用
+	Definition  models.TemplateDefinition `json:"definition"`
+}
+
+// SaveProjectTemplateRequest 把项目保存为模板请求
+type SaveProjectTemplateRequest struct {
+	Name         string `json:"name" binding:"required,max=100"`
+	Description  string `json:"description"`
+	Shared       bool   `json:"shared"`
+	IncludeTasks bool   `json:"include_tasks"` // 是否把未归档的任务保存为初始任务
+}
+
+// GetTemplates 获取当前用户可以使用的模板（内置、共享和自己保存的）
+func (h *ProjectTemplateHandler) GetTemplates(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	templates, err := h.TemplateService.VisibleTemplates(database.DB, userID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch project templates")
+		return
+	}
+
+	utils.Success(c, gin.H{"templates": templates})
+}
+
+// GetTemplate 获取模板详情
+func (h *ProjectTemplateHandler) GetTemplate(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	template, ok := h.findTemplate(c, userID)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, gin.H{"template": template})
+}
+
+// CreateTemplate 创建模板
+func (h *ProjectTemplateHandler) CreateTemplate(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req CreateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	if err := h.TemplateService.ValidateDefinition(&req.Definition); err != nil {
+		utils.BadRequest(c, "Invalid template: "+err.Error())
+		return
+	}
+
+	template := models.ProjectTemplate{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		OwnerID:     &userID,
+		Shared:      req.Shared,
+		Definition:  req.Definition,
+	}
+	if err := database.DB.Create(&template).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create project template")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"template": template,
+		"message":  "Project template created successfully",
+	})
+}
+
+// DeleteTemplate 删除自己保存的模板，内置模板不能删除
+func (h *ProjectTemplateHandler) DeleteTemplate(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	template, ok := h.findTemplate(c, userID)
+	if !ok {
+		return
+	}
+
+	if template.Builtin || template.OwnerID == nil || *template.OwnerID != userID {
+		utils.Forbidden(c, "Only the template owner can delete this template")
+		return
+	}
+
+	if err := database.DB.Delete(template).Error; err != nil {
+		utils.InternalServerError(c, "Failed to delete project template")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Project template deleted successfully"})
+}
+
+// SaveProjectAsTemplate 把项目的阶段、标签、流转规则、自动化规则（和任务）保存为模板
+func (h *ProjectTemplateHandler) SaveProjectAsTemplate(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	var req SaveProjectTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	if !utils.CanManageProject(userID, project.ID) {
+		utils.Forbidden(c, "Insufficient permissions to save project as template")
+		return
+	}
+
+	definition, err := h.TemplateService.DefinitionFromProject(database.DB, project.ID, req.IncludeTasks)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to read project settings")
+		return
+	}
+	if err := h.TemplateService.ValidateDefinition(definition); err != nil {
+		utils.BadRequest(c, "Project cannot be saved as a template: "+err.Error())
+		return
+	}
+
+	template := models.ProjectTemplate{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		OwnerID:     &userID,
+		Shared:      req.Shared,
+		Definition:  *definition,
+	}
+	if err := database.DB.Create(&template).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create project template")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"template": template,
+		"message":  "Project saved as template successfully",
+	})
+}
+
+// findTemplate 查找路由中当前用户可以使用的模板
+func (h *ProjectTemplateHandler) findTemplate(c *gin.Context, userID uint) (*models.ProjectTemplate, bool) {
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid template ID")
+		return nil, false
+	}
+
+	template, err := h.TemplateService.FindVisible(database.DB, uint(templateID), userID)
+	if err != nil {
+		utils.NotFound(c, "Project template not found")
+		return nil, false
+	}
+	return template, true
+}
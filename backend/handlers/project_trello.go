@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"log"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportTrelloRequest 导入 Trello 看板请求
+type ImportTrelloRequest struct {
+	Board     services.TrelloBoard `json:"board"`      // Trello 看板导出的 JSON
+	Name      string               `json:"name"`       // 项目名称（可选，默认为看板名称）
+	KeyPrefix string               `json:"key_prefix"` // 任务编号前缀（可选，默认根据项目名称生成）
+	UserMap   map[string]string    `json:"user_map"`   // Trello 用户名或成员ID到本地邮箱或用户名的映射（可选）
+	DryRun    bool                 `json:"dry_run"`    // 只返回导入摘要和成员匹配结果，不创建项目
+}
+
+// ImportTrelloBoard 从 Trello 看板导出的 JSON 创建项目，导入人成为项目所有者
+// 列表→阶段，卡片→任务（保留顺序和卡片序号），标签、截止日期、检查项清单（写入任务描述）和评论一并导入；
+// 没有匹配到本地用户的评论记为导入人发表并注明原作者，已归档的卡片导入为已归档的任务
+func (h *ProjectHandler) ImportTrelloBoard(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req ImportTrelloRequest
+	if !bindImportJSON(c, &req, "Invalid request data: ") {
+		return
+	}
+
+	trelloService := services.NewTrelloImportService()
+	if name := strings.TrimSpace(req.Name); name != "" {
+		req.Board.Name = name
+	}
+	if err := trelloService.ValidateBoard(&req.Board); err != nil {
+		utils.BadRequest(c, "Invalid Trello board: "+err.Error())
+		return
+	}
+
+	summary, err := trelloService.MatchMembers(database.DB, &req.Board, req.UserMap)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to match Trello members")
+		return
+	}
+
+	if req.DryRun {
+		trelloService.DryRun(&req.Board, summary)
+		utils.Success(c, gin.H{"summary": summary})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	keyService := services.NewTaskKeyService()
+	keyPrefix := services.NormalizeKeyPrefix(req.KeyPrefix)
+	if keyPrefix == "" {
+		keyPrefix = keyService.GenerateKeyPrefix(tx, req.Board.Name, 0)
+	} else if err := keyService.ValidateKeyPrefix(tx, keyPrefix, 0); err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	startDate := time.Now()
+	project := models.Project{
+		Name:        strings.TrimSpace(req.Board.Name),
+		Description: req.Board.Desc,
+		OwnerID:     userID,
+		Status:      models.ProjectStatusActive,
+		StartDate:   &startDate,
+		KeyPrefix:   keyPrefix,
+		CreatedBy:   userID,
+	}
+
+	if err := tx.Create(&project).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to create project: "+err.Error())
+		return
+	}
+
+	if err := tx.Create(&models.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    userID,
+		Role:      models.ProjectMemberRoleOwner,
+	}).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to add project owner as member")
+		return
+	}
+
+	tasks, err := trelloService.ImportBoard(tx, &req.Board, &project, summary, userID)
+	if err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, "Failed to import Trello board: "+err.Error())
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	// 导入的任务和评论加入搜索索引
+	searchService := services.NewSearchService()
+	for i := range tasks {
+		if err := searchService.IndexTask(database.DB, &tasks[i]); err != nil {
+			log.Printf("Failed to index task %d: %v", tasks[i].ID, err)
+		}
+		if err := searchService.IndexTaskComments(database.DB, &tasks[i]); err != nil {
+			log.Printf("Failed to index comments of task %d: %v", tasks[i].ID, err)
+		}
+	}
+
+	if err := database.DB.Preload("Owner").First(&project, project.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload project data")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project": project,
+		"summary": summary,
+		"message": "Trello board imported successfully",
+	})
+}
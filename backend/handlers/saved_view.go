@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SavedViewHandler 保存的视图处理器
+type SavedViewHandler struct{}
+
+// CreateSavedViewRequest 创建视图请求
+type CreateSavedViewRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	ProjectID *uint    `json:"project_id"` // 为空时为个人的跨项目视图
+	Shared    bool     `json:"shared"`     // 共享给项目成员（需要 project_id）
+	Query     string   `json:"query"`
+	Sort      string   `json:"sort"`
+	GroupBy   string   `json:"group_by"`
+	Mode      string   `json:"mode"`
+	Columns   []string `json:"columns"`
+}
+
+// UpdateSavedViewRequest 更新视图请求（只更新传入的字段）
+type UpdateSavedViewRequest struct {
+	Name    *string  `json:"name"`
+	Shared  *bool    `json:"shared"`
+	Query   *string  `json:"query"`
+	Sort    *string  `json:"sort"`
+	GroupBy *string  `json:"group_by"`
+	Mode    *string  `json:"mode"`
+	Columns []string `json:"columns"`
+}
+
+// 支持的分组字段
+var savedViewGroupFields = map[string]bool{
+	"":         true,
+	"stage":    true,
+	"assignee": true,
+	"priority": true,
+	"status":   true,
+	"project":  true,
+}
+
+// maxViewColumns 可见列数量上限
+const maxViewColumns = 50
+
+// TaskGroup 视图执行结果中的任务分组
+type TaskGroup struct {
+	Key     string `json:"key"`
+	Label   string `json:"label"`
+	Count   int    `json:"count"`
+	TaskIDs []uint `json:"task_ids"`
+}
+
+// GetSavedViews 获取当前用户可用的视图
+// 指定 project_id 时返回该项目中自己的视图和共享视图，否则返回自己的所有视图和参与项目中的共享视图
+func (h *SavedViewHandler) GetSavedViews(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	query := database.DB.Model(&models.SavedView{})
+	var defaultViewID *uint
+	if value := c.Query("project_id"); value != "" {
+		projectID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "Invalid project ID")
+			return
+		}
+		if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+			utils.Forbidden(c, "Access denied to this project")
+			return
+		}
+		query = query.Where("project_id = ? AND (owner_id = ? OR shared = ?)", projectID, userID, true)
+
+		var def models.SavedViewDefault
+		if err := database.DB.Where("user_id = ? AND project_id = ?", userID, projectID).First(&def).Error; err == nil {
+			defaultViewID = &def.ViewID
+		}
+	} else {
+		query = query.Where("owner_id = ? OR (shared = ? AND project_id IN ?)", userID, true, visibleProjectIDs(userID))
+	}
+
+	var views []models.SavedView
+	if err := query.Order("name ASC, id ASC").Find(&views).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch views")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"views":           views,
+		"total":           len(views),
+		"default_view_id": defaultViewID,
+	})
+}
+
+// GetSavedView 获取视图详情
+func (h *SavedViewHandler) GetSavedView(c *gin.Context) {
+	view, ok := h.loadView(c, false)
+	if !ok {
+		return
+	}
+	utils.Success(c, gin.H{"view": view})
+}
+
+// CreateSavedView 创建视图
+func (h *SavedViewHandler) CreateSavedView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req CreateSavedViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	if req.ProjectID != nil {
+		if !utils.CheckProjectMember(userID, *req.ProjectID) && !utils.CheckProjectOwner(userID, *req.ProjectID) {
+			utils.Forbidden(c, "Access denied to this project")
+			return
+		}
+	} else if req.Shared {
+		utils.BadRequest(c, "Only project views can be shared")
+		return
+	}
+
+	view := models.SavedView{
+		Name:           strings.TrimSpace(req.Name),
+		OwnerID:        userID,
+		ProjectID:      req.ProjectID,
+		Shared:         req.Shared,
+		Query:          req.Query,
+		Sort:           req.Sort,
+		GroupBy:        req.GroupBy,
+		Mode:           models.SavedViewMode(req.Mode),
+		VisibleColumns: req.Columns,
+	}
+	if err := validateSavedView(&view); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := database.DB.Create(&view).Error; err != nil {
+		utils.InternalServerError(c, "Failed to create view: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"view":    view,
+		"message": "View created successfully",
+	})
+}
+
+// UpdateSavedView 更新视图（视图所有者，共享视图也可以由项目管理员修改）
+func (h *SavedViewHandler) UpdateSavedView(c *gin.Context) {
+	view, ok := h.loadView(c, true)
+	if !ok {
+		return
+	}
+
+	var req UpdateSavedViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		view.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Shared != nil {
+		if *req.Shared && view.ProjectID == nil {
+			utils.BadRequest(c, "Only project views can be shared")
+			return
+		}
+		view.Shared = *req.Shared
+	}
+	if req.Query != nil {
+		view.Query = *req.Query
+	}
+	if req.Sort != nil {
+		view.Sort = *req.Sort
+	}
+	if req.GroupBy != nil {
+		view.GroupBy = *req.GroupBy
+	}
+	if req.Mode != nil {
+		view.Mode = models.SavedViewMode(*req.Mode)
+	}
+	if req.Columns != nil {
+		view.VisibleColumns = req.Columns
+	}
+	if err := validateSavedView(view); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := database.DB.Save(view).Error; err != nil {
+		utils.InternalServerError(c, "Failed to update view")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"view":    view,
+		"message": "View updated successfully",
+	})
+}
+
+// DeleteSavedView 删除视图，同时清除以它为默认视图的设置
+func (h *SavedViewHandler) DeleteSavedView(c *gin.Context) {
+	view, ok := h.loadView(c, true)
+	if !ok {
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("view_id = ?", view.ID).Delete(&models.SavedViewDefault{}).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to delete view defaults")
+		return
+	}
+	if err := tx.Delete(view).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to delete view")
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "View deleted successfully"})
+}
+
+// SetDefaultView 将视图设为当前用户在所属项目中的默认视图
+func (h *SavedViewHandler) SetDefaultView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	view, ok := h.loadView(c, false)
+	if !ok {
+		return
+	}
+	if view.ProjectID == nil {
+		utils.BadRequest(c, "Only project views can be set as default")
+		return
+	}
+
+	def := models.SavedViewDefault{UserID: userID, ProjectID: *view.ProjectID}
+	if err := database.DB.Where("user_id = ? AND project_id = ?", userID, *view.ProjectID).
+		Assign(models.SavedViewDefault{ViewID: view.ID}).FirstOrCreate(&def).Error; err != nil {
+		utils.InternalServerError(c, "Failed to set default view")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"default": def,
+		"message": "Default view updated successfully",
+	})
+}
+
+// ClearDefaultView 取消当前用户在项目中的默认视图
+func (h *SavedViewHandler) ClearDefaultView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	view, ok := h.loadView(c, false)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Where("user_id = ? AND view_id = ?", userID, view.ID).Delete(&models.SavedViewDefault{}).Error; err != nil {
+		utils.InternalServerError(c, "Failed to clear default view")
+		return
+	}
+
+	utils.Success(c, gin.H{"message": "Default view cleared successfully"})
+}
+
+// GetProjectDefaultView 获取当前用户在项目中的默认视图，未设置时 view 为 null
+func (h *SavedViewHandler) GetProjectDefaultView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	var def models.SavedViewDefault
+	if err := database.DB.Where("user_id = ? AND project_id = ?", userID, projectID).First(&def).Error; err != nil {
+		utils.Success(c, gin.H{"view": nil})
+		return
+	}
+
+	var view models.SavedView
+	if err := database.DB.Where("id = ? AND (owner_id = ? OR shared = ?)", def.ViewID, userID, true).First(&view).Error; err != nil {
+		// 视图已取消共享，默认设置失效
+		utils.Success(c, gin.H{"view": nil})
+		return
+	}
+
+	utils.Success(c, gin.H{"view": view})
+}
+
+// ExecuteSavedView 执行视图，返回匹配的任务以及分组结果
+// 日历模式只返回设置了开始或截止日期的任务
+func (h *SavedViewHandler) ExecuteSavedView(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	view, ok := h.loadView(c, false)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Preload("Stage").Preload("Project").Preload("Assignee")
+	if view.ProjectID != nil {
+		query = query.Where("tasks.project_id = ?", *view.ProjectID)
+	} else {
+		query = query.Where("tasks.project_id IN ?", visibleProjectIDs(userID))
+	}
+	if view.Mode == models.SavedViewModeCalendar {
+		query = query.Where("tasks.start_date IS NOT NULL OR tasks.due_date IS NOT NULL")
+	}
+
+	// 与任务列表一致，查询中没有 is:archived 时排除已归档的任务
+	if view.Query == "" {
+		query = query.Where(services.TaskNotArchivedCondition)
+	} else {
+		parsed, err := services.ParseTaskQuery(view.Query)
+		if err == nil {
+			var projectID uint
+			if view.ProjectID != nil {
+				projectID = *view.ProjectID
+			}
+			query, err = parsed.Apply(query, services.TaskQueryContext{
+				UserID:   userID,
+				Now:      time.Now(),
+				Location: utils.TaskTimezone(userID, projectID),
+			})
+		}
+		if err != nil {
+			utils.BadRequest(c, "Invalid view query: "+err.Error())
+			return
+		}
+		if !parsed.IncludesArchived() {
+			query = query.Where(services.TaskNotArchivedCondition)
+		}
+	}
+
+	// 传入 limit 或 cursor 时分页，分组只统计本页任务
+	tasks, pageInfo, ok := fetchTaskPage(c, query, view.Sort, 0, 500, false)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, withListTotal(c, gin.H{
+		"view":       view,
+		"tasks":      tasks,
+		"groups":     groupTasks(tasks, view.GroupBy),
+		"pagination": pageInfo,
+	}, len(tasks), pageInfo))
+}
+
+// loadView 读取路由中的视图；forWrite 为 true 时检查修改权限
+func (h *SavedViewHandler) loadView(c *gin.Context, forWrite bool) (*models.SavedView, bool) {
+	userID := c.MustGet("user_id").(uint)
+	viewID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid view ID")
+		return nil, false
+	}
+
+	var view models.SavedView
+	if err := database.DB.First(&view, viewID).Error; err != nil {
+		utils.NotFound(c, "View not found")
+		return nil, false
+	}
+
+	// 项目视图要求当前仍是项目成员，创建者被移出项目后也不能再通过视图读取任务
+	if view.ProjectID != nil &&
+		!utils.CheckProjectMember(userID, *view.ProjectID) && !utils.CheckProjectOwner(userID, *view.ProjectID) {
+		utils.NotFound(c, "View not found")
+		return nil, false
+	}
+	if view.OwnerID == userID {
+		return &view, true
+	}
+	if !view.Shared || view.ProjectID == nil {
+		utils.NotFound(c, "View not found")
+		return nil, false
+	}
+	if forWrite && !utils.CanManageProject(userID, *view.ProjectID) {
+		utils.Forbidden(c, "Only the owner or project managers can modify this view")
+		return nil, false
+	}
+	return &view, true
+}
+
+// validateSavedView 校验视图设置，并填充默认值
+func validateSavedView(view *models.SavedView) error {
+	if view.Name == "" {
+		return errors.New("view name is required")
+	}
+	if len([]rune(view.Name)) > 100 {
+		return errors.New("view name must be at most 100 characters")
+	}
+	if view.Query != "" {
+		if _, err := services.ParseTaskQuery(view.Query); err != nil {
+			return fmt.Errorf("invalid query: %v", err)
+		}
+	}
+	sortKeys, err := services.ParseTaskSort(view.Sort)
+	if err != nil {
+		return fmt.Errorf("invalid sort: %v", err)
+	}
+	view.Sort = services.FormatTaskSort(sortKeys)
+	if !savedViewGroupFields[view.GroupBy] {
+		return fmt.Errorf("invalid group_by %q, use stage, assignee, priority, status or project", view.GroupBy)
+	}
+	switch view.Mode {
+	case "":
+		view.Mode = models.SavedViewModeList
+	case models.SavedViewModeBoard, models.SavedViewModeList, models.SavedViewModeCalendar:
+	default:
+		return fmt.Errorf("invalid mode %q, use board, list or calendar", view.Mode)
+	}
+	if len(view.VisibleColumns) > maxViewColumns {
+		return fmt.Errorf("at most %d columns can be visible", maxViewColumns)
+	}
+	for i, column := range view.VisibleColumns {
+		column = strings.TrimSpace(column)
+		if column == "" || strings.Contains(column, ",") {
+			return fmt.Errorf("invalid column name %q", column)
+		}
+		view.VisibleColumns[i] = column
+	}
+	return nil
+}
+
+// groupTasks 按字段对任务分组，分组顺序为任务中首次出现的顺序
+func groupTasks(tasks []models.Task, groupBy string) []TaskGroup {
+	if groupBy == "" {
+		return nil
+	}
+
+	groups := []TaskGroup{}
+	index := make(map[string]int)
+	for _, task := range tasks {
+		key, label := "", ""
+		switch groupBy {
+		case "stage":
+			key = strconv.FormatUint(uint64(task.StageID), 10)
+			if task.Stage != nil {
+				label = task.Stage.Name
+			}
+		case "assignee":
+			key = "none"
+			if task.AssigneeID != nil {
+				key = strconv.FormatUint(uint64(*task.AssigneeID), 10)
+				if task.Assignee != nil {
+					label = task.Assignee.Username
+				}
+			}
+		case "priority":
+			key, label = task.Priority, task.Priority
+		case "status":
+			key, label = task.Status, task.Status
+		case "project":
+			key = strconv.FormatUint(uint64(task.ProjectID), 10)
+			if task.Project != nil {
+				label = task.Project.Name
+			}
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, TaskGroup{Key: key, Label: label, TaskIDs: []uint{}})
+		}
+		groups[i].Count++
+		groups[i].TaskIDs = append(groups[i].TaskIDs, task.ID)
+	}
+	return groups
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler 全文搜索处理器
+type SearchHandler struct {
+	SearchService *services.SearchService
+}
+
+// NewSearchHandler 创建全文搜索处理器
+func NewSearchHandler() *SearchHandler {
+	return &SearchHandler{
+		SearchService: services.NewSearchService(),
+	}
+}
+
+// Search 全文搜索任务和评论
+// 查询参数：q 关键词（支持中文），type 为 task 或 comment，project_id 限定项目，limit/offset 分页
+// 只返回当前用户参与的活跃项目中的内容，按相关度排序
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		utils.BadRequest(c, "Query is required")
+		return
+	}
+
+	docType := c.Query("type")
+	if docType != "" && docType != services.SearchDocTask && docType != services.SearchDocComment {
+		utils.BadRequest(c, "Invalid type, must be task or comment")
+		return
+	}
+
+	var projectID uint
+	if value := c.Query("project_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "Invalid project ID")
+			return
+		}
+		projectID = uint(id)
+		if !utils.CheckProjectMember(userID, projectID) && !utils.CheckProjectOwner(userID, projectID) {
+			utils.Forbidden(c, "Access denied to this project")
+			return
+		}
+	}
+
+	// 获取分页参数
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100 // 限制最大数量
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	result, err := h.SearchService.Search(database.DB, services.SearchQuery{
+		Text:       text,
+		Type:       docType,
+		ProjectIDs: visibleProjectIDs(userID),
+		ProjectID:  projectID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrSearchUnavailable) {
+			utils.Error(c, http.StatusServiceUnavailable, "Full-text search is not available")
+			return
+		}
+		utils.InternalServerErrorSafe(c, "Failed to search", err)
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"query":  text,
+		"terms":  result.Terms,
+		"hits":   result.Hits,
+		"total":  result.Total,
+		"offset": offset,
+		"pagination": utils.PageInfo{
+			Limit:   limit,
+			HasMore: offset+limit < result.Total,
+			Total:   &result.Total,
+		},
+	})
+}
+
+// RebuildIndex 重建全文搜索索引（系统管理员功能）
+func (h *SearchHandler) RebuildIndex(c *gin.Context) {
+	userRole := c.MustGet("user_role").(string)
+	if userRole != "admin" {
+		utils.Forbidden(c, "Admin access required")
+		return
+	}
+
+	indexed, err := h.SearchService.Rebuild(database.DB)
+	if err != nil {
+		if errors.Is(err, services.ErrSearchUnavailable) {
+			utils.Error(c, http.StatusServiceUnavailable, "Full-text search is not available")
+			return
+		}
+		utils.InternalServerError(c, "Failed to rebuild search index: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"indexed": indexed,
+		"message": "Search index rebuilt successfully",
+	})
+}
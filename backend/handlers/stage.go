@@ -3,6 +3,7 @@ package handlers
 import (
 	"project-manager-backend/database"
 	"project-manager-backend/models"
+	"project-manager-backend/services"
 	"project-manager-backend/utils"
 	"strconv"
 	"time"
@@ -16,12 +17,15 @@ type StageHandler struct{}
 
 // CreateStageRequest 创建阶段请求
 type CreateStageRequest struct {
-	ProjectID        uint   `json:"project_id" binding:"required"`
-	Name             string `json:"name" binding:"required"`
-	Description      string `json:"description"`
-	Color            string `json:"color"`
-	TaskLimit        *int   `json:"task_limit"`
-	AutoAssignStatus string `json:"autoAssignStatus"`
+	ProjectID           uint           `json:"project_id" binding:"required"`
+	Name                string         `json:"name" binding:"required"`
+	Description         string         `json:"description"`
+	Color               string         `json:"color"`
+	TaskLimit           *int           `json:"task_limit"` // 旧版本字段，与 maxTasks 相同
+	MaxTasks            *int           `json:"maxTasks"`
+	MaxTasksPerAssignee *int           `json:"maxTasksPerAssignee"`
+	WIPMode             models.WIPMode `json:"wipMode"`
+	AutoAssignStatus    string         `json:"autoAssignStatus"`
 }
 
 // UpdateStageRequest 更新阶段请求
@@ -29,9 +33,11 @@ type UpdateStageRequest struct {
 	Name                string  `json:"name"`
 	Description         string  `json:"description"`
 	Color               string  `json:"color"`
-	TaskLimit           *int    `json:"task_limit"`
+	TaskLimit           *int    `json:"task_limit"` // 旧版本字段，与 maxTasks 相同
 	IsCompleted         *bool   `json:"is_completed"`
 	MaxTasks            *int    `json:"maxTasks"`
+	MaxTasksPerAssignee *int    `json:"maxTasksPerAssignee"`
+	WIPMode             *string `json:"wipMode"`
 	AllowTaskCreation   *bool   `json:"allowTaskCreation"`
 	AllowTaskDeletion   *bool   `json:"allowTaskDeletion"`
 	AllowTaskMovement   *bool   `json:"allowTaskMovement"`
@@ -66,6 +72,9 @@ func (h *StageHandler) CreateStage(c *gin.Context) {
 		utils.Forbidden(c, "Insufficient permissions to create stage")
 		return
 	}
+	if !utils.CheckProjectWritable(c, req.ProjectID) {
+		return
+	}
 
 	// 检查项目是否存在
 	var project models.Project
@@ -74,6 +83,17 @@ func (h *StageHandler) CreateStage(c *gin.Context) {
 		return
 	}
 
+	if !validateAutoAssignStatus(c, req.ProjectID, req.AutoAssignStatus) {
+		return
+	}
+	maxTasks := stageMaxTasks(req.MaxTasks, req.TaskLimit)
+	if req.WIPMode == "" {
+		req.WIPMode = models.WIPModeHard
+	}
+	if !validateWIPSettings(c, maxTasks, req.MaxTasksPerAssignee, req.WIPMode) {
+		return
+	}
+
 	// 获取当前最大排序值
 	var maxSortOrder int
 	database.DB.Model(&models.Stage{}).Where("project_id = ?", req.ProjectID).Select("COALESCE(MAX(position), 0)").Scan(&maxSortOrder)
@@ -85,9 +105,15 @@ func (h *StageHandler) CreateStage(c *gin.Context) {
 		Description:      req.Description,
 		Color:            req.Color,
 		Position:         maxSortOrder + 1,
-		TaskLimit:        req.TaskLimit,
 		CreatedBy:        userID,
 		AutoAssignStatus: req.AutoAssignStatus,
+		WIPMode:          req.WIPMode,
+	}
+	if maxTasks != nil {
+		stage.MaxTasks = *maxTasks
+	}
+	if req.MaxTasksPerAssignee != nil {
+		stage.MaxTasksPerAssignee = *req.MaxTasksPerAssignee
 	}
 
 	if stage.Color == "" {
@@ -169,6 +195,9 @@ func (h *StageHandler) UpdateStage(c *gin.Context) {
 		utils.Forbidden(c, "Insufficient permissions to update stage")
 		return
 	}
+	if !utils.CheckProjectWritable(c, stage.ProjectID) {
+		return
+	}
 
 	var req UpdateStageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -188,9 +217,6 @@ func (h *StageHandler) UpdateStage(c *gin.Context) {
 	if req.Color != "" {
 		updates["color"] = req.Color
 	}
-	if req.TaskLimit != nil {
-		updates["task_limit"] = *req.TaskLimit
-	}
 	if req.IsCompleted != nil {
 		updates["is_completed"] = *req.IsCompleted
 		if *req.IsCompleted {
@@ -199,8 +225,22 @@ func (h *StageHandler) UpdateStage(c *gin.Context) {
 			updates["completed_at"] = nil
 		}
 	}
-	if req.MaxTasks != nil {
-		updates["max_tasks"] = *req.MaxTasks
+	maxTasks := stageMaxTasks(req.MaxTasks, req.TaskLimit)
+	wipMode := models.WIPMode("")
+	if req.WIPMode != nil {
+		wipMode = models.WIPMode(*req.WIPMode)
+	}
+	if !validateWIPSettings(c, maxTasks, req.MaxTasksPerAssignee, wipMode) {
+		return
+	}
+	if maxTasks != nil {
+		updates["max_tasks"] = *maxTasks
+	}
+	if req.MaxTasksPerAssignee != nil {
+		updates["max_tasks_per_assignee"] = *req.MaxTasksPerAssignee
+	}
+	if req.WIPMode != nil {
+		updates["wip_mode"] = wipMode
 	}
 	if req.AllowTaskCreation != nil {
 		updates["allow_task_creation"] = *req.AllowTaskCreation
@@ -215,6 +255,9 @@ func (h *StageHandler) UpdateStage(c *gin.Context) {
 		updates["notification_enabled"] = *req.NotificationEnabled
 	}
 	if req.AutoAssignStatus != nil {
+		if !validateAutoAssignStatus(c, stage.ProjectID, *req.AutoAssignStatus) {
+			return
+		}
 		updates["auto_assign_status"] = *req.AutoAssignStatus
 	}
 
@@ -303,68 +346,6 @@ func (h *StageHandler) UpdateStage(c *gin.Context) {
 	})
 }
 
-// DeleteStage 删除阶段
-func (h *StageHandler) DeleteStage(c *gin.Context) {
-	userID := c.MustGet("user_id").(uint)
-	stageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "Invalid stage ID")
-		return
-	}
-
-	// 查找阶段
-	var stage models.Stage
-	if err := database.DB.Preload("Project").First(&stage, stageID).Error; err != nil {
-		utils.NotFound(c, "Stage not found")
-		return
-	}
-
-	// 单机版：检查用户是否是项目成员即可
-	if !utils.CanManageStages(userID, stage.ProjectID) {
-		utils.Forbidden(c, "Insufficient permissions to delete stage")
-		return
-	}
-
-	// 开始事务
-	tx := database.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// 先删除阶段内的所有任务
-	if err := tx.Where("stage_id = ?", stageID).Delete(&models.Task{}).Error; err != nil {
-		tx.Rollback()
-		utils.InternalServerError(c, "Failed to delete stage tasks")
-		return
-	}
-
-	// 删除阶段
-	if err := tx.Delete(&stage).Error; err != nil {
-		tx.Rollback()
-		utils.InternalServerError(c, "Failed to delete stage")
-		return
-	}
-
-	// 重新排序其他阶段
-	if err := tx.Model(&models.Stage{}).
-		Where("project_id = ? AND position > ?", stage.ProjectID, stage.Position).
-		Update("position", "position - 1").Error; err != nil {
-		tx.Rollback()
-		utils.InternalServerError(c, "Failed to reorder stages")
-		return
-	}
-
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		utils.InternalServerError(c, "Failed to commit transaction")
-		return
-	}
-
-	utils.Success(c, gin.H{"message": "Stage deleted successfully"})
-}
-
 // ReorderStages 重新排序阶段
 func (h *StageHandler) ReorderStages(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
@@ -392,6 +373,9 @@ func (h *StageHandler) ReorderStages(c *gin.Context) {
 		utils.Forbidden(c, "Insufficient permissions to reorder stages")
 		return
 	}
+	if !utils.CheckProjectWritable(c, firstStage.ProjectID) {
+		return
+	}
 
 	// 开始事务
 	tx := database.DB.Begin()
@@ -420,3 +404,41 @@ func (h *StageHandler) ReorderStages(c *gin.Context) {
 
 	utils.Success(c, gin.H{"message": "Stages reordered successfully"})
 }
+
+// validateAutoAssignStatus 检查阶段自动设置的状态是否在项目工作流中定义，空值表示不自动设置
+func validateAutoAssignStatus(c *gin.Context, projectID uint, status string) bool {
+	if status == "" {
+		return true
+	}
+	workflow, err := services.NewWorkflowService().GetWorkflow(database.DB, projectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return false
+	}
+	if err := workflow.ValidateStatus(status); err != nil {
+		utils.BadRequest(c, "Invalid autoAssignStatus: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// stageMaxTasks 阶段任务上限参数，task_limit 是旧版本的字段，同时提供时以 maxTasks 为准
+func stageMaxTasks(maxTasks, taskLimit *int) *int {
+	if maxTasks != nil {
+		return maxTasks
+	}
+	return taskLimit
+}
+
+// validateWIPSettings 检查阶段在制品上限设置，mode 为空表示不修改
+func validateWIPSettings(c *gin.Context, maxTasks, perAssignee *int, mode models.WIPMode) bool {
+	if (maxTasks != nil && *maxTasks < 0) || (perAssignee != nil && *perAssignee < 0) {
+		utils.BadRequest(c, "Task limits must not be negative")
+		return false
+	}
+	if mode != "" && !services.ValidMode(mode) {
+		utils.BadRequest(c, "Invalid wipMode: must be hard or soft")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 删除阶段时阶段内任务的处理方式
+const (
+	StageDeleteMigrate = "migrate" // 按顺序追加到目标阶段末尾，并按目标阶段设置同步状态和完成时间
+	StageDeleteArchive = "archive" // 归档任务，之后可以恢复到其他阶段
+	StageDeleteCascade = "cascade" // 彻底删除任务及其评论、活动记录等关联数据
+)
+
+// StageRef 阶段的简要信息
+type StageRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// StageDeletionTask 删除阶段时对单个任务的处理
+type StageDeletionTask struct {
+	ID         uint   `json:"id"`
+	Key        string `json:"key"`
+	Title      string `json:"title"`
+	Archived   bool   `json:"archived"`             // 删除前已归档（迁移时只更换阶段，保持归档）
+	Status     string `json:"status"`               // 当前状态
+	NewStatus  string `json:"new_status,omitempty"` // 迁移后的状态（有变化时）
+	Completion string `json:"completion,omitempty"` // 迁移后完成（completed）或重新打开（reopened）
+}
+
+// StageDeletionPlan 删除阶段的执行计划，dry_run 时只返回计划
+type StageDeletionPlan struct {
+	Strategy        string                       `json:"strategy,omitempty"`
+	DryRun          bool                         `json:"dry_run"`
+	Stage           StageRef                     `json:"stage"`
+	TargetStage     *StageRef                    `json:"target_stage,omitempty"`
+	Tasks           []StageDeletionTask          `json:"tasks"`
+	Removed         *services.TaskRelationCounts `json:"removed,omitempty"` // cascade 时一并删除的数据
+	TransitionRules int                          `json:"transition_rules"`  // 一并删除的阶段流转规则数
+	WIPViolations   []models.WIPViolation        `json:"wip_violations,omitempty"`
+	Blocked         bool                         `json:"blocked"` // 目标阶段的硬性在制品上限不允许迁移
+}
+
+// DeleteStage 删除阶段
+// 阶段内有未归档的任务时必须通过 strategy 参数指定处理方式：
+//   - migrate：迁移到 target_stage_id 指定的同项目阶段（不检查流转规则，但检查目标阶段的在制品上限）
+//   - archive：归档任务
+//   - cascade：彻底删除任务及其评论和活动记录
+//
+// dry_run=true 时不做修改，只返回执行计划
+func (h *StageHandler) DeleteStage(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	stageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid stage ID")
+		return
+	}
+
+	// 查找阶段
+	var stage models.Stage
+	if err := database.DB.Preload("Project").First(&stage, stageID).Error; err != nil {
+		utils.NotFound(c, "Stage not found")
+		return
+	}
+
+	// 单机版：检查用户是否是项目成员即可
+	if !utils.CanManageStages(userID, stage.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to delete stage")
+		return
+	}
+	if !utils.CheckProjectWritable(c, stage.ProjectID) {
+		return
+	}
+
+	strategy := c.Query("strategy")
+	dryRun := c.Query("dry_run") == "true" || c.Query("dry_run") == "1"
+
+	// 阶段内的任务按看板顺序处理，包括已归档的任务
+	var tasks []models.Task
+	if err := database.DB.Where("stage_id = ?", stage.ID).Order("rank ASC, id ASC").Find(&tasks).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch stage tasks")
+		return
+	}
+	var activeTasks []*models.Task
+	var taskIDs []uint
+	for i := range tasks {
+		taskIDs = append(taskIDs, tasks[i].ID)
+		if tasks[i].ArchivedAt == nil {
+			activeTasks = append(activeTasks, &tasks[i])
+		}
+	}
+
+	switch strategy {
+	case "":
+		if len(activeTasks) > 0 {
+			utils.ErrorWithData(c, http.StatusConflict,
+				fmt.Sprintf("Stage has %d tasks, specify strategy=migrate, archive or cascade", len(activeTasks)),
+				gin.H{"task_count": len(activeTasks), "strategies": []string{StageDeleteMigrate, StageDeleteArchive, StageDeleteCascade}})
+			return
+		}
+	case StageDeleteMigrate, StageDeleteArchive, StageDeleteCascade:
+	default:
+		utils.BadRequest(c, "Invalid strategy: must be migrate, archive or cascade")
+		return
+	}
+
+	plan := &StageDeletionPlan{
+		Strategy: strategy,
+		DryRun:   dryRun,
+		Stage:    StageRef{ID: stage.ID, Name: stage.Name},
+		Tasks:    make([]StageDeletionTask, 0, len(tasks)),
+	}
+	for i := range tasks {
+		plan.Tasks = append(plan.Tasks, StageDeletionTask{
+			ID:       tasks[i].ID,
+			Key:      tasks[i].Key,
+			Title:    tasks[i].Title,
+			Archived: tasks[i].ArchivedAt != nil,
+			Status:   tasks[i].Status,
+		})
+	}
+	if err := database.DB.Model(&models.StageTransition{}).
+		Where("from_stage_id = ? OR to_stage_id = ?", stage.ID, stage.ID).Count(&plan.TransitionRules).Error; err != nil {
+		utils.InternalServerError(c, "Failed to count stage transitions")
+		return
+	}
+
+	// 迁移：检查目标阶段，计算每个任务进入目标阶段后的状态
+	var targetStage models.Stage
+	var workflow *services.Workflow
+	var wip *services.WIPResult
+	entries := make(map[uint]*services.StageEntry)
+	now := time.Now()
+	if strategy == StageDeleteMigrate {
+		targetID, err := strconv.ParseUint(c.Query("target_stage_id"), 10, 32)
+		if err != nil {
+			utils.BadRequest(c, "target_stage_id is required for the migrate strategy")
+			return
+		}
+		if uint(targetID) == stage.ID {
+			utils.BadRequest(c, "Target stage must be different from the deleted stage")
+			return
+		}
+		if err := database.DB.Where("id = ? AND project_id = ?", targetID, stage.ProjectID).First(&targetStage).Error; err != nil {
+			utils.NotFound(c, "Target stage not found")
+			return
+		}
+		if !targetStage.AllowTaskMovement && len(activeTasks) > 0 {
+			utils.BadRequest(c, "Task movement is not allowed to the target stage")
+			return
+		}
+		plan.TargetStage = &StageRef{ID: targetStage.ID, Name: targetStage.Name}
+
+		if workflow, err = services.NewWorkflowService().GetWorkflow(database.DB, stage.ProjectID); err != nil {
+			utils.InternalServerError(c, "Failed to load project workflow")
+			return
+		}
+		assignees := make([]*uint, len(activeTasks))
+		for i, task := range activeTasks {
+			assignees[i] = task.AssigneeID
+			entries[task.ID] = services.ComputeStageEntry(workflow, task, &stage, &targetStage, now)
+		}
+		for i := range plan.Tasks {
+			if entry := entries[plan.Tasks[i].ID]; entry != nil {
+				if entry.StatusChanged() {
+					plan.Tasks[i].NewStatus = entry.NewStatus
+				}
+				plan.Tasks[i].Completion = completionName(entry.Completion)
+			}
+		}
+
+		if wip, err = services.NewWIPService().CheckEntry(database.DB, &targetStage, assignees, "migrate", userID); err != nil {
+			utils.InternalServerError(c, "Failed to check WIP limits")
+			return
+		}
+		plan.WIPViolations = wip.Violations
+		plan.Blocked = wip.Blocked()
+	}
+
+	archiveService := services.NewTaskArchiveService()
+	if strategy == StageDeleteCascade {
+		if plan.Removed, err = archiveService.CountTaskRelations(database.DB, taskIDs); err != nil {
+			utils.InternalServerError(c, "Failed to count task data")
+			return
+		}
+	}
+
+	if dryRun {
+		utils.Success(c, gin.H{
+			"plan":    plan,
+			"message": "Dry run, no changes were made",
+		})
+		return
+	}
+
+	if plan.Blocked {
+		if err := services.NewWIPService().Record(database.DB, wip, 0); err != nil {
+			log.Printf("Failed to record WIP violation: %v", err)
+		}
+		utils.ErrorWithData(c, http.StatusBadRequest, wip.Error(), gin.H{"plan": plan})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	switch strategy {
+	case StageDeleteMigrate:
+		if err := appendTasksToStage(tx, stageMoves(tasks, &stage, entries), targetStage.ID); err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to migrate task: "+err.Error())
+			return
+		}
+
+	case StageDeleteArchive:
+		if err := archiveService.ArchiveTasks(tx, taskIDs, now); err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to archive stage tasks")
+			return
+		}
+
+	case StageDeleteCascade:
+		if err := archiveService.DeleteTasksCascade(tx, taskIDs); err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to delete stage tasks")
+			return
+		}
+	}
+
+	// 删除与该阶段相关的流转规则
+	if err := services.NewStageTransitionService().RemoveStageRules(tx, stage.ID); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to delete stage transitions")
+		return
+	}
+
+	// 删除阶段
+	if err := tx.Delete(&stage).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to delete stage")
+		return
+	}
+
+	// 重新排序其他阶段
+	if err := shiftStagePositions(tx, stage.ProjectID, stage.Position, -1); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to reorder stages")
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.afterStageDeleted(c, strategy, &stage, &targetStage, tasks, activeTasks, entries, wip, userID)
+
+	utils.Success(c, gin.H{
+		"plan":    plan,
+		"message": "Stage deleted successfully",
+	})
+}
+
+// afterStageDeleted 事务提交后记录迁移和归档的活动，并为迁移的任务执行自动化规则
+func (h *StageHandler) afterStageDeleted(c *gin.Context, strategy string, stage, targetStage *models.Stage,
+	tasks []models.Task, activeTasks []*models.Task, entries map[uint]*services.StageEntry, wip *services.WIPResult, userID uint) {
+	switch strategy {
+	case StageDeleteMigrate:
+		if len(wip.Warnings()) > 0 {
+			if err := services.NewWIPService().Record(database.DB, wip, 0); err != nil {
+				log.Printf("Failed to record WIP violation: %v", err)
+			}
+		}
+		afterStageTasksMoved(c, stageMoves(tasks, stage, entries), targetStage, userID)
+
+	case StageDeleteArchive:
+		activity := services.NewTaskActivityService()
+		for _, task := range activeTasks {
+			if err := activity.LogTaskArchived(task.ID, userID, task.ProjectID, true, c); err != nil {
+				log.Printf("Failed to log task archive activity: %v", err)
+			}
+		}
+	}
+}
+
+// stageMoves 删除阶段时按顺序迁移阶段内的全部任务，已归档的任务没有进入阶段的变化
+func stageMoves(tasks []models.Task, from *models.Stage, entries map[uint]*services.StageEntry) []stageTaskMove {
+	moves := make([]stageTaskMove, 0, len(tasks))
+	for i := range tasks {
+		moves = append(moves, stageTaskMove{Task: &tasks[i], From: from, Entry: entries[tasks[i].ID]})
+	}
+	return moves
+}
+
+// completionName 完成状态变化的名称，没有变化时为空
+func completionName(change services.CompletionChange) string {
+	switch change {
+	case services.CompletionCompleted:
+		return "completed"
+	case services.CompletionReopened:
+		return "reopened"
+	}
+	return ""
+}
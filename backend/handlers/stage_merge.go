@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// 拆分阶段时新阶段相对原阶段的位置
+const (
+	StageSplitAfter  = "after"
+	StageSplitBefore = "before"
+)
+
+// MergeStagesRequest 合并阶段请求
+type MergeStagesRequest struct {
+	TargetStageID  uint   `json:"target_stage_id" binding:"required"`
+	SourceStageIDs []uint `json:"source_stage_ids" binding:"required"`
+}
+
+// SplitStageRequest 拆分阶段请求
+type SplitStageRequest struct {
+	Name             string             `json:"name" binding:"required"`
+	Description      string             `json:"description"`
+	Color            string             `json:"color"`                       // 默认与原阶段相同
+	TaskIDs          []services.TaskRef `json:"task_ids" binding:"required"` // 任务ID或编号
+	Placement        string             `json:"placement"`                   // 新阶段放在原阶段之后（after，默认）或之前（before）
+	AutoAssignStatus string             `json:"autoAssignStatus"`
+}
+
+// stageTaskMove 阶段合并、拆分或删除时迁移的一个任务
+type stageTaskMove struct {
+	Task  *models.Task
+	From  *models.Stage
+	Entry *services.StageEntry // 已归档的任务只更换阶段，为 nil
+}
+
+// MergeStages 合并阶段，把来源阶段的全部任务（包括已归档的）移动到目标阶段末尾并删除来源阶段
+// 来源阶段按看板顺序、阶段内按任务顺序追加；与来源阶段相关的流转规则一并删除
+func (h *StageHandler) MergeStages(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req MergeStagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	var target models.Stage
+	if err := database.DB.First(&target, req.TargetStageID).Error; err != nil {
+		utils.NotFound(c, "Target stage not found")
+		return
+	}
+
+	if !utils.CanManageStages(userID, target.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to merge stages")
+		return
+	}
+	if !utils.CheckProjectWritable(c, target.ProjectID) {
+		return
+	}
+
+	if len(req.SourceStageIDs) == 0 {
+		utils.BadRequest(c, "source_stage_ids cannot be empty")
+		return
+	}
+	seen := make(map[uint]bool, len(req.SourceStageIDs))
+	for _, id := range req.SourceStageIDs {
+		if id == target.ID {
+			utils.BadRequest(c, "Target stage cannot be one of the source stages")
+			return
+		}
+		if seen[id] {
+			utils.BadRequest(c, "Duplicate source stage: "+strconv.FormatUint(uint64(id), 10))
+			return
+		}
+		seen[id] = true
+	}
+
+	var sources []models.Stage
+	if err := database.DB.Where("id IN (?) AND project_id = ?", req.SourceStageIDs, target.ProjectID).
+		Order("position ASC, id ASC").Find(&sources).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch source stages")
+		return
+	}
+	if len(sources) != len(req.SourceStageIDs) {
+		utils.NotFound(c, "Source stage not found in the target stage's project")
+		return
+	}
+
+	workflow, err := services.NewWorkflowService().GetWorkflow(database.DB, target.ProjectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return
+	}
+
+	now := time.Now()
+	var moves []stageTaskMove
+	var assignees []*uint
+	for i := range sources {
+		source := &sources[i]
+		var tasks []models.Task
+		if err := database.DB.Where("stage_id = ?", source.ID).Order("rank ASC, id ASC").Find(&tasks).Error; err != nil {
+			utils.InternalServerError(c, "Failed to fetch stage tasks")
+			return
+		}
+		for j := range tasks {
+			move := stageTaskMove{Task: &tasks[j], From: source}
+			if tasks[j].ArchivedAt == nil {
+				move.Entry = services.ComputeStageEntry(workflow, &tasks[j], source, &target, now)
+				assignees = append(assignees, tasks[j].AssigneeID)
+			}
+			moves = append(moves, move)
+		}
+	}
+
+	if !target.AllowTaskMovement && len(assignees) > 0 {
+		utils.BadRequest(c, "Task movement is not allowed to the target stage")
+		return
+	}
+
+	wipService := services.NewWIPService()
+	wip, err := wipService.CheckEntry(database.DB, &target, assignees, "merge", userID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to check WIP limits")
+		return
+	}
+	if wip.Blocked() {
+		if err := wipService.Record(database.DB, wip, 0); err != nil {
+			log.Printf("Failed to record WIP violation: %v", err)
+		}
+		utils.ErrorWithData(c, http.StatusBadRequest, wip.Error(), gin.H{"violations": wip.Violations})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := appendTasksToStage(tx, moves, target.ID); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to move tasks: "+err.Error())
+		return
+	}
+
+	// 从后往前删除来源阶段，保证每次调整的位置都是删除前的位置
+	transitions := services.NewStageTransitionService()
+	for i := len(sources) - 1; i >= 0; i-- {
+		if err := transitions.RemoveStageRules(tx, sources[i].ID); err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to delete stage transitions")
+			return
+		}
+		if err := tx.Delete(&sources[i]).Error; err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to delete stage")
+			return
+		}
+		if err := shiftStagePositions(tx, target.ProjectID, sources[i].Position, -1); err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to reorder stages")
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	if len(wip.Warnings()) > 0 {
+		if err := wipService.Record(database.DB, wip, 0); err != nil {
+			log.Printf("Failed to record WIP violation: %v", err)
+		}
+	}
+	afterStageTasksMoved(c, moves, &target, userID)
+
+	if err := database.DB.First(&target, target.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload stage data")
+		return
+	}
+
+	removed := make([]StageRef, 0, len(sources))
+	for _, source := range sources {
+		removed = append(removed, StageRef{ID: source.ID, Name: source.Name})
+	}
+	utils.Success(c, withWIPWarnings(gin.H{
+		"stage":          target,
+		"removed_stages": removed,
+		"moved_tasks":    len(moves),
+		"message":        "Stages merged successfully",
+	}, wip))
+}
+
+// SplitStage 拆分阶段，在原阶段旁边新建阶段并把选中的任务按原顺序移动过去
+// 新阶段使用默认的任务权限且不设在制品上限，原阶段的流转规则不会复制到新阶段
+func (h *StageHandler) SplitStage(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	stageID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid stage ID")
+		return
+	}
+
+	var req SplitStageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	var source models.Stage
+	if err := database.DB.First(&source, stageID).Error; err != nil {
+		utils.NotFound(c, "Stage not found")
+		return
+	}
+
+	if !utils.CanManageStages(userID, source.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to split stage")
+		return
+	}
+	if !utils.CheckProjectWritable(c, source.ProjectID) {
+		return
+	}
+
+	if len(req.TaskIDs) == 0 {
+		utils.BadRequest(c, "task_ids cannot be empty")
+		return
+	}
+	taskIDs, ok := resolveTaskRefs(c, req.TaskIDs...)
+	if !ok {
+		return
+	}
+	if req.Placement == "" {
+		req.Placement = StageSplitAfter
+	}
+	if req.Placement != StageSplitAfter && req.Placement != StageSplitBefore {
+		utils.BadRequest(c, "Invalid placement: must be after or before")
+		return
+	}
+	if !validateAutoAssignStatus(c, source.ProjectID, req.AutoAssignStatus) {
+		return
+	}
+
+	var tasks []models.Task
+	if err := database.DB.Where("id IN (?) AND stage_id = ?", taskIDs, source.ID).
+		Order("rank ASC, id ASC").Find(&tasks).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch stage tasks")
+		return
+	}
+	if len(tasks) != len(uniqueIDs(taskIDs)) {
+		utils.BadRequest(c, "All tasks must belong to the stage being split")
+		return
+	}
+	for i := range tasks {
+		if tasks[i].ArchivedAt != nil {
+			utils.BadRequest(c, "Archived tasks cannot be moved, restore them first")
+			return
+		}
+	}
+
+	workflow, err := services.NewWorkflowService().GetWorkflow(database.DB, source.ProjectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return
+	}
+
+	position := source.Position + 1
+	if req.Placement == StageSplitBefore {
+		position = source.Position
+	}
+	stage := models.Stage{
+		ProjectID:        source.ProjectID,
+		Name:             req.Name,
+		Description:      req.Description,
+		Color:            req.Color,
+		Position:         position,
+		CreatedBy:        userID,
+		AutoAssignStatus: req.AutoAssignStatus,
+		WIPMode:          models.WIPModeHard,
+	}
+	if stage.Color == "" {
+		stage.Color = source.Color
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 为新阶段腾出位置
+	if err := shiftStagePositions(tx, source.ProjectID, position-1, 1); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to reorder stages")
+		return
+	}
+
+	if err := tx.Create(&stage).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to create stage")
+		return
+	}
+
+	now := time.Now()
+	moves := make([]stageTaskMove, 0, len(tasks))
+	for i := range tasks {
+		moves = append(moves, stageTaskMove{
+			Task:  &tasks[i],
+			From:  &source,
+			Entry: services.ComputeStageEntry(workflow, &tasks[i], &source, &stage, now),
+		})
+	}
+	if err := appendTasksToStage(tx, moves, stage.ID); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to move tasks: "+err.Error())
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	afterStageTasksMoved(c, moves, &stage, userID)
+
+	if err := database.DB.Preload("Project").First(&stage, stage.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload stage data")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"stage":       stage,
+		"moved_tasks": len(moves),
+		"message":     "Stage split successfully",
+	})
+}
+
+// appendTasksToStage 按给定顺序把任务追加到阶段末尾，并应用进入阶段后的状态变化
+func appendTasksToStage(tx *gorm.DB, moves []stageTaskMove, stageID uint) error {
+	rankService := services.NewTaskRankService()
+	for _, move := range moves {
+		rank, err := rankService.RankForAppend(tx, stageID)
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"stage_id": stageID, "rank": rank}
+		if move.Entry != nil {
+			for field, value := range move.Entry.Updates {
+				updates[field] = value
+			}
+		}
+		if err := tx.Model(&models.Task{}).Where("id = ?", move.Task.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterStageTasksMoved 事务提交后为移动的未归档任务记录活动并执行自动化规则
+func afterStageTasksMoved(c *gin.Context, moves []stageTaskMove, target *models.Stage, userID uint) {
+	activity := services.NewTaskActivityService()
+	var moved []*models.Task
+	for _, move := range moves {
+		if move.Entry == nil {
+			continue
+		}
+		if err := activity.LogTaskMoved(
+			move.Task.ID, userID, move.Task.ProjectID,
+			move.From.ID, target.ID,
+			move.From.Name, target.Name,
+			c,
+		); err != nil {
+			log.Printf("Failed to log task move activity: %v", err)
+		}
+		activity.LogStageEntry(move.Task, userID, move.Entry, c)
+		moved = append(moved, move.Task)
+	}
+
+	automation := services.NewAutomationService()
+	for _, task := range moved {
+		automation.Dispatch(database.DB, services.AutomationEvent{
+			Trigger:   models.TriggerTaskMoved,
+			ProjectID: task.ProjectID,
+			TaskID:    task.ID,
+			ActorID:   userID,
+			StageID:   target.ID,
+		})
+	}
+}
+
+// shiftStagePositions 把项目中位置在 after 之后的阶段整体移动 delta 位
+func shiftStagePositions(tx *gorm.DB, projectID uint, after, delta int) error {
+	return tx.Exec("UPDATE stages SET position = position + ? WHERE project_id = ? AND position > ?",
+		delta, projectID, after).Error
+}
+
+// uniqueIDs 去重后的ID列表，保持原顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StageTransitionHandler 阶段流转规则处理器
+type StageTransitionHandler struct {
+	TransitionService *services.StageTransitionService
+}
+
+// NewStageTransitionHandler 创建阶段流转规则处理器
+func NewStageTransitionHandler() *StageTransitionHandler {
+	return &StageTransitionHandler{
+		TransitionService: services.NewStageTransitionService(),
+	}
+}
+
+// UpdateTransitionsRequest 更新流转规则请求
+type UpdateTransitionsRequest struct {
+	Rules []models.StageTransition `json:"rules"`
+}
+
+// GetTransitions 获取项目的阶段流转规则
+func (h *StageTransitionHandler) GetTransitions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	rules, err := h.TransitionService.LoadRules(database.DB, uint(projectID))
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch stage transitions")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project_id":      projectID,
+		"rules":           rules.Rules,
+		"required_fields": services.TransitionFieldNames(),
+	})
+}
+
+// UpdateTransitions 替换项目的阶段流转规则，传入空数组表示不限制
+// allow 规则：目标阶段有允许规则时，只能从规则列出的来源阶段进入（from_stage_id 为空表示任意阶段），
+// 并且需要满足规则的角色和必填字段要求；deny 规则：禁止从来源阶段进入目标阶段
+func (h *StageTransitionHandler) UpdateTransitions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CanManageProject(userID, uint(projectID)) {
+		utils.Forbidden(c, "Insufficient permissions to update stage transitions")
+		return
+	}
+	if !utils.CheckProjectWritable(c, uint(projectID)) {
+		return
+	}
+
+	var req UpdateTransitionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := h.TransitionService.ReplaceRules(tx, uint(projectID), req.Rules); err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	rules, err := h.TransitionService.LoadRules(database.DB, uint(projectID))
+	if err != nil {
+		utils.InternalServerError(c, "Failed to reload stage transitions")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project_id": projectID,
+		"rules":      rules.Rules,
+		"message":    "Stage transitions updated successfully",
+	})
+}
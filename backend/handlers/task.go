@@ -14,7 +14,14 @@ import (
 
 // TaskHandler 任务处理器
 type TaskHandler struct {
-	ActivityService *services.TaskActivityService // 任务活动记录服务
+	ActivityService   *services.TaskActivityService    // 任务活动记录服务
+	RankService       *services.TaskRankService        // 任务排序键服务
+	KeyService        *services.TaskKeyService         // 任务编号服务
+	SearchService     *services.SearchService          // 全文搜索服务
+	WorkflowService   *services.WorkflowService        // 项目工作流服务
+	TransitionService *services.StageTransitionService // 阶段流转规则服务
+	AutomationService *services.AutomationService      // 自动化规则服务
+	WIPService        *services.WIPService             // 在制品限制服务
 }
 
 // CreateTaskRequest 创建任务请求
@@ -25,7 +32,9 @@ type CreateTaskRequest struct {
 	Description    string   `json:"description"`
 	Priority       string   `json:"priority"`
 	AssigneeID     *uint    `json:"assignee_id"`
-	DueDate        string   `json:"due_date"`
+	StartDate      string   `json:"start_date"`
+	DueDate        string   `json:"due_date"`    // YYYY-MM-DD（全天）或 RFC 3339 时刻
+	DueAllDay      *bool    `json:"due_all_day"` // 不传时根据截止时间格式推断
 	Status         string   `json:"status"`
 	EstimatedHours *float64 `json:"estimated_hours"`
 }
@@ -37,15 +46,20 @@ type UpdateTaskRequest struct {
 	Priority       string   `json:"priority"`
 	Status         string   `json:"status"`
 	AssigneeID     *uint    `json:"assignee_id"`
-	DueDate        string   `json:"due_date"`
+	StartDate      string   `json:"start_date"`
+	DueDate        string   `json:"due_date"`    // YYYY-MM-DD（全天）或 RFC 3339 时刻
+	DueAllDay      *bool    `json:"due_all_day"` // 只传该字段时转换已有截止时间
 	EstimatedHours *float64 `json:"estimated_hours"`
+	ActualHours    *float64 `json:"actual_hours"`
 }
 
 // MoveTaskRequest 移动任务请求
 type MoveTaskRequest struct {
-	NewStageID  uint `json:"new_stage_id" binding:"required"`
-	NewOrder    int  `json:"new_order"`
-	NewPosition int  `json:"new_position"`
+	NewStageID   uint              `json:"new_stage_id" binding:"required"`
+	NewOrder     int               `json:"new_order"`
+	NewPosition  int               `json:"new_position"`
+	AfterTaskID  *services.TaskRef `json:"after_task_id"`  // 插入到该任务之后（任务ID或编号）
+	BeforeTaskID *services.TaskRef `json:"before_task_id"` // 插入到该任务之前（任务ID或编号）
 }
 
 // ReorderTasksRequest 重新排序任务请求
@@ -55,8 +69,8 @@ type ReorderTasksRequest struct {
 
 // TaskOrder 任务排序
 type TaskOrder struct {
-	TaskID   uint `json:"task_id" binding:"required"`
-	Position int  `json:"position" binding:"required"`
+	TaskID   services.TaskRef `json:"task_id" binding:"required"` // 任务ID或编号
+	Position int              `json:"position"`                   // 阶段内目标位置（从0开始）
 }
 
 // CreateTask 创建任务
@@ -93,6 +107,11 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 	// 	return
 	// }
 
+	// 已归档的项目只读
+	if !utils.CheckProjectWritable(c, req.ProjectID) {
+		return
+	}
+
 	// 检查阶段是否存在
 	var stage models.Stage
 	if err := database.DB.Where("id = ? AND project_id = ?", req.StageID, req.ProjectID).First(&stage).Error; err != nil {
@@ -106,32 +125,74 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	// 检查阶段任务数量限制
-	var taskCount int64
-	if err := database.DB.Model(&models.Task{}).Where("stage_id = ?", req.StageID).Count(&taskCount).Error; err != nil {
-		utils.InternalServerError(c, "Failed to check stage task count")
+	// 检查阶段在制品上限
+	wip, ok := h.checkStageWIP(c, &stage, []*uint{req.AssigneeID}, "create", userID, 0)
+	if !ok {
 		return
 	}
 
-	if stage.MaxTasks > 0 && int(taskCount) >= stage.MaxTasks {
-		utils.BadRequest(c, "Stage has reached maximum task limit")
+	// 状态和优先级必须是项目工作流中定义的取值，未指定时使用默认值
+	workflow, err := h.WorkflowService.GetWorkflow(database.DB, req.ProjectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return
+	}
+	if req.Status == "" {
+		req.Status = workflow.DefaultStatus()
+	} else if err := workflow.ValidateStatus(req.Status); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if req.Priority == "" {
+		req.Priority = workflow.DefaultPriority()
+	} else if err := workflow.ValidatePriority(req.Priority); err != nil {
+		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	// 解析截止日期
+	// 解析截止时间，不带时区偏移的时刻按用户（或项目）时区解释
 	var dueDate *time.Time
+	dueAllDay := false
 	if req.DueDate != "" {
-		parsedDate, err := time.Parse("2006-01-02", req.DueDate)
+		loc := utils.TaskTimezone(userID, req.ProjectID)
+		parsedDate, allDay, err := utils.ParseDueDate(req.DueDate, req.DueAllDay, loc)
 		if err != nil {
-			utils.BadRequest(c, "Invalid due date format")
+			utils.BadRequest(c, "Invalid due date format: "+err.Error())
 			return
 		}
-		dueDate = &parsedDate
+		dueDate, dueAllDay = &parsedDate, allDay
 	}
 
-	// 获取当前阶段的最大位置
-	var maxPosition int
-	database.DB.Model(&models.Task{}).Where("stage_id = ?", req.StageID).Select("COALESCE(MAX(position), 0)").Scan(&maxPosition)
+	// 解析开始日期
+	var startDate *time.Time
+	if req.StartDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			utils.BadRequest(c, "Invalid start date format")
+			return
+		}
+		startDate = &parsedDate
+	}
+	if startDate != nil && dueDate != nil && startDate.After(h.dueDay(*dueDate, dueAllDay, userID, req.ProjectID)) {
+		utils.BadRequest(c, "Start date must not be later than due date")
+		return
+	}
+
+	// 开始事务（分配任务编号和创建任务需要在同一事务中完成）
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 新任务追加到阶段末尾
+	rank, err := h.RankService.RankForAppend(tx, req.StageID)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to compute task position: "+err.Error())
+		return
+	}
 
 	// 创建任务
 	task := models.Task{
@@ -141,27 +202,43 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		Description:    req.Description,
 		Priority:       req.Priority,
 		AssigneeID:     req.AssigneeID,
+		StartDate:      startDate,
 		DueDate:        dueDate,
+		DueAllDay:      dueAllDay,
 		EstimatedHours: req.EstimatedHours,
 		Status:         req.Status,
-		Position:       maxPosition + 1, // 设置位置为当前最大值+1
-		CreatedBy:      userID,          // 设置创建者ID
+		Rank:           rank,
+		CreatedBy:      userID, // 设置创建者ID
 	}
-	if task.Priority == "" {
-		task.Priority = "P2" // 默认优先级
+
+	// 分配项目内的任务编号
+	if err := h.KeyService.AssignTaskKey(tx, &task); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to assign task key: "+err.Error())
+		return
 	}
 
-	if err := database.DB.Create(&task).Error; err != nil {
+	if err := tx.Create(&task).Error; err != nil {
+		tx.Rollback()
 		utils.InternalServerError(c, "Failed to create task: "+err.Error())
 		return
 	}
 
 	// 验证任务ID是否被正确设置
 	if task.ID == 0 {
+		tx.Rollback()
 		utils.InternalServerError(c, "Failed to get task ID after creation. Please check database table structure.")
 		return
 	}
 
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	h.recordWIPWarnings(wip, task.ID)
+
 	// 记录任务创建活动
 	if h.ActivityService != nil {
 		if err := h.ActivityService.LogTaskCreated(&task, userID, c); err != nil {
@@ -169,81 +246,49 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		}
 	}
 
+	// 更新搜索索引
+	h.indexTask(&task)
+
+	// 执行自动化规则
+	h.dispatchAutomation(services.AutomationEvent{
+		Trigger:   models.TriggerTaskCreated,
+		ProjectID: task.ProjectID,
+		TaskID:    task.ID,
+		ActorID:   userID,
+	})
+
 	// 重新加载任务信息
 	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, task.ID).Error; err != nil {
 		utils.InternalServerError(c, "Failed to reload task data")
 		return
 	}
 
-	utils.Success(c, gin.H{
+	utils.Success(c, withWIPWarnings(gin.H{
 		"task":    task,
 		"message": "Task created successfully",
-	})
+	}, wip))
 }
 
 // GetTasks 获取任务列表
+// 分页返回，未传 limit 时每页 taskListDefaultLimit 条，limit 最大为 taskListMaxLimit；
+// 通过 pagination.next_cursor 获取后续页面，需要整个看板时使用看板接口
 func (h *TaskHandler) GetTasks(c *gin.Context) {
-	userID := c.MustGet("user_id").(uint)
-	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
-	if err != nil {
-		utils.BadRequest(c, "Invalid project ID")
-		return
-	}
-
-	// 验证项目ID是否有效
-	if projectID == 0 {
-		utils.BadRequest(c, "Invalid project ID: project ID cannot be 0")
+	query, projectID, ok := projectTaskQuery(c)
+	if !ok {
 		return
 	}
 
-	// 检查项目是否存在
-	var project models.Project
-	if err := database.DB.Where("id = ? AND status = ?", projectID, models.ProjectStatusActive).First(&project).Error; err != nil {
-		utils.NotFound(c, "Project not found")
+	// 获取任务列表，默认按阶段和阶段内顺序排序
+	tasks, pageInfo, ok := fetchTaskPage(c, query, c.Query("sort"), taskListDefaultLimit, taskListMaxLimit, false)
+	if !ok {
 		return
 	}
 
-	// 单机版：检查用户是否是项目成员即可
-	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
-		utils.Forbidden(c, "Access denied to this project")
-		return
-	}
-
-	// 获取查询参数
-	stageID := c.Query("stage_id")
-	assigneeID := c.Query("assignee_id")
-	priority := c.Query("priority")
-	status := c.Query("status")
-
-	// 构建查询
-	query := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").
-		Where("project_id = ?", projectID)
-
-	if stageID != "" {
-		query = query.Where("stage_id = ?", stageID)
-	}
-	if assigneeID != "" {
-		query = query.Where("assignee_id = ?", assigneeID)
-	}
-	if priority != "" {
-		query = query.Where("priority = ?", priority)
-	}
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-
-	// 获取任务列表，按阶段和位置排序
-	var tasks []models.Task
-	if err := query.Order("stage_id ASC, position ASC").Find(&tasks).Error; err != nil {
-		utils.InternalServerError(c, "Failed to fetch tasks")
-		return
-	}
-
-	utils.Success(c, gin.H{
+	utils.Success(c, withListTotal(c, gin.H{
 		"project_id": projectID,
 		"tasks":      tasks,
-		"total":      len(tasks),
-	})
+		"pagination": pageInfo,
+	}, len(tasks), pageInfo))
 }
 
 // UpdateTask 更新任务
@@ -272,6 +317,11 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	// 	return
 	// }
 
+	// 已归档的项目只读
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
 	var req UpdateTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "Invalid request data: "+err.Error())
@@ -290,26 +340,88 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 	if req.Description != "" {
 		updates["description"] = req.Description
 	}
-	if req.Priority != "" {
-		updates["priority"] = req.Priority
-	}
-	if req.Status != "" {
-		updates["status"] = req.Status
+	var workflow *services.Workflow
+	if req.Priority != "" || req.Status != "" {
+		// 状态和优先级必须是项目工作流中定义的取值
+		workflow, err = h.WorkflowService.GetWorkflow(database.DB, task.ProjectID)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to load project workflow")
+			return
+		}
+		if req.Priority != "" {
+			if err := workflow.ValidatePriority(req.Priority); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+			updates["priority"] = req.Priority
+		}
+		if req.Status != "" {
+			if err := workflow.ValidateStatus(req.Status); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+			updates["status"] = req.Status
+		}
 	}
 	if req.AssigneeID != nil {
 		updates["assignee_id"] = req.AssigneeID
 	}
+	dueAllDay := task.DueAllDay
 	if req.DueDate != "" {
-		parsedDate, err := time.Parse("2006-01-02", req.DueDate)
+		loc := utils.TaskTimezone(userID, task.ProjectID)
+		parsedDate, allDay, err := utils.ParseDueDate(req.DueDate, req.DueAllDay, loc)
 		if err != nil {
-			utils.BadRequest(c, "Invalid due date format")
+			utils.BadRequest(c, "Invalid due date format: "+err.Error())
 			return
 		}
 		updates["due_date"] = parsedDate
+		dueAllDay = allDay
+	} else if req.DueAllDay != nil && *req.DueAllDay != task.DueAllDay && task.DueDate != nil {
+		// 只修改全天标记时，转换已有的截止时间
+		loc := utils.TaskTimezone(userID, task.ProjectID)
+		updates["due_date"] = utils.ConvertDueDate(*task.DueDate, *req.DueAllDay, loc)
+		dueAllDay = *req.DueAllDay
+	}
+	if dueAllDay != task.DueAllDay {
+		updates["due_all_day"] = dueAllDay
+	}
+	if req.StartDate != "" {
+		parsedDate, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			utils.BadRequest(c, "Invalid start date format")
+			return
+		}
+		updates["start_date"] = parsedDate
+	}
+
+	// 校验开始日期不晚于截止日期
+	effectiveStart, effectiveDue := task.StartDate, task.DueDate
+	if v, ok := updates["start_date"].(time.Time); ok {
+		effectiveStart = &v
 	}
+	if v, ok := updates["due_date"].(time.Time); ok {
+		effectiveDue = &v
+	}
+	if effectiveStart != nil && effectiveDue != nil && effectiveStart.After(h.dueDay(*effectiveDue, dueAllDay, userID, task.ProjectID)) {
+		utils.BadRequest(c, "Start date must not be later than due date")
+		return
+	}
+
 	if req.EstimatedHours != nil {
 		updates["estimated_hours"] = req.EstimatedHours
 	}
+	if req.ActualHours != nil {
+		updates["actual_hours"] = req.ActualHours
+	}
+
+	// 改派负责人时检查阶段内负责人的在制品上限
+	var wip *services.WIPResult
+	if req.AssigneeID != nil && task.Stage != nil && !utils.SameUserID(task.AssigneeID, req.AssigneeID) {
+		var ok bool
+		if wip, ok = h.checkAssigneeWIP(c, task.Stage, req.AssigneeID, userID, task.ID); !ok {
+			return
+		}
+	}
 
 	// 执行更新
 	if len(updates) > 0 {
@@ -337,12 +449,22 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 					}
 				case "due_date":
 					if originalTask.DueDate != nil {
-						oldValue = originalTask.DueDate.Format("2006-01-02")
+						oldValue = utils.FormatDueDate(*originalTask.DueDate, originalTask.DueAllDay)
+					}
+				case "due_all_day":
+					oldValue = strconv.FormatBool(originalTask.DueAllDay)
+				case "start_date":
+					if originalTask.StartDate != nil {
+						oldValue = originalTask.StartDate.Format("2006-01-02")
 					}
 				case "estimated_hours":
 					if originalTask.EstimatedHours != nil {
 						oldValue = strconv.FormatFloat(*originalTask.EstimatedHours, 'f', 2, 64)
 					}
+				case "actual_hours":
+					if originalTask.ActualHours != nil {
+						oldValue = strconv.FormatFloat(*originalTask.ActualHours, 'f', 2, 64)
+					}
 				}
 
 				var newValueStr string
@@ -354,7 +476,13 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 						newValueStr = strconv.FormatUint(uint64(*v), 10)
 					}
 				case time.Time:
-					newValueStr = v.Format("2006-01-02")
+					if field == "due_date" {
+						newValueStr = utils.FormatDueDate(v, dueAllDay)
+					} else {
+						newValueStr = v.Format("2006-01-02")
+					}
+				case bool:
+					newValueStr = strconv.FormatBool(v)
 				case *float64:
 					if v != nil {
 						newValueStr = strconv.FormatFloat(*v, 'f', 2, 64)
@@ -372,23 +500,67 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 		}
 	}
 
-	// 如果任务状态变为已完成，记录完成时间
-	if req.Status == "done" {
-		if err := database.DB.Model(&task).Update("completed_at", time.Now()).Error; err != nil {
-			log.Printf("Failed to update completed_at for task %d: %v", taskID, err)
+	// 状态变化导致任务完成或重新打开时，记录或清除完成时间（位于已完成阶段的任务始终视为完成）
+	if req.Status != "" && req.Status != originalTask.Status {
+		wasDone := services.IsTaskDone(workflow, originalTask.Status, task.Stage)
+		isDone := services.IsTaskDone(workflow, req.Status, task.Stage)
+		if wasDone != isDone {
+			entry := &services.StageEntry{Completion: services.CompletionReopened}
+			var completedAt interface{}
+			if isDone {
+				entry.Completion = services.CompletionCompleted
+				completedAt = time.Now()
+			}
+			if err := database.DB.Model(&task).Update("completed_at", completedAt).Error; err != nil {
+				log.Printf("Failed to update completed_at for task %d: %v", taskID, err)
+			}
+			h.logStageEntry(&task, userID, entry, c)
 		}
 	}
 
+	// 负责人变化时执行自动化规则
+	if _, ok := updates["assignee_id"]; ok && !utils.SameUserID(originalTask.AssigneeID, req.AssigneeID) {
+		h.dispatchAutomation(services.AutomationEvent{
+			Trigger:   models.TriggerAssigneeChanged,
+			ProjectID: task.ProjectID,
+			TaskID:    task.ID,
+			ActorID:   userID,
+		})
+	}
+
 	// 重新加载任务信息
 	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, taskID).Error; err != nil {
 		utils.InternalServerError(c, "Failed to reload task data")
 		return
 	}
 
-	utils.Success(c, gin.H{
+	// 更新搜索索引
+	h.indexTask(&task)
+
+	h.recordWIPWarnings(wip, task.ID)
+
+	utils.Success(c, withWIPWarnings(gin.H{
 		"task":    task,
 		"message": "Task updated successfully",
-	})
+	}, wip))
+}
+
+// dispatchAutomation 在事务提交后执行匹配事件的自动化规则，返回执行的规则数
+func (h *TaskHandler) dispatchAutomation(event services.AutomationEvent) int {
+	if h.AutomationService == nil {
+		return 0
+	}
+	return h.AutomationService.Dispatch(database.DB, event)
+}
+
+// indexTask 更新任务的搜索索引，失败时只记录日志
+func (h *TaskHandler) indexTask(task *models.Task) {
+	if h.SearchService == nil {
+		return
+	}
+	if err := h.SearchService.IndexTask(database.DB, task); err != nil {
+		log.Printf("Failed to index task %d: %v", task.ID, err)
+	}
 }
 
 // DeleteTask 删除任务
@@ -417,6 +589,11 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 	// 	return
 	// }
 
+	// 已归档的项目只读
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
 	// 检查阶段是否允许删除任务
 	if !task.Stage.AllowTaskDeletion {
 		utils.BadRequest(c, "Task deletion is not allowed in this stage")
@@ -440,6 +617,21 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
+	// 清理任务依赖关系
+	if err := database.DB.Where("task_id = ? OR depends_on_task_id = ?", task.ID, task.ID).Delete(&models.TaskDependency{}).Error; err != nil {
+		log.Printf("Failed to delete dependencies of task %d: %v", task.ID, err)
+	}
+	if err := services.NewLabelService().RemoveTaskLabels(database.DB, task.ID); err != nil {
+		log.Printf("Failed to delete labels of task %d: %v", task.ID, err)
+	}
+
+	// 删除搜索索引
+	if h.SearchService != nil {
+		if err := h.SearchService.RemoveTask(database.DB, task.ID); err != nil {
+			log.Printf("Failed to remove task %d from search index: %v", task.ID, err)
+		}
+	}
+
 	utils.Success(c, gin.H{"message": "Task deleted successfully"})
 }
 
@@ -459,6 +651,14 @@ func (h *TaskHandler) MoveTask(c *gin.Context) {
 		utils.BadRequest(c, "Invalid request data: "+err.Error())
 		return
 	}
+	afterTaskID, ok := resolveOptionalTaskRef(c, req.AfterTaskID)
+	if !ok {
+		return
+	}
+	beforeTaskID, ok := resolveOptionalTaskRef(c, req.BeforeTaskID)
+	if !ok {
+		return
+	}
 
 	log.Printf("🎯 收到移动任务请求 - 任务ID: %d, 目标阶段ID: %d, 目标位置: %d", taskID, req.NewStageID, req.NewPosition)
 
@@ -479,6 +679,16 @@ func (h *TaskHandler) MoveTask(c *gin.Context) {
 	// 	return
 	// }
 
+	// 已归档的项目只读
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	if task.ArchivedAt != nil {
+		utils.BadRequest(c, "Task is archived, restore it before moving")
+		return
+	}
+
 	// 检查目标阶段是否存在
 	var newStage models.Stage
 	log.Printf("🔍 查找目标阶段 - 阶段ID: %d, 项目ID: %d", req.NewStageID, task.ProjectID)
@@ -495,16 +705,15 @@ func (h *TaskHandler) MoveTask(c *gin.Context) {
 		return
 	}
 
-	// 检查目标阶段任务数量限制
-	if newStage.MaxTasks > 0 {
-		var taskCount int64
-		if err := database.DB.Model(&models.Task{}).Where("stage_id = ?", req.NewStageID).Count(&taskCount).Error; err != nil {
-			utils.InternalServerError(c, "Failed to check target stage task count")
-			return
-		}
-
-		if int(taskCount) >= newStage.MaxTasks {
-			utils.BadRequest(c, "Target stage has reached maximum task limit")
+	// 跨阶段移动时检查流转规则和目标阶段在制品上限
+	stageChanged := task.StageID != req.NewStageID
+	if stageChanged && !h.checkTransitions(c, task.ProjectID, []*models.Task{&task}, req.NewStageID, userID) {
+		return
+	}
+	var wip *services.WIPResult
+	if stageChanged {
+		var ok bool
+		if wip, ok = h.checkStageWIP(c, &newStage, []*uint{task.AssigneeID}, "move", userID, task.ID); !ok {
 			return
 		}
 	}
@@ -513,6 +722,17 @@ func (h *TaskHandler) MoveTask(c *gin.Context) {
 	oldStageID := task.StageID
 	oldStageName := task.Stage.Name
 
+	// 跨阶段移动时按目标阶段设置同步状态和完成时间
+	var entry *services.StageEntry
+	if stageChanged {
+		workflow, err := h.WorkflowService.GetWorkflow(database.DB, task.ProjectID)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to load project workflow")
+			return
+		}
+		entry = services.ComputeStageEntry(workflow, &task, task.Stage, &newStage, time.Now())
+	}
+
 	// 开始事务
 	tx := database.DB.Begin()
 	defer func() {
@@ -521,72 +741,30 @@ func (h *TaskHandler) MoveTask(c *gin.Context) {
 		}
 	}()
 
-	// 处理任务位置逻辑
-	newPosition := req.NewPosition
+	// 计算新的排序键：指定了相邻任务时插入到两者之间，否则按 new_position 插入（小于0表示追加到末尾）
 	log.Printf("🔄 移动任务位置处理 - 任务ID: %d, 目标阶段: %d, 指定位置: %d", taskID, req.NewStageID, req.NewPosition)
-	log.Printf("🔄 请求参数详情 - NewStageID: %d (类型: %T), NewPosition: %d (类型: %T)", req.NewStageID, req.NewStageID, req.NewPosition, req.NewPosition)
-
-	if newPosition < 0 {
-		// 如果没有指定位置，获取目标阶段的最大位置+1
-		var maxPosition int
-		tx.Model(&models.Task{}).Where("stage_id = ?", req.NewStageID).Select("COALESCE(MAX(position), 0)").Scan(&maxPosition)
-		newPosition = maxPosition + 1
-		log.Printf("🔄 自动计算位置: %d (最大位置: %d)", newPosition, maxPosition)
-	} else {
-		// 如果指定了位置，需要调整目标阶段其他任务的位置
-		// 将目标位置及之后的任务位置+1（使用 SQLite 兼容的 SQL）
-		adjustSQL := "UPDATE tasks SET position = position + 1, updated_at = datetime('now') WHERE stage_id = ? AND position >= ?"
-		adjustResult := tx.Exec(adjustSQL, req.NewStageID, newPosition)
-		if adjustResult.Error != nil {
-			log.Printf("❌ 调整其他任务位置失败: %v", adjustResult.Error)
-		} else {
-			log.Printf("🔄 调整了 %d 个任务的位置", adjustResult.RowsAffected)
-		}
+	newRank, err := h.RankService.RankForMove(tx, req.NewStageID, task.ID, afterTaskID, beforeTaskID, req.NewPosition)
+	if err != nil {
+		tx.Rollback()
+		utils.BadRequest(c, "Failed to compute task position: "+err.Error())
+		return
 	}
 
-	// 更新任务阶段和位置 - 使用明确的字段更新
-	log.Printf("🔄 更新任务 - ID: %d, 新阶段: %d, 新位置: %d", taskID, req.NewStageID, newPosition)
-	log.Printf("🔄 更新前任务状态 - 当前阶段: %d, 当前位置: %d", task.StageID, task.Position)
-
-	// 使用原生SQL确保更新的原子性和可见性（SQLite 使用 datetime('now') 而不是 NOW()）
-	updateSQL := "UPDATE tasks SET stage_id = ?, position = ?, updated_at = datetime('now') WHERE id = ?"
-	result := tx.Exec(updateSQL, req.NewStageID, newPosition, taskID)
-
-	if result.Error != nil {
-		log.Printf("❌ 更新任务失败: %v", result.Error)
+	// 只更新被移动的任务，其他任务的排序键保持不变
+	log.Printf("🔄 更新任务 - ID: %d, 新阶段: %d, 新排序键: %s", taskID, req.NewStageID, newRank)
+	updateSQL := "UPDATE tasks SET stage_id = ?, rank = ?, updated_at = datetime('now') WHERE id = ?"
+	if err := tx.Exec(updateSQL, req.NewStageID, newRank, taskID).Error; err != nil {
+		log.Printf("❌ 更新任务失败: %v", err)
 		tx.Rollback()
-		utils.InternalServerError(c, "Failed to move task: "+result.Error.Error())
+		utils.InternalServerError(c, "Failed to move task: "+err.Error())
 		return
 	}
-
-	// 检查是否真的更新了行
-	if result.RowsAffected == 0 {
-		log.Printf("⚠️ 警告：没有行被更新！可能的原因：")
-		log.Printf("   - 任务ID不存在: %d", taskID)
-		log.Printf("   - 数据库约束问题")
-		log.Printf("   - 并发更新冲突")
-
-		// 尝试直接查询任务是否存在
-		var checkTask models.Task
-		if err := tx.First(&checkTask, taskID).Error; err != nil {
-			log.Printf("❌ 任务不存在: %v", err)
-			tx.Rollback()
-			utils.NotFound(c, "Task not found")
-			return
-		}
-		log.Printf("🔍 任务存在，当前数据: StageID=%d, Position=%d", checkTask.StageID, checkTask.Position)
-
-		// 如果任务存在但没有更新，可能是数据没有变化
-		if checkTask.StageID == req.NewStageID && checkTask.Position == newPosition {
-			log.Printf("ℹ️ 数据没有变化，跳过更新")
-		} else {
-			log.Printf("❌ 数据更新失败，可能存在并发问题")
+	if entry != nil && len(entry.Updates) > 0 {
+		if err := tx.Model(&models.Task{}).Where("id = ?", taskID).Updates(entry.Updates).Error; err != nil {
 			tx.Rollback()
-			utils.InternalServerError(c, "Task update failed due to concurrent modification")
+			utils.InternalServerError(c, "Failed to apply stage settings: "+err.Error())
 			return
 		}
-	} else {
-		log.Printf("✅ 成功更新了 %d 行", result.RowsAffected)
 	}
 
 	// 提交事务
@@ -597,59 +775,25 @@ func (h *TaskHandler) MoveTask(c *gin.Context) {
 	}
 
 	log.Printf("✅ 任务移动事务提交成功 - 任务ID: %d", taskID)
+	h.recordWIPWarnings(wip, task.ID)
 
-	// 重新加载任务信息 - 使用新的变量避免GORM缓存问题
+	// 重新加载任务信息
 	var reloadedTask models.Task
-
-	// 强制从数据库重新读取数据，避免缓存
-	log.Printf("🔄 强制从数据库重新加载任务数据 - 任务ID: %d", taskID)
-	if err := database.DB.Set("gorm:query_hint", "/*+ USE_INDEX(tasks, PRIMARY) */").
-		Preload("Stage").Preload("Project").Preload("Assignee").
+	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").
 		First(&reloadedTask, taskID).Error; err != nil {
 		log.Printf("❌ 重新加载任务数据失败: %v", err)
 		utils.InternalServerError(c, "Failed to reload task data")
 		return
 	}
-
-	// 使用重新加载的任务数据
 	task = reloadedTask
 
-	// 验证重新加载的数据是否正确
-	log.Printf("🔍 重新加载的任务数据验证 - 任务ID: %d, 当前阶段: %d, 期望阶段: %d",
-		task.ID, task.StageID, req.NewStageID)
-
-	if task.StageID != req.NewStageID {
-		log.Printf("❌ 警告：重新加载的任务数据不正确！数据库更新可能失败")
-		log.Printf("❌ 任务 %d 的阶段应该是 %d，但实际是 %d", task.ID, req.NewStageID, task.StageID)
-
-		// 额外验证：直接查询数据库中的原始数据
-		var dbStageID uint
-		var dbPosition int
-		err := database.DB.Raw("SELECT stage_id, position FROM tasks WHERE id = ?", taskID).
-			Row().Scan(&dbStageID, &dbPosition)
-		if err != nil {
-			log.Printf("❌ 直接数据库查询失败: %v", err)
-			utils.InternalServerError(c, "Failed to verify task update")
-			return
-		} else {
-			log.Printf("🔍 直接数据库查询结果 - StageID: %d, Position: %d", dbStageID, dbPosition)
-			if dbStageID == req.NewStageID && dbPosition == newPosition {
-				log.Printf("✅ 直接查询证实数据库已正确更新，使用数据库数据")
-				// 使用数据库中的实际数据
-				task.StageID = dbStageID
-				task.Position = dbPosition
-			} else {
-				log.Printf("❌ 直接查询确认数据库更新失败")
-				utils.InternalServerError(c, "Task move verification failed")
-				return
-			}
-		}
-	} else {
-		log.Printf("✅ 重新加载的任务数据验证通过")
+	// 返回任务在阶段内的实际位置（从0开始）
+	if index, err := h.RankService.IndexOfRank(database.DB, task.StageID, task.Rank); err == nil {
+		task.Position = index
 	}
 
-	// 记录任务移动活动
-	if h.ActivityService != nil {
+	// 记录任务移动活动（阶段内排序不记录）
+	if stageChanged && h.ActivityService != nil {
 		if err := h.ActivityService.LogTaskMoved(
 			task.ID, userID, task.ProjectID,
 			oldStageID, req.NewStageID,
@@ -659,11 +803,31 @@ func (h *TaskHandler) MoveTask(c *gin.Context) {
 			log.Printf("Failed to log task move activity: %v", err)
 		}
 	}
+	if entry != nil {
+		h.logStageEntry(&task, userID, entry, c)
+	}
 
-	utils.Success(c, gin.H{
+	// 跨阶段移动时执行自动化规则，规则修改了任务时返回修改后的数据
+	if stageChanged && h.dispatchAutomation(services.AutomationEvent{
+		Trigger:   models.TriggerTaskMoved,
+		ProjectID: task.ProjectID,
+		TaskID:    task.ID,
+		ActorID:   userID,
+		StageID:   req.NewStageID,
+	}) > 0 {
+		if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, taskID).Error; err != nil {
+			utils.InternalServerError(c, "Failed to reload task data")
+			return
+		}
+		if index, err := h.RankService.IndexOfRank(database.DB, task.StageID, task.Rank); err == nil {
+			task.Position = index
+		}
+	}
+
+	utils.Success(c, withWIPWarnings(gin.H{
 		"task":    task,
 		"message": "Task moved successfully",
-	})
+	}, wip))
 }
 
 // ReorderTasks 重新排序任务
@@ -675,6 +839,26 @@ func (h *TaskHandler) ReorderTasks(c *gin.Context) {
 		return
 	}
 
+	// 已归档项目中的任务不能调整顺序
+	refs := make([]services.TaskRef, len(req.TaskOrders))
+	for i, taskOrder := range req.TaskOrders {
+		refs[i] = taskOrder.TaskID
+	}
+	taskIDs, ok := resolveTaskRefs(c, refs...)
+	if !ok {
+		return
+	}
+	var projectIDs []uint
+	if err := database.DB.Model(&models.Task{}).Where("id IN (?)", taskIDs).Pluck("DISTINCT project_id", &projectIDs).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch tasks")
+		return
+	}
+	for _, projectID := range projectIDs {
+		if !utils.CheckProjectWritable(c, projectID) {
+			return
+		}
+	}
+
 	// 开始事务
 	tx := database.DB.Begin()
 	defer func() {
@@ -683,12 +867,25 @@ func (h *TaskHandler) ReorderTasks(c *gin.Context) {
 		}
 	}()
 
-	// 批量更新任务位置
-	for _, taskOrder := range req.TaskOrders {
-		if err := tx.Model(&models.Task{}).Where("id = ?", taskOrder.TaskID).
-			Update("position", taskOrder.Position).Error; err != nil {
+	// 按阶段分组，每个任务只写入新的排序键
+	stageOrders := make(map[uint]map[uint]int)
+	for i, taskOrder := range req.TaskOrders {
+		var task models.Task
+		if err := tx.Select("id, stage_id").First(&task, taskIDs[i]).Error; err != nil {
+			tx.Rollback()
+			utils.NotFound(c, "Task not found")
+			return
+		}
+		if stageOrders[task.StageID] == nil {
+			stageOrders[task.StageID] = make(map[uint]int)
+		}
+		stageOrders[task.StageID][task.ID] = taskOrder.Position
+	}
+
+	for stageID, positions := range stageOrders {
+		if err := h.RankService.ReorderStage(tx, stageID, positions); err != nil {
 			tx.Rollback()
-			utils.InternalServerError(c, "Failed to reorder tasks")
+			utils.InternalServerError(c, "Failed to reorder tasks: "+err.Error())
 			return
 		}
 	}
@@ -721,8 +918,9 @@ func (h *TaskHandler) GetCompletedTasksStats(c *gin.Context) {
 
 	// 获取已完成任务数量
 	var completedCount int64
+	doneCondition := services.TaskStatusCategoryCondition(models.StatusCategoryDone)
 	if err := database.DB.Model(&models.Task{}).
-		Where("project_id = ? AND status = ?", projectID, "done").
+		Where("project_id = ? AND "+doneCondition, projectID).
 		Count(&completedCount).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get completed tasks count")
 		return
@@ -732,7 +930,7 @@ func (h *TaskHandler) GetCompletedTasksStats(c *gin.Context) {
 	var recentCompletedCount int64
 	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
 	if err := database.DB.Model(&models.Task{}).
-		Where("project_id = ? AND status = ? AND completed_at >= ?", projectID, "done", sevenDaysAgo).
+		Where("project_id = ? AND "+doneCondition+" AND completed_at >= ?", projectID, sevenDaysAgo).
 		Count(&recentCompletedCount).Error; err != nil {
 		utils.InternalServerError(c, "Failed to get recent completed tasks count")
 		return
@@ -745,3 +943,12 @@ func (h *TaskHandler) GetCompletedTasksStats(c *gin.Context) {
 		"total_tasks":            completedCount, // 这里可以添加总任务数
 	})
 }
+
+// dueDay 返回截止时间在用户时区中所在的日期，用于和开始日期比较
+func (h *TaskHandler) dueDay(due time.Time, allDay bool, userID, projectID uint) time.Time {
+	if allDay {
+		return due
+	}
+	loc := utils.TaskTimezone(userID, projectID)
+	return utils.DueDay(due, false, loc)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"project-manager-backend/services"
 	"project-manager-backend/utils"
 	"strconv"
@@ -41,36 +42,25 @@ func (h *TaskActivityHandler) GetTaskActivities(c *gin.Context) {
 	}
 
 	// 获取分页参数
-	limitStr := c.DefaultQuery("limit", "20")
-	offsetStr := c.DefaultQuery("offset", "0")
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 20
-	}
-	if limit > 100 {
-		limit = 100 // 限制最大数量
-	}
-
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0
+	page, offset, ok := activityPageParams(c, 20, 100)
+	if !ok {
+		return
 	}
 
 	// 获取活动记录
-	activities, err := h.ActivityService.GetTaskActivities(uint(taskID), limit, offset)
+	activities, pageInfo, err := h.ActivityService.GetTaskActivities(uint(taskID), page, offset)
 	if err != nil {
-		utils.InternalServerErrorSafe(c, "获取任务活动记录失败", err)
+		activityListError(c, "获取任务活动记录失败", err)
 		return
 	}
 
-	utils.Success(c, gin.H{
+	utils.Success(c, withListTotal(c, gin.H{
 		"task_id":    taskID,
 		"activities": activities,
-		"total":      len(activities),
-		"limit":      limit,
+		"limit":      pageInfo.Limit,
 		"offset":     offset,
-	})
+		"pagination": pageInfo,
+	}, len(activities), pageInfo))
 }
 
 // GetProjectActivities 获取项目活动记录
@@ -94,36 +84,25 @@ func (h *TaskActivityHandler) GetProjectActivities(c *gin.Context) {
 	}
 
 	// 获取分页参数
-	limitStr := c.DefaultQuery("limit", "50")
-	offsetStr := c.DefaultQuery("offset", "0")
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 50
-	}
-	if limit > 200 {
-		limit = 200 // 限制最大数量
-	}
-
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0
+	page, offset, ok := activityPageParams(c, 50, 200)
+	if !ok {
+		return
 	}
 
 	// 获取活动记录
-	activities, err := h.ActivityService.GetProjectActivities(uint(projectID), limit, offset)
+	activities, pageInfo, err := h.ActivityService.GetProjectActivities(uint(projectID), page, offset)
 	if err != nil {
-		utils.InternalServerErrorSafe(c, "获取项目活动记录失败", err)
+		activityListError(c, "获取项目活动记录失败", err)
 		return
 	}
 
-	utils.Success(c, gin.H{
+	utils.Success(c, withListTotal(c, gin.H{
 		"project_id": projectID,
 		"activities": activities,
-		"total":      len(activities),
-		"limit":      limit,
+		"limit":      pageInfo.Limit,
 		"offset":     offset,
-	})
+		"pagination": pageInfo,
+	}, len(activities), pageInfo))
 }
 
 // GetUserActivities 获取用户活动记录
@@ -153,36 +132,49 @@ func (h *TaskActivityHandler) GetUserActivities(c *gin.Context) {
 	}
 
 	// 获取分页参数
-	limitStr := c.DefaultQuery("limit", "30")
-	offsetStr := c.DefaultQuery("offset", "0")
-
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 30
-	}
-	if limit > 100 {
-		limit = 100 // 限制最大数量
-	}
-
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil || offset < 0 {
-		offset = 0
+	page, offset, ok := activityPageParams(c, 30, 100)
+	if !ok {
+		return
 	}
 
 	// 获取活动记录
-	activities, err := h.ActivityService.GetUserActivities(targetUserID, limit, offset)
+	activities, pageInfo, err := h.ActivityService.GetUserActivities(targetUserID, page, offset)
 	if err != nil {
-		utils.InternalServerErrorSafe(c, "获取用户活动记录失败", err)
+		activityListError(c, "获取用户活动记录失败", err)
 		return
 	}
 
-	utils.Success(c, gin.H{
+	utils.Success(c, withListTotal(c, gin.H{
 		"user_id":    targetUserID,
 		"activities": activities,
-		"total":      len(activities),
-		"limit":      limit,
+		"limit":      pageInfo.Limit,
 		"offset":     offset,
-	})
+		"pagination": pageInfo,
+	}, len(activities), pageInfo))
+}
+
+// activityPageParams 解析活动记录的分页参数，offset 仅在未传游标时生效
+func activityPageParams(c *gin.Context, defaultLimit, maxLimit int) (*utils.PageParams, int, bool) {
+	page, err := utils.ParsePageParams(c, services.ActivitySort, defaultLimit, maxLimit)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return nil, 0, false
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 || page.HasCursor() {
+		offset = 0
+	}
+	return page, offset, true
+}
+
+// activityListError 游标与列表不匹配时返回 400，其余错误返回 500
+func activityListError(c *gin.Context, message string, err error) {
+	if errors.Is(err, utils.ErrInvalidCursor) {
+		utils.BadRequest(c, "Invalid pagination: "+utils.ErrInvalidCursor.Error())
+		return
+	}
+	utils.InternalServerErrorSafe(c, message, err)
 }
 
 // GetActivityStats 获取活动统计
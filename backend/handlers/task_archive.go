@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"log"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnarchiveTaskRequest 恢复已归档任务请求
+type UnarchiveTaskRequest struct {
+	StageID uint `json:"stage_id"` // 恢复到的阶段，默认为归档前所在阶段（该阶段已删除时必填）
+}
+
+// ArchiveTask 归档任务，任务保留所在阶段但不再出现在看板和列表中
+func (h *TaskHandler) ArchiveTask(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return
+	}
+
+	var task models.Task
+	if err := database.DB.First(&task, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	if !utils.CanManageTasks(userID, task.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to archive task")
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	if task.ArchivedAt != nil {
+		utils.BadRequest(c, "Task is already archived")
+		return
+	}
+
+	if err := services.NewTaskArchiveService().ArchiveTasks(database.DB, []uint{task.ID}, time.Now()); err != nil {
+		utils.InternalServerError(c, "Failed to archive task")
+		return
+	}
+
+	if h.ActivityService != nil {
+		if err := h.ActivityService.LogTaskArchived(task.ID, userID, task.ProjectID, true, c); err != nil {
+			log.Printf("Failed to log task archive activity: %v", err)
+		}
+	}
+
+	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, task.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload task data")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"task":    task,
+		"message": "Task archived successfully",
+	})
+}
+
+// UnarchiveTask 恢复已归档的任务，追加到目标阶段末尾
+// 恢复到其他阶段时与移动任务相同，按目标阶段设置同步状态和完成时间；恢复时检查在制品上限
+func (h *TaskHandler) UnarchiveTask(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return
+	}
+
+	var req UnarchiveTaskRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, "Invalid request data: "+err.Error())
+			return
+		}
+	}
+
+	var task models.Task
+	if err := database.DB.First(&task, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	if !utils.CanManageTasks(userID, task.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to restore task")
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	if task.ArchivedAt == nil {
+		utils.BadRequest(c, "Task is not archived")
+		return
+	}
+
+	// 归档前所在的阶段可能已被删除
+	var fromStage *models.Stage
+	var original models.Stage
+	if err := database.DB.Where("id = ? AND project_id = ?", task.StageID, task.ProjectID).First(&original).Error; err == nil {
+		fromStage = &original
+	}
+
+	targetStageID := req.StageID
+	if targetStageID == 0 {
+		if fromStage == nil {
+			utils.BadRequest(c, "The task's stage no longer exists, stage_id is required")
+			return
+		}
+		targetStageID = fromStage.ID
+	}
+
+	var targetStage models.Stage
+	if err := database.DB.Where("id = ? AND project_id = ?", targetStageID, task.ProjectID).First(&targetStage).Error; err != nil {
+		utils.NotFound(c, "Target stage not found")
+		return
+	}
+
+	wip, ok := h.checkStageWIP(c, &targetStage, []*uint{task.AssigneeID}, "unarchive", userID, task.ID)
+	if !ok {
+		return
+	}
+
+	var entry *services.StageEntry
+	if fromStage == nil || fromStage.ID != targetStage.ID {
+		workflow, err := h.WorkflowService.GetWorkflow(database.DB, task.ProjectID)
+		if err != nil {
+			utils.InternalServerError(c, "Failed to load project workflow")
+			return
+		}
+		entry = services.ComputeStageEntry(workflow, &task, fromStage, &targetStage, time.Now())
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rank, err := h.RankService.RankForAppend(tx, targetStage.ID)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to compute task position: "+err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{
+		"stage_id":    targetStage.ID,
+		"rank":        rank,
+		"archived_at": nil,
+	}
+	if entry != nil {
+		for field, value := range entry.Updates {
+			updates[field] = value
+		}
+	}
+	if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to restore task")
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.recordWIPWarnings(wip, task.ID)
+
+	if h.ActivityService != nil {
+		if err := h.ActivityService.LogTaskArchived(task.ID, userID, task.ProjectID, false, c); err != nil {
+			log.Printf("Failed to log task restore activity: %v", err)
+		}
+		if fromStage == nil || fromStage.ID != targetStage.ID {
+			oldStageName := ""
+			if fromStage != nil {
+				oldStageName = fromStage.Name
+			}
+			if err := h.ActivityService.LogTaskMoved(
+				task.ID, userID, task.ProjectID,
+				task.StageID, targetStage.ID,
+				oldStageName, targetStage.Name,
+				c,
+			); err != nil {
+				log.Printf("Failed to log task move activity: %v", err)
+			}
+		}
+	}
+	h.logStageEntry(&task, userID, entry, c)
+
+	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, task.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload task data")
+		return
+	}
+
+	utils.Success(c, withWIPWarnings(gin.H{
+		"task":    task,
+		"message": "Task restored successfully",
+	}, wip))
+}
+
+// GetArchivedTasks 获取项目中已归档的任务，按归档时间倒序，支持 limit/cursor 分页
+func (h *TaskHandler) GetArchivedTasks(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	query := database.DB.Preload("Stage").Preload("Assignee").
+		Where("tasks.project_id = ? AND tasks.archived_at IS NOT NULL", projectID)
+
+	tasks, pageInfo, ok := fetchTaskPage(c, query, c.DefaultQuery("sort", "-archived_at"), 50, 200, true)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"project_id": projectID,
+		"tasks":      tasks,
+		"pagination": pageInfo,
+	})
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BulkMoveTasksRequest 批量移动任务请求
+type BulkMoveTasksRequest struct {
+	TaskIDs []services.TaskRef `json:"task_ids" binding:"required,min=1,max=200"` // 任务ID或编号
+	StageID uint               `json:"stage_id" binding:"required"`               // 目标阶段，任务按请求顺序追加到末尾
+}
+
+// BulkMoveTasks 批量移动任务到同一项目的另一个阶段
+// 与单个移动相同，按目标阶段设置同步任务状态和完成时间
+func (h *TaskHandler) BulkMoveTasks(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req BulkMoveTasksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+	taskIDs, ok := resolveTaskRefs(c, req.TaskIDs...)
+	if !ok {
+		return
+	}
+
+	var newStage models.Stage
+	if err := database.DB.First(&newStage, req.StageID).Error; err != nil {
+		utils.NotFound(c, "Target stage not found")
+		return
+	}
+
+	if !utils.CanManageTasks(userID, newStage.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to move tasks")
+		return
+	}
+	if !utils.CheckProjectWritable(c, newStage.ProjectID) {
+		return
+	}
+
+	if !newStage.AllowTaskMovement {
+		utils.BadRequest(c, "Task movement is not allowed to this stage")
+		return
+	}
+
+	var tasks []models.Task
+	if err := database.DB.Preload("Stage").Where("id IN (?)", taskIDs).Find(&tasks).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch tasks")
+		return
+	}
+	byID := make(map[uint]*models.Task, len(tasks))
+	for i := range tasks {
+		if tasks[i].ProjectID != newStage.ProjectID {
+			utils.BadRequest(c, "All tasks must belong to the target stage's project")
+			return
+		}
+		if tasks[i].ArchivedAt != nil {
+			utils.BadRequest(c, "Archived tasks cannot be moved, restore them first")
+			return
+		}
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	// 按请求顺序处理，跳过重复的任务和已在目标阶段的任务
+	var moving []*models.Task
+	seen := make(map[uint]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		task, ok := byID[id]
+		if !ok {
+			utils.NotFound(c, "Task not found")
+			return
+		}
+		if seen[id] || task.StageID == newStage.ID {
+			continue
+		}
+		seen[id] = true
+		moving = append(moving, task)
+	}
+
+	if !h.checkTransitions(c, newStage.ProjectID, moving, newStage.ID, userID) {
+		return
+	}
+
+	// 检查目标阶段在制品上限，按全部任务移动完成后的数量计算
+	assignees := make([]*uint, len(moving))
+	for i, task := range moving {
+		assignees[i] = task.AssigneeID
+	}
+	wip, ok := h.checkStageWIP(c, &newStage, assignees, "bulk_move", userID, 0)
+	if !ok {
+		return
+	}
+
+	workflow, err := h.WorkflowService.GetWorkflow(database.DB, newStage.ProjectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	now := time.Now()
+	entries := make([]*services.StageEntry, len(moving))
+	for i, task := range moving {
+		rank, err := h.RankService.RankForAppend(tx, newStage.ID)
+		if err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to compute task position: "+err.Error())
+			return
+		}
+
+		entries[i] = services.ComputeStageEntry(workflow, task, task.Stage, &newStage, now)
+		updates := map[string]interface{}{
+			"stage_id": newStage.ID,
+			"rank":     rank,
+		}
+		for field, value := range entries[i].Updates {
+			updates[field] = value
+		}
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to move task: "+err.Error())
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.recordWIPWarnings(wip, 0)
+
+	// 记录每个任务的移动活动
+	if h.ActivityService != nil {
+		for i, task := range moving {
+			oldStageName := ""
+			if task.Stage != nil {
+				oldStageName = task.Stage.Name
+			}
+			if err := h.ActivityService.LogTaskMoved(
+				task.ID, userID, task.ProjectID,
+				task.StageID, newStage.ID,
+				oldStageName, newStage.Name,
+				c,
+			); err != nil {
+				log.Printf("Failed to log task move activity: %v", err)
+			}
+			h.logStageEntry(task, userID, entries[i], c)
+		}
+	}
+
+	// 执行自动化规则
+	for _, task := range moving {
+		h.dispatchAutomation(services.AutomationEvent{
+			Trigger:   models.TriggerTaskMoved,
+			ProjectID: task.ProjectID,
+			TaskID:    task.ID,
+			ActorID:   userID,
+			StageID:   newStage.ID,
+		})
+	}
+
+	var movedIDs []uint
+	for _, task := range moving {
+		movedIDs = append(movedIDs, task.ID)
+	}
+	var moved []models.Task
+	if len(movedIDs) > 0 {
+		if err := database.DB.Preload("Stage").Preload("Assignee").
+			Where("id IN (?)", movedIDs).Order("rank ASC").Find(&moved).Error; err != nil {
+			utils.InternalServerError(c, "Failed to reload task data")
+			return
+		}
+	}
+
+	utils.Success(c, withWIPWarnings(gin.H{
+		"tasks":   moved,
+		"moved":   len(moved),
+		"skipped": len(taskIDs) - len(moved),
+		"message": "Tasks moved successfully",
+	}, wip))
+}
+
+// logStageEntry 记录任务进入阶段引起的状态变化、完成和重新打开活动
+func (h *TaskHandler) logStageEntry(task *models.Task, userID uint, entry *services.StageEntry, c *gin.Context) {
+	if h.ActivityService == nil {
+		return
+	}
+	h.ActivityService.LogStageEntry(task, userID, entry, c)
+}
+
+// checkTransitions 检查任务移动到目标阶段是否满足项目的流转规则
+// 任一任务不满足时拒绝整个请求，返回 422 和所有不满足的原因
+func (h *TaskHandler) checkTransitions(c *gin.Context, projectID uint, tasks []*models.Task, toStageID, userID uint) bool {
+	if h.TransitionService == nil || len(tasks) == 0 {
+		return true
+	}
+
+	rules, err := h.TransitionService.LoadRules(database.DB, projectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load stage transitions")
+		return false
+	}
+
+	var violations []*services.TransitionViolation
+	for _, task := range tasks {
+		if violation := rules.Check(task, task.StageID, toStageID, userID); violation != nil {
+			violations = append(violations, violation)
+		}
+	}
+	if len(violations) == 0 {
+		return true
+	}
+
+	message := violations[0].Error()
+	if len(violations) > 1 {
+		message = fmt.Sprintf("%s (and %d more tasks)", message, len(violations)-1)
+	}
+	utils.ErrorWithData(c, http.StatusUnprocessableEntity, message, gin.H{"violations": violations})
+	return false
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taskCSVMaxSize 导入的 CSV 文件大小上限
+const taskCSVMaxSize = 5 << 20
+
+// taskCSVFormSlack multipart 表单中文件以外的部分（边界、映射等参数）允许的额外大小
+const taskCSVFormSlack = 1 << 20
+
+// TaskCSVHandler 任务 CSV 导入导出处理器
+type TaskCSVHandler struct {
+	CSVService      *services.TaskCSVService
+	ActivityService *services.TaskActivityService
+	SearchService   *services.SearchService
+}
+
+// NewTaskCSVHandler 创建任务 CSV 导入导出处理器
+func NewTaskCSVHandler() *TaskCSVHandler {
+	return &TaskCSVHandler{
+		CSVService:      services.NewTaskCSVService(),
+		ActivityService: services.NewTaskActivityService(),
+		SearchService:   services.NewSearchService(),
+	}
+}
+
+// ExportTasksCSV 把项目任务导出为 CSV
+// 过滤和排序参数与任务列表相同（stage_id、assignee_id、priority、status、q、sort）；
+// columns 为逗号分隔的列（见 services.TaskCSVColumns），负责人导出为邮箱，多个标签用分号分隔
+func (h *TaskCSVHandler) ExportTasksCSV(c *gin.Context) {
+	columns, err := services.ParseTaskCSVColumns(c.Query("columns"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid columns: "+err.Error())
+		return
+	}
+
+	query, projectID, ok := projectTaskQuery(c)
+	if !ok {
+		return
+	}
+	tasks, _, ok := fetchTaskPage(c, query, c.Query("sort"), 0, services.TaskCSVMaxRows, false)
+	if !ok {
+		return
+	}
+
+	// 写入 UTF-8 BOM，Excel 才能正确识别中文
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	if err := h.CSVService.WriteTasksCSV(database.DB, &buf, tasks, columns); err != nil {
+		utils.InternalServerError(c, "Failed to export tasks: "+err.Error())
+		return
+	}
+
+	var project models.Project
+	database.DB.Select("id, key_prefix").First(&project, projectID)
+	name := project.KeyPrefix
+	if name == "" {
+		name = "project-" + strconv.FormatUint(projectID, 10)
+	}
+	filename := fmt.Sprintf("%s-tasks-%s.csv", strings.ToLower(name), time.Now().Format("20060102"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// ImportTasksCSV 从 CSV 导入任务，任务追加到阶段末尾；与批量创建一样不检查在制品上限，也不触发自动化规则
+// CSV 可以作为 multipart 的 file 字段上传，也可以直接作为请求体；参数可以放在表单或查询参数中：
+//   - mapping：JSON 对象，任务字段到 CSV 表头的映射（如 {"title":"Summary","assignee":"Owner"}），不传时按表头名称匹配
+//   - create_stages=true：找不到的阶段按名称在末尾创建（需要阶段管理权限）
+//   - dry_run=true：只返回每一行的解析结果和错误，不创建任务
+//   - skip_invalid=true：跳过有错误的行，只导入其余的行；否则有任意一行错误时不导入
+func (h *TaskCSVHandler) ImportTasksCSV(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	if !utils.CanManageTasks(userID, project.ID) {
+		utils.Forbidden(c, "Insufficient permissions to import tasks")
+		return
+	}
+	if !utils.CheckProjectWritable(c, project.ID) {
+		return
+	}
+
+	if err := limitTaskCSVUpload(c); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	createStages := csvImportParam(c, "create_stages") == "true"
+	if createStages && !utils.CanManageStages(userID, project.ID) {
+		utils.Forbidden(c, "Insufficient permissions to create stages")
+		return
+	}
+
+	var mapping map[string]string
+	if raw := csvImportParam(c, "mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			utils.BadRequest(c, "Invalid mapping: "+err.Error())
+			return
+		}
+	}
+
+	content, err := readTaskCSVUpload(c)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	header, rows, err := services.ReadTaskCSV(bytes.NewReader(content))
+	if err != nil {
+		utils.BadRequest(c, "Invalid CSV: "+err.Error())
+		return
+	}
+
+	report, err := h.CSVService.PlanImport(database.DB, &project, header, rows, services.TaskCSVImportOptions{
+		Mapping:      mapping,
+		CreateStages: createStages,
+		Location:     utils.TaskTimezone(userID, project.ID),
+	})
+	if err != nil {
+		utils.BadRequest(c, "Invalid CSV: "+err.Error())
+		return
+	}
+
+	if csvImportParam(c, "dry_run") == "true" {
+		report.DryRun = true
+		utils.Success(c, gin.H{"report": report})
+		return
+	}
+	if report.Invalid > 0 && csvImportParam(c, "skip_invalid") != "true" {
+		utils.ErrorWithData(c, http.StatusBadRequest, fmt.Sprintf("CSV contains %d invalid rows, fix them or import with skip_invalid=true", report.Invalid), gin.H{
+			"report": report,
+		})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	tasks, err := h.CSVService.ExecuteImport(tx, report, userID)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to import tasks: "+err.Error())
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction: "+err.Error())
+		return
+	}
+
+	// 记录创建活动并更新搜索索引
+	for i := range tasks {
+		if err := h.ActivityService.LogTaskCreated(&tasks[i], userID, c); err != nil {
+			log.Printf("Failed to log task creation activity: %v", err)
+		}
+		if err := h.SearchService.IndexTask(database.DB, &tasks[i]); err != nil {
+			log.Printf("Failed to index task %d: %v", tasks[i].ID, err)
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"report":  report,
+		"message": fmt.Sprintf("%d tasks imported successfully", report.Created),
+	})
+}
+
+// limitTaskCSVUpload 在读取表单之前限制请求体大小，multipart 上传在这里整体解析到内存
+// 必须在 csvImportParam 和 readTaskCSVUpload 之前调用，否则超大的表单会先被完整读取
+func limitTaskCSVUpload(c *gin.Context) error {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, taskCSVMaxSize+taskCSVFormSlack)
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return nil
+	}
+	if err := c.Request.ParseMultipartForm(taskCSVMaxSize + taskCSVFormSlack); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return fmt.Errorf("CSV file is larger than %d MB", taskCSVMaxSize>>20)
+		}
+		return fmt.Errorf("Invalid multipart form: %v", err)
+	}
+	return nil
+}
+
+// csvImportParam 读取表单字段，没有时读取查询参数
+func csvImportParam(c *gin.Context, key string) string {
+	return c.DefaultPostForm(key, c.Query(key))
+}
+
+// readTaskCSVUpload 读取上传的 CSV：multipart 的 file 字段或整个请求体
+func readTaskCSVUpload(c *gin.Context) ([]byte, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("CSV file is required in the \"file\" field")
+		}
+		if file.Size > taskCSVMaxSize {
+			return nil, fmt.Errorf("CSV file is larger than %d MB", taskCSVMaxSize>>20)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CSV file")
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(c.Request.Body, taskCSVMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read request body")
+	}
+	if len(content) > taskCSVMaxSize {
+		return nil, fmt.Errorf("CSV file is larger than %d MB", taskCSVMaxSize>>20)
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, fmt.Errorf("CSV content is required")
+	}
+	return content, nil
+}
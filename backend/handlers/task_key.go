@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTaskByKey 根据任务编号获取任务（支持移动项目或修改前缀前的旧编号）
+func (h *TaskHandler) GetTaskByKey(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	key := strings.ToUpper(c.Param("key"))
+
+	taskID, err := h.KeyService.ResolveTaskKey(database.DB, key)
+	if err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	var task models.Task
+	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, task.ProjectID) && !utils.CheckProjectOwner(userID, task.ProjectID) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"task":     task,
+		"is_alias": task.Key != key, // 通过旧编号访问
+	})
+}
+
+// resolveTaskRefs 解析请求体中的任务引用（数字ID或任务编号），失败时返回错误响应
+func resolveTaskRefs(c *gin.Context, refs ...services.TaskRef) ([]uint, bool) {
+	ids, err := services.NewTaskKeyService().ResolveTaskRefs(database.DB, refs)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task reference: "+err.Error())
+		return nil, false
+	}
+	return ids, true
+}
+
+// resolveOptionalTaskRef 解析可选的任务引用，未传时返回 nil
+func resolveOptionalTaskRef(c *gin.Context, ref *services.TaskRef) (*uint, bool) {
+	if ref == nil || *ref == "" {
+		return nil, true
+	}
+	ids, ok := resolveTaskRefs(c, *ref)
+	if !ok {
+		return nil, false
+	}
+	return &ids[0], true
+}
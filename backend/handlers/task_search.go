@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// SearchTasks 跨项目搜索任务
+// 查询参数 q 为任务查询语言（见 services.ParseTaskQuery），只搜索当前用户参与的活跃项目；
+// sort 为排序（默认按更新时间倒序），支持 limit/cursor 游标分页
+func (h *TaskHandler) SearchTasks(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	query := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").
+		Where("tasks.project_id IN ?", visibleProjectIDs(userID))
+
+	query, parsed, ok := applyTaskQuery(c, query, userID, 0)
+	if !ok {
+		return
+	}
+
+	tasks, pageInfo, ok := fetchTaskPage(c, query, c.DefaultQuery("sort", "-updated_at"), 50, 200, true)
+	if !ok {
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"query":      parsed,
+		"tasks":      tasks,
+		"total":      *pageInfo.Total,
+		"limit":      pageInfo.Limit,
+		"pagination": pageInfo,
+	})
+}
+
+// visibleProjectIDs 当前用户拥有或参与的活跃项目ID（子查询）
+func visibleProjectIDs(userID uint) interface{} {
+	memberProjects := database.DB.Table("project_members").Select("project_id").Where("user_id = ?", userID).SubQuery()
+	return database.DB.Table("projects").Select("id").
+		Where("status = ? AND (owner_id = ? OR id IN ?)", models.ProjectStatusActive, userID, memberProjects).SubQuery()
+}
+
+// projectTaskQuery 根据路由中的项目和请求中的过滤参数（stage_id、assignee_id、priority、status、q）构建任务查询
+// 项目不存在或没有权限时直接返回错误
+func projectTaskQuery(c *gin.Context) (*gorm.DB, uint64, bool) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return nil, 0, false
+	}
+
+	// 验证项目ID是否有效
+	if projectID == 0 {
+		utils.BadRequest(c, "Invalid project ID: project ID cannot be 0")
+		return nil, 0, false
+	}
+
+	// 检查项目是否存在（已归档的项目可以只读访问）
+	var project models.Project
+	if err := database.DB.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return nil, 0, false
+	}
+
+	// 单机版：检查用户是否是项目成员即可
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return nil, 0, false
+	}
+
+	// 获取查询参数
+	stageID := c.Query("stage_id")
+	assigneeID := c.Query("assignee_id")
+	priority := c.Query("priority")
+	status := c.Query("status")
+
+	// 构建查询
+	query := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").
+		Where("tasks.project_id = ?", projectID)
+
+	if stageID != "" {
+		query = query.Where("stage_id = ?", stageID)
+	}
+	if assigneeID != "" {
+		query = query.Where("assignee_id = ?", assigneeID)
+	}
+	if priority != "" {
+		query = query.Where("priority = ?", priority)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	// 查询语言过滤（q 参数）
+	query, _, ok := applyTaskQuery(c, query, userID, uint(projectID))
+	if !ok {
+		return nil, 0, false
+	}
+	return query, projectID, true
+}
+
+// applyTaskQuery 解析请求中的 q 参数并加到查询上，语法错误时返回 400 和出错位置
+// 查询中没有 is:archived 时排除已归档的任务；projectID 为 0 表示跨项目查询，用于确定日期条件的时区
+func applyTaskQuery(c *gin.Context, query *gorm.DB, userID, projectID uint) (*gorm.DB, *services.TaskQuery, bool) {
+	raw := c.Query("q")
+	if raw == "" {
+		return query.Where(services.TaskNotArchivedCondition), nil, true
+	}
+
+	parsed, err := services.ParseTaskQuery(raw)
+	if err == nil {
+		query, err = parsed.Apply(query, services.TaskQueryContext{
+			UserID:   userID,
+			Now:      time.Now(),
+			Location: utils.TaskTimezone(userID, projectID),
+		})
+	}
+	if err != nil {
+		if queryErr, ok := err.(*services.TaskQueryError); ok {
+			utils.ErrorWithData(c, http.StatusBadRequest, "Invalid query: "+queryErr.Error(), gin.H{
+				"position": queryErr.Position,
+				"error":    queryErr.Message,
+			})
+		} else {
+			utils.InternalServerError(c, "Failed to apply query: "+err.Error())
+		}
+		return nil, nil, false
+	}
+	if !parsed.IncludesArchived() {
+		query = query.Where(services.TaskNotArchivedCondition)
+	}
+	return query, parsed, true
+}
+
+// withListTotal 在分页列表响应中附加 total：请求了总数（with_total）时为总数，
+// 本页已是完整列表（没有游标和偏移且没有下一页）时为本页数量；否则无法确定总数，不返回该字段
+func withListTotal(c *gin.Context, data gin.H, count int, info utils.PageInfo) gin.H {
+	switch {
+	case info.Total != nil:
+		data["total"] = *info.Total
+	case !info.HasMore && c.Query("cursor") == "" && c.DefaultQuery("offset", "0") == "0":
+		data["total"] = count
+	}
+	return data
+}
+
+// 任务列表未传 limit 时的每页数量和 limit 上限
+const (
+	taskListDefaultLimit = 100
+	taskListMaxLimit     = 500
+)
+
+// fetchTaskPage 按排序（sortSpec，见 services.ParseTaskSort）和请求中的分页参数查询任务
+// defaultLimit 为 0 时未传 limit 返回全部；forceTotal 为 true 时总是返回总数
+// 参数错误时直接返回 400
+func fetchTaskPage(c *gin.Context, query *gorm.DB, sortSpec string, defaultLimit, maxLimit int, forceTotal bool) ([]models.Task, utils.PageInfo, bool) {
+	sortKeys, err := services.ParseTaskSort(sortSpec)
+	if err != nil {
+		utils.BadRequest(c, "Invalid sort: "+err.Error())
+		return nil, utils.PageInfo{}, false
+	}
+	page, err := utils.ParsePageParams(c, services.FormatTaskSort(sortKeys), defaultLimit, maxLimit)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return nil, utils.PageInfo{}, false
+	}
+
+	var total *int
+	if page.WithTotal || forceTotal {
+		var count int
+		if err := query.Model(&models.Task{}).Count(&count).Error; err != nil {
+			utils.InternalServerError(c, "Failed to count tasks")
+			return nil, utils.PageInfo{}, false
+		}
+		total = &count
+	}
+
+	keyset := services.TaskKeyset(sortKeys)
+	listQuery, err := page.Apply(query, keyset)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return nil, utils.PageInfo{}, false
+	}
+	var tasks []models.Task
+	if err := listQuery.Find(&tasks).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch tasks")
+		return nil, utils.PageInfo{}, false
+	}
+
+	n, hasMore := page.Trim(len(tasks))
+	tasks = tasks[:n]
+	var lastID interface{}
+	if n > 0 {
+		lastID = tasks[n-1].ID
+	}
+	info, err := page.Info(database.DB, keyset, hasMore, lastID, total)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return nil, utils.PageInfo{}, false
+	}
+
+	if err := fillStagePositions(tasks); err != nil {
+		utils.InternalServerError(c, "Failed to compute task positions")
+		return nil, utils.PageInfo{}, false
+	}
+	return tasks, info, true
+}
+
+// fillStagePositions position 返回任务在整个阶段内的顺序（从0开始），兼容按 position 排序的客户端
+// 与过滤条件和分页无关，可以直接用于移动任务；一次查询统计本页每个任务之前的任务数
+func fillStagePositions(tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+
+	var rows []struct {
+		ID       uint
+		Position int
+	}
+	if err := database.DB.Raw(`SELECT t.id AS id, (SELECT COUNT(*) FROM tasks WHERE tasks.stage_id = t.stage_id AND `+
+		services.TaskNotArchivedCondition+` AND (tasks.rank < t.rank OR (tasks.rank = t.rank AND tasks.id < t.id))) AS position
+		FROM tasks t WHERE t.id IN (?)`, ids).Scan(&rows).Error; err != nil {
+		return err
+	}
+	positions := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		positions[row.ID] = row.Position
+	}
+	for i := range tasks {
+		tasks[i].Position = positions[tasks[i].ID]
+	}
+	return nil
+}
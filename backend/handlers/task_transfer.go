@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CloneTaskRequest 复制任务请求
+// 任务目前没有独立的检查清单，清单内容保存在描述中，会随描述一起复制
+type CloneTaskRequest struct {
+	ProjectID       uint   `json:"project_id"`       // 目标项目ID，默认为原项目
+	StageID         uint   `json:"stage_id"`         // 目标阶段ID，默认为原阶段（跨项目复制时必填）
+	Title           string `json:"title"`            // 新任务标题，默认为原标题
+	IncludeComments bool   `json:"include_comments"` // 是否同时复制评论
+}
+
+// TransferTaskRequest 跨项目移动任务请求
+type TransferTaskRequest struct {
+	ProjectID  uint  `json:"project_id" binding:"required"`
+	StageID    uint  `json:"stage_id" binding:"required"`
+	AssigneeID *uint `json:"assignee_id"` // 原负责人不是目标项目成员时改派的负责人（可选，不传则取消分配）
+}
+
+// CloneTask 复制任务
+func (h *TaskHandler) CloneTask(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return
+	}
+
+	// 所有选项都是可选的，没有请求体时使用默认值
+	var req CloneTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	// 查找原任务
+	var source models.Task
+	if err := database.DB.Preload("Stage").First(&source, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	// 读取原任务需要是原项目成员
+	if !utils.CanManageTasks(userID, source.ProjectID) {
+		utils.Forbidden(c, "Access denied to this task")
+		return
+	}
+
+	// 确定目标项目和阶段
+	targetProjectID := req.ProjectID
+	if targetProjectID == 0 {
+		targetProjectID = source.ProjectID
+	}
+	targetStageID := req.StageID
+	if targetStageID == 0 {
+		if targetProjectID != source.ProjectID {
+			utils.BadRequest(c, "Stage ID is required when cloning into another project")
+			return
+		}
+		targetStageID = source.StageID
+	}
+
+	// 写入目标项目需要是目标项目成员
+	if !utils.CanManageTasks(userID, targetProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to create task in target project")
+		return
+	}
+	if !utils.CheckProjectWritable(c, targetProjectID) {
+		return
+	}
+
+	var targetProject models.Project
+	if err := database.DB.Where("id = ? AND status = ?", targetProjectID, models.ProjectStatusActive).First(&targetProject).Error; err != nil {
+		utils.NotFound(c, "Target project not found")
+		return
+	}
+
+	var targetStage models.Stage
+	if err := database.DB.Where("id = ? AND project_id = ?", targetStageID, targetProjectID).First(&targetStage).Error; err != nil {
+		utils.NotFound(c, "Target stage not found")
+		return
+	}
+
+	// 检查目标阶段是否允许创建任务
+	if !targetStage.AllowTaskCreation {
+		utils.BadRequest(c, "Task creation is not allowed in target stage")
+		return
+	}
+
+	// 负责人不是目标项目成员时不保留
+	assigneeID := source.AssigneeID
+	if assigneeID != nil && !utils.CanManageTasks(*assigneeID, targetProjectID) {
+		assigneeID = nil
+	}
+
+	// 检查目标阶段在制品上限
+	wip, ok := h.checkStageWIP(c, &targetStage, []*uint{assigneeID}, "clone", userID, 0)
+	if !ok {
+		return
+	}
+
+	title := req.Title
+	if title == "" {
+		title = source.Title
+	}
+
+	// 按目标项目的工作流和目标阶段设置状态和完成时间；复制出的任务是新任务，不沿用原任务的完成时间
+	workflow, err := h.WorkflowService.GetWorkflow(database.DB, targetProjectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return
+	}
+	seed := source
+	seed.CompletedAt = nil
+	entry := services.ComputeStageEntry(workflow, &seed, source.Stage, &targetStage, time.Now())
+
+	// 优先级不在目标项目的工作流中时使用默认优先级
+	priority := source.Priority
+	if workflow.Priority(priority) == nil {
+		priority = workflow.DefaultPriority()
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rank, err := h.RankService.RankForAppend(tx, targetStageID)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to compute task position: "+err.Error())
+		return
+	}
+
+	clone := models.Task{
+		StageID:        targetStageID,
+		ProjectID:      targetProjectID,
+		Title:          title,
+		Description:    source.Description,
+		Status:         entry.NewStatus,
+		Priority:       priority,
+		AssigneeID:     assigneeID,
+		StartDate:      source.StartDate,
+		DueDate:        source.DueDate,
+		DueAllDay:      source.DueAllDay,
+		EstimatedHours: source.EstimatedHours,
+		Rank:           rank,
+		CreatedBy:      userID,
+	}
+	if completedAt, ok := entry.Updates["completed_at"].(time.Time); ok {
+		clone.CompletedAt = &completedAt
+	}
+
+	if err := h.KeyService.AssignTaskKey(tx, &clone); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to assign task key: "+err.Error())
+		return
+	}
+
+	if err := tx.Create(&clone).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to clone task: "+err.Error())
+		return
+	}
+
+	// 复制评论（按创建顺序复制，保证父评论先于回复创建，便于重新映射ID）
+	copiedComments := 0
+	if req.IncludeComments {
+		var comments []models.Comment
+		if err := tx.Where("task_id = ?", source.ID).Order("id ASC").Find(&comments).Error; err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to fetch task comments")
+			return
+		}
+
+		commentIDMap := make(map[uint]uint)
+		for _, comment := range comments {
+			copied := models.Comment{
+				TaskID:    clone.ID,
+				UserID:    comment.UserID,
+				Content:   comment.Content,
+				MediaID:   comment.MediaID,
+				MediaType: comment.MediaType,
+				MediaName: comment.MediaName,
+			}
+			if comment.ParentCommentID != nil {
+				if newID, ok := commentIDMap[*comment.ParentCommentID]; ok {
+					copied.ParentCommentID = &newID
+				}
+			}
+			if comment.ReplyToID != nil {
+				if newID, ok := commentIDMap[*comment.ReplyToID]; ok {
+					copied.ReplyToID = &newID
+				}
+			}
+
+			if err := tx.Create(&copied).Error; err != nil {
+				tx.Rollback()
+				utils.InternalServerError(c, "Failed to clone task comments")
+				return
+			}
+			commentIDMap[comment.ID] = copied.ID
+			copiedComments++
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.recordWIPWarnings(wip, clone.ID)
+
+	// 记录任务复制活动
+	if h.ActivityService != nil {
+		if err := h.ActivityService.LogTaskCloned(&clone, userID, source.ID, source.ProjectID, c); err != nil {
+			log.Printf("Failed to log task clone activity: %v", err)
+		}
+	}
+	h.logStageEntry(&clone, userID, entry, c)
+
+	// 重新加载任务信息
+	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&clone, clone.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload task data")
+		return
+	}
+
+	// 更新搜索索引
+	h.indexTask(&clone)
+	if copiedComments > 0 && h.SearchService != nil {
+		if err := h.SearchService.IndexTaskComments(database.DB, &clone); err != nil {
+			log.Printf("Failed to index comments of task %d: %v", clone.ID, err)
+		}
+	}
+
+	utils.Success(c, withWIPWarnings(gin.H{
+		"task":            clone,
+		"source_task_id":  source.ID,
+		"copied_comments": copiedComments,
+		"message":         "Task cloned successfully",
+	}, wip))
+}
+
+// TransferTask 将任务移动到其他项目
+// 任务与原项目中其他任务的依赖关系会被删除，并在响应的 removed_dependencies 中返回；
+// 标签换成目标项目的同名标签，目标项目没有的标签在 removed_labels 中返回
+func (h *TaskHandler) TransferTask(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return
+	}
+
+	var req TransferTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	// 查找任务
+	var task models.Task
+	if err := database.DB.Preload("Stage").Preload("Project").First(&task, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return
+	}
+
+	if req.ProjectID == task.ProjectID {
+		utils.BadRequest(c, "Task already belongs to this project, use move instead")
+		return
+	}
+
+	// 跨项目移动需要同时是原项目和目标项目的成员
+	if !utils.CanManageTasks(userID, task.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to move task out of its project")
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+	if task.ArchivedAt != nil {
+		utils.BadRequest(c, "Task is archived, restore it before moving")
+		return
+	}
+	if !utils.CanManageTasks(userID, req.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to move task into target project")
+		return
+	}
+	if !utils.CheckProjectWritable(c, req.ProjectID) {
+		return
+	}
+
+	var targetProject models.Project
+	if err := database.DB.Where("id = ? AND status = ?", req.ProjectID, models.ProjectStatusActive).First(&targetProject).Error; err != nil {
+		utils.NotFound(c, "Target project not found")
+		return
+	}
+
+	var targetStage models.Stage
+	if err := database.DB.Where("id = ? AND project_id = ?", req.StageID, req.ProjectID).First(&targetStage).Error; err != nil {
+		utils.NotFound(c, "Target stage not found")
+		return
+	}
+
+	// 检查目标阶段是否允许移动任务
+	if !targetStage.AllowTaskMovement {
+		utils.BadRequest(c, "Task movement is not allowed to this stage")
+		return
+	}
+
+	// 负责人不是目标项目成员时重新分配
+	assigneeID := task.AssigneeID
+	assigneeRemapped := false
+	if assigneeID != nil && !utils.CanManageTasks(*assigneeID, req.ProjectID) {
+		assigneeID = nil
+		assigneeRemapped = true
+	}
+	if assigneeRemapped && req.AssigneeID != nil {
+		if !utils.CanManageTasks(*req.AssigneeID, req.ProjectID) {
+			utils.BadRequest(c, "Replacement assignee is not a member of the target project")
+			return
+		}
+		assigneeID = req.AssigneeID
+	}
+
+	// 检查目标阶段在制品上限
+	wip, ok := h.checkStageWIP(c, &targetStage, []*uint{assigneeID}, "transfer", userID, task.ID)
+	if !ok {
+		return
+	}
+
+	oldProjectID := task.ProjectID
+	oldProjectName := ""
+	if task.Project != nil {
+		oldProjectName = task.Project.Name
+	}
+	oldStageName := ""
+	if task.Stage != nil {
+		oldStageName = task.Stage.Name
+	}
+	oldAssigneeID := task.AssigneeID
+
+	// 按目标项目的工作流和目标阶段设置同步状态和完成时间
+	workflow, err := h.WorkflowService.GetWorkflow(database.DB, req.ProjectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to load project workflow")
+		return
+	}
+	entry := services.ComputeStageEntry(workflow, &task, task.Stage, &targetStage, time.Now())
+
+	// 优先级不在目标项目的工作流中时改为默认优先级
+	oldPriority := task.Priority
+	newPriority := oldPriority
+	if workflow.Priority(oldPriority) == nil {
+		newPriority = workflow.DefaultPriority()
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rank, err := h.RankService.RankForAppend(tx, req.StageID)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to compute task position: "+err.Error())
+		return
+	}
+
+	// 旧编号保留为别名，并分配目标项目的新编号
+	oldKey := task.Key
+	task.ProjectID = req.ProjectID
+	if err := h.KeyService.MoveTaskKey(tx, &task, oldProjectID); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to assign task key: "+err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{
+		"project_id":  req.ProjectID,
+		"stage_id":    req.StageID,
+		"rank":        rank,
+		"assignee_id": assigneeID,
+		"number":      task.Number,
+		"task_key":    task.Key,
+		"priority":    newPriority,
+	}
+	for field, value := range entry.Updates {
+		updates[field] = value
+	}
+	if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to transfer task: "+err.Error())
+		return
+	}
+
+	// 依赖只能存在于同一项目的任务之间，移出项目后删除原有的依赖关系
+	var removedDependencies []models.TaskDependency
+	if err := tx.Where("task_id = ? OR depends_on_task_id = ?", task.ID, task.ID).Find(&removedDependencies).Error; err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to load task dependencies: "+err.Error())
+		return
+	}
+	if len(removedDependencies) > 0 {
+		if err := tx.Where("task_id = ? OR depends_on_task_id = ?", task.ID, task.ID).Delete(&models.TaskDependency{}).Error; err != nil {
+			tx.Rollback()
+			utils.InternalServerError(c, "Failed to remove task dependencies: "+err.Error())
+			return
+		}
+	}
+
+	// 标签属于项目，换成目标项目的同名标签
+	removedLabels, err := services.NewLabelService().MoveTaskLabels(tx, task.ID, req.ProjectID)
+	if err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, "Failed to move task labels: "+err.Error())
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.recordWIPWarnings(wip, task.ID)
+
+	// 在两个项目中记录移动活动
+	if h.ActivityService != nil {
+		if err := h.ActivityService.LogTaskTransferred(
+			task.ID, userID,
+			oldProjectID, req.ProjectID,
+			oldProjectName, targetProject.Name,
+			oldStageName, targetStage.Name,
+			c,
+		); err != nil {
+			log.Printf("Failed to log task transfer activity: %v", err)
+		}
+
+		if assigneeRemapped {
+			oldValue := ""
+			if oldAssigneeID != nil {
+				oldValue = strconv.FormatUint(uint64(*oldAssigneeID), 10)
+			}
+			newValue := ""
+			if assigneeID != nil {
+				newValue = strconv.FormatUint(uint64(*assigneeID), 10)
+			}
+			if err := h.ActivityService.LogTaskUpdated(
+				task.ID, userID, req.ProjectID,
+				"assignee_id", oldValue, newValue,
+				c,
+			); err != nil {
+				log.Printf("Failed to log assignee remap activity: %v", err)
+			}
+		}
+
+		if newPriority != oldPriority {
+			if err := h.ActivityService.LogTaskUpdated(
+				task.ID, userID, req.ProjectID,
+				"priority", oldPriority, newPriority,
+				c,
+			); err != nil {
+				log.Printf("Failed to log priority remap activity: %v", err)
+			}
+		}
+	}
+	h.logStageEntry(&task, userID, entry, c)
+
+	// 在目标项目中执行自动化规则
+	h.dispatchAutomation(services.AutomationEvent{
+		Trigger:   models.TriggerTaskMoved,
+		ProjectID: req.ProjectID,
+		TaskID:    task.ID,
+		ActorID:   userID,
+		StageID:   req.StageID,
+	})
+
+	// 重新加载任务信息
+	if err := database.DB.Preload("Stage").Preload("Project").Preload("Assignee").First(&task, task.ID).Error; err != nil {
+		utils.InternalServerError(c, "Failed to reload task data")
+		return
+	}
+
+	// 更新搜索索引（任务编号和所属项目已变化）
+	h.indexTask(&task)
+
+	utils.Success(c, withWIPWarnings(gin.H{
+		"task":                 task,
+		"old_project_id":       oldProjectID,
+		"old_key":              oldKey,
+		"assignee_remapped":    assigneeRemapped,
+		"removed_dependencies": removedDependencies,
+		"removed_labels":       removedLabels,
+		"message":              "Task transferred successfully",
+	}, wip))
+}
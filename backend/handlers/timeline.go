@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimelineHandler 时间线（甘特图）处理器
+type TimelineHandler struct {
+	ScheduleService *services.TaskScheduleService // 任务排期服务
+	ActivityService *services.TaskActivityService // 任务活动记录服务
+}
+
+// NewTimelineHandler 创建时间线处理器
+func NewTimelineHandler() *TimelineHandler {
+	return &TimelineHandler{
+		ScheduleService: services.NewTaskScheduleService(),
+		ActivityService: services.NewTaskActivityService(),
+	}
+}
+
+// AddDependencyRequest 添加任务依赖请求
+type AddDependencyRequest struct {
+	DependsOnTaskID services.TaskRef `json:"depends_on_task_id" binding:"required"` // 任务ID或编号
+}
+
+// ShiftTaskDatesRequest 平移任务日期请求
+type ShiftTaskDatesRequest struct {
+	Days              int  `json:"days" binding:"required"`
+	IncludeDependents bool `json:"include_dependents"` // 同时平移所有后续任务
+}
+
+// GetProjectTimeline 获取项目时间线
+// 查询参数 from/to（YYYY-MM-DD），默认使用项目开始/结束日期，未设置时为今天前后30/90天
+func (h *TimelineHandler) GetProjectTimeline(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -30)
+	to := today.AddDate(0, 0, 90)
+	if project.StartDate != nil {
+		from = *project.StartDate
+	}
+	if project.EndDate != nil {
+		to = *project.EndDate
+	}
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			utils.BadRequest(c, "Invalid from date format")
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			utils.BadRequest(c, "Invalid to date format")
+			return
+		}
+	}
+	// 包含结束日期当天
+	to = to.Truncate(24*time.Hour).AddDate(0, 0, 1).Add(-time.Second)
+	if to.Before(from) {
+		utils.BadRequest(c, "The to date must not be earlier than the from date")
+		return
+	}
+
+	timeline, err := h.ScheduleService.BuildTimeline(database.DB, uint(projectID), from, to)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to build timeline: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"timeline":         timeline,
+		"project_start":    project.StartDate,
+		"project_end_date": project.EndDate,
+	})
+}
+
+// GetTaskDependencies 获取任务的前置任务和后续任务
+func (h *TimelineHandler) GetTaskDependencies(c *gin.Context) {
+	task, ok := h.loadTask(c)
+	if !ok {
+		return
+	}
+
+	var dependsOn []models.TaskDependency
+	if err := database.DB.Preload("DependsOnTask").Where("task_id = ?", task.ID).Find(&dependsOn).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch dependencies")
+		return
+	}
+
+	var dependents []models.TaskDependency
+	if err := database.DB.Where("depends_on_task_id = ?", task.ID).Find(&dependents).Error; err != nil {
+		utils.InternalServerError(c, "Failed to fetch dependents")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"task_id":    task.ID,
+		"depends_on": dependsOn,
+		"dependents": dependents,
+	})
+}
+
+// AddTaskDependency 添加任务依赖（当前任务依赖 depends_on_task_id）
+func (h *TimelineHandler) AddTaskDependency(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	task, ok := h.loadTask(c)
+	if !ok {
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	var req AddDependencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	dependsOnIDs, ok := resolveTaskRefs(c, req.DependsOnTaskID)
+	if !ok {
+		return
+	}
+	var dependsOn models.Task
+	if err := database.DB.First(&dependsOn, dependsOnIDs[0]).Error; err != nil {
+		utils.NotFound(c, "Dependency task not found")
+		return
+	}
+
+	dependency, err := h.ScheduleService.AddDependency(database.DB, task, &dependsOn, userID)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	if h.ActivityService != nil {
+		if err := h.ActivityService.LogTaskUpdated(
+			task.ID, userID, task.ProjectID,
+			"dependencies", "", strconv.FormatUint(uint64(dependsOn.ID), 10),
+			c,
+		); err != nil {
+			log.Printf("Failed to log dependency activity: %v", err)
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"dependency": dependency,
+		"message":    "Dependency added successfully",
+	})
+}
+
+// RemoveTaskDependency 删除任务依赖
+func (h *TimelineHandler) RemoveTaskDependency(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	task, ok := h.loadTask(c)
+	if !ok {
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	dependsOnID, err := services.NewTaskKeyService().ResolveTaskRef(database.DB, c.Param("dependsOnId"))
+	if err != nil {
+		utils.NotFound(c, "Dependency not found")
+		return
+	}
+
+	result := database.DB.Where("task_id = ? AND depends_on_task_id = ?", task.ID, dependsOnID).Delete(&models.TaskDependency{})
+	if result.Error != nil {
+		utils.InternalServerError(c, "Failed to remove dependency")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.NotFound(c, "Dependency not found")
+		return
+	}
+
+	if h.ActivityService != nil {
+		if err := h.ActivityService.LogTaskUpdated(
+			task.ID, userID, task.ProjectID,
+			"dependencies", strconv.FormatUint(uint64(dependsOnID), 10), "",
+			c,
+		); err != nil {
+			log.Printf("Failed to log dependency activity: %v", err)
+		}
+	}
+
+	utils.Success(c, gin.H{"message": "Dependency removed successfully"})
+}
+
+// ShiftTaskDates 将任务的开始和截止日期平移 N 天，可选同时平移所有后续任务
+// 平移后任何任务超出项目结束日期时拒绝操作
+func (h *TimelineHandler) ShiftTaskDates(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	task, ok := h.loadTask(c)
+	if !ok {
+		return
+	}
+	if !utils.CheckProjectWritable(c, task.ProjectID) {
+		return
+	}
+
+	var req ShiftTaskDatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	var project models.Project
+	if err := database.DB.First(&project, task.ProjectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return
+	}
+
+	shifts, violations, err := h.ScheduleService.PlanShift(database.DB, task, &project, req.Days, req.IncludeDependents)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to plan date shift: "+err.Error())
+		return
+	}
+	if len(violations) > 0 {
+		utils.ErrorWithData(c, http.StatusBadRequest, "Shifted dates would exceed the project end date", gin.H{
+			"violations": violations,
+		})
+		return
+	}
+
+	// 开始事务
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := h.ScheduleService.ApplyShift(tx, shifts); err != nil {
+		tx.Rollback()
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	// 记录日期变更活动
+	if h.ActivityService != nil {
+		for _, shift := range shifts {
+			if shift.NewStartDate != nil {
+				if err := h.ActivityService.LogTaskUpdated(
+					shift.TaskID, userID, task.ProjectID,
+					"start_date", shift.OldStartDate.Format("2006-01-02"), shift.NewStartDate.Format("2006-01-02"),
+					c,
+				); err != nil {
+					log.Printf("Failed to log date shift activity: %v", err)
+				}
+			}
+			if shift.NewDueDate != nil {
+				if err := h.ActivityService.LogTaskUpdated(
+					shift.TaskID, userID, task.ProjectID,
+					"due_date", shift.OldDueDate.Format("2006-01-02"), shift.NewDueDate.Format("2006-01-02"),
+					c,
+				); err != nil {
+					log.Printf("Failed to log date shift activity: %v", err)
+				}
+			}
+		}
+	}
+
+	utils.Success(c, gin.H{
+		"shifted": shifts,
+		"days":    req.Days,
+		"message": "Task dates shifted successfully",
+	})
+}
+
+// loadTask 读取路由中的任务并检查项目权限
+func (h *TimelineHandler) loadTask(c *gin.Context) (*models.Task, bool) {
+	userID := c.MustGet("user_id").(uint)
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid task ID")
+		return nil, false
+	}
+
+	var task models.Task
+	if err := database.DB.First(&task, taskID).Error; err != nil {
+		utils.NotFound(c, "Task not found")
+		return nil, false
+	}
+
+	if !utils.CanManageTasks(userID, task.ProjectID) {
+		utils.Forbidden(c, "Insufficient permissions to manage this task")
+		return nil, false
+	}
+	return &task, true
+}
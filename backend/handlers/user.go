@@ -96,9 +96,21 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
+	// 排序和游标分页（传入 limit 或 cursor 时忽略 page/page_size）
+	columns, sort, err := utils.ParseSort(c.Query("sort"), "-created_at", userSortFields, "users.id")
+	if err != nil {
+		utils.BadRequest(c, "Invalid sort: "+err.Error())
+		return
+	}
+	pageParams, err := utils.ParsePageParams(c, sort, pageSize, 100)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return
+	}
+
 	// 查询用户
 	var users []models.User
-	var total int64
+	var total int
 
 	// 获取总数
 	if err := database.DB.Model(&models.User{}).Count(&total).Error; err != nil {
@@ -107,23 +119,48 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 	}
 
 	// 获取用户列表
-	if err := database.DB.Select("id, username, email, role, created_at").
-		Offset(offset).
-		Limit(pageSize).
-		Order("created_at DESC").
-		Find(&users).Error; err != nil {
+	keyset := utils.Keyset{Table: "users", Columns: columns}
+	query, err := pageParams.Apply(database.DB.Select("id, username, email, role, created_at"), keyset)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pagination: "+err.Error())
+		return
+	}
+	if !pageParams.Paginated {
+		query = query.Offset(offset)
+	}
+	if err := query.Find(&users).Error; err != nil {
 		utils.InternalServerError(c, "Failed to fetch users")
 		return
 	}
 
+	n, hasMore := pageParams.Trim(len(users))
+	users = users[:n]
+	var lastID interface{}
+	if n > 0 {
+		lastID = users[n-1].ID
+	}
+	pageInfo, err := pageParams.Info(database.DB, keyset, hasMore, lastID, &total)
+	if err != nil {
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
 	utils.Success(c, gin.H{
-		"users":     users,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
+		"users":      users,
+		"total":      total,
+		"page":       page,
+		"page_size":  pageSize,
+		"pagination": pageInfo,
 	})
 }
 
+// 用户列表可排序字段
+var userSortFields = map[string]string{
+	"username":   "users.username",
+	"email":      "users.email",
+	"created_at": "users.created_at",
+}
+
 // CreateUser 创建用户（系统管理员功能）
 func (h *UserHandler) CreateUser(c *gin.Context) {
 	userRole := c.MustGet("user_role").(string)
@@ -315,3 +352,58 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 
 	utils.Success(c, gin.H{"message": "User deleted successfully"})
 }
+
+// UserPreferencesRequest 更新个人偏好请求
+type UserPreferencesRequest struct {
+	Timezone *string `json:"timezone"` // IANA 时区名称，空字符串表示使用 UTC
+}
+
+// GetMyPreferences 获取当前用户的个人偏好
+func (h *UserHandler) GetMyPreferences(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.NotFound(c, "User not found")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"timezone": user.Timezone,
+	})
+}
+
+// UpdateMyPreferences 更新当前用户的个人偏好
+func (h *UserHandler) UpdateMyPreferences(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var req UserPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		utils.NotFound(c, "User not found")
+		return
+	}
+
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if !utils.IsValidTimezone(timezone) {
+			utils.BadRequest(c, "Invalid timezone: "+timezone)
+			return
+		}
+		if err := database.DB.Model(&user).Update("timezone", timezone).Error; err != nil {
+			utils.InternalServerError(c, "Failed to update preferences")
+			return
+		}
+		user.Timezone = timezone
+	}
+
+	utils.Success(c, gin.H{
+		"timezone": user.Timezone,
+		"message":  "Preferences updated successfully",
+	})
+}
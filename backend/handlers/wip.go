@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WIPHandler 在制品限制报表处理器
+type WIPHandler struct {
+	WIPService *services.WIPService
+}
+
+// NewWIPHandler 创建在制品限制报表处理器
+func NewWIPHandler() *WIPHandler {
+	return &WIPHandler{
+		WIPService: services.NewWIPService(),
+	}
+}
+
+// GetWIPReport 获取项目各阶段当前的在制品情况和历史违规
+// 查询参数：since（YYYY-MM-DD，只统计该日期之后的违规）、stage_id（只返回该阶段的违规记录）、limit（违规记录条数，默认50，最多200）
+func (h *WIPHandler) GetWIPReport(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, uint(projectID)) && !utils.CheckProjectOwner(userID, uint(projectID)) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	var since *time.Time
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			utils.BadRequest(c, "Invalid since date format")
+			return
+		}
+		since = &parsed
+	}
+
+	var stageID uint64
+	if value := c.Query("stage_id"); value != "" {
+		if stageID, err = strconv.ParseUint(value, 10, 32); err != nil {
+			utils.BadRequest(c, "Invalid stage ID")
+			return
+		}
+	}
+
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			utils.BadRequest(c, "Invalid limit")
+			return
+		}
+		if limit > 200 {
+			limit = 200
+		}
+	}
+
+	stages, err := h.WIPService.StageReport(database.DB, uint(projectID), since)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to build WIP report")
+		return
+	}
+
+	violations, err := h.WIPService.RecentViolations(database.DB, uint(projectID), uint(stageID), since, limit)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch WIP violations")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"stages":     stages,
+		"violations": violations,
+	})
+}
+
+// wipService 任务处理器使用的在制品限制服务；限制必须始终生效，未注入时使用默认实例
+func (h *TaskHandler) wipService() *services.WIPService {
+	if h.WIPService != nil {
+		return h.WIPService
+	}
+	return services.NewWIPService()
+}
+
+// checkStageWIP 检查任务进入阶段是否超出在制品上限
+// 硬限制超出时记录违规并返回 400；软限制超出时返回检查结果，由调用方在操作完成后调用 recordWIPWarnings
+func (h *TaskHandler) checkStageWIP(c *gin.Context, stage *models.Stage, assignees []*uint, action string, userID, taskID uint) (*services.WIPResult, bool) {
+	result, err := h.wipService().CheckEntry(database.DB, stage, assignees, action, userID)
+	return h.enforceWIP(c, result, err, taskID)
+}
+
+// checkAssigneeWIP 检查阶段内任务改派后是否超出负责人的在制品上限
+func (h *TaskHandler) checkAssigneeWIP(c *gin.Context, stage *models.Stage, assigneeID *uint, userID, taskID uint) (*services.WIPResult, bool) {
+	result, err := h.wipService().CheckAssign(database.DB, stage, assigneeID, "assign", userID)
+	return h.enforceWIP(c, result, err, taskID)
+}
+
+func (h *TaskHandler) enforceWIP(c *gin.Context, result *services.WIPResult, err error, taskID uint) (*services.WIPResult, bool) {
+	if err != nil {
+		utils.InternalServerError(c, "Failed to check WIP limits")
+		return nil, false
+	}
+	if result.Blocked() {
+		if err := h.wipService().Record(database.DB, result, taskID); err != nil {
+			log.Printf("Failed to record WIP violation: %v", err)
+		}
+		utils.ErrorWithData(c, http.StatusBadRequest, result.Error(), gin.H{"violations": result.Violations})
+		return nil, false
+	}
+	return result, true
+}
+
+// recordWIPWarnings 操作完成后记录软限制下放行的违规
+func (h *TaskHandler) recordWIPWarnings(result *services.WIPResult, taskID uint) {
+	if len(result.Warnings()) == 0 {
+		return
+	}
+	if err := h.wipService().Record(database.DB, result, taskID); err != nil {
+		log.Printf("Failed to record WIP violation: %v", err)
+	}
+}
+
+// withWIPWarnings 在响应中附加软限制警告
+func withWIPWarnings(data gin.H, results ...*services.WIPResult) gin.H {
+	var warnings []models.WIPViolation
+	message := ""
+	for _, result := range results {
+		if len(result.Warnings()) == 0 {
+			continue
+		}
+		if message == "" {
+			message = result.Error()
+		}
+		warnings = append(warnings, result.Warnings()...)
+	}
+	if len(warnings) > 0 {
+		data["wip_warning"] = message
+		data["wip_warnings"] = warnings
+	}
+	return data
+}
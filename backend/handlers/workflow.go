@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"net/http"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkflowHandler 项目工作流处理器
+type WorkflowHandler struct {
+	WorkflowService *services.WorkflowService
+}
+
+// NewWorkflowHandler 创建项目工作流处理器
+func NewWorkflowHandler() *WorkflowHandler {
+	return &WorkflowHandler{
+		WorkflowService: services.NewWorkflowService(),
+	}
+}
+
+// UpdateStatusesRequest 更新状态定义请求
+type UpdateStatusesRequest struct {
+	Statuses     []models.WorkflowStatus `json:"statuses" binding:"required"`
+	Replacements map[string]string       `json:"replacements"` // 被删除的状态 -> 任务改用的状态
+}
+
+// UpdatePrioritiesRequest 更新优先级定义请求
+type UpdatePrioritiesRequest struct {
+	Priorities   []models.WorkflowPriority `json:"priorities" binding:"required"`
+	Replacements map[string]string         `json:"replacements"` // 被删除的优先级 -> 任务改用的优先级
+}
+
+// GetWorkflow 获取项目的任务状态和优先级定义
+func (h *WorkflowHandler) GetWorkflow(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	if !utils.CheckProjectMember(userID, projectID) && !utils.CheckProjectOwner(userID, projectID) {
+		utils.Forbidden(c, "Access denied to this project")
+		return
+	}
+
+	workflow, err := h.WorkflowService.GetWorkflow(database.DB, projectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to fetch workflow: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{"workflow": workflow})
+}
+
+// UpdateStatuses 替换项目的状态定义
+// 删除仍被任务使用的状态时，需要在 replacements 中指定替换状态，否则返回 409 和使用情况
+func (h *WorkflowHandler) UpdateStatuses(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	if !utils.CanManageProject(userID, projectID) {
+		utils.Forbidden(c, "Insufficient permissions to update workflow")
+		return
+	}
+	if !utils.CheckProjectWritable(c, projectID) {
+		return
+	}
+
+	var req UpdateStatusesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	// 确保项目已有定义，便于判断哪些状态被删除
+	if err := h.WorkflowService.EnsureWorkflow(database.DB, projectID); err != nil {
+		utils.InternalServerError(c, "Failed to fetch workflow: "+err.Error())
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := h.WorkflowService.UpdateStatuses(tx, projectID, req.Statuses, req.Replacements); err != nil {
+		tx.Rollback()
+		h.respondUpdateError(c, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.respondWorkflow(c, projectID, "Workflow statuses updated successfully")
+}
+
+// UpdatePriorities 替换项目的优先级定义，数组顺序即优先级从高到低
+func (h *WorkflowHandler) UpdatePriorities(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	projectID, ok := h.projectID(c)
+	if !ok {
+		return
+	}
+
+	if !utils.CanManageProject(userID, projectID) {
+		utils.Forbidden(c, "Insufficient permissions to update workflow")
+		return
+	}
+	if !utils.CheckProjectWritable(c, projectID) {
+		return
+	}
+
+	var req UpdatePrioritiesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request data: "+err.Error())
+		return
+	}
+
+	if err := h.WorkflowService.EnsureWorkflow(database.DB, projectID); err != nil {
+		utils.InternalServerError(c, "Failed to fetch workflow: "+err.Error())
+		return
+	}
+
+	tx := database.DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := h.WorkflowService.UpdatePriorities(tx, projectID, req.Priorities, req.Replacements); err != nil {
+		tx.Rollback()
+		h.respondUpdateError(c, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		utils.InternalServerError(c, "Failed to commit transaction")
+		return
+	}
+
+	h.respondWorkflow(c, projectID, "Workflow priorities updated successfully")
+}
+
+// projectID 解析路由中的项目ID并检查项目是否存在
+func (h *WorkflowHandler) projectID(c *gin.Context) (uint, bool) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequest(c, "Invalid project ID")
+		return 0, false
+	}
+
+	var project models.Project
+	if err := database.DB.Select("id").First(&project, projectID).Error; err != nil {
+		utils.NotFound(c, "Project not found")
+		return 0, false
+	}
+	return uint(projectID), true
+}
+
+func (h *WorkflowHandler) respondUpdateError(c *gin.Context, err error) {
+	if inUse, ok := err.(*services.WorkflowInUseError); ok {
+		utils.ErrorWithData(c, http.StatusConflict, inUse.Error()+"; provide replacements", inUse)
+		return
+	}
+	utils.BadRequest(c, err.Error())
+}
+
+func (h *WorkflowHandler) respondWorkflow(c *gin.Context, projectID uint, message string) {
+	workflow, err := h.WorkflowService.GetWorkflow(database.DB, projectID)
+	if err != nil {
+		utils.InternalServerError(c, "Failed to reload workflow: "+err.Error())
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"workflow": workflow,
+		"message":  message,
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"project-manager-backend/config"
 	"project-manager-backend/database"
 	"project-manager-backend/routes"
+	"project-manager-backend/services"
+	"time"
 )
 
 func main() {
@@ -16,6 +18,44 @@ func main() {
 	database.InitDatabase(cfg)
 	defer database.CloseDatabase()
 
+	// 为旧任务生成排序键，并重新平衡排序键过长的阶段
+	if err := services.NewTaskRankService().MigratePositionsToRanks(database.DB); err != nil {
+		log.Fatal("Failed to migrate task ranks:", err)
+	}
+
+	// 为旧项目生成编号前缀，为旧任务分配编号
+	if err := services.NewTaskKeyService().MigrateTaskKeys(database.DB); err != nil {
+		log.Fatal("Failed to migrate task keys:", err)
+	}
+
+	// 为旧项目创建默认的任务状态和优先级定义
+	if err := services.NewWorkflowService().MigrateWorkflows(database.DB); err != nil {
+		log.Fatal("Failed to migrate project workflows:", err)
+	}
+
+	// 创建或更新内置项目模板
+	if err := services.NewProjectTemplateService().EnsureBuiltinTemplates(database.DB); err != nil {
+		log.Fatal("Failed to create builtin project templates:", err)
+	}
+
+	// 创建全文搜索索引（不支持 FTS5 时搜索不可用，其他功能不受影响）
+	if err := services.NewSearchService().EnsureIndex(database.DB); err != nil {
+		log.Printf("Full-text search disabled: %v", err)
+	}
+
+	// 自动化 webhook 允许访问的内网地址
+	if err := services.SetWebhookAllowlist(cfg.Automation.WebhookAllowlist); err != nil {
+		log.Fatal("Invalid AUTOMATION_WEBHOOK_ALLOWLIST:", err)
+	}
+
+	// 启动截止日期提醒调度器
+	if cfg.Reminder.Enabled {
+		reminderService := services.NewReminderService(cfg.Reminder.Offsets, cfg.Reminder.EscalationDays)
+		stopReminders := reminderService.Start(database.DB, time.Duration(cfg.Reminder.Interval)*time.Minute)
+		defer stopReminders()
+		log.Printf("Reminder scheduler started (every %d minutes)", cfg.Reminder.Interval)
+	}
+
 	// 设置路由
 	r := routes.SetupRoutes(cfg)
 	log.Println("Routes configured successfully")
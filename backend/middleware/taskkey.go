@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"project-manager-backend/database"
+	"project-manager-backend/services"
+	"project-manager-backend/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TaskKeyMiddleware 任务编号解析中间件
+// 路由参数中的任务编号（如 WEB-123 或旧编号别名）会被替换为任务ID，后续处理器无需区分
+func TaskKeyMiddleware(params ...string) gin.HandlerFunc {
+	keyService := services.NewTaskKeyService()
+
+	return func(c *gin.Context) {
+		for _, name := range params {
+			for i := range c.Params {
+				if c.Params[i].Key != name || !services.IsTaskKey(c.Params[i].Value) {
+					continue
+				}
+
+				taskID, err := keyService.ResolveTaskKey(database.DB, c.Params[i].Value)
+				if err != nil {
+					utils.NotFound(c, "Task not found")
+					c.Abort()
+					return
+				}
+				c.Params[i].Value = strconv.FormatUint(uint64(taskID), 10)
+			}
+		}
+
+		c.Next()
+	}
+}
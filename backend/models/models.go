@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/jinzhu/gorm"
@@ -38,6 +40,7 @@ type User struct {
 	Email        string    `json:"email" gorm:"unique;not null"`
 	PasswordHash string    `json:"-" gorm:"not null"` // 密码不返回给前端
 	Role         UserRole  `json:"role" gorm:"default:'user'"`
+	Timezone     string    `json:"timezone" gorm:"size:64"` // IANA 时区，如 Asia/Shanghai，用于截止时间和逾期计算
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 
@@ -67,9 +70,13 @@ type Project struct {
 	Description string        `json:"description" gorm:"type:text"`
 	OwnerID     uint          `json:"owner_id" gorm:"not null"`
 	Status      ProjectStatus `json:"status" gorm:"default:'active'"`
+	ArchivedAt  *time.Time    `json:"archived_at"` // 归档时间，已归档的项目只读
 	StartDate   *time.Time    `json:"start_date"`
 	EndDate     *time.Time    `json:"end_date"`
-	Version     int64         `json:"version" gorm:"default:1"` // 版本号，用于乐观锁
+	Timezone    string        `json:"timezone" gorm:"size:64"`                   // 项目时区，统计逾期任务时使用
+	KeyPrefix   string        `json:"key_prefix" gorm:"size:10;index"`           // 任务编号前缀，如 WEB
+	TaskSeq     int           `json:"task_seq" gorm:"column:task_seq;default:0"` // 已分配的最大任务序号
+	Version     int64         `json:"version" gorm:"default:1"`                  // 版本号，用于乐观锁
 	CreatedAt   time.Time     `json:"created_at"`
 	UpdatedAt   time.Time     `json:"updated_at"`
 	CreatedBy   uint          `json:"created_by"`
@@ -105,7 +112,6 @@ type Stage struct {
 	Description         string     `json:"description" gorm:"type:text"`
 	Color               string     `json:"color" gorm:"default:'#3B82F6';size:7"`
 	Position            int        `json:"position" gorm:"default:0;index"`
-	TaskLimit           *int       `json:"task_limit"`
 	IsCompleted         bool       `json:"is_completed" gorm:"default:false;index"`
 	CompletedAt         *time.Time `json:"completed_at"`
 	CreatedBy           uint       `json:"created_by" gorm:"not null;index"`
@@ -113,7 +119,9 @@ type Stage struct {
 	CreatedAt           time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt           time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 	AllowTaskCreation   bool       `json:"allow_task_creation" gorm:"default:true"`
-	MaxTasks            int        `json:"max_tasks"`
+	MaxTasks            int        `json:"max_tasks"`                                              // 阶段在制品上限，0 表示不限制
+	MaxTasksPerAssignee int        `json:"max_tasks_per_assignee"`                                 // 阶段内每个负责人的在制品上限，0 表示不限制
+	WIPMode             WIPMode    `json:"wip_mode" gorm:"column:wip_mode;size:10;default:'hard'"` // 超出上限时拒绝（hard）或仅警告（soft）
 	AllowTaskDeletion   bool       `json:"allow_task_deletion" gorm:"default:true"`
 	AllowTaskMovement   bool       `json:"allow_task_movement" gorm:"default:true"`
 	NotificationEnabled bool       `json:"notification_enabled" gorm:"default:true"`
@@ -133,11 +141,18 @@ type Task struct {
 	Status         string     `json:"status" gorm:"default:'todo'"`
 	Priority       string     `json:"priority" gorm:"default:'P2'"`
 	AssigneeID     *uint      `json:"assignee_id"`
+	StartDate      *time.Time `json:"start_date"`
 	DueDate        *time.Time `json:"due_date"`
+	DueAllDay      bool       `json:"due_all_day"` // 全天任务：截止日期当天结束时到期
 	EstimatedHours *float64   `json:"estimated_hours"`
 	ActualHours    *float64   `json:"actual_hours"`
 	Position       int        `json:"position" gorm:"default:0"`
+	Rank           string     `json:"rank" gorm:"size:255;index"`               // 阶段内排序键，按字典序排序
+	Number         int        `json:"number" gorm:"default:0"`                  // 项目内序号
+	Key            string     `json:"key" gorm:"column:task_key;size:32;index"` // 任务编号，如 WEB-123
 	CreatedBy      uint       `json:"created_by"`
+	CompletedAt    *time.Time `json:"completed_at"`             // 完成时间，进入已完成状态或已完成阶段时记录
+	ArchivedAt     *time.Time `json:"archived_at" gorm:"index"` // 归档时间，归档的任务不在看板和列表中显示
 	Version        int64      `json:"version" gorm:"default:1"` // 版本号，用于乐观锁
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
@@ -751,4 +766,533 @@ type TaskActivity struct {
 
 func (TaskActivity) TableName() string {
 	return "task_activities"
-}
\ No newline at end of file
+}
+
+// TaskKeyAlias 任务编号别名
+// 任务移动到其他项目或项目修改编号前缀后，旧编号仍然可以通过别名找到任务
+type TaskKeyAlias struct {
+	ID        uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	Key       string    `json:"key" gorm:"column:alias_key;size:32;unique_index"`
+	TaskID    uint      `json:"task_id" gorm:"not null;index"`
+	ProjectID uint      `json:"project_id" gorm:"not null"` // 旧编号所属项目
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (TaskKeyAlias) TableName() string {
+	return "task_key_aliases"
+}
+
+// ==================== 任务排期相关模型 ====================
+
+// TaskDependencyType 任务依赖类型
+type TaskDependencyType string
+
+const (
+	TaskDependencyFinishToStart TaskDependencyType = "finish_to_start" // 前置任务完成后才能开始
+)
+
+// TaskDependency 任务依赖关系，TaskID 依赖 DependsOnTaskID
+type TaskDependency struct {
+	ID              uint               `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	ProjectID       uint               `json:"project_id" gorm:"not null;index"`
+	TaskID          uint               `json:"task_id" gorm:"not null;index"`
+	DependsOnTaskID uint               `json:"depends_on_task_id" gorm:"not null;index"`
+	Type            TaskDependencyType `json:"type" gorm:"size:30;default:'finish_to_start'"`
+	CreatedBy       uint               `json:"created_by"`
+	CreatedAt       time.Time          `json:"created_at"`
+
+	// 关联关系
+	DependsOnTask *Task `json:"depends_on_task,omitempty" gorm:"foreignkey:DependsOnTaskID"`
+}
+
+func (TaskDependency) TableName() string {
+	return "task_dependencies"
+}
+
+// ==================== 通知提醒相关模型 ====================
+
+// NotificationType 通知类型
+type NotificationType string
+
+const (
+	NotificationTypeDueReminder NotificationType = "due_reminder"       // 截止前提醒
+	NotificationTypeOverdue     NotificationType = "overdue"            // 每日逾期通知
+	NotificationTypeEscalation  NotificationType = "overdue_escalation" // 逾期升级给项目管理员
+)
+
+// Notification 用户通知
+type Notification struct {
+	ID        uint             `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	UserID    uint             `json:"user_id" gorm:"not null;index"`
+	ProjectID uint             `json:"project_id" gorm:"index"`
+	TaskID    uint             `json:"task_id" gorm:"index"`
+	Type      NotificationType `json:"type" gorm:"size:30;index"`
+	Title     string           `json:"title" gorm:"size:255"`
+	Content   string           `json:"content" gorm:"type:text"`
+	IsRead    bool             `json:"is_read" gorm:"index"`
+	ReadAt    *time.Time       `json:"read_at"`
+	CreatedAt time.Time        `json:"created_at"`
+
+	// 关联关系
+	Task *Task `json:"task,omitempty" gorm:"foreignkey:TaskID"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// TaskReminderLog 提醒发送记录，用于去重
+// 同一任务、同一用户、同一截止时间下每个提醒（提前量、逾期日期或升级）只发送一次
+type TaskReminderLog struct {
+	ID        uint             `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	TaskID    uint             `json:"task_id" gorm:"not null;unique_index:idx_task_reminder_dedup"`
+	UserID    uint             `json:"user_id" gorm:"not null;unique_index:idx_task_reminder_dedup"`
+	Type      NotificationType `json:"type" gorm:"size:30;unique_index:idx_task_reminder_dedup"`
+	DedupKey  string           `json:"dedup_key" gorm:"size:64;unique_index:idx_task_reminder_dedup"` // 提前量（如 1h0m0s）或逾期日期
+	DueDate   time.Time        `json:"due_date" gorm:"unique_index:idx_task_reminder_dedup"`          // 发送时的截止时间，截止时间修改后重新提醒
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+func (TaskReminderLog) TableName() string {
+	return "task_reminder_logs"
+}
+
+// ==================== 保存的视图相关模型 ====================
+
+// SavedViewMode 视图展示方式
+type SavedViewMode string
+
+const (
+	SavedViewModeBoard    SavedViewMode = "board"    // 看板
+	SavedViewModeList     SavedViewMode = "list"     // 列表
+	SavedViewModeCalendar SavedViewMode = "calendar" // 日历
+)
+
+// SavedView 保存的任务视图（查询、排序、分组和展示方式）
+// ProjectID 为空时是个人的跨项目视图；Shared 为 true 时项目成员都可以使用
+type SavedView struct {
+	ID             uint          `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	Name           string        `json:"name" gorm:"size:100;not null"`
+	OwnerID        uint          `json:"owner_id" gorm:"not null;index"`
+	ProjectID      *uint         `json:"project_id" gorm:"index"`
+	Shared         bool          `json:"shared"`
+	Query          string        `json:"query" gorm:"type:text"`  // 任务查询语言
+	Sort           string        `json:"sort" gorm:"size:255"`    // 排序，如 due_date:asc,priority
+	GroupBy        string        `json:"group_by" gorm:"size:30"` // stage/assignee/priority/status/project
+	Mode           SavedViewMode `json:"mode" gorm:"size:20"`
+	ColumnList     string        `json:"-" gorm:"column:columns;type:text"` // 逗号分隔的可见列
+	VisibleColumns []string      `json:"columns" gorm:"-"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+func (SavedView) TableName() string {
+	return "saved_views"
+}
+
+// BeforeSave 保存前将可见列拼接为字符串
+func (v *SavedView) BeforeSave() error {
+	v.ColumnList = strings.Join(v.VisibleColumns, ",")
+	return nil
+}
+
+// AfterFind 读取后拆分可见列
+func (v *SavedView) AfterFind() error {
+	v.VisibleColumns = []string{}
+	if v.ColumnList != "" {
+		v.VisibleColumns = strings.Split(v.ColumnList, ",")
+	}
+	return nil
+}
+
+// SavedViewDefault 用户在项目中的默认视图
+type SavedViewDefault struct {
+	ID        uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	UserID    uint      `json:"user_id" gorm:"not null;unique_index:idx_saved_view_default"`
+	ProjectID uint      `json:"project_id" gorm:"not null;unique_index:idx_saved_view_default"`
+	ViewID    uint      `json:"view_id" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (SavedViewDefault) TableName() string {
+	return "saved_view_defaults"
+}
+
+// ==================== 工作流相关模型 ====================
+
+// StatusCategory 任务状态分类，统计和完成判断按分类进行
+type StatusCategory string
+
+const (
+	StatusCategoryTodo       StatusCategory = "todo"        // 未开始
+	StatusCategoryInProgress StatusCategory = "in_progress" // 进行中
+	StatusCategoryDone       StatusCategory = "done"        // 已完成
+)
+
+// WorkflowStatus 项目允许的任务状态
+type WorkflowStatus struct {
+	ID        uint           `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	ProjectID uint           `json:"project_id" gorm:"not null;unique_index:idx_workflow_status_key"`
+	Key       string         `json:"key" gorm:"column:status_key;size:50;not null;unique_index:idx_workflow_status_key"` // 保存在 Task.Status 中的值
+	Name      string         `json:"name" gorm:"size:100"`
+	Category  StatusCategory `json:"category" gorm:"size:20"`
+	Color     string         `json:"color" gorm:"size:20"`
+	Position  int            `json:"position"`
+	IsDefault bool           `json:"is_default"` // 新任务的默认状态
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+func (WorkflowStatus) TableName() string {
+	return "workflow_statuses"
+}
+
+// WorkflowPriority 项目允许的任务优先级
+type WorkflowPriority struct {
+	ID        uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	ProjectID uint      `json:"project_id" gorm:"not null;unique_index:idx_workflow_priority_key"`
+	Key       string    `json:"key" gorm:"column:priority_key;size:50;not null;unique_index:idx_workflow_priority_key"` // 保存在 Task.Priority 中的值
+	Name      string    `json:"name" gorm:"size:100"`
+	Color     string    `json:"color" gorm:"size:20"`
+	Level     int       `json:"level"`      // 排序级别，数值越小越紧急
+	IsDefault bool      `json:"is_default"` // 新任务的默认优先级
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (WorkflowPriority) TableName() string {
+	return "workflow_priorities"
+}
+
+// TransitionEffect 阶段流转规则的效果
+type TransitionEffect string
+
+const (
+	TransitionAllow TransitionEffect = "allow" // 目标阶段有允许规则时，只能从规则列出的阶段进入
+	TransitionDeny  TransitionEffect = "deny"  // 禁止从来源阶段进入目标阶段
+)
+
+// StageTransition 阶段流转规则
+type StageTransition struct {
+	ID             uint              `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	ProjectID      uint              `json:"project_id" gorm:"not null;index"`
+	FromStageID    *uint             `json:"from_stage_id"` // 来源阶段，为空表示任意阶段
+	ToStageID      uint              `json:"to_stage_id" gorm:"not null;index"`
+	Effect         TransitionEffect  `json:"effect" gorm:"size:10"`
+	RequiredRole   ProjectMemberRole `json:"required_role" gorm:"size:20"`             // 执行移动所需的最低项目角色，为空不限制
+	FieldList      string            `json:"-" gorm:"column:required_fields;size:255"` // 逗号分隔的必填字段
+	RequiredFields []string          `json:"required_fields" gorm:"-"`                 // 移动前任务必须填写的字段，如 actual_hours
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+func (StageTransition) TableName() string {
+	return "stage_transitions"
+}
+
+// BeforeSave 保存前将必填字段拼接为字符串
+func (t *StageTransition) BeforeSave() error {
+	t.FieldList = strings.Join(t.RequiredFields, ",")
+	return nil
+}
+
+// AfterFind 读取后拆分必填字段
+func (t *StageTransition) AfterFind() error {
+	t.RequiredFields = []string{}
+	if t.FieldList != "" {
+		t.RequiredFields = strings.Split(t.FieldList, ",")
+	}
+	return nil
+}
+
+// ==================== 标签相关模型 ====================
+
+// Label 项目标签
+type Label struct {
+	ID        uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	ProjectID uint      `json:"project_id" gorm:"not null;unique_index:idx_label_name"`
+	Name      string    `json:"name" gorm:"size:50;not null;unique_index:idx_label_name"`
+	Color     string    `json:"color" gorm:"size:20"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Label) TableName() string {
+	return "labels"
+}
+
+// TaskLabel 任务与标签的关联
+type TaskLabel struct {
+	ID        uint      `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	TaskID    uint      `json:"task_id" gorm:"not null;unique_index:idx_task_label"`
+	LabelID   uint      `json:"label_id" gorm:"not null;unique_index:idx_task_label;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (TaskLabel) TableName() string {
+	return "task_labels"
+}
+
+// ==================== 自动化相关模型 ====================
+
+// AutomationTrigger 自动化规则的触发事件
+type AutomationTrigger string
+
+const (
+	TriggerTaskCreated     AutomationTrigger = "task_created"     // 创建任务
+	TriggerTaskMoved       AutomationTrigger = "task_moved"       // 任务移动到阶段，可用 TriggerStageID 限定目标阶段
+	TriggerAssigneeChanged AutomationTrigger = "assignee_changed" // 负责人变化
+	TriggerDueDatePassed   AutomationTrigger = "due_date_passed"  // 截止时间已过且任务未完成
+	TriggerCommentAdded    AutomationTrigger = "comment_added"    // 添加评论
+)
+
+// AutomationCondition 自动化规则的条件，所有条件都满足时执行动作
+type AutomationCondition struct {
+	Field    string   `json:"field"`    // status/priority/assignee_id/stage_id/label/title/due_date
+	Operator string   `json:"operator"` // eq/neq/in/not_in/contains/empty/not_empty
+	Values   []string `json:"values"`
+}
+
+// AutomationAction 自动化规则的动作
+type AutomationAction struct {
+	Type    string `json:"type"`               // set_field/assign_user/add_label/remove_label/move_to_stage/post_comment/send_webhook
+	Field   string `json:"field,omitempty"`    // set_field：status/priority/due_date/estimated_hours/actual_hours
+	Value   string `json:"value,omitempty"`    // set_field 的值，空值表示清空
+	UserID  *uint  `json:"user_id,omitempty"`  // assign_user，为空表示取消分配
+	LabelID uint   `json:"label_id,omitempty"` // add_label/remove_label
+	StageID uint   `json:"stage_id,omitempty"` // move_to_stage
+	Content string `json:"content,omitempty"`  // post_comment 的内容
+	URL     string `json:"url,omitempty"`      // send_webhook 的地址
+}
+
+// AutomationRule 项目自动化规则："当 X 发生且满足条件时执行 Y"
+type AutomationRule struct {
+	ID             uint                  `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	ProjectID      uint                  `json:"project_id" gorm:"not null;index"`
+	Name           string                `json:"name" gorm:"size:100;not null"`
+	Enabled        bool                  `json:"enabled"`
+	Trigger        AutomationTrigger     `json:"trigger" gorm:"column:trigger_event;size:30;index"`
+	TriggerStageID *uint                 `json:"trigger_stage_id"`                     // task_moved 时限定的目标阶段
+	ConditionsJSON string                `json:"-" gorm:"column:conditions;type:text"` // 条件的 JSON
+	ActionsJSON    string                `json:"-" gorm:"column:actions;type:text"`    // 动作的 JSON
+	Conditions     []AutomationCondition `json:"conditions" gorm:"-"`
+	Actions        []AutomationAction    `json:"actions" gorm:"-"`
+	RunCount       int                   `json:"run_count" gorm:"default:0"`
+	LastRunAt      *time.Time            `json:"last_run_at"`
+	LastError      string                `json:"last_error" gorm:"type:text"`
+	CreatedBy      uint                  `json:"created_by" gorm:"not null"` // 自动化产生的活动和评论记在创建者名下
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+func (AutomationRule) TableName() string {
+	return "automation_rules"
+}
+
+// BeforeSave 保存前序列化条件和动作
+func (r *AutomationRule) BeforeSave() error {
+	conditions, err := json.Marshal(r.Conditions)
+	if err != nil {
+		return err
+	}
+	actions, err := json.Marshal(r.Actions)
+	if err != nil {
+		return err
+	}
+	r.ConditionsJSON = string(conditions)
+	r.ActionsJSON = string(actions)
+	return nil
+}
+
+// AfterFind 读取后解析条件和动作
+func (r *AutomationRule) AfterFind() error {
+	r.Conditions = []AutomationCondition{}
+	r.Actions = []AutomationAction{}
+	if r.ConditionsJSON != "" {
+		if err := json.Unmarshal([]byte(r.ConditionsJSON), &r.Conditions); err != nil {
+			return err
+		}
+	}
+	if r.ActionsJSON != "" {
+		if err := json.Unmarshal([]byte(r.ActionsJSON), &r.Actions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ==================== 在制品限制相关模型 ====================
+
+// WIPMode 在制品限制模式
+type WIPMode string
+
+const (
+	WIPModeHard WIPMode = "hard" // 超出上限时拒绝操作
+	WIPModeSoft WIPMode = "soft" // 超出上限时允许操作，返回警告
+)
+
+// WIPScope 在制品限制的范围
+type WIPScope string
+
+const (
+	WIPScopeStage    WIPScope = "stage"    // 阶段内全部任务
+	WIPScopeAssignee WIPScope = "assignee" // 阶段内同一负责人的任务
+)
+
+// WIPViolation 在制品限制违规记录，被拒绝的操作和软限制下放行的操作都会记录
+type WIPViolation struct {
+	ID         uint      `json:"id" gorm:"primary_key;autoIncrement"`
+	ProjectID  uint      `json:"project_id" gorm:"not null;index"`
+	StageID    uint      `json:"stage_id" gorm:"not null;index"`
+	TaskID     *uint     `json:"task_id"`     // 触发违规的任务，批量操作或被拒绝的创建为空
+	AssigneeID *uint     `json:"assignee_id"` // 负责人范围的违规对应的负责人
+	Scope      WIPScope  `json:"scope" gorm:"size:20"`
+	Mode       WIPMode   `json:"mode" gorm:"size:10"`
+	Limit      int       `json:"limit" gorm:"column:limit_value"`
+	Count      int       `json:"count"`                 // 操作完成后（或被拒绝的操作完成后将达到）的任务数
+	Action     string    `json:"action" gorm:"size:20"` // create/move/bulk_move/clone/transfer/assign/automation
+	Blocked    bool      `json:"blocked"`
+	UserID     uint      `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (WIPViolation) TableName() string {
+	return "wip_violations"
+}
+
+// ==================== 项目模板相关模型 ====================
+
+// TemplateStage 模板中的阶段，Ref 是模板内引用阶段的标识
+type TemplateStage struct {
+	Ref                 string  `json:"ref"`
+	Name                string  `json:"name"`
+	Description         string  `json:"description,omitempty"`
+	Color               string  `json:"color,omitempty"`
+	IsCompleted         bool    `json:"is_completed,omitempty"`
+	MaxTasks            int     `json:"max_tasks,omitempty"`
+	MaxTasksPerAssignee int     `json:"max_tasks_per_assignee,omitempty"`
+	WIPMode             WIPMode `json:"wip_mode,omitempty"`
+	AllowTaskCreation   *bool   `json:"allow_task_creation,omitempty"` // 为空时允许
+	AllowTaskDeletion   *bool   `json:"allow_task_deletion,omitempty"`
+	AllowTaskMovement   *bool   `json:"allow_task_movement,omitempty"`
+	NotificationEnabled *bool   `json:"notification_enabled,omitempty"`
+	AutoAssignStatus    string  `json:"auto_assign_status,omitempty"`
+}
+
+// TemplateLabel 模板中的标签
+type TemplateLabel struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// TemplateTransition 模板中的阶段流转规则，阶段用 Ref 引用
+type TemplateTransition struct {
+	FromStage      string            `json:"from_stage,omitempty"` // 为空表示任意阶段
+	ToStage        string            `json:"to_stage"`
+	Effect         TransitionEffect  `json:"effect"`
+	RequiredRole   ProjectMemberRole `json:"required_role,omitempty"`
+	RequiredFields []string          `json:"required_fields,omitempty"`
+}
+
+// TemplateAction 模板中的自动化动作，阶段用 Ref、标签用名称引用
+type TemplateAction struct {
+	Type      string `json:"type"`
+	Field     string `json:"field,omitempty"`
+	Value     string `json:"value,omitempty"`
+	UserID    *uint  `json:"user_id,omitempty"`    // assign_user；用户不是新项目成员时跳过该规则
+	UserEmail string `json:"user_email,omitempty"` // 项目导出包中用邮箱引用 assign_user 的用户，导入时换成本地用户ID
+	Label     string `json:"label,omitempty"`
+	Stage     string `json:"stage,omitempty"`
+	Content   string `json:"content,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// TemplateAutomation 模板中的自动化规则；stage_id 条件的取值为阶段 Ref，label 条件的取值为标签名称
+type TemplateAutomation struct {
+	Name         string                `json:"name"`
+	Enabled      bool                  `json:"enabled"`
+	Trigger      AutomationTrigger     `json:"trigger"`
+	TriggerStage string                `json:"trigger_stage,omitempty"`
+	Conditions   []AutomationCondition `json:"conditions,omitempty"`
+	Actions      []TemplateAction      `json:"actions"`
+}
+
+// TemplateTask 模板中的初始任务
+type TemplateTask struct {
+	Title          string   `json:"title"`
+	Description    string   `json:"description,omitempty"`
+	Stage          string   `json:"stage"`              // 阶段 Ref
+	Status         string   `json:"status,omitempty"`   // 为空时使用阶段自动设置的状态或默认状态
+	Priority       string   `json:"priority,omitempty"` // 为空时使用默认优先级
+	EstimatedHours *float64 `json:"estimated_hours,omitempty"`
+	Labels         []string `json:"labels,omitempty"` // 标签名称
+}
+
+// TemplateStatus 模板中的任务状态
+type TemplateStatus struct {
+	Key       string         `json:"key"`
+	Name      string         `json:"name,omitempty"`
+	Category  StatusCategory `json:"category"`
+	Color     string         `json:"color,omitempty"`
+	IsDefault bool           `json:"is_default,omitempty"`
+}
+
+// TemplatePriority 模板中的优先级，顺序即级别
+type TemplatePriority struct {
+	Key       string `json:"key"`
+	Name      string `json:"name,omitempty"`
+	Color     string `json:"color,omitempty"`
+	IsDefault bool   `json:"is_default,omitempty"`
+}
+
+// TemplateDefinition 项目模板的内容；状态或优先级为空时使用默认工作流
+type TemplateDefinition struct {
+	Statuses    []TemplateStatus     `json:"statuses,omitempty"`
+	Priorities  []TemplatePriority   `json:"priorities,omitempty"`
+	Stages      []TemplateStage      `json:"stages"`
+	Labels      []TemplateLabel      `json:"labels,omitempty"`
+	Transitions []TemplateTransition `json:"transitions,omitempty"`
+	Automations []TemplateAutomation `json:"automations,omitempty"`
+	Tasks       []TemplateTask       `json:"tasks,omitempty"`
+}
+
+// ProjectTemplate 项目模板，创建项目时按模板生成阶段、标签、流转规则、自动化规则和初始任务
+// 内置模板（Builtin）在启动时同步，所有用户可用；用户保存的模板仅所有者可用，Shared 为 true 时所有用户可用
+type ProjectTemplate struct {
+	ID             uint               `json:"id" gorm:"primary_key;AUTO_INCREMENT"`
+	BuiltinKey     string             `json:"builtin_key,omitempty" gorm:"size:50;index"` // 内置模板的标识，如 kanban
+	Name           string             `json:"name" gorm:"size:100;not null"`
+	Description    string             `json:"description" gorm:"type:text"`
+	Builtin        bool               `json:"builtin"`
+	OwnerID        *uint              `json:"owner_id" gorm:"index"` // 内置模板为空
+	Shared         bool               `json:"shared"`
+	DefinitionJSON string             `json:"-" gorm:"column:definition;type:text"`
+	Definition     TemplateDefinition `json:"definition" gorm:"-"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+func (ProjectTemplate) TableName() string {
+	return "project_templates"
+}
+
+// BeforeSave 保存前序列化模板内容
+func (t *ProjectTemplate) BeforeSave() error {
+	definition, err := json.Marshal(t.Definition)
+	if err != nil {
+		return err
+	}
+	t.DefinitionJSON = string(definition)
+	return nil
+}
+
+// AfterFind 读取后解析模板内容
+func (t *ProjectTemplate) AfterFind() error {
+	t.Definition = TemplateDefinition{}
+	if t.DefinitionJSON == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(t.DefinitionJSON), &t.Definition)
+}
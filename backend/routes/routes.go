@@ -6,6 +6,7 @@ import (
 	"project-manager-backend/handlers"
 	"project-manager-backend/middleware"
 	"project-manager-backend/models"
+	"project-manager-backend/services"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -75,11 +76,13 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		users := api.Group("/users")
 		{
 			userHandler := &handlers.UserHandler{}
-			users.POST("/search", userHandler.SearchUsers) // 搜索用户
-			users.GET("", userHandler.GetUsers)            // 获取用户列表（管理员）
-			users.POST("", userHandler.CreateUser)         // 创建用户（管理员）
-			users.PUT("/:id", userHandler.UpdateUser)      // 更新用户（管理员）
-			users.DELETE("/:id", userHandler.DeleteUser)   // 删除用户（管理员）
+			users.POST("/search", userHandler.SearchUsers)                // 搜索用户
+			users.GET("", userHandler.GetUsers)                           // 获取用户列表（管理员）
+			users.POST("", userHandler.CreateUser)                        // 创建用户（管理员）
+			users.PUT("/:id", userHandler.UpdateUser)                     // 更新用户（管理员）
+			users.DELETE("/:id", userHandler.DeleteUser)                  // 删除用户（管理员）
+			users.GET("/me/preferences", userHandler.GetMyPreferences)    // 获取个人偏好（时区等）
+			users.PUT("/me/preferences", userHandler.UpdateMyPreferences) // 更新个人偏好
 		}
 
 		// 用户在线状态相关路由
@@ -104,12 +107,57 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		projects := api.Group("/projects")
 		{
 			projectHandler := &handlers.ProjectHandler{}
-			projects.GET("", projectHandler.GetProjects)                               // 获取项目列表
-			projects.POST("", projectHandler.CreateProject)                            // 创建项目
-			projects.GET("/:id", projectHandler.GetProject)                            // 获取项目详情
-			projects.PUT("/:id", projectHandler.UpdateProject)                         // 更新项目
-			projects.DELETE("/:id", projectHandler.DeleteProject)                      // 删除项目
-			projects.GET("/:id/collaborators", projectHandler.GetProjectCollaborators) // 获取项目协作人员
+			timelineHandler := handlers.NewTimelineHandler()
+			savedViewHandler := &handlers.SavedViewHandler{}
+			workflowHandler := handlers.NewWorkflowHandler()
+			transitionHandler := handlers.NewStageTransitionHandler()
+			labelHandler := handlers.NewLabelHandler()
+			automationHandler := handlers.NewAutomationHandler()
+			wipHandler := handlers.NewWIPHandler()
+			boardHandler := handlers.NewBoardHandler()
+			templateHandler := handlers.NewProjectTemplateHandler()
+			projects.GET("", projectHandler.GetProjects)                                        // 获取项目列表
+			projects.GET("/archived", projectHandler.GetArchivedProjects)                       // 获取已归档的项目
+			projects.POST("", projectHandler.CreateProject)                                     // 创建项目
+			projects.POST("/import", projectHandler.ImportProject)                              // 从导出包导入项目
+			projects.POST("/import/trello", projectHandler.ImportTrelloBoard)                   // 导入 Trello 看板
+			projects.POST("/import/jira", projectHandler.ImportJiraCSV)                         // 导入 Jira CSV
+			projects.GET("/:id", projectHandler.GetProject)                                     // 获取项目详情
+			projects.PUT("/:id", projectHandler.UpdateProject)                                  // 更新项目
+			projects.DELETE("/:id", projectHandler.DeleteProject)                               // 删除项目
+			projects.POST("/:id/clone", projectHandler.CloneProject)                            // 复制项目
+			projects.GET("/:id/export", projectHandler.ExportProject)                           // 导出项目
+			projects.POST("/:id/archive", projectHandler.ArchiveProject)                        // 归档项目
+			projects.POST("/:id/unarchive", projectHandler.UnarchiveProject)                    // 恢复已归档的项目
+			projects.GET("/:id/collaborators", projectHandler.GetProjectCollaborators)          // 获取项目协作人员
+			projects.GET("/:id/timeline", timelineHandler.GetProjectTimeline)                   // 获取项目时间线（甘特图）
+			projects.GET("/:id/default-view", savedViewHandler.GetProjectDefaultView)           // 获取我的默认视图
+			projects.GET("/:id/workflow", workflowHandler.GetWorkflow)                          // 获取任务状态和优先级定义
+			projects.PUT("/:id/workflow/statuses", workflowHandler.UpdateStatuses)              // 更新任务状态定义
+			projects.PUT("/:id/workflow/priorities", workflowHandler.UpdatePriorities)          // 更新任务优先级定义
+			projects.GET("/:id/stage-transitions", transitionHandler.GetTransitions)            // 获取阶段流转规则
+			projects.PUT("/:id/stage-transitions", transitionHandler.UpdateTransitions)         // 更新阶段流转规则
+			projects.GET("/:id/labels", labelHandler.GetProjectLabels)                          // 获取项目标签
+			projects.POST("/:id/labels", labelHandler.CreateLabel)                              // 创建项目标签
+			projects.DELETE("/:id/labels/:labelId", labelHandler.DeleteLabel)                   // 删除项目标签
+			projects.GET("/:id/automations", automationHandler.GetAutomationRules)              // 获取自动化规则
+			projects.POST("/:id/automations", automationHandler.CreateAutomationRule)           // 创建自动化规则
+			projects.PUT("/:id/automations/:ruleId", automationHandler.UpdateAutomationRule)    // 更新自动化规则
+			projects.DELETE("/:id/automations/:ruleId", automationHandler.DeleteAutomationRule) // 删除自动化规则
+			projects.GET("/:id/wip", wipHandler.GetWIPReport)                                   // 获取在制品限制报表
+			projects.GET("/:id/archived-tasks", (&handlers.TaskHandler{}).GetArchivedTasks)     // 获取已归档的任务
+			projects.GET("/:id/board", boardHandler.GetBoard)                                   // 获取按泳道分组的看板
+			projects.POST("/:id/save-as-template", templateHandler.SaveProjectAsTemplate)       // 把项目保存为模板
+		}
+
+		// 项目模板相关路由
+		projectTemplates := api.Group("/project-templates")
+		{
+			templateHandler := handlers.NewProjectTemplateHandler()
+			projectTemplates.GET("", templateHandler.GetTemplates)          // 获取可用的项目模板
+			projectTemplates.GET("/:id", templateHandler.GetTemplate)       // 获取模板详情
+			projectTemplates.POST("", templateHandler.CreateTemplate)       // 创建模板
+			projectTemplates.DELETE("/:id", templateHandler.DeleteTemplate) // 删除模板
 		}
 
 		// 协作人员相关路由
@@ -144,6 +192,8 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			stages.PUT("/:id", stageHandler.UpdateStage)
 			stages.DELETE("/:id", stageHandler.DeleteStage)
 			stages.POST("/reorder", stageHandler.ReorderStages)
+			stages.POST("/merge", stageHandler.MergeStages)    // 合并阶段
+			stages.POST("/:id/split", stageHandler.SplitStage) // 拆分阶段
 		}
 
 		// 项目阶段相关路由（独立的路由组）
@@ -156,16 +206,43 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 		// 任务相关路由
 		tasks := api.Group("/tasks")
+		tasks.Use(middleware.TaskKeyMiddleware("id")) // 路由中的任务ID也可以使用任务编号
 		{
 			taskHandler := &handlers.TaskHandler{
-				ActivityService: handlers.NewTaskActivityHandler().ActivityService,
+				ActivityService:   handlers.NewTaskActivityHandler().ActivityService,
+				RankService:       services.NewTaskRankService(),
+				KeyService:        services.NewTaskKeyService(),
+				SearchService:     services.NewSearchService(),
+				WorkflowService:   services.NewWorkflowService(),
+				TransitionService: services.NewStageTransitionService(),
+				AutomationService: services.NewAutomationService(),
+				WIPService:        services.NewWIPService(),
 			}
 			tasks.GET("", taskHandler.GetTasks)
+			tasks.GET("/by-key/:key", taskHandler.GetTaskByKey) // 根据任务编号获取任务
+			tasks.GET("/search", taskHandler.SearchTasks)       // 跨项目搜索任务（查询语言）
 			tasks.POST("", taskHandler.CreateTask)
 			tasks.PUT("/:id", taskHandler.UpdateTask)
 			tasks.DELETE("/:id", taskHandler.DeleteTask)
 			tasks.PATCH("/:id/move", taskHandler.MoveTask)
 			tasks.POST("/reorder", taskHandler.ReorderTasks)
+			tasks.POST("/bulk-move", taskHandler.BulkMoveTasks)      // 批量移动任务到另一个阶段
+			tasks.POST("/:id/clone", taskHandler.CloneTask)          // 复制任务
+			tasks.PATCH("/:id/transfer", taskHandler.TransferTask)   // 跨项目移动任务
+			tasks.POST("/:id/archive", taskHandler.ArchiveTask)      // 归档任务
+			tasks.POST("/:id/unarchive", taskHandler.UnarchiveTask)  // 恢复已归档的任务
+			tasks.POST("/:id/board-move", taskHandler.MoveBoardTask) // 在泳道看板上移动任务
+
+			timelineHandler := handlers.NewTimelineHandler()
+			tasks.GET("/:id/dependencies", timelineHandler.GetTaskDependencies)                  // 获取任务依赖
+			tasks.POST("/:id/dependencies", timelineHandler.AddTaskDependency)                   // 添加任务依赖
+			tasks.DELETE("/:id/dependencies/:dependsOnId", timelineHandler.RemoveTaskDependency) // 删除任务依赖
+			tasks.POST("/:id/shift", timelineHandler.ShiftTaskDates)                             // 平移任务日期
+
+			labelHandler := handlers.NewLabelHandler()
+			tasks.GET("/:id/labels", labelHandler.GetTaskLabels)               // 获取任务标签
+			tasks.POST("/:id/labels", labelHandler.AddTaskLabel)               // 给任务添加标签
+			tasks.DELETE("/:id/labels/:labelId", labelHandler.RemoveTaskLabel) // 移除任务标签
 		}
 
 		// 项目任务相关路由（独立的路由组）
@@ -175,11 +252,16 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			taskHandler := &handlers.TaskHandler{}
 			projectTasks.GET("/:projectId", taskHandler.GetTasks)
 			projectTasks.GET("/:projectId/completed-stats", taskHandler.GetCompletedTasksStats)
+
+			csvHandler := handlers.NewTaskCSVHandler()
+			projectTasks.GET("/:projectId/export", csvHandler.ExportTasksCSV)  // 导出任务 CSV
+			projectTasks.POST("/:projectId/import", csvHandler.ImportTasksCSV) // 从 CSV 导入任务
 		}
 
 		// 任务评论路由（独立的路由组）
 		taskComments := api.Group("/task-comments")
 		taskComments.Use(middleware.AuthMiddleware())
+		taskComments.Use(middleware.TaskKeyMiddleware("taskId"))
 		{
 			commentHandler := &handlers.CommentHandler{}
 			taskComments.GET("/:taskId", commentHandler.GetTaskComments)
@@ -189,6 +271,7 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 		// 任务活动记录路由
 		taskActivities := api.Group("/task-activities")
+		taskActivities.Use(middleware.TaskKeyMiddleware("taskId"))
 		{
 			activityHandler := handlers.NewTaskActivityHandler()
 			taskActivities.GET("/task/:taskId", activityHandler.GetTaskActivities)            // 获取任务活动记录
@@ -206,6 +289,40 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 			comments.DELETE("/:id", commentHandler.DeleteComment)
 		}
 
+		// 保存的视图相关路由
+		views := api.Group("/views")
+		{
+			savedViewHandler := &handlers.SavedViewHandler{}
+			views.GET("", savedViewHandler.GetSavedViews)                   // 获取可用视图
+			views.POST("", savedViewHandler.CreateSavedView)                // 创建视图
+			views.GET("/:id", savedViewHandler.GetSavedView)                // 获取视图详情
+			views.PUT("/:id", savedViewHandler.UpdateSavedView)             // 更新视图
+			views.DELETE("/:id", savedViewHandler.DeleteSavedView)          // 删除视图
+			views.GET("/:id/tasks", savedViewHandler.ExecuteSavedView)      // 执行视图，返回匹配的任务
+			views.PUT("/:id/default", savedViewHandler.SetDefaultView)      // 设为项目默认视图
+			views.DELETE("/:id/default", savedViewHandler.ClearDefaultView) // 取消默认视图
+		}
+
+		// 全文搜索路由
+		search := api.Group("/search")
+		{
+			searchHandler := handlers.NewSearchHandler()
+			search.GET("", searchHandler.Search)                // 搜索任务和评论
+			search.POST("/reindex", searchHandler.RebuildIndex) // 重建搜索索引（管理员）
+		}
+
+		// 通知相关路由
+		notifications := api.Group("/notifications")
+		{
+			notificationHandler := handlers.NewNotificationHandler(
+				services.NewReminderService(cfg.Reminder.Offsets, cfg.Reminder.EscalationDays),
+			)
+			notifications.GET("", notificationHandler.GetNotifications)                  // 获取我的通知
+			notifications.PUT("/:id/read", notificationHandler.MarkNotificationRead)     // 标记通知为已读
+			notifications.PUT("/read-all", notificationHandler.MarkAllNotificationsRead) // 全部标记为已读
+			notifications.POST("/run-reminders", notificationHandler.RunReminders)       // 立即执行提醒扫描（管理员）
+		}
+
 		// 统计相关路由
 		analytics := api.Group("/analytics")
 		{
@@ -0,0 +1,772 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"project-manager-backend/models"
+	"project-manager-backend/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 自动化规则引擎
+//
+// 规则由触发事件、条件和动作组成。触发事件在原操作的事务提交后分发，匹配的规则依次执行：
+// 每条规则的动作在一个事务中完成，失败时回滚并记录在规则的 last_error 中。
+// 动作可能引发新的事件（例如移动任务触发 task_moved、发表评论触发 comment_added），
+// 新事件继续匹配规则。为防止规则互相触发形成循环，一次分发中同一条规则对同一任务只执行一次，
+// 并且事件最多嵌套 automationMaxDepth 层。
+
+// automationMaxDepth 自动化引发的事件最大嵌套层数
+const automationMaxDepth = 5
+
+// automationDedupType 截止时间已过事件的去重记录类型（复用提醒去重表）
+const automationDedupType models.NotificationType = "automation"
+
+// AutomationService 项目自动化规则服务
+type AutomationService struct {
+	Activity    *TaskActivityService
+	Workflow    *WorkflowService
+	Rank        *TaskRankService
+	Labels      *LabelService
+	Search      *SearchService
+	WIP         *WIPService
+	Transitions *StageTransitionService
+	HTTPClient  *http.Client
+}
+
+// NewAutomationService 创建项目自动化规则服务
+func NewAutomationService() *AutomationService {
+	return &AutomationService{
+		Activity:    NewTaskActivityService(),
+		Workflow:    NewWorkflowService(),
+		Rank:        NewTaskRankService(),
+		Labels:      NewLabelService(),
+		Search:      NewSearchService(),
+		WIP:         NewWIPService(),
+		Transitions: NewStageTransitionService(),
+		HTTPClient:  newWebhookClient(),
+	}
+}
+
+// AutomationEvent 触发自动化的事件
+type AutomationEvent struct {
+	Trigger   models.AutomationTrigger `json:"trigger"`
+	ProjectID uint                     `json:"project_id"`
+	TaskID    uint                     `json:"task_id"`
+	ActorID   uint                     `json:"actor_id"`             // 触发事件的用户，由自动化引发时为规则创建者，定时触发时为0
+	StageID   uint                     `json:"stage_id,omitempty"`   // task_moved 的目标阶段
+	CommentID uint                     `json:"comment_id,omitempty"` // comment_added 的评论
+	RuleID    uint                     `json:"rule_id,omitempty"`    // 引发该事件的规则，0 表示用户操作
+}
+
+// wipBlockedError 动作因硬性在制品上限被拒绝
+type wipBlockedError struct {
+	result *WIPResult
+}
+
+func (e *wipBlockedError) Error() string {
+	return e.result.Error()
+}
+
+// automationEffects 规则执行后需要在事务提交后处理的内容
+type automationEffects struct {
+	activities []func()
+	events     []AutomationEvent
+	webhooks   []string
+}
+
+// automationLookups 规则动作需要的权限、流转规则和时区
+// 数据库只有一个连接，规则的事务中不能再通过 database.DB 查询，这些数据在开始事务前准备好
+type automationLookups struct {
+	members     map[uint]bool    // assign_user 的目标用户是否是项目成员
+	transitions *TransitionRules // move_to_stage 检查的流转规则，已预先查询规则创建者的角色
+	location    *time.Location   // set_field due_date 解析日期使用的项目时区
+}
+
+var automationTriggers = map[models.AutomationTrigger]bool{
+	models.TriggerTaskCreated:     true,
+	models.TriggerTaskMoved:       true,
+	models.TriggerAssigneeChanged: true,
+	models.TriggerDueDatePassed:   true,
+	models.TriggerCommentAdded:    true,
+}
+
+var automationConditionFields = map[string]bool{
+	"status": true, "priority": true, "assignee_id": true, "stage_id": true,
+	"label": true, "title": true, "due_date": true,
+}
+
+var automationOperators = map[string]bool{
+	"eq": true, "neq": true, "in": true, "not_in": true,
+	"contains": true, "empty": true, "not_empty": true,
+}
+
+var automationSetFields = map[string]bool{
+	"status": true, "priority": true, "due_date": true, "estimated_hours": true, "actual_hours": true,
+}
+
+// ValidateRule 检查规则的触发事件、条件和动作，引用的阶段、标签和用户必须属于规则所在项目
+func (s *AutomationService) ValidateRule(db *gorm.DB, rule *models.AutomationRule) error {
+	if strings.TrimSpace(rule.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !automationTriggers[rule.Trigger] {
+		return fmt.Errorf("invalid trigger %q", rule.Trigger)
+	}
+	if rule.TriggerStageID != nil {
+		if rule.Trigger != models.TriggerTaskMoved {
+			return fmt.Errorf("trigger_stage_id is only supported for task_moved")
+		}
+		if !s.stageInProject(db, rule.ProjectID, *rule.TriggerStageID) {
+			return fmt.Errorf("trigger_stage_id %d is not a stage of this project", *rule.TriggerStageID)
+		}
+	}
+
+	for i, condition := range rule.Conditions {
+		if !automationConditionFields[condition.Field] {
+			return fmt.Errorf("condition %d: unsupported field %q", i+1, condition.Field)
+		}
+		if !automationOperators[condition.Operator] {
+			return fmt.Errorf("condition %d: unsupported operator %q", i+1, condition.Operator)
+		}
+		if condition.Operator != "empty" && condition.Operator != "not_empty" && len(condition.Values) == 0 {
+			return fmt.Errorf("condition %d: values are required", i+1)
+		}
+	}
+
+	if len(rule.Actions) == 0 {
+		return fmt.Errorf("at least one action is required")
+	}
+	workflow, err := s.Workflow.GetWorkflow(db, rule.ProjectID)
+	if err != nil {
+		return err
+	}
+	for i, action := range rule.Actions {
+		if err := s.validateAction(db, rule.ProjectID, workflow, action); err != nil {
+			return fmt.Errorf("action %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *AutomationService) validateAction(db *gorm.DB, projectID uint, workflow *Workflow, action models.AutomationAction) error {
+	switch action.Type {
+	case "set_field":
+		if !automationSetFields[action.Field] {
+			return fmt.Errorf("unsupported field %q", action.Field)
+		}
+		switch action.Field {
+		case "status":
+			return workflow.ValidateStatus(action.Value)
+		case "priority":
+			return workflow.ValidatePriority(action.Value)
+		case "due_date":
+			if action.Value != "" {
+				if _, _, err := utils.ParseDueDate(action.Value, nil, time.UTC); err != nil {
+					return err
+				}
+			}
+		case "estimated_hours", "actual_hours":
+			if action.Value != "" {
+				if _, err := strconv.ParseFloat(action.Value, 64); err != nil {
+					return fmt.Errorf("invalid number %q", action.Value)
+				}
+			}
+		}
+	case "assign_user":
+		if action.UserID != nil && !utils.CanManageTasks(*action.UserID, projectID) {
+			return fmt.Errorf("user %d is not a member of this project", *action.UserID)
+		}
+	case "add_label", "remove_label":
+		var count int
+		db.Model(&models.Label{}).Where("id = ? AND project_id = ?", action.LabelID, projectID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("label %d not found in project", action.LabelID)
+		}
+	case "move_to_stage":
+		if !s.stageInProject(db, projectID, action.StageID) {
+			return fmt.Errorf("stage %d is not a stage of this project", action.StageID)
+		}
+	case "post_comment":
+		if strings.TrimSpace(action.Content) == "" {
+			return fmt.Errorf("content is required")
+		}
+	case "send_webhook":
+		if err := CheckWebhookURL(action.URL); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported action type %q", action.Type)
+	}
+	return nil
+}
+
+func (s *AutomationService) stageInProject(db *gorm.DB, projectID, stageID uint) bool {
+	var count int
+	db.Model(&models.Stage{}).Where("id = ? AND project_id = ?", stageID, projectID).Count(&count)
+	return count > 0
+}
+
+// Dispatch 分发事件并执行匹配的规则，包括规则引发的后续事件，返回执行的规则数
+// 需要在触发操作的事务提交后调用，规则执行失败只记录在规则上，不影响调用方
+func (s *AutomationService) Dispatch(db *gorm.DB, event AutomationEvent) int {
+	type queuedEvent struct {
+		event AutomationEvent
+		depth int
+	}
+	queue := []queuedEvent{{event: event}}
+	fired := make(map[string]bool)
+	runs := 0
+
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		if item.depth >= automationMaxDepth {
+			log.Printf("Automation stopped at depth %d for task %d (trigger %s)", item.depth, item.event.TaskID, item.event.Trigger)
+			continue
+		}
+
+		rules, err := s.matchingRules(db, item.event)
+		if err != nil {
+			log.Printf("Failed to load automation rules for project %d: %v", item.event.ProjectID, err)
+			continue
+		}
+		for i := range rules {
+			rule := &rules[i]
+			key := fmt.Sprintf("%d:%d", rule.ID, item.event.TaskID)
+			if fired[key] {
+				continue
+			}
+
+			var task models.Task
+			if err := db.Preload("Stage").First(&task, item.event.TaskID).Error; err != nil {
+				break // 任务已被删除
+			}
+			if task.ProjectID != rule.ProjectID {
+				break // 任务已被前面的规则移出项目
+			}
+			matched, err := s.matchConditions(db, rule, &task)
+			if err != nil {
+				s.recordRun(db, rule, err)
+				continue
+			}
+			if !matched {
+				continue
+			}
+
+			fired[key] = true
+			runs++
+			effects, err := s.execute(db, rule, &task, item.event)
+			s.recordRun(db, rule, err)
+			if err != nil {
+				log.Printf("Automation rule %d failed on task %d: %v", rule.ID, task.ID, err)
+				continue
+			}
+			for _, next := range effects.events {
+				queue = append(queue, queuedEvent{event: next, depth: item.depth + 1})
+			}
+		}
+	}
+	return runs
+}
+
+// matchingRules 项目中响应该事件的已启用规则
+func (s *AutomationService) matchingRules(db *gorm.DB, event AutomationEvent) ([]models.AutomationRule, error) {
+	query := db.Where("project_id = ? AND enabled = ? AND trigger_event = ?", event.ProjectID, true, event.Trigger)
+	if event.Trigger == models.TriggerTaskMoved {
+		query = query.Where("trigger_stage_id IS NULL OR trigger_stage_id = ?", event.StageID)
+	}
+	var rules []models.AutomationRule
+	err := query.Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// matchConditions 任务是否满足规则的全部条件
+func (s *AutomationService) matchConditions(db *gorm.DB, rule *models.AutomationRule, task *models.Task) (bool, error) {
+	for _, condition := range rule.Conditions {
+		var values []string
+		switch condition.Field {
+		case "status":
+			values = []string{task.Status}
+		case "priority":
+			values = []string{task.Priority}
+		case "assignee_id":
+			if task.AssigneeID != nil {
+				values = []string{strconv.FormatUint(uint64(*task.AssigneeID), 10)}
+			}
+		case "stage_id":
+			values = []string{strconv.FormatUint(uint64(task.StageID), 10)}
+		case "title":
+			values = []string{task.Title}
+		case "due_date":
+			if task.DueDate != nil {
+				values = []string{utils.FormatDueDate(*task.DueDate, task.DueAllDay)}
+			}
+		case "label":
+			labels, err := s.Labels.TaskLabels(db, task.ID)
+			if err != nil {
+				return false, err
+			}
+			// 标签可以用名称或ID匹配
+			for _, label := range labels {
+				values = append(values, label.Name, strconv.FormatUint(uint64(label.ID), 10))
+			}
+		}
+		if !matchCondition(condition, values) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchCondition 按运算符比较字段的取值，values 为空表示字段未设置
+func matchCondition(condition models.AutomationCondition, values []string) bool {
+	contains := func(targets []string) bool {
+		for _, value := range values {
+			for _, target := range targets {
+				if value == target {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch condition.Operator {
+	case "empty":
+		return len(values) == 0 || (len(values) == 1 && values[0] == "")
+	case "not_empty":
+		return !(len(values) == 0 || (len(values) == 1 && values[0] == ""))
+	case "eq":
+		return contains(condition.Values[:1])
+	case "neq":
+		return !contains(condition.Values[:1])
+	case "in":
+		return contains(condition.Values)
+	case "not_in":
+		return !contains(condition.Values)
+	case "contains":
+		for _, value := range values {
+			for _, target := range condition.Values {
+				if strings.Contains(strings.ToLower(value), strings.ToLower(target)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// execute 在一个事务中执行规则的全部动作，提交后记录活动并发送 webhook
+func (s *AutomationService) execute(db *gorm.DB, rule *models.AutomationRule, task *models.Task, event AutomationEvent) (*automationEffects, error) {
+	effects := &automationEffects{}
+	lookups, err := s.prepareLookups(db, rule, task)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	for i, action := range rule.Actions {
+		if err := s.applyAction(tx, rule, task, action, lookups, effects); err != nil {
+			tx.Rollback()
+			if blocked, ok := err.(*wipBlockedError); ok {
+				if err := s.WIP.Record(db, blocked.result, task.ID); err != nil {
+					log.Printf("Failed to record WIP violation: %v", err)
+				}
+			}
+			return nil, fmt.Errorf("action %d (%s): %v", i+1, action.Type, err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	for _, logActivity := range effects.activities {
+		logActivity()
+	}
+	if err := s.Search.IndexTask(db, task); err != nil {
+		log.Printf("Failed to index task %d: %v", task.ID, err)
+	}
+	for _, target := range effects.webhooks {
+		go s.sendWebhook(target, rule, task, event)
+	}
+	return effects, nil
+}
+
+// prepareLookups 在开始规则的事务前查询动作需要的权限、流转规则和时区
+func (s *AutomationService) prepareLookups(db *gorm.DB, rule *models.AutomationRule, task *models.Task) (*automationLookups, error) {
+	lookups := &automationLookups{members: make(map[uint]bool)}
+	for _, action := range rule.Actions {
+		switch action.Type {
+		case "assign_user":
+			if action.UserID != nil {
+				lookups.members[*action.UserID] = utils.CanManageTasks(*action.UserID, task.ProjectID)
+			}
+		case "move_to_stage":
+			if lookups.transitions == nil {
+				rules, err := s.Transitions.LoadRules(db, task.ProjectID)
+				if err != nil {
+					return nil, err
+				}
+				rules.PreloadRoles(rule.CreatedBy)
+				lookups.transitions = rules
+			}
+		case "set_field":
+			if action.Field == "due_date" && lookups.location == nil {
+				lookups.location = utils.ResolveTimezone(utils.ProjectTimezone(task.ProjectID))
+			}
+		}
+	}
+	return lookups, nil
+}
+
+// applyAction 执行单个动作，task 会同步更新为修改后的值
+func (s *AutomationService) applyAction(tx *gorm.DB, rule *models.AutomationRule, task *models.Task, action models.AutomationAction, lookups *automationLookups, effects *automationEffects) error {
+	logActivity := func(description, field, oldValue, newValue string) {
+		taskID, projectID := task.ID, task.ProjectID
+		effects.activities = append(effects.activities, func() {
+			if err := s.Activity.LogAutomation(taskID, projectID, rule, description, field, oldValue, newValue); err != nil {
+				log.Printf("Failed to log automation activity: %v", err)
+			}
+		})
+	}
+	event := func(trigger models.AutomationTrigger) AutomationEvent {
+		return AutomationEvent{Trigger: trigger, ProjectID: task.ProjectID, TaskID: task.ID, ActorID: rule.CreatedBy, RuleID: rule.ID}
+	}
+
+	switch action.Type {
+	case "set_field":
+		return s.setField(tx, task, action, lookups.location, logActivity)
+
+	case "assign_user":
+		if utils.SameUserID(task.AssigneeID, action.UserID) {
+			return nil
+		}
+		if action.UserID != nil && !lookups.members[*action.UserID] {
+			return fmt.Errorf("user %d is not a member of this project", *action.UserID)
+		}
+		if task.Stage != nil {
+			result, err := s.WIP.CheckAssign(tx, task.Stage, action.UserID, "automation", rule.CreatedBy)
+			if err := s.enforceWIP(tx, result, err, task.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Update("assignee_id", action.UserID).Error; err != nil {
+			return err
+		}
+		logActivity("修改了负责人", "assignee_id", formatUserID(task.AssigneeID), formatUserID(action.UserID))
+		task.AssigneeID = action.UserID
+		effects.events = append(effects.events, event(models.TriggerAssigneeChanged))
+
+	case "add_label":
+		label, added, err := s.Labels.AddTaskLabel(tx, task, action.LabelID)
+		if err != nil {
+			return err
+		}
+		if added {
+			logActivity(fmt.Sprintf("添加了标签 \"%s\"", label.Name), "labels", "", label.Name)
+		}
+
+	case "remove_label":
+		label, removed, err := s.Labels.RemoveTaskLabel(tx, task, action.LabelID)
+		if err != nil {
+			return err
+		}
+		if removed {
+			logActivity(fmt.Sprintf("移除了标签 \"%s\"", label.Name), "labels", label.Name, "")
+		}
+
+	case "move_to_stage":
+		if task.StageID == action.StageID {
+			return nil
+		}
+		var stage models.Stage
+		if err := tx.Where("id = ? AND project_id = ?", action.StageID, task.ProjectID).First(&stage).Error; err != nil {
+			return fmt.Errorf("stage %d not found", action.StageID)
+		}
+		// 与手动移动相同：目标阶段需要允许移动，并以规则创建者的身份满足流转规则
+		if !stage.AllowTaskMovement {
+			return fmt.Errorf("task movement is not allowed to stage %q", stage.Name)
+		}
+		if violation := lookups.transitions.Check(task, task.StageID, stage.ID, rule.CreatedBy); violation != nil {
+			return violation
+		}
+		result, err := s.WIP.CheckEntry(tx, &stage, []*uint{task.AssigneeID}, "automation", rule.CreatedBy)
+		if err := s.enforceWIP(tx, result, err, task.ID); err != nil {
+			return err
+		}
+		workflow, err := s.Workflow.GetWorkflow(tx, task.ProjectID)
+		if err != nil {
+			return err
+		}
+		rank, err := s.Rank.RankForAppend(tx, stage.ID)
+		if err != nil {
+			return err
+		}
+		entry := ComputeStageEntry(workflow, task, task.Stage, &stage, time.Now())
+		updates := map[string]interface{}{"stage_id": stage.ID, "rank": rank}
+		for field, value := range entry.Updates {
+			updates[field] = value
+		}
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		oldStageName := ""
+		if task.Stage != nil {
+			oldStageName = task.Stage.Name
+		}
+		logActivity(fmt.Sprintf("将任务从 \"%s\" 移动到 \"%s\"", oldStageName, stage.Name), "stage_id",
+			strconv.FormatUint(uint64(task.StageID), 10), strconv.FormatUint(uint64(stage.ID), 10))
+		if entry.StatusChanged() {
+			logActivity(fmt.Sprintf("将状态修改为 \"%s\"", workflowStatusName(workflow, entry.NewStatus)), "status", entry.OldStatus, entry.NewStatus)
+		}
+		task.StageID, task.Stage, task.Status, task.Rank = stage.ID, &stage, entry.NewStatus, rank
+		moved := event(models.TriggerTaskMoved)
+		moved.StageID = stage.ID
+		effects.events = append(effects.events, moved)
+
+	case "post_comment":
+		content := strings.NewReplacer(
+			"{{task.key}}", task.Key,
+			"{{task.title}}", task.Title,
+			"{{task.status}}", task.Status,
+			"{{task.priority}}", task.Priority,
+		).Replace(action.Content)
+		comment := models.Comment{Content: content, UserID: rule.CreatedBy, TaskID: task.ID}
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		if err := s.Search.IndexComment(tx, &comment); err != nil {
+			log.Printf("Failed to index comment %d: %v", comment.ID, err)
+		}
+		logActivity("发表了评论", "", "", "")
+		commented := event(models.TriggerCommentAdded)
+		commented.CommentID = comment.ID
+		effects.events = append(effects.events, commented)
+
+	case "send_webhook":
+		effects.webhooks = append(effects.webhooks, action.URL)
+
+	default:
+		return fmt.Errorf("unsupported action type %q", action.Type)
+	}
+	return nil
+}
+
+// enforceWIP 硬限制超出时返回 wipBlockedError，软限制超出时在规则的事务中记录违规
+func (s *AutomationService) enforceWIP(tx *gorm.DB, result *WIPResult, err error, taskID uint) error {
+	if err != nil {
+		return err
+	}
+	if result.Blocked() {
+		return &wipBlockedError{result: result}
+	}
+	return s.WIP.Record(tx, result, taskID)
+}
+
+// setField 修改任务字段，修改状态时同步完成时间
+// loc 为解析截止日期使用的项目时区
+func (s *AutomationService) setField(tx *gorm.DB, task *models.Task, action models.AutomationAction, loc *time.Location, logActivity func(description, field, oldValue, newValue string)) error {
+	updates := make(map[string]interface{})
+	var oldValue string
+
+	switch action.Field {
+	case "status":
+		if task.Status == action.Value {
+			return nil
+		}
+		workflow, err := s.Workflow.GetWorkflow(tx, task.ProjectID)
+		if err != nil {
+			return err
+		}
+		if err := workflow.ValidateStatus(action.Value); err != nil {
+			return err
+		}
+		wasDone := IsTaskDone(workflow, task.Status, task.Stage)
+		isDone := IsTaskDone(workflow, action.Value, task.Stage)
+		if !wasDone && isDone {
+			updates["completed_at"] = time.Now()
+		} else if wasDone && !isDone {
+			updates["completed_at"] = nil
+		}
+		updates["status"] = action.Value
+		oldValue = task.Status
+		task.Status = action.Value
+
+	case "priority":
+		if task.Priority == action.Value {
+			return nil
+		}
+		workflow, err := s.Workflow.GetWorkflow(tx, task.ProjectID)
+		if err != nil {
+			return err
+		}
+		if err := workflow.ValidatePriority(action.Value); err != nil {
+			return err
+		}
+		updates["priority"] = action.Value
+		oldValue = task.Priority
+		task.Priority = action.Value
+
+	case "due_date":
+		if task.DueDate != nil {
+			oldValue = utils.FormatDueDate(*task.DueDate, task.DueAllDay)
+		}
+		if action.Value == "" {
+			updates["due_date"] = nil
+		} else {
+			due, allDay, err := utils.ParseDueDate(action.Value, nil, loc)
+			if err != nil {
+				return err
+			}
+			updates["due_date"] = due
+			updates["due_all_day"] = allDay
+		}
+
+	case "estimated_hours", "actual_hours":
+		current := task.EstimatedHours
+		if action.Field == "actual_hours" {
+			current = task.ActualHours
+		}
+		if current != nil {
+			oldValue = strconv.FormatFloat(*current, 'f', 2, 64)
+		}
+		if action.Value == "" {
+			updates[action.Field] = nil
+		} else {
+			hours, err := strconv.ParseFloat(action.Value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q", action.Value)
+			}
+			updates[action.Field] = hours
+		}
+
+	default:
+		return fmt.Errorf("unsupported field %q", action.Field)
+	}
+
+	if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	logActivity(fmt.Sprintf("将 %s 从 \"%s\" 修改为 \"%s\"", action.Field, oldValue, action.Value), action.Field, oldValue, action.Value)
+	return nil
+}
+
+// recordRun 记录规则的执行次数和最近一次错误
+func (s *AutomationService) recordRun(db *gorm.DB, rule *models.AutomationRule, runErr error) {
+	lastError := ""
+	if runErr != nil {
+		lastError = runErr.Error()
+	}
+	if err := db.Model(&models.AutomationRule{}).Where("id = ?", rule.ID).UpdateColumns(map[string]interface{}{
+		"run_count":   gorm.Expr("run_count + 1"),
+		"last_run_at": time.Now(),
+		"last_error":  lastError,
+	}).Error; err != nil {
+		log.Printf("Failed to record automation rule %d run: %v", rule.ID, err)
+	}
+}
+
+// sendWebhook 向规则配置的地址 POST 事件和任务信息
+func (s *AutomationService) sendWebhook(target string, rule *models.AutomationRule, task *models.Task, event AutomationEvent) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"rule":  map[string]interface{}{"id": rule.ID, "name": rule.Name},
+		"event": event,
+		"task": map[string]interface{}{
+			"id":          task.ID,
+			"key":         task.Key,
+			"title":       task.Title,
+			"project_id":  task.ProjectID,
+			"stage_id":    task.StageID,
+			"status":      task.Status,
+			"priority":    task.Priority,
+			"assignee_id": task.AssigneeID,
+			"due_date":    task.DueDate,
+		},
+		"sent_at": time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Failed to encode automation webhook payload: %v", err)
+		return
+	}
+
+	resp, err := s.HTTPClient.Post(target, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("Automation rule %d webhook to %s failed: %v", rule.ID, target, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Automation rule %d webhook to %s returned %s", rule.ID, target, resp.Status)
+	}
+}
+
+// DispatchDueDatePassed 为已过截止时间且未完成的任务触发 due_date_passed 规则
+// 每个任务的每个截止时间只触发一次，截止时间修改后会重新触发
+func (s *AutomationService) DispatchDueDatePassed(db *gorm.DB, now time.Time) (int, error) {
+	var projectIDs []uint
+	if err := db.Model(&models.AutomationRule{}).
+		Where("enabled = ? AND trigger_event = ?", true, models.TriggerDueDatePassed).
+		Pluck("DISTINCT project_id", &projectIDs).Error; err != nil {
+		return 0, err
+	}
+	if len(projectIDs) == 0 {
+		return 0, nil
+	}
+
+	var tasks []models.Task
+	if err := db.Preload("Project").Select("tasks.*").
+		Joins("JOIN stages ON stages.id = tasks.stage_id").
+		Where("tasks.project_id IN (?) AND tasks.due_date IS NOT NULL AND "+TaskNotArchivedCondition, projectIDs).
+		Where("NOT "+TaskStatusCategoryCondition(models.StatusCategoryDone)).
+		Where("(stages.is_completed IS NULL OR stages.is_completed = ?)", false).
+		Order("tasks.id ASC").Find(&tasks).Error; err != nil {
+		return 0, err
+	}
+
+	runs := 0
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Project == nil || task.Project.Status == models.ProjectStatusArchived {
+			continue
+		}
+		loc := utils.ResolveTimezone(task.Project.Timezone)
+		if !utils.IsOverdue(*task.DueDate, task.DueAllDay, now, loc) {
+			continue
+		}
+
+		var count int
+		if err := db.Model(&models.TaskReminderLog{}).
+			Where("task_id = ? AND user_id = 0 AND type = ? AND dedup_key = ? AND due_date = ?", task.ID, automationDedupType, models.TriggerDueDatePassed, task.DueDate.UTC()).
+			Count(&count).Error; err != nil {
+			return runs, err
+		}
+		if count > 0 {
+			continue
+		}
+		entry := models.TaskReminderLog{TaskID: task.ID, Type: automationDedupType, DedupKey: string(models.TriggerDueDatePassed), DueDate: task.DueDate.UTC()}
+		if err := db.Create(&entry).Error; err != nil {
+			return runs, err
+		}
+
+		runs += s.Dispatch(db, AutomationEvent{Trigger: models.TriggerDueDatePassed, ProjectID: task.ProjectID, TaskID: task.ID})
+	}
+	return runs, nil
+}
+
+func formatUserID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
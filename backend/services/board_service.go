@@ -0,0 +1,281 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"sort"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 看板泳道的分组方式
+const (
+	LaneByAssignee = "assignee" // 按负责人，泳道键为用户ID
+	LaneByPriority = "priority" // 按优先级，泳道键为优先级的 key
+	LaneByLabel    = "label"    // 按标签，泳道键为标签ID；有多个标签的任务出现在每个标签的泳道中
+)
+
+// LaneNone 没有取值的任务所在泳道的键（未分配负责人、没有标签）
+const LaneNone = ""
+
+// BoardService 看板泳道服务
+type BoardService struct{}
+
+// NewBoardService 创建看板泳道服务
+func NewBoardService() *BoardService {
+	return &BoardService{}
+}
+
+// Board 阶段 × 泳道的看板
+type Board struct {
+	ProjectID uint         `json:"project_id"`
+	LaneBy    string       `json:"lane_by"`
+	Stages    []BoardStage `json:"stages"`
+	Lanes     []BoardLane  `json:"lanes"`
+	TaskCount int          `json:"task_count"`
+}
+
+// BoardStage 看板的列
+type BoardStage struct {
+	ID        uint           `json:"id"`
+	Name      string         `json:"name"`
+	Color     string         `json:"color"`
+	Position  int            `json:"position"`
+	MaxTasks  int            `json:"max_tasks"`
+	WIPMode   models.WIPMode `json:"wip_mode"`
+	TaskCount int            `json:"task_count"`
+}
+
+// BoardLane 看板的泳道，cells 与 stages 一一对应
+type BoardLane struct {
+	Key       string      `json:"key"`
+	Title     string      `json:"title"`
+	Color     string      `json:"color,omitempty"`
+	TaskCount int         `json:"task_count"`
+	Cells     []BoardCell `json:"cells"`
+}
+
+// BoardCell 泳道中一个阶段的任务，按阶段内顺序排列
+type BoardCell struct {
+	StageID uint          `json:"stage_id"`
+	Count   int           `json:"count"`
+	Tasks   []models.Task `json:"tasks"`
+}
+
+// ValidLaneBy 检查泳道分组方式
+func ValidLaneBy(laneBy string) bool {
+	return laneBy == LaneByAssignee || laneBy == LaneByPriority || laneBy == LaneByLabel
+}
+
+// BuildBoard 把任务按阶段和泳道分组，tasks 应已按阶段内顺序排列
+// 负责人、优先级和标签的泳道都会列出（即使没有任务），便于把任务拖到空泳道
+func (s *BoardService) BuildBoard(db *gorm.DB, projectID uint, laneBy string, tasks []models.Task) (*Board, error) {
+	if !ValidLaneBy(laneBy) {
+		return nil, fmt.Errorf("invalid lane_by: must be assignee, priority or label")
+	}
+
+	var stages []models.Stage
+	if err := db.Where("project_id = ?", projectID).Order("position ASC, id ASC").Find(&stages).Error; err != nil {
+		return nil, err
+	}
+
+	var lanes []BoardLane
+	var laneKeys map[uint][]string
+	var err error
+	switch laneBy {
+	case LaneByAssignee:
+		lanes, laneKeys, err = s.assigneeLanes(db, projectID, tasks)
+	case LaneByPriority:
+		lanes, laneKeys, err = s.priorityLanes(db, projectID, tasks)
+	case LaneByLabel:
+		lanes, laneKeys, err = s.labelLanes(db, projectID, tasks)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	board := &Board{
+		ProjectID: projectID,
+		LaneBy:    laneBy,
+		Stages:    make([]BoardStage, 0, len(stages)),
+		TaskCount: len(tasks),
+	}
+	stageIndex := make(map[uint]int, len(stages))
+	for i, stage := range stages {
+		stageIndex[stage.ID] = i
+		board.Stages = append(board.Stages, BoardStage{
+			ID:       stage.ID,
+			Name:     stage.Name,
+			Color:    stage.Color,
+			Position: stage.Position,
+			MaxTasks: stage.MaxTasks,
+			WIPMode:  StageMode(&stage),
+		})
+	}
+
+	laneIndex := make(map[string]int, len(lanes))
+	for i := range lanes {
+		laneIndex[lanes[i].Key] = i
+		lanes[i].Cells = make([]BoardCell, len(stages))
+		for j, stage := range stages {
+			lanes[i].Cells[j] = BoardCell{StageID: stage.ID, Tasks: []models.Task{}}
+		}
+	}
+
+	for _, task := range tasks {
+		si, ok := stageIndex[task.StageID]
+		if !ok {
+			continue
+		}
+		board.Stages[si].TaskCount++
+		for _, key := range laneKeys[task.ID] {
+			li, ok := laneIndex[key]
+			if !ok {
+				continue
+			}
+			lane := &lanes[li]
+			cell := &lane.Cells[si]
+			cell.Tasks = append(cell.Tasks, task)
+			cell.Count++
+			lane.TaskCount++
+		}
+	}
+
+	board.Lanes = lanes
+	return board, nil
+}
+
+// assigneeLanes 项目所有者、成员和任务负责人各一条泳道，按用户名排序，未分配的任务在最后
+func (s *BoardService) assigneeLanes(db *gorm.DB, projectID uint, tasks []models.Task) ([]BoardLane, map[uint][]string, error) {
+	var userIDs []uint
+	if err := db.Model(&models.ProjectMember{}).Where("project_id = ?", projectID).Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	var project models.Project
+	if err := db.Select("id, owner_id").First(&project, projectID).Error; err != nil {
+		return nil, nil, err
+	}
+	userIDs = append(userIDs, project.OwnerID)
+
+	keys := make(map[uint][]string, len(tasks))
+	for _, task := range tasks {
+		if task.AssigneeID == nil || *task.AssigneeID == 0 {
+			keys[task.ID] = []string{LaneNone}
+			continue
+		}
+		userIDs = append(userIDs, *task.AssigneeID)
+		keys[task.ID] = []string{strconv.FormatUint(uint64(*task.AssigneeID), 10)}
+	}
+
+	var users []models.User
+	if err := db.Where("id IN (?)", userIDs).Order("username ASC, id ASC").Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+	lanes := make([]BoardLane, 0, len(users)+1)
+	for _, user := range users {
+		lanes = append(lanes, BoardLane{Key: strconv.FormatUint(uint64(user.ID), 10), Title: user.Username})
+	}
+	// 未分配泳道总是保留，作为取消分配的拖放目标
+	lanes = append(lanes, BoardLane{Key: LaneNone, Title: "Unassigned"})
+	return lanes, keys, nil
+}
+
+// priorityLanes 工作流中的每个优先级一条泳道，按紧急程度排序；不在工作流中的取值排在最后
+func (s *BoardService) priorityLanes(db *gorm.DB, projectID uint, tasks []models.Task) ([]BoardLane, map[uint][]string, error) {
+	workflow, err := NewWorkflowService().GetWorkflow(db, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lanes := make([]BoardLane, 0, len(workflow.Priorities))
+	known := make(map[string]bool, len(workflow.Priorities))
+	priorities := append([]models.WorkflowPriority(nil), workflow.Priorities...)
+	sort.SliceStable(priorities, func(i, j int) bool { return priorities[i].Level < priorities[j].Level })
+	for _, priority := range priorities {
+		known[priority.Key] = true
+		lanes = append(lanes, BoardLane{Key: priority.Key, Title: priority.Name, Color: priority.Color})
+	}
+
+	keys := make(map[uint][]string, len(tasks))
+	for _, task := range tasks {
+		keys[task.ID] = []string{task.Priority}
+		if !known[task.Priority] {
+			known[task.Priority] = true
+			title := task.Priority
+			if title == LaneNone {
+				title = "No priority"
+			}
+			lanes = append(lanes, BoardLane{Key: task.Priority, Title: title})
+		}
+	}
+	return lanes, keys, nil
+}
+
+// labelLanes 项目的每个标签一条泳道，按名称排序，没有标签的任务在最后
+func (s *BoardService) labelLanes(db *gorm.DB, projectID uint, tasks []models.Task) ([]BoardLane, map[uint][]string, error) {
+	labels, err := NewLabelService().ProjectLabels(db, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	lanes := make([]BoardLane, 0, len(labels)+1)
+	for _, label := range labels {
+		lanes = append(lanes, BoardLane{Key: strconv.FormatUint(uint64(label.ID), 10), Title: label.Name, Color: label.Color})
+	}
+	lanes = append(lanes, BoardLane{Key: LaneNone, Title: "No label"})
+
+	keys := make(map[uint][]string, len(tasks))
+	if len(tasks) > 0 {
+		taskIDs := make([]uint, len(tasks))
+		for i, task := range tasks {
+			taskIDs[i] = task.ID
+		}
+		var taskLabels []models.TaskLabel
+		if err := db.Where("task_id IN (?)", taskIDs).Find(&taskLabels).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, taskLabel := range taskLabels {
+			keys[taskLabel.TaskID] = append(keys[taskLabel.TaskID], strconv.FormatUint(uint64(taskLabel.LabelID), 10))
+		}
+	}
+
+	// 按标签名称的顺序排列任务所在的泳道
+	order := make(map[string]int, len(lanes))
+	for i, lane := range lanes {
+		order[lane.Key] = i
+	}
+	for _, task := range tasks {
+		if len(keys[task.ID]) == 0 {
+			keys[task.ID] = []string{LaneNone}
+			continue
+		}
+		taskKeys := keys[task.ID]
+		sort.Slice(taskKeys, func(i, j int) bool { return order[taskKeys[i]] < order[taskKeys[j]] })
+	}
+	return lanes, keys, nil
+}
+
+// ParseLaneUserID 解析负责人泳道的键，未分配泳道返回 nil
+func ParseLaneUserID(key string) (*uint, error) {
+	if key == LaneNone {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(key, 10, 32)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("invalid assignee lane: %s", key)
+	}
+	userID := uint(id)
+	return &userID, nil
+}
+
+// ParseLaneLabelID 解析标签泳道的键，没有标签的泳道返回 0
+func ParseLaneLabelID(key string) (uint, error) {
+	if key == LaneNone {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(key, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid label lane: %s", key)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,820 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// JiraImportService Jira CSV 导入服务
+type JiraImportService struct{}
+
+// NewJiraImportService 创建 Jira CSV 导入服务
+func NewJiraImportService() *JiraImportService {
+	return &JiraImportService{}
+}
+
+// jiraStatusOrder 常见 Jira 状态的阶段顺序，其余状态按首次出现的顺序排在后面
+var jiraStatusOrder = []string{
+	"backlog", "open", "to do", "selected for development", "reopened",
+	"in progress", "in review", "code review", "testing", "qa",
+	"done", "resolved", "closed",
+}
+
+// jiraStatusCategories CSV 没有 Status Category 列时，常见 Jira 状态所属的分类，其余状态按未开始处理
+var jiraStatusCategories = map[string]models.StatusCategory{
+	"in progress": models.StatusCategoryInProgress,
+	"in review":   models.StatusCategoryInProgress,
+	"code review": models.StatusCategoryInProgress,
+	"testing":     models.StatusCategoryInProgress,
+	"qa":          models.StatusCategoryInProgress,
+	"done":        models.StatusCategoryDone,
+	"resolved":    models.StatusCategoryDone,
+	"closed":      models.StatusCategoryDone,
+}
+
+// jiraCategoryNames Jira Status Category 列的取值
+var jiraCategoryNames = map[string]models.StatusCategory{
+	"to do":       models.StatusCategoryTodo,
+	"new":         models.StatusCategoryTodo,
+	"in progress": models.StatusCategoryInProgress,
+	"done":        models.StatusCategoryDone,
+	"complete":    models.StatusCategoryDone,
+}
+
+// jiraCategoryOrder 阶段包含多个分类的状态时取顺序靠后的分类
+var jiraCategoryOrder = map[models.StatusCategory]int{
+	models.StatusCategoryTodo:       0,
+	models.StatusCategoryInProgress: 1,
+	models.StatusCategoryDone:       2,
+}
+
+// DefaultJiraPriorityMap Jira 优先级到 P0–P3 的默认映射（小写）
+var DefaultJiraPriorityMap = map[string]string{
+	"highest":  "P0",
+	"blocker":  "P0",
+	"critical": "P0",
+	"high":     "P1",
+	"major":    "P1",
+	"medium":   "P2",
+	"low":      "P3",
+	"minor":    "P3",
+	"lowest":   "P3",
+	"trivial":  "P3",
+}
+
+// jiraDateLayouts Jira CSV 中可能出现的日期格式
+var jiraDateLayouts = []string{
+	"02/Jan/06 3:04 PM",
+	"2/Jan/06 3:04 PM",
+	"02/Jan/2006 3:04 PM",
+	"2/Jan/2006 3:04 PM",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05.000-0700",
+	time.RFC3339,
+	"02/Jan/06",
+	"2/Jan/06",
+	"2006-01-02",
+}
+
+// JiraImportOptions 导入选项，映射的键不区分大小写
+type JiraImportOptions struct {
+	StatusMap   map[string]string // Jira 状态到阶段名称，未映射的状态创建同名阶段
+	PriorityMap map[string]string // Jira 优先级到 P0–P3，覆盖默认映射
+	UserMap     map[string]string // Jira 用户（显示名称、用户名或账号ID）到本地邮箱或用户名，未映射的按邮箱或用户名匹配
+	Location    *time.Location    // 解析日期使用的时区
+}
+
+// JiraComment Jira 评论
+type JiraComment struct {
+	Date   time.Time
+	Author string
+	Body   string
+}
+
+// JiraIssue 从 CSV 一行解析出的 Jira 问题
+type JiraIssue struct {
+	Row         int
+	Project     string // Project name 列
+	ProjectKey  string // Project key 列
+	Key         string
+	ID          string
+	Summary     string
+	Description string
+	Status      string
+	Category    models.StatusCategory // 状态分类，来自 Status Category 列或按状态名称推断
+	Priority    string
+	Assignee    string
+	Reporter    string
+	Created     *time.Time
+	Due         *time.Time
+	DueAllDay   bool
+	Estimate    *float64 // 小时
+	Spent       *float64 // 小时
+	Parent      string   // 父问题的ID或编号
+	Labels      []string
+	Comments    []JiraComment
+	Errors      []string
+}
+
+// JiraStagePlan 导入时使用的阶段
+type JiraStagePlan struct {
+	Name        string                `json:"name"`
+	Statuses    []string              `json:"statuses"` // 映射到该阶段的 Jira 状态
+	Category    models.StatusCategory `json:"category"` // 阶段及其任务使用该分类的工作流状态
+	IsCompleted bool                  `json:"is_completed"`
+}
+
+// JiraUserMatch Jira 用户与本地用户的匹配结果
+type JiraUserMatch struct {
+	Name     string `json:"name"`
+	UserID   *uint  `json:"user_id"` // 为空表示没有匹配到本地用户
+	Issues   int    `json:"issues"`  // 作为负责人或报告人的问题数
+	Comments int    `json:"comments"`
+}
+
+// JiraIssueResult 每一行的导入结果
+type JiraIssueResult struct {
+	Row      int      `json:"row"`
+	Key      string   `json:"key"`
+	Summary  string   `json:"summary"`
+	Stage    string   `json:"stage"`
+	Priority string   `json:"priority"`
+	Errors   []string `json:"errors,omitempty"`
+	TaskID   uint     `json:"task_id,omitempty"`
+	TaskKey  string   `json:"task_key,omitempty"`
+}
+
+// JiraImportSummary 导入摘要；试运行时为预览
+type JiraImportSummary struct {
+	DryRun      bool               `json:"dry_run"`
+	Project     string             `json:"project"`
+	KeyPrefix   string             `json:"key_prefix"` // Jira 问题编号中最常见的前缀
+	Issues      int                `json:"issues"`
+	Valid       int                `json:"valid"`
+	Invalid     int                `json:"invalid"`
+	Created     int                `json:"created"`
+	Stages      []*JiraStagePlan   `json:"stages"`
+	Labels      []string           `json:"labels"`
+	Comments    int                `json:"comments"`
+	Aliases     int                `json:"aliases"`      // 保留为别名的 Jira 编号
+	ParentLinks int                `json:"parent_links"` // 写入描述的父问题引用
+	Users       []*JiraUserMatch   `json:"users"`
+	Results     []*JiraIssueResult `json:"results"`
+	Warnings    []string           `json:"warnings,omitempty"`
+
+	issues  []*JiraIssue
+	stageOf map[string]string // Jira 状态（小写）到阶段名称
+	users   map[string]uint   // Jira 用户（小写）到本地用户ID
+}
+
+// ParseJiraCSV 按 Jira 导出的表头解析问题；Labels 和 Comment 列可以重复出现
+// 一次只能导入一个 Jira 项目，包含多个项目（按 Project key 或 Project name 区分）时返回错误
+func (s *JiraImportService) ParseJiraCSV(header []string, rows [][]string, loc *time.Location) ([]*JiraIssue, string, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	columns := make(map[string][]int)
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		columns[key] = append(columns[key], i)
+	}
+	if _, ok := columns["summary"]; !ok {
+		return nil, "", fmt.Errorf("column \"Summary\" not found, is this a Jira CSV export?")
+	}
+	first := func(record []string, names ...string) string {
+		for _, name := range names {
+			for _, i := range columns[name] {
+				if i < len(record) && strings.TrimSpace(record[i]) != "" {
+					return strings.TrimSpace(record[i])
+				}
+			}
+		}
+		return ""
+	}
+	all := func(record []string, name string) []string {
+		var values []string
+		for _, i := range columns[name] {
+			if i < len(record) && strings.TrimSpace(record[i]) != "" {
+				values = append(values, strings.TrimSpace(record[i]))
+			}
+		}
+		return values
+	}
+
+	var issues []*JiraIssue
+	keys := make(map[string]bool)
+	prefixes := make(map[string]int)
+	for n, record := range rows {
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		issue := &JiraIssue{
+			Row:         n + 2,
+			Project:     first(record, "project name"),
+			ProjectKey:  strings.ToUpper(first(record, "project key")),
+			Key:         strings.ToUpper(first(record, "issue key", "key")),
+			ID:          first(record, "issue id"),
+			Summary:     first(record, "summary"),
+			Description: first(record, "description"),
+			Status:      first(record, "status"),
+			Priority:    first(record, "priority"),
+			Assignee:    first(record, "assignee"),
+			Reporter:    first(record, "reporter", "creator"),
+			Parent:      first(record, "parent", "parent id", "parent key"),
+		}
+		issue.Category = jiraStatusCategory(issue.Status, first(record, "status category"))
+		fail := func(format string, args ...interface{}) {
+			issue.Errors = append(issue.Errors, fmt.Sprintf(format, args...))
+		}
+		if issue.Summary == "" {
+			fail("summary is required")
+		}
+		if issue.Key != "" {
+			if keys[issue.Key] {
+				fail("duplicate issue key %q", issue.Key)
+			}
+			keys[issue.Key] = true
+			if m := taskKeyPattern.FindStringSubmatch(issue.Key); m != nil {
+				prefixes[m[1]]++
+			} else {
+				fail("invalid issue key %q", issue.Key)
+			}
+		}
+
+		if raw := first(record, "created"); raw != "" {
+			if created, _, err := parseJiraDate(raw, loc); err != nil {
+				fail("invalid created date %q", raw)
+			} else {
+				issue.Created = &created
+			}
+		}
+		if raw := first(record, "due date", "due"); raw != "" {
+			if due, allDay, err := parseJiraDate(raw, loc); err != nil {
+				fail("invalid due date %q", raw)
+			} else {
+				issue.Due, issue.DueAllDay = &due, allDay
+			}
+		}
+		var err error
+		if issue.Estimate, err = parseJiraSeconds(first(record, "original estimate")); err != nil {
+			fail("invalid original estimate %q", first(record, "original estimate"))
+		}
+		if issue.Spent, err = parseJiraSeconds(first(record, "time spent")); err != nil {
+			fail("invalid time spent %q", first(record, "time spent"))
+		}
+
+		for _, value := range all(record, "labels") {
+			for _, label := range strings.Fields(value) {
+				if len(label) > 50 {
+					fail("label %q is longer than 50 characters", label)
+					continue
+				}
+				issue.Labels = append(issue.Labels, label)
+			}
+		}
+		for _, value := range all(record, "comment") {
+			comment, err := parseJiraComment(value, loc)
+			if err != nil {
+				fail("invalid comment: %v", err)
+				continue
+			}
+			issue.Comments = append(issue.Comments, comment)
+		}
+		issues = append(issues, issue)
+	}
+	if len(issues) == 0 {
+		return nil, "", fmt.Errorf("CSV has no issues")
+	}
+
+	var projects []string
+	seenProjects := make(map[string]bool)
+	for _, issue := range issues {
+		project := issue.ProjectKey
+		if project == "" {
+			project = issue.Project
+		}
+		if project != "" && !seenProjects[strings.ToLower(project)] {
+			seenProjects[strings.ToLower(project)] = true
+			projects = append(projects, project)
+		}
+	}
+	if len(projects) > 1 {
+		return nil, "", fmt.Errorf("CSV contains issues from %d Jira projects (%s), import one project at a time", len(projects), strings.Join(projects, ", "))
+	}
+
+	prefix, count := "", 0
+	for p, n := range prefixes {
+		if n > count || (n == count && p < prefix) {
+			prefix, count = p, n
+		}
+	}
+	return issues, strings.ToUpper(prefix), nil
+}
+
+// PlanImport 生成导入预览：阶段映射、优先级、用户匹配和行级错误，不写入数据
+func (s *JiraImportService) PlanImport(db *gorm.DB, issues []*JiraIssue, prefix string, opts JiraImportOptions) (*JiraImportSummary, error) {
+	summary := &JiraImportSummary{
+		KeyPrefix: prefix,
+		Issues:    len(issues),
+		Stages:    []*JiraStagePlan{},
+		Labels:    []string{},
+		Users:     []*JiraUserMatch{},
+		Results:   make([]*JiraIssueResult, 0, len(issues)),
+		issues:    issues,
+		stageOf:   make(map[string]string),
+		users:     make(map[string]uint),
+	}
+
+	statusMap := lowerKeys(opts.StatusMap)
+	priorityMap := make(map[string]string, len(DefaultJiraPriorityMap)+len(opts.PriorityMap))
+	for key, value := range DefaultJiraPriorityMap {
+		priorityMap[key] = value
+	}
+	priorities := make(map[string]bool)
+	for _, priority := range DefaultWorkflowPriorities() {
+		priorities[priority.Key] = true
+	}
+	for key, value := range lowerKeys(opts.PriorityMap) {
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !priorities[value] {
+			return nil, fmt.Errorf("priority_map: %q is not a valid priority (use P0, P1, P2 or P3)", value)
+		}
+		priorityMap[key] = value
+	}
+
+	// 阶段：常见状态按固定顺序，其余按首次出现的顺序
+	var statuses []string
+	seen := make(map[string]bool)
+	categories := make(map[string]models.StatusCategory)
+	for _, issue := range issues {
+		status := issue.Status
+		if status == "" {
+			status = "To Do"
+		}
+		if !seen[strings.ToLower(status)] {
+			seen[strings.ToLower(status)] = true
+			statuses = append(statuses, status)
+			categories[strings.ToLower(status)] = issue.Category
+		}
+	}
+	order := func(status string) int {
+		for i, known := range jiraStatusOrder {
+			if strings.EqualFold(known, status) {
+				return i
+			}
+		}
+		return len(jiraStatusOrder)
+	}
+	sort.SliceStable(statuses, func(i, j int) bool { return order(statuses[i]) < order(statuses[j]) })
+	stages := make(map[string]*JiraStagePlan)
+	for _, status := range statuses {
+		name := status
+		if mapped := strings.TrimSpace(statusMap[strings.ToLower(status)]); mapped != "" {
+			name = mapped
+		}
+		stage, ok := stages[strings.ToLower(name)]
+		if !ok {
+			stage = &JiraStagePlan{Name: name, Statuses: []string{}, Category: models.StatusCategoryTodo}
+			stages[strings.ToLower(name)] = stage
+			summary.Stages = append(summary.Stages, stage)
+		}
+		stage.Statuses = append(stage.Statuses, status)
+		if category := categories[strings.ToLower(status)]; jiraCategoryOrder[category] > jiraCategoryOrder[stage.Category] {
+			stage.Category = category
+		}
+		stage.IsCompleted = stage.Category == models.StatusCategoryDone
+		summary.stageOf[strings.ToLower(status)] = stage.Name
+	}
+	for key := range statusMap {
+		if !seen[key] {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("status_map: status %q does not appear in the CSV", key))
+		}
+	}
+
+	// 用户：按映射、邮箱或用户名匹配
+	if err := s.matchUsers(db, summary, lowerKeys(opts.UserMap)); err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]bool)
+	unmappedPriorities := make(map[string]bool)
+	for _, issue := range issues {
+		result := &JiraIssueResult{Row: issue.Row, Key: issue.Key, Summary: issue.Summary, Errors: issue.Errors}
+		status := issue.Status
+		if status == "" {
+			status = "To Do"
+		}
+		result.Stage = summary.stageOf[strings.ToLower(status)]
+		result.Priority = priorityMap[strings.ToLower(issue.Priority)]
+		if result.Priority == "" {
+			result.Priority = "P2"
+			if issue.Priority != "" && !unmappedPriorities[strings.ToLower(issue.Priority)] {
+				unmappedPriorities[strings.ToLower(issue.Priority)] = true
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("priority %q is not mapped, using P2", issue.Priority))
+			}
+		}
+		for _, label := range issue.Labels {
+			if !labels[label] {
+				labels[label] = true
+				summary.Labels = append(summary.Labels, label)
+			}
+		}
+		summary.Comments += len(issue.Comments)
+		if len(result.Errors) > 0 {
+			summary.Invalid++
+		} else {
+			summary.Valid++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	sort.Strings(summary.Labels)
+	return summary, nil
+}
+
+// matchUsers 匹配负责人、报告人和评论作者
+func (s *JiraImportService) matchUsers(db *gorm.DB, summary *JiraImportSummary, userMap map[string]string) error {
+	matches := make(map[string]*JiraUserMatch)
+	add := func(name string) *JiraUserMatch {
+		if name == "" {
+			return nil
+		}
+		key := strings.ToLower(name)
+		if match, ok := matches[key]; ok {
+			return match
+		}
+		match := &JiraUserMatch{Name: name}
+		matches[key] = match
+		summary.Users = append(summary.Users, match)
+		return match
+	}
+	for _, issue := range summary.issues {
+		for _, name := range []string{issue.Assignee, issue.Reporter} {
+			if match := add(name); match != nil {
+				match.Issues++
+			}
+		}
+		for _, comment := range issue.Comments {
+			if match := add(comment.Author); match != nil {
+				match.Comments++
+			}
+		}
+	}
+	if len(summary.Users) == 0 {
+		return nil
+	}
+
+	target := func(match *JiraUserMatch) string {
+		key := strings.ToLower(match.Name)
+		if mapped, ok := userMap[key]; ok {
+			return strings.ToLower(strings.TrimSpace(mapped))
+		}
+		return key
+	}
+	names := make([]string, 0, len(summary.Users))
+	for _, match := range summary.Users {
+		names = append(names, target(match))
+	}
+	var users []models.User
+	if err := db.Select("id, username, email").Where("LOWER(username) IN (?) OR LOWER(email) IN (?)", names, names).Find(&users).Error; err != nil {
+		return err
+	}
+	local := make(map[string]uint, len(users)*2)
+	for _, user := range users {
+		local[strings.ToLower(user.Username)] = user.ID
+	}
+	for _, user := range users {
+		local[strings.ToLower(user.Email)] = user.ID
+	}
+	for _, match := range summary.Users {
+		if id, ok := local[target(match)]; ok {
+			match.UserID = &id
+			summary.users[strings.ToLower(match.Name)] = id
+		}
+	}
+	return nil
+}
+
+// ImportIssues 把没有错误的行导入到刚创建的 project 中，应在创建项目的事务中调用
+// Jira 编号中的序号尽量保留为任务序号，原编号与新编号不同时保存为任务编号别名；
+// 没有父子任务模型，父问题以新编号写在任务描述末尾
+func (s *JiraImportService) ImportIssues(db *gorm.DB, summary *JiraImportSummary, project *models.Project, userID uint) ([]models.Task, error) {
+	// 匹配到的用户加入项目
+	memberIDs := map[uint]bool{project.OwnerID: true}
+	for _, match := range summary.Users {
+		if match.UserID == nil || memberIDs[*match.UserID] {
+			continue
+		}
+		if err := db.Create(&models.ProjectMember{
+			ProjectID: project.ID,
+			UserID:    *match.UserID,
+			Role:      models.ProjectMemberRoleCollaborator,
+			InvitedBy: &userID,
+		}).Error; err != nil {
+			return nil, err
+		}
+		memberIDs[*match.UserID] = true
+	}
+
+	// 阶段分类对应的工作流状态：默认状态属于该分类时使用默认状态，否则使用该分类的第一个状态
+	workflow, err := NewWorkflowService().GetWorkflow(db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	statusOf := func(category models.StatusCategory) string {
+		if workflow.Category(workflow.DefaultStatus()) == category {
+			return workflow.DefaultStatus()
+		}
+		for _, status := range workflow.Statuses {
+			if status.Category == category {
+				return status.Key
+			}
+		}
+		return workflow.DefaultStatus()
+	}
+
+	def := &models.TemplateDefinition{}
+	for i, stage := range summary.Stages {
+		def.Stages = append(def.Stages, models.TemplateStage{
+			Ref:              "stage-" + strconv.Itoa(i+1),
+			Name:             stage.Name,
+			IsCompleted:      stage.IsCompleted,
+			AutoAssignStatus: statusOf(stage.Category),
+		})
+	}
+	for _, label := range summary.Labels {
+		def.Labels = append(def.Labels, models.TemplateLabel{Name: label})
+	}
+	applied, err := NewProjectTemplateService().ApplyTemplate(db, project, def, userID)
+	if err != nil {
+		return nil, err
+	}
+	stages := make(map[string]*models.Stage, len(applied.Stages))
+	for i := range applied.Stages {
+		stages[strings.ToLower(summary.Stages[i].Name)] = &applied.Stages[i]
+	}
+	labelIDs := make(map[string]uint, len(applied.Labels))
+	for _, label := range applied.Labels {
+		labelIDs[label.Name] = label.ID
+	}
+
+	// 序号：与主要前缀相同的编号保留原序号，其余在最大序号之后依次编号
+	numbers := make(map[*JiraIssue]int)
+	used := make(map[int]bool)
+	maxNumber := 0
+	for i, issue := range summary.issues {
+		if len(summary.Results[i].Errors) > 0 {
+			continue
+		}
+		if m := taskKeyPattern.FindStringSubmatch(issue.Key); m != nil && strings.EqualFold(m[1], summary.KeyPrefix) {
+			if n, err := strconv.Atoi(m[2]); err == nil && n > 0 && !used[n] {
+				numbers[issue] = n
+				used[n] = true
+				if n > maxNumber {
+					maxNumber = n
+				}
+			}
+		}
+	}
+
+	// 创建顺序：阶段顺序，阶段内按 Jira 序号，没有序号的按 CSV 顺序排在后面
+	order := make([]int, 0, len(summary.issues))
+	stageIndex := make(map[string]int, len(summary.Stages))
+	for i, stage := range summary.Stages {
+		stageIndex[strings.ToLower(stage.Name)] = i
+	}
+	for i := range summary.issues {
+		if len(summary.Results[i].Errors) == 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ra, rb := summary.Results[order[a]], summary.Results[order[b]]
+		if stageIndex[strings.ToLower(ra.Stage)] != stageIndex[strings.ToLower(rb.Stage)] {
+			return stageIndex[strings.ToLower(ra.Stage)] < stageIndex[strings.ToLower(rb.Stage)]
+		}
+		na, nb := numbers[summary.issues[order[a]]], numbers[summary.issues[order[b]]]
+		if na == 0 || nb == 0 {
+			return na != 0 && nb == 0
+		}
+		return na < nb
+	})
+
+	aliasesUsable := true
+	if summary.KeyPrefix != "" && summary.KeyPrefix != project.KeyPrefix {
+		var count int
+		if err := db.Model(&models.Project{}).Where("key_prefix = ? AND id <> ?", summary.KeyPrefix, project.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			aliasesUsable = false
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("Jira keys are not kept as aliases: prefix %s is used by another project", summary.KeyPrefix))
+		}
+	}
+
+	rankService := NewTaskRankService()
+	keyService := NewTaskKeyService()
+	tasks := make([]models.Task, 0, len(order))
+	created := make([]*JiraIssue, 0, len(order))
+	for _, i := range order {
+		issue, result := summary.issues[i], summary.Results[i]
+		stage := stages[strings.ToLower(result.Stage)]
+		rank, err := rankService.RankForAppend(db, stage.ID)
+		if err != nil {
+			return nil, err
+		}
+		number, ok := numbers[issue]
+		if !ok {
+			maxNumber++
+			number = maxNumber
+		}
+
+		task := models.Task{
+			StageID:        stage.ID,
+			ProjectID:      project.ID,
+			Title:          issue.Summary,
+			Description:    issue.Description,
+			Status:         stage.AutoAssignStatus,
+			Priority:       result.Priority,
+			DueDate:        issue.Due,
+			DueAllDay:      issue.DueAllDay,
+			EstimatedHours: issue.Estimate,
+			ActualHours:    issue.Spent,
+			Rank:           rank,
+			Number:         number,
+			Key:            FormatTaskKey(project.KeyPrefix, number),
+			CreatedBy:      userID,
+		}
+		if id, ok := summary.users[strings.ToLower(issue.Assignee)]; ok && memberIDs[id] {
+			task.AssigneeID = &id
+		}
+		if id, ok := summary.users[strings.ToLower(issue.Reporter)]; ok {
+			task.CreatedBy = id
+		}
+		if workflow.IsDone(task.Status) {
+			completedAt := time.Now()
+			task.CompletedAt = &completedAt
+		}
+		if err := db.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		if issue.Created != nil {
+			if err := restoreTimestamps(db, &task, *issue.Created, *issue.Created); err != nil {
+				return nil, err
+			}
+			task.CreatedAt, task.UpdatedAt = *issue.Created, *issue.Created
+		}
+		for _, label := range issue.Labels {
+			if err := db.Create(&models.TaskLabel{TaskID: task.ID, LabelID: labelIDs[label]}).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		if aliasesUsable && issue.Key != "" && issue.Key != task.Key {
+			if _, err := keyService.ResolveTaskKey(db, issue.Key); err == nil {
+				summary.Warnings = append(summary.Warnings, fmt.Sprintf("row %d: key %s already refers to another task, alias not kept", issue.Row, issue.Key))
+			} else if err := keyService.addAlias(db, task.ID, project.ID, issue.Key); err != nil {
+				return nil, err
+			} else {
+				summary.Aliases++
+			}
+		}
+
+		for _, item := range issue.Comments {
+			comment := models.Comment{TaskID: task.ID, UserID: userID, Content: item.Body}
+			if id, ok := summary.users[strings.ToLower(item.Author)]; ok {
+				comment.UserID = id
+			} else {
+				author := item.Author
+				if author == "" {
+					author = "unknown"
+				}
+				comment.Content = fmt.Sprintf("[Jira: %s] %s", author, item.Body)
+			}
+			if err := db.Create(&comment).Error; err != nil {
+				return nil, err
+			}
+			if err := restoreTimestamps(db, &comment, item.Date, item.Date); err != nil {
+				return nil, err
+			}
+		}
+
+		result.TaskID, result.TaskKey = task.ID, task.Key
+		tasks = append(tasks, task)
+		created = append(created, issue)
+	}
+
+	// tasks 不再追加，可以安全地引用其中的元素
+	byKey := make(map[string]*models.Task, len(tasks))
+	byID := make(map[string]*models.Task, len(tasks))
+	for i := range tasks {
+		if created[i].Key != "" {
+			byKey[created[i].Key] = &tasks[i]
+		}
+		if created[i].ID != "" {
+			byID[created[i].ID] = &tasks[i]
+		}
+	}
+	for i, issue := range created {
+		if issue.Parent == "" {
+			continue
+		}
+		parent, ok := byID[issue.Parent]
+		if !ok {
+			parent, ok = byKey[strings.ToUpper(issue.Parent)]
+		}
+		if !ok {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("row %d: parent %s is not in the import", issue.Row, issue.Parent))
+			continue
+		}
+		line := "Parent: " + parent.Key
+		description := strings.TrimSpace(tasks[i].Description)
+		if description != "" {
+			description += "\n\n"
+		}
+		description += line
+		if err := db.Model(&models.Task{}).Where("id = ?", tasks[i].ID).UpdateColumn("description", description).Error; err != nil {
+			return nil, err
+		}
+		tasks[i].Description = description
+		summary.ParentLinks++
+	}
+
+	summary.Created = len(tasks)
+	if err := db.Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumn("task_seq", maxNumber).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// jiraStatusCategory 问题状态的分类：优先使用 Status Category 列，否则按状态名称推断
+func jiraStatusCategory(status, category string) models.StatusCategory {
+	if mapped, ok := jiraCategoryNames[strings.ToLower(category)]; ok {
+		return mapped
+	}
+	if mapped, ok := jiraStatusCategories[strings.ToLower(status)]; ok {
+		return mapped
+	}
+	return models.StatusCategoryTodo
+}
+
+// parseJiraDate 解析 Jira 日期，没有时间或时间为零点时视为全天日期
+func parseJiraDate(value string, loc *time.Location) (time.Time, bool, error) {
+	for _, layout := range jiraDateLayouts {
+		t, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			continue
+		}
+		if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), true, nil
+		}
+		return t.UTC(), false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+}
+
+// parseJiraSeconds Jira 导出的工时单位为秒，转换为小时
+func parseJiraSeconds(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return nil, fmt.Errorf("invalid duration %q", value)
+	}
+	hours := seconds / 3600
+	return &hours, nil
+}
+
+// parseJiraComment 解析 "日期;作者;内容" 格式的评论列，内容中可以包含分号
+func parseJiraComment(value string, loc *time.Location) (JiraComment, error) {
+	parts := strings.SplitN(value, ";", 3)
+	if len(parts) < 3 {
+		return JiraComment{Date: time.Now(), Body: value}, nil
+	}
+	date, _, err := parseJiraDate(strings.TrimSpace(parts[0]), loc)
+	if err != nil {
+		// 不是导出格式时整列作为评论内容
+		return JiraComment{Date: time.Now(), Body: value}, nil
+	}
+	body := strings.TrimSpace(parts[2])
+	if body == "" {
+		return JiraComment{}, fmt.Errorf("empty comment")
+	}
+	return JiraComment{Date: date, Author: strings.TrimSpace(parts[1]), Body: body}, nil
+}
+
+// lowerKeys 把映射的键转为小写并去除空白
+func lowerKeys(m map[string]string) map[string]string {
+	result := make(map[string]string, len(m))
+	for key, value := range m {
+		result[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return result
+}
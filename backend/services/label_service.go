@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+
+	"github.com/jinzhu/gorm"
+)
+
+// LabelService 任务标签服务
+type LabelService struct{}
+
+// NewLabelService 创建任务标签服务
+func NewLabelService() *LabelService {
+	return &LabelService{}
+}
+
+// ProjectLabels 项目的全部标签，按名称排序
+func (s *LabelService) ProjectLabels(db *gorm.DB, projectID uint) ([]models.Label, error) {
+	var labels []models.Label
+	err := db.Where("project_id = ?", projectID).Order("name ASC").Find(&labels).Error
+	return labels, err
+}
+
+// TaskLabels 任务的标签
+func (s *LabelService) TaskLabels(db *gorm.DB, taskID uint) ([]models.Label, error) {
+	var labels []models.Label
+	err := db.Where("id IN (SELECT label_id FROM task_labels WHERE task_id = ?)", taskID).
+		Order("name ASC").Find(&labels).Error
+	return labels, err
+}
+
+// projectLabel 查找项目中的标签
+func (s *LabelService) projectLabel(db *gorm.DB, projectID, labelID uint) (*models.Label, error) {
+	var label models.Label
+	if err := db.Where("id = ? AND project_id = ?", labelID, projectID).First(&label).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, fmt.Errorf("label %d not found in project", labelID)
+		}
+		return nil, err
+	}
+	return &label, nil
+}
+
+// AddTaskLabel 给任务添加标签，标签必须属于任务所在项目；已有该标签时 added 为 false
+func (s *LabelService) AddTaskLabel(db *gorm.DB, task *models.Task, labelID uint) (label *models.Label, added bool, err error) {
+	label, err = s.projectLabel(db, task.ProjectID, labelID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var count int
+	if err := db.Model(&models.TaskLabel{}).Where("task_id = ? AND label_id = ?", task.ID, labelID).Count(&count).Error; err != nil {
+		return nil, false, err
+	}
+	if count > 0 {
+		return label, false, nil
+	}
+	if err := db.Create(&models.TaskLabel{TaskID: task.ID, LabelID: labelID}).Error; err != nil {
+		return nil, false, err
+	}
+	return label, true, nil
+}
+
+// RemoveTaskLabel 移除任务的标签，任务没有该标签时 removed 为 false
+func (s *LabelService) RemoveTaskLabel(db *gorm.DB, task *models.Task, labelID uint) (label *models.Label, removed bool, err error) {
+	label, err = s.projectLabel(db, task.ProjectID, labelID)
+	if err != nil {
+		return nil, false, err
+	}
+	result := db.Where("task_id = ? AND label_id = ?", task.ID, labelID).Delete(&models.TaskLabel{})
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	return label, result.RowsAffected > 0, nil
+}
+
+// DeleteLabel 删除标签及其与任务的关联
+func (s *LabelService) DeleteLabel(db *gorm.DB, labelID uint) error {
+	if err := db.Where("label_id = ?", labelID).Delete(&models.TaskLabel{}).Error; err != nil {
+		return err
+	}
+	return db.Where("id = ?", labelID).Delete(&models.Label{}).Error
+}
+
+// RemoveTaskLabels 删除任务时清理标签关联
+func (s *LabelService) RemoveTaskLabels(db *gorm.DB, taskIDs ...uint) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	return db.Where("task_id IN (?)", taskIDs).Delete(&models.TaskLabel{}).Error
+}
+
+// MoveTaskLabels 任务移到其他项目后，按名称把标签换成目标项目的同名标签，
+// 目标项目没有同名标签的关联被删除，返回被删除的标签名
+func (s *LabelService) MoveTaskLabels(db *gorm.DB, taskID, projectID uint) ([]string, error) {
+	labels, err := s.TaskLabels(db, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	targetLabels, err := s.ProjectLabels(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	targetIDs := make(map[string]uint, len(targetLabels))
+	for _, label := range targetLabels {
+		targetIDs[label.Name] = label.ID
+	}
+
+	if err := s.RemoveTaskLabels(db, taskID); err != nil {
+		return nil, err
+	}
+	var dropped []string
+	for _, label := range labels {
+		targetID, ok := targetIDs[label.Name]
+		if !ok {
+			dropped = append(dropped, label.Name)
+			continue
+		}
+		if err := db.Create(&models.TaskLabel{TaskID: taskID, LabelID: targetID}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return dropped, nil
+}
@@ -0,0 +1,775 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 项目导出包的格式标识和版本，格式不兼容时增加版本号
+const (
+	ProjectBundleFormat  = "project-manager/project-bundle"
+	ProjectBundleVersion = 1
+)
+
+// ProjectBundleService 项目导出和导入服务
+type ProjectBundleService struct{}
+
+// NewProjectBundleService 创建项目导出和导入服务
+func NewProjectBundleService() *ProjectBundleService {
+	return &ProjectBundleService{}
+}
+
+// ProjectBundle 可以在不同安装之间迁移的项目导出包
+// 包内的任务和评论用导出时的ID互相引用，用户用邮箱引用；评论的媒体只保留引用，不包含文件内容
+type ProjectBundle struct {
+	Format       string                    `json:"format"`
+	Version      int                       `json:"version"`
+	ExportedAt   time.Time                 `json:"exported_at"`
+	Project      BundleProject             `json:"project"`
+	Definition   models.TemplateDefinition `json:"definition"` // 工作流、阶段、标签、流转规则和自动化规则
+	Members      []BundleMember            `json:"members"`
+	Tasks        []BundleTask              `json:"tasks"`
+	Dependencies []BundleDependency        `json:"dependencies"`
+	Comments     []BundleComment           `json:"comments"`
+	Activities   []BundleActivity          `json:"activities"`
+}
+
+// BundleProject 导出包中的项目信息
+type BundleProject struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	KeyPrefix   string     `json:"key_prefix"`
+	Timezone    string     `json:"timezone"`
+	StartDate   *time.Time `json:"start_date"`
+	EndDate     *time.Time `json:"end_date"`
+	Owner       string     `json:"owner"` // 所有者邮箱
+}
+
+// BundleMember 导出包中的项目成员
+type BundleMember struct {
+	Email    string                   `json:"email"`
+	Username string                   `json:"username"`
+	Role     models.ProjectMemberRole `json:"role"`
+}
+
+// BundleTask 导出包中的任务，Stage 为阶段 Ref，Labels 为标签名称
+type BundleTask struct {
+	ID             uint       `json:"id"`
+	Number         int        `json:"number"`
+	Key            string     `json:"key"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Stage          string     `json:"stage"`
+	Status         string     `json:"status"`
+	Priority       string     `json:"priority"`
+	Assignee       string     `json:"assignee,omitempty"` // 负责人邮箱
+	CreatedBy      string     `json:"created_by,omitempty"`
+	StartDate      *time.Time `json:"start_date"`
+	DueDate        *time.Time `json:"due_date"`
+	DueAllDay      bool       `json:"due_all_day"`
+	EstimatedHours *float64   `json:"estimated_hours"`
+	ActualHours    *float64   `json:"actual_hours"`
+	Rank           string     `json:"rank"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	ArchivedAt     *time.Time `json:"archived_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Labels         []string   `json:"labels,omitempty"`
+}
+
+// BundleDependency 导出包中的任务依赖
+type BundleDependency struct {
+	TaskID          uint                      `json:"task_id"`
+	DependsOnTaskID uint                      `json:"depends_on_task_id"`
+	Type            models.TaskDependencyType `json:"type"`
+}
+
+// BundleComment 导出包中的评论
+type BundleComment struct {
+	ID              uint      `json:"id"`
+	TaskID          uint      `json:"task_id"`
+	Author          string    `json:"author"` // 作者邮箱
+	Content         string    `json:"content"`
+	MediaID         *string   `json:"media_id,omitempty"`
+	MediaType       *string   `json:"media_type,omitempty"`
+	MediaName       *string   `json:"media_name,omitempty"`
+	ReplyToID       *uint     `json:"reply_to_id,omitempty"`
+	ParentCommentID *uint     `json:"parent_comment_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// BundleActivity 导出包中的任务活动记录
+type BundleActivity struct {
+	TaskID      uint      `json:"task_id"`
+	User        string    `json:"user"` // 操作人邮箱
+	ActionType  string    `json:"action_type"`
+	Description string    `json:"description"`
+	FieldName   string    `json:"field_name,omitempty"`
+	OldValue    string    `json:"old_value,omitempty"`
+	NewValue    string    `json:"new_value,omitempty"`
+	Metadata    string    `json:"metadata,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UnresolvedUser 导入时在本地找不到的用户及其被引用的次数
+// 找不到的负责人置空，任务创建人、评论作者和活动操作人记为导入人
+type UnresolvedUser struct {
+	Email      string `json:"email"`
+	Member     bool   `json:"member"`
+	Tasks      int    `json:"tasks"`
+	Comments   int    `json:"comments"`
+	Activities int    `json:"activities"`
+}
+
+// ProjectImportReport 导入结果；试运行时各项数量为导出包中的数量
+type ProjectImportReport struct {
+	DryRun          bool             `json:"dry_run"`
+	Version         int              `json:"version"`
+	Stages          int              `json:"stages"`
+	Labels          int              `json:"labels"`
+	Members         int              `json:"members"`
+	Transitions     int              `json:"transitions"`
+	Automations     int              `json:"automations"`
+	Tasks           int              `json:"tasks"`
+	Dependencies    int              `json:"dependencies"`
+	Comments        int              `json:"comments"`
+	Activities      int              `json:"activities"`
+	TaskIDs         map[uint]uint    `json:"task_ids,omitempty"` // 导出包中的任务ID到新任务ID
+	UnresolvedUsers []UnresolvedUser `json:"unresolved_users"`
+	Warnings        []string         `json:"warnings,omitempty"`
+}
+
+// ProjectImportResult 导入后需要在事务外处理的数据
+type ProjectImportResult struct {
+	Report *ProjectImportReport
+	Tasks  []models.Task
+}
+
+// normalizeEmail 统一邮箱的大小写和空白，便于匹配用户
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ExportProject 导出项目
+func (s *ProjectBundleService) ExportProject(db *gorm.DB, projectID uint) (*ProjectBundle, error) {
+	var project models.Project
+	if err := db.First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+
+	def, err := NewProjectTemplateService().DefinitionFromProject(db, projectID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// 收集项目涉及的用户，导出包中用邮箱代替用户ID
+	emails := make(map[uint]string)
+	userIDs := []uint{project.OwnerID}
+	var members []models.ProjectMember
+	if err := db.Where("project_id = ?", projectID).Order("id ASC").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	var tasks []models.Task
+	if err := db.Where("project_id = ?", projectID).Order("number ASC, id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	taskIDs := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+		userIDs = append(userIDs, task.CreatedBy)
+		if task.AssigneeID != nil {
+			userIDs = append(userIDs, *task.AssigneeID)
+		}
+	}
+	var comments []models.Comment
+	var activities []models.TaskActivity
+	if len(taskIDs) > 0 {
+		if err := db.Where("task_id IN (?)", taskIDs).Order("id ASC").Find(&comments).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Where("project_id = ? AND task_id IN (?)", projectID, taskIDs).Order("id ASC").Find(&activities).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, comment := range comments {
+		userIDs = append(userIDs, comment.UserID)
+	}
+	for _, activity := range activities {
+		userIDs = append(userIDs, activity.UserID)
+	}
+	for _, automation := range def.Automations {
+		for _, action := range automation.Actions {
+			if action.UserID != nil {
+				userIDs = append(userIDs, *action.UserID)
+			}
+		}
+	}
+	var users []models.User
+	if err := db.Select("id, username, email").Where("id IN (?)", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		emails[user.ID] = user.Email
+		usernames[user.ID] = user.Username
+	}
+	userEmail := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return emails[*id]
+	}
+
+	for i := range def.Automations {
+		for j := range def.Automations[i].Actions {
+			action := &def.Automations[i].Actions[j]
+			if action.UserID != nil {
+				action.UserEmail = userEmail(action.UserID)
+				action.UserID = nil
+			}
+		}
+	}
+
+	bundle := &ProjectBundle{
+		Format:     ProjectBundleFormat,
+		Version:    ProjectBundleVersion,
+		ExportedAt: time.Now(),
+		Project: BundleProject{
+			Name:        project.Name,
+			Description: project.Description,
+			KeyPrefix:   project.KeyPrefix,
+			Timezone:    project.Timezone,
+			StartDate:   project.StartDate,
+			EndDate:     project.EndDate,
+			Owner:       emails[project.OwnerID],
+		},
+		Definition:   *def,
+		Members:      []BundleMember{},
+		Tasks:        []BundleTask{},
+		Dependencies: []BundleDependency{},
+		Comments:     []BundleComment{},
+		Activities:   []BundleActivity{},
+	}
+	for _, member := range members {
+		bundle.Members = append(bundle.Members, BundleMember{Email: emails[member.UserID], Username: usernames[member.UserID], Role: member.Role})
+	}
+
+	// 阶段 Ref 与 DefinitionFromProject 的顺序一致
+	var stages []models.Stage
+	if err := db.Where("project_id = ?", projectID).Order("position ASC, id ASC").Find(&stages).Error; err != nil {
+		return nil, err
+	}
+	stageRefs := make(map[uint]string, len(stages))
+	for i, stage := range stages {
+		stageRefs[stage.ID] = def.Stages[i].Ref
+	}
+	labels, err := NewLabelService().ProjectLabels(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	labelNames := make(map[uint]string, len(labels))
+	for _, label := range labels {
+		labelNames[label.ID] = label.Name
+	}
+	taskLabels := make(map[uint][]string)
+	if len(taskIDs) > 0 {
+		var rows []models.TaskLabel
+		if err := db.Where("task_id IN (?)", taskIDs).Order("id ASC").Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if name, ok := labelNames[row.LabelID]; ok {
+				taskLabels[row.TaskID] = append(taskLabels[row.TaskID], name)
+			}
+		}
+	}
+
+	for _, task := range tasks {
+		ref, ok := stageRefs[task.StageID]
+		if !ok {
+			continue
+		}
+		createdBy := task.CreatedBy
+		bundle.Tasks = append(bundle.Tasks, BundleTask{
+			ID:             task.ID,
+			Number:         task.Number,
+			Key:            task.Key,
+			Title:          task.Title,
+			Description:    task.Description,
+			Stage:          ref,
+			Status:         task.Status,
+			Priority:       task.Priority,
+			Assignee:       userEmail(task.AssigneeID),
+			CreatedBy:      userEmail(&createdBy),
+			StartDate:      task.StartDate,
+			DueDate:        task.DueDate,
+			DueAllDay:      task.DueAllDay,
+			EstimatedHours: task.EstimatedHours,
+			ActualHours:    task.ActualHours,
+			Rank:           task.Rank,
+			CompletedAt:    task.CompletedAt,
+			ArchivedAt:     task.ArchivedAt,
+			CreatedAt:      task.CreatedAt,
+			UpdatedAt:      task.UpdatedAt,
+			Labels:         taskLabels[task.ID],
+		})
+	}
+
+	var dependencies []models.TaskDependency
+	if err := db.Where("project_id = ?", projectID).Order("id ASC").Find(&dependencies).Error; err != nil {
+		return nil, err
+	}
+	for _, dependency := range dependencies {
+		bundle.Dependencies = append(bundle.Dependencies, BundleDependency{
+			TaskID:          dependency.TaskID,
+			DependsOnTaskID: dependency.DependsOnTaskID,
+			Type:            dependency.Type,
+		})
+	}
+
+	for _, comment := range comments {
+		author := comment.UserID
+		bundle.Comments = append(bundle.Comments, BundleComment{
+			ID:              comment.ID,
+			TaskID:          comment.TaskID,
+			Author:          userEmail(&author),
+			Content:         comment.Content,
+			MediaID:         comment.MediaID,
+			MediaType:       comment.MediaType,
+			MediaName:       comment.MediaName,
+			ReplyToID:       comment.ReplyToID,
+			ParentCommentID: comment.ParentCommentID,
+			CreatedAt:       comment.CreatedAt,
+			UpdatedAt:       comment.UpdatedAt,
+		})
+	}
+
+	for _, activity := range activities {
+		actor := activity.UserID
+		bundle.Activities = append(bundle.Activities, BundleActivity{
+			TaskID:      activity.TaskID,
+			User:        userEmail(&actor),
+			ActionType:  activity.ActionType,
+			Description: activity.Description,
+			FieldName:   activity.FieldName,
+			OldValue:    activity.OldValue,
+			NewValue:    activity.NewValue,
+			Metadata:    activity.Metadata,
+			CreatedAt:   activity.CreatedAt,
+		})
+	}
+	return bundle, nil
+}
+
+// ValidateBundle 检查导出包的格式、版本以及包内的引用
+func (s *ProjectBundleService) ValidateBundle(bundle *ProjectBundle) error {
+	if bundle.Format != ProjectBundleFormat {
+		return fmt.Errorf("unsupported bundle format %q", bundle.Format)
+	}
+	if bundle.Version < 1 || bundle.Version > ProjectBundleVersion {
+		return fmt.Errorf("unsupported bundle version %d, this installation supports up to %d", bundle.Version, ProjectBundleVersion)
+	}
+	if strings.TrimSpace(bundle.Project.Name) == "" {
+		return fmt.Errorf("project name is required")
+	}
+	if err := NewProjectTemplateService().ValidateDefinition(&bundle.Definition); err != nil {
+		return err
+	}
+
+	stages := make(map[string]bool, len(bundle.Definition.Stages))
+	for _, stage := range bundle.Definition.Stages {
+		stages[stage.Ref] = true
+	}
+	labels := make(map[string]bool, len(bundle.Definition.Labels))
+	for _, label := range bundle.Definition.Labels {
+		labels[label.Name] = true
+	}
+	tasks := make(map[uint]bool, len(bundle.Tasks))
+	numbers := make(map[int]bool, len(bundle.Tasks))
+	for _, task := range bundle.Tasks {
+		if task.ID == 0 || tasks[task.ID] {
+			return fmt.Errorf("task %q: missing or duplicate id", task.Title)
+		}
+		tasks[task.ID] = true
+		if task.Number < 0 || (task.Number > 0 && numbers[task.Number]) {
+			return fmt.Errorf("task %d: invalid or duplicate number %d", task.ID, task.Number)
+		}
+		numbers[task.Number] = true
+		if strings.TrimSpace(task.Title) == "" {
+			return fmt.Errorf("task %d: title is required", task.ID)
+		}
+		if !stages[task.Stage] {
+			return fmt.Errorf("task %d: unknown stage %q", task.ID, task.Stage)
+		}
+		for _, label := range task.Labels {
+			if !labels[label] {
+				return fmt.Errorf("task %d: unknown label %q", task.ID, label)
+			}
+		}
+	}
+	for i, dependency := range bundle.Dependencies {
+		if !tasks[dependency.TaskID] || !tasks[dependency.DependsOnTaskID] {
+			return fmt.Errorf("dependency %d: unknown task", i+1)
+		}
+	}
+	comments := make(map[uint]bool, len(bundle.Comments))
+	for _, comment := range bundle.Comments {
+		if comment.ID == 0 || comments[comment.ID] {
+			return fmt.Errorf("comment on task %d: missing or duplicate id", comment.TaskID)
+		}
+		comments[comment.ID] = true
+		if !tasks[comment.TaskID] {
+			return fmt.Errorf("comment %d: unknown task %d", comment.ID, comment.TaskID)
+		}
+	}
+	for i, activity := range bundle.Activities {
+		if !tasks[activity.TaskID] {
+			return fmt.Errorf("activity %d: unknown task %d", i+1, activity.TaskID)
+		}
+	}
+	return nil
+}
+
+// ResolveUsers 按邮箱匹配本地用户，返回邮箱到用户ID的映射和找不到的用户
+func (s *ProjectBundleService) ResolveUsers(db *gorm.DB, bundle *ProjectBundle) (map[string]uint, []UnresolvedUser, error) {
+	refs := make(map[string]*UnresolvedUser)
+	var order []string
+	ref := func(email string) *UnresolvedUser {
+		email = normalizeEmail(email)
+		if email == "" {
+			return nil
+		}
+		if refs[email] == nil {
+			refs[email] = &UnresolvedUser{Email: email}
+			order = append(order, email)
+		}
+		return refs[email]
+	}
+	ref(bundle.Project.Owner)
+	for _, member := range bundle.Members {
+		if u := ref(member.Email); u != nil {
+			u.Member = true
+		}
+	}
+	for _, task := range bundle.Tasks {
+		for _, email := range []string{task.Assignee, task.CreatedBy} {
+			if u := ref(email); u != nil {
+				u.Tasks++
+			}
+		}
+	}
+	for _, comment := range bundle.Comments {
+		if u := ref(comment.Author); u != nil {
+			u.Comments++
+		}
+	}
+	for _, activity := range bundle.Activities {
+		if u := ref(activity.User); u != nil {
+			u.Activities++
+		}
+	}
+	for _, automation := range bundle.Definition.Automations {
+		for _, action := range automation.Actions {
+			ref(action.UserEmail)
+		}
+	}
+
+	resolved := make(map[string]uint, len(order))
+	if len(order) > 0 {
+		var users []models.User
+		if err := db.Select("id, email").Where("LOWER(email) IN (?)", order).Find(&users).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, user := range users {
+			resolved[normalizeEmail(user.Email)] = user.ID
+		}
+	}
+	unresolved := []UnresolvedUser{}
+	for _, email := range order {
+		if _, ok := resolved[email]; !ok {
+			unresolved = append(unresolved, *refs[email])
+		}
+	}
+	return resolved, unresolved, nil
+}
+
+// DryRunReport 不写入数据，按导出包的内容生成导入报告
+func (s *ProjectBundleService) DryRunReport(bundle *ProjectBundle, unresolved []UnresolvedUser) *ProjectImportReport {
+	return &ProjectImportReport{
+		DryRun:          true,
+		Version:         bundle.Version,
+		Stages:          len(bundle.Definition.Stages),
+		Labels:          len(bundle.Definition.Labels),
+		Members:         len(bundle.Members),
+		Transitions:     len(bundle.Definition.Transitions),
+		Automations:     len(bundle.Definition.Automations),
+		Tasks:           len(bundle.Tasks),
+		Dependencies:    len(bundle.Dependencies),
+		Comments:        len(bundle.Comments),
+		Activities:      len(bundle.Activities),
+		UnresolvedUsers: unresolved,
+	}
+}
+
+// ImportProject 把导出包导入到刚创建的 project 中，应在创建项目的事务中调用
+// users 为 ResolveUsers 的结果（权限检查和用户匹配需要在事务外完成）；项目的所有者应已是成员，编号前缀应已设置
+func (s *ProjectBundleService) ImportProject(db *gorm.DB, bundle *ProjectBundle, project *models.Project, users map[string]uint, unresolved []UnresolvedUser, userID uint) (*ProjectImportResult, error) {
+	report := &ProjectImportReport{
+		Version:         bundle.Version,
+		TaskIDs:         make(map[uint]uint, len(bundle.Tasks)),
+		UnresolvedUsers: unresolved,
+	}
+	lookup := func(email string) (uint, bool) {
+		id, ok := users[normalizeEmail(email)]
+		return id, ok
+	}
+	userOrImporter := func(email string) uint {
+		if id, ok := lookup(email); ok {
+			return id
+		}
+		return userID
+	}
+
+	// 成员：导入人是新项目的所有者，原所有者作为管理员加入
+	memberIDs := map[uint]bool{project.OwnerID: true}
+	for _, member := range bundle.Members {
+		id, ok := lookup(member.Email)
+		if !ok || memberIDs[id] {
+			continue
+		}
+		role := member.Role
+		if role == models.ProjectMemberRoleOwner || role == "" {
+			role = models.ProjectMemberRoleManager
+		}
+		if err := db.Create(&models.ProjectMember{ProjectID: project.ID, UserID: id, Role: role, InvitedBy: &userID}).Error; err != nil {
+			return nil, err
+		}
+		memberIDs[id] = true
+		report.Members++
+	}
+
+	// 工作流、阶段、标签、流转规则和自动化规则
+	def := bundle.Definition
+	def.Tasks = nil
+	def.Automations = make([]models.TemplateAutomation, len(bundle.Definition.Automations))
+	for i, automation := range bundle.Definition.Automations {
+		automation.Actions = append([]models.TemplateAction(nil), automation.Actions...)
+		for j := range automation.Actions {
+			action := &automation.Actions[j]
+			action.UserID = nil
+			if action.UserEmail != "" {
+				if id, ok := lookup(action.UserEmail); ok {
+					action.UserID = &id
+				}
+				action.UserEmail = ""
+			}
+		}
+		def.Automations[i] = automation
+	}
+	applied, err := NewProjectTemplateService().ApplyTemplate(db, project, &def, userID)
+	if err != nil {
+		return nil, err
+	}
+	report.Stages = len(applied.Stages)
+	report.Labels = len(applied.Labels)
+	report.Transitions = applied.Transitions
+	report.Automations = applied.Automations
+	report.Warnings = append(report.Warnings, applied.Warnings...)
+
+	stages := make(map[string]*models.Stage, len(applied.Stages))
+	for i := range applied.Stages {
+		stages[def.Stages[i].Ref] = &applied.Stages[i]
+	}
+	labelIDs := make(map[string]uint, len(applied.Labels))
+	for _, label := range applied.Labels {
+		labelIDs[label.Name] = label.ID
+	}
+
+	workflow, err := NewWorkflowService().GetWorkflow(db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 任务：保留原来的序号，没有序号的任务排在最后
+	bundleTasks := append([]BundleTask(nil), bundle.Tasks...)
+	maxNumber := 0
+	for _, task := range bundleTasks {
+		if task.Number > maxNumber {
+			maxNumber = task.Number
+		}
+	}
+	sort.SliceStable(bundleTasks, func(i, j int) bool {
+		a, b := bundleTasks[i].Number, bundleTasks[j].Number
+		if a == 0 || b == 0 {
+			return a != 0 && b == 0
+		}
+		return a < b
+	})
+	rankService := NewTaskRankService()
+	tasks := make([]models.Task, 0, len(bundleTasks))
+	for _, item := range bundleTasks {
+		stage := stages[item.Stage]
+		status := item.Status
+		if workflow.ValidateStatus(status) != nil {
+			status = workflow.DefaultStatus()
+			report.Warnings = append(report.Warnings, fmt.Sprintf("task %d: unknown status %q replaced by %q", item.ID, item.Status, status))
+		}
+		priority := item.Priority
+		if workflow.ValidatePriority(priority) != nil {
+			priority = workflow.DefaultPriority()
+			report.Warnings = append(report.Warnings, fmt.Sprintf("task %d: unknown priority %q replaced by %q", item.ID, item.Priority, priority))
+		}
+		var assigneeID *uint
+		if id, ok := lookup(item.Assignee); ok && memberIDs[id] {
+			assigneeID = &id
+		}
+		number := item.Number
+		if number == 0 {
+			maxNumber++
+			number = maxNumber
+		}
+		rank := item.Rank
+		if rank == "" {
+			if rank, err = rankService.RankForAppend(db, stage.ID); err != nil {
+				return nil, err
+			}
+		}
+
+		task := models.Task{
+			StageID:        stage.ID,
+			ProjectID:      project.ID,
+			Title:          item.Title,
+			Description:    item.Description,
+			Status:         status,
+			Priority:       priority,
+			AssigneeID:     assigneeID,
+			StartDate:      item.StartDate,
+			DueDate:        item.DueDate,
+			DueAllDay:      item.DueAllDay,
+			EstimatedHours: item.EstimatedHours,
+			ActualHours:    item.ActualHours,
+			Rank:           rank,
+			Number:         number,
+			Key:            FormatTaskKey(project.KeyPrefix, number),
+			CreatedBy:      userOrImporter(item.CreatedBy),
+			CompletedAt:    item.CompletedAt,
+			ArchivedAt:     item.ArchivedAt,
+			CreatedAt:      item.CreatedAt,
+			UpdatedAt:      item.UpdatedAt,
+		}
+		if err := db.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		if err := restoreTimestamps(db, &task, item.CreatedAt, item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		task.CreatedAt, task.UpdatedAt = item.CreatedAt, item.UpdatedAt
+		for _, name := range item.Labels {
+			if err := db.Create(&models.TaskLabel{TaskID: task.ID, LabelID: labelIDs[name]}).Error; err != nil {
+				return nil, err
+			}
+		}
+		report.TaskIDs[item.ID] = task.ID
+		tasks = append(tasks, task)
+	}
+	report.Tasks = len(tasks)
+	if err := db.Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumn("task_seq", maxNumber).Error; err != nil {
+		return nil, err
+	}
+
+	for _, dependency := range bundle.Dependencies {
+		if err := db.Create(&models.TaskDependency{
+			ProjectID:       project.ID,
+			TaskID:          report.TaskIDs[dependency.TaskID],
+			DependsOnTaskID: report.TaskIDs[dependency.DependsOnTaskID],
+			Type:            dependency.Type,
+			CreatedBy:       userID,
+		}).Error; err != nil {
+			return nil, err
+		}
+		report.Dependencies++
+	}
+
+	// 评论按导出时的ID顺序创建，保证父评论先于回复创建
+	bundleComments := append([]BundleComment(nil), bundle.Comments...)
+	sort.SliceStable(bundleComments, func(i, j int) bool { return bundleComments[i].ID < bundleComments[j].ID })
+	commentIDs := make(map[uint]uint, len(bundleComments))
+	for _, item := range bundleComments {
+		comment := models.Comment{
+			TaskID:    report.TaskIDs[item.TaskID],
+			UserID:    userOrImporter(item.Author),
+			Content:   item.Content,
+			MediaID:   item.MediaID,
+			MediaType: item.MediaType,
+			MediaName: item.MediaName,
+			CreatedAt: item.CreatedAt,
+			UpdatedAt: item.UpdatedAt,
+		}
+		if item.ReplyToID != nil {
+			if id, ok := commentIDs[*item.ReplyToID]; ok {
+				comment.ReplyToID = &id
+			}
+		}
+		if item.ParentCommentID != nil {
+			if id, ok := commentIDs[*item.ParentCommentID]; ok {
+				comment.ParentCommentID = &id
+			}
+		}
+		if err := db.Create(&comment).Error; err != nil {
+			return nil, err
+		}
+		if err := restoreTimestamps(db, &comment, item.CreatedAt, item.UpdatedAt); err != nil {
+			return nil, err
+		}
+		commentIDs[item.ID] = comment.ID
+		report.Comments++
+	}
+
+	for _, item := range bundle.Activities {
+		activity := models.TaskActivity{
+			TaskID:      report.TaskIDs[item.TaskID],
+			UserID:      userOrImporter(item.User),
+			ProjectID:   project.ID,
+			ActionType:  item.ActionType,
+			Description: item.Description,
+			FieldName:   item.FieldName,
+			OldValue:    item.OldValue,
+			NewValue:    item.NewValue,
+			Metadata:    item.Metadata,
+			CreatedAt:   item.CreatedAt,
+		}
+		if err := db.Create(&activity).Error; err != nil {
+			return nil, err
+		}
+		report.Activities++
+	}
+
+	if len(unresolved) > 0 {
+		report.Warnings = append(report.Warnings, strconv.Itoa(len(unresolved))+" users not found: their tasks are unassigned and their comments and activities are attributed to the importer")
+	}
+	return &ProjectImportResult{Report: report, Tasks: tasks}, nil
+}
+
+// restoreTimestamps 恢复导出包中的创建和更新时间（创建钩子会把时间设为当前时间），时间为空时保持不变
+func restoreTimestamps(db *gorm.DB, model interface{}, createdAt, updatedAt time.Time) error {
+	if createdAt.IsZero() {
+		return nil
+	}
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+	return db.Model(model).UpdateColumns(map[string]interface{}{
+		"created_at": createdAt,
+		"updated_at": updatedAt,
+	}).Error
+}
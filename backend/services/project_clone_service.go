@@ -0,0 +1,267 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ProjectCloneService 项目复制服务
+type ProjectCloneService struct{}
+
+// NewProjectCloneService 创建项目复制服务
+func NewProjectCloneService() *ProjectCloneService {
+	return &ProjectCloneService{}
+}
+
+// ProjectCloneOptions 复制项目的选项，阶段（含设置、流转规则和自动化规则）和工作流总是复制
+type ProjectCloneOptions struct {
+	IncludeTasks   bool // 复制未完成且未归档的任务
+	IncludeLabels  bool // 复制标签（以及任务上的标签）
+	IncludeMembers bool // 复制成员和角色
+	DateOffsetDays int  // 任务开始和截止日期平移的天数
+}
+
+// ProjectCloneReport 复制结果，ID 映射的键为原项目中的ID
+type ProjectCloneReport struct {
+	SourceProjectID uint          `json:"source_project_id"`
+	Stages          int           `json:"stages"`
+	Labels          int           `json:"labels"`
+	Members         int           `json:"members"`
+	Transitions     int           `json:"transitions"`
+	Automations     int           `json:"automations"`
+	Tasks           int           `json:"tasks"`
+	TaskLabels      int           `json:"task_labels"`
+	Dependencies    int           `json:"dependencies"`
+	SkippedTasks    int           `json:"skipped_tasks"` // 已完成或已归档、未复制的任务
+	StageIDs        map[uint]uint `json:"stage_ids"`
+	LabelIDs        map[uint]uint `json:"label_ids"`
+	TaskIDs         map[uint]uint `json:"task_ids"`
+	Warnings        []string      `json:"warnings,omitempty"`
+}
+
+// CloneProject 把 source 的设置和数据复制到刚创建的 target 项目中，应在创建 target 的事务中调用
+// target 的所有者应已是成员；返回复制出的任务，便于事务提交后更新搜索索引
+func (s *ProjectCloneService) CloneProject(db *gorm.DB, source, target *models.Project, opts ProjectCloneOptions, userID uint) (*ProjectCloneReport, []models.Task, error) {
+	report := &ProjectCloneReport{
+		SourceProjectID: source.ID,
+		StageIDs:        make(map[uint]uint),
+		LabelIDs:        make(map[uint]uint),
+		TaskIDs:         make(map[uint]uint),
+	}
+
+	// 成员先于自动化规则复制，指派给成员的规则才能通过检查
+	memberIDs := map[uint]bool{target.OwnerID: true}
+	if opts.IncludeMembers {
+		var members []models.ProjectMember
+		if err := db.Where("project_id = ?", source.ID).Order("id ASC").Find(&members).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, member := range members {
+			if memberIDs[member.UserID] {
+				continue
+			}
+			// 新项目只有一个所有者，原所有者作为管理员加入
+			role := member.Role
+			if role == models.ProjectMemberRoleOwner {
+				role = models.ProjectMemberRoleManager
+			}
+			copied := models.ProjectMember{
+				ProjectID: target.ID,
+				UserID:    member.UserID,
+				Role:      role,
+				InvitedBy: &userID,
+			}
+			if err := db.Create(&copied).Error; err != nil {
+				return nil, nil, err
+			}
+			memberIDs[member.UserID] = true
+			report.Members++
+		}
+	}
+
+	// 工作流、阶段、标签、流转规则和自动化规则按模板的方式复制
+	templateService := NewProjectTemplateService()
+	def, err := templateService.DefinitionFromProject(db, source.ID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !opts.IncludeLabels {
+		def.Labels = nil
+		automations := def.Automations[:0]
+		for _, automation := range def.Automations {
+			if automationUsesLabels(&automation) {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("automation %q not copied: it uses labels", automation.Name))
+				continue
+			}
+			automations = append(automations, automation)
+		}
+		def.Automations = automations
+	}
+	applied, err := templateService.ApplyTemplate(db, target, def, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Stages = len(applied.Stages)
+	report.Labels = len(applied.Labels)
+	report.Transitions = applied.Transitions
+	report.Automations = applied.Automations
+	report.Warnings = append(report.Warnings, applied.Warnings...)
+
+	// 模板中的阶段与原项目的阶段顺序一致，标签名称在项目内唯一
+	var sourceStages []models.Stage
+	if err := db.Where("project_id = ?", source.ID).Order("position ASC, id ASC").Find(&sourceStages).Error; err != nil {
+		return nil, nil, err
+	}
+	stages := make(map[uint]*models.Stage, len(sourceStages))
+	for i, stage := range sourceStages {
+		report.StageIDs[stage.ID] = applied.Stages[i].ID
+		stages[stage.ID] = &applied.Stages[i]
+	}
+	if opts.IncludeLabels {
+		labelIDs := make(map[string]uint, len(applied.Labels))
+		for _, label := range applied.Labels {
+			labelIDs[label.Name] = label.ID
+		}
+		sourceLabels, err := NewLabelService().ProjectLabels(db, source.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, label := range sourceLabels {
+			report.LabelIDs[label.ID] = labelIDs[label.Name]
+		}
+	}
+
+	if !opts.IncludeTasks {
+		return report, nil, nil
+	}
+	tasks, err := s.cloneTasks(db, source, target, opts, userID, stages, memberIDs, report)
+	if err != nil {
+		return nil, nil, err
+	}
+	return report, tasks, nil
+}
+
+// cloneTasks 按编号顺序复制未完成且未归档的任务，保留阶段内顺序，并复制任务之间的标签和依赖
+func (s *ProjectCloneService) cloneTasks(db *gorm.DB, source, target *models.Project, opts ProjectCloneOptions, userID uint, stages map[uint]*models.Stage, memberIDs map[uint]bool, report *ProjectCloneReport) ([]models.Task, error) {
+	workflow, err := NewWorkflowService().GetWorkflow(db, target.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.Task
+	if err := db.Preload("Stage").Where("project_id = ?", source.ID).Order("number ASC, id ASC").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	keyService := NewTaskKeyService()
+	copied := make([]models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.ArchivedAt != nil || task.CompletedAt != nil || IsTaskDone(workflow, task.Status, task.Stage) {
+			report.SkippedTasks++
+			continue
+		}
+		stage := stages[task.StageID]
+
+		// 负责人不是新项目成员时不保留
+		assigneeID := task.AssigneeID
+		if assigneeID != nil && !memberIDs[*assigneeID] {
+			assigneeID = nil
+		}
+
+		clone := models.Task{
+			StageID:        stage.ID,
+			ProjectID:      target.ID,
+			Title:          task.Title,
+			Description:    task.Description,
+			Status:         task.Status,
+			Priority:       task.Priority,
+			AssigneeID:     assigneeID,
+			StartDate:      shiftDays(task.StartDate, opts.DateOffsetDays),
+			DueDate:        shiftDays(task.DueDate, opts.DateOffsetDays),
+			DueAllDay:      task.DueAllDay,
+			EstimatedHours: task.EstimatedHours,
+			Rank:           task.Rank, // 阶段内排序键原样保留，顺序不变
+			CreatedBy:      userID,
+		}
+		if err := keyService.AssignTaskKey(db, &clone); err != nil {
+			return nil, err
+		}
+		if err := db.Create(&clone).Error; err != nil {
+			return nil, err
+		}
+		report.TaskIDs[task.ID] = clone.ID
+		copied = append(copied, clone)
+	}
+	report.Tasks = len(copied)
+	if len(copied) == 0 {
+		return copied, nil
+	}
+
+	if opts.IncludeLabels {
+		var taskLabels []models.TaskLabel
+		if err := db.Where("task_id IN (SELECT id FROM tasks WHERE project_id = ?)", source.ID).Order("id ASC").Find(&taskLabels).Error; err != nil {
+			return nil, err
+		}
+		for _, taskLabel := range taskLabels {
+			taskID, ok := report.TaskIDs[taskLabel.TaskID]
+			labelID := report.LabelIDs[taskLabel.LabelID]
+			if !ok || labelID == 0 {
+				continue
+			}
+			if err := db.Create(&models.TaskLabel{TaskID: taskID, LabelID: labelID}).Error; err != nil {
+				return nil, err
+			}
+			report.TaskLabels++
+		}
+	}
+
+	var dependencies []models.TaskDependency
+	if err := db.Where("project_id = ?", source.ID).Order("id ASC").Find(&dependencies).Error; err != nil {
+		return nil, err
+	}
+	for _, dependency := range dependencies {
+		taskID, ok := report.TaskIDs[dependency.TaskID]
+		dependsOnID, ok2 := report.TaskIDs[dependency.DependsOnTaskID]
+		if !ok || !ok2 {
+			continue
+		}
+		if err := db.Create(&models.TaskDependency{
+			ProjectID:       target.ID,
+			TaskID:          taskID,
+			DependsOnTaskID: dependsOnID,
+			Type:            dependency.Type,
+			CreatedBy:       userID,
+		}).Error; err != nil {
+			return nil, err
+		}
+		report.Dependencies++
+	}
+	return copied, nil
+}
+
+// automationUsesLabels 自动化规则是否引用了标签
+func automationUsesLabels(automation *models.TemplateAutomation) bool {
+	for _, condition := range automation.Conditions {
+		if condition.Field == "label" {
+			return true
+		}
+	}
+	for _, action := range automation.Actions {
+		if action.Label != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// shiftDays 把时间平移若干天，nil 保持为 nil
+func shiftDays(t *time.Time, days int) *time.Time {
+	if t == nil {
+		return nil
+	}
+	shifted := t.AddDate(0, 0, days)
+	return &shifted
+}
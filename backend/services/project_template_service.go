@@ -0,0 +1,698 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ProjectTemplateService 项目模板服务
+type ProjectTemplateService struct{}
+
+// NewProjectTemplateService 创建项目模板服务
+func NewProjectTemplateService() *ProjectTemplateService {
+	return &ProjectTemplateService{}
+}
+
+// TemplateApplyResult 按模板创建的内容；Warnings 为无法在新项目中使用而跳过的自动化规则
+type TemplateApplyResult struct {
+	Stages      []models.Stage `json:"stages"`
+	Labels      []models.Label `json:"labels"`
+	Transitions int            `json:"transitions"`
+	Automations int            `json:"automations"`
+	Tasks       []models.Task  `json:"tasks"`
+	Warnings    []string       `json:"warnings,omitempty"`
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// BuiltinTemplates 内置模板：基础看板、Scrum 和缺陷分流
+func BuiltinTemplates() []models.ProjectTemplate {
+	return []models.ProjectTemplate{
+		{
+			BuiltinKey:  "kanban",
+			Name:        "基础看板",
+			Description: "待办、进行中、已完成三列的简单看板，进行中限制 5 个任务（超出时仅提醒）。",
+			Definition: models.TemplateDefinition{
+				Stages: []models.TemplateStage{
+					{Ref: "todo", Name: "待办", Color: "#3B82F6", AutoAssignStatus: "todo"},
+					{Ref: "doing", Name: "进行中", Color: "#F59E0B", MaxTasks: 5, WIPMode: models.WIPModeSoft, AutoAssignStatus: "in_progress"},
+					{Ref: "done", Name: "已完成", Color: "#10B981", IsCompleted: true, AutoAssignStatus: "done"},
+				},
+				Tasks: []models.TemplateTask{
+					{Title: "熟悉看板", Description: "把任务拖到右侧的阶段即可更新进度。", Stage: "todo"},
+				},
+			},
+		},
+		{
+			BuiltinKey:  "scrum",
+			Name:        "Scrum",
+			Description: "产品待办、冲刺待办、开发、评审和完成五个阶段；完成前必须经过评审。",
+			Definition: models.TemplateDefinition{
+				Stages: []models.TemplateStage{
+					{Ref: "backlog", Name: "产品待办", Color: "#64748B", AutoAssignStatus: "todo"},
+					{Ref: "sprint", Name: "冲刺待办", Color: "#3B82F6", AutoAssignStatus: "todo"},
+					{Ref: "dev", Name: "开发中", Color: "#F59E0B", MaxTasksPerAssignee: 2, AutoAssignStatus: "in_progress"},
+					{Ref: "review", Name: "评审中", Color: "#8B5CF6", AutoAssignStatus: "review"},
+					{Ref: "done", Name: "已完成", Color: "#10B981", IsCompleted: true, AutoAssignStatus: "done"},
+				},
+				Labels: []models.TemplateLabel{
+					{Name: "用户故事", Color: "#3B82F6"},
+					{Name: "技术债", Color: "#F59E0B"},
+					{Name: "缺陷", Color: "#EF4444"},
+				},
+				Transitions: []models.TemplateTransition{
+					{FromStage: "review", ToStage: "done", Effect: models.TransitionAllow},
+				},
+				Automations: []models.TemplateAutomation{
+					{
+						Name:         "进入评审时提醒指派评审人",
+						Enabled:      true,
+						Trigger:      models.TriggerTaskMoved,
+						TriggerStage: "review",
+						Actions:      []models.TemplateAction{{Type: "post_comment", Content: "已提交评审，请指派评审人。"}},
+					},
+				},
+				Tasks: []models.TemplateTask{
+					{Title: "规划第一个冲刺", Description: "从产品待办中挑选本次冲刺要完成的任务。", Stage: "backlog", Labels: []string{"用户故事"}},
+				},
+			},
+		},
+		{
+			BuiltinKey:  "bug_triage",
+			Name:        "缺陷分流",
+			Description: "新提交、已分流、修复中、待验证和已关闭；缺陷必须分流后才能修复，分流时崩溃类缺陷自动设为紧急。",
+			Definition: models.TemplateDefinition{
+				Statuses: []models.TemplateStatus{
+					{Key: "new", Name: "新提交", Category: models.StatusCategoryTodo, Color: "#909399", IsDefault: true},
+					{Key: "triaged", Name: "已分流", Category: models.StatusCategoryTodo, Color: "#409EFF"},
+					{Key: "fixing", Name: "修复中", Category: models.StatusCategoryInProgress, Color: "#E6A23C"},
+					{Key: "verifying", Name: "待验证", Category: models.StatusCategoryInProgress, Color: "#8B5CF6"},
+					{Key: "closed", Name: "已关闭", Category: models.StatusCategoryDone, Color: "#67C23A"},
+					{Key: "wont_fix", Name: "不修复", Category: models.StatusCategoryDone, Color: "#C0C4CC"},
+				},
+				Stages: []models.TemplateStage{
+					{Ref: "new", Name: "新提交", Color: "#64748B", AutoAssignStatus: "new"},
+					{Ref: "triaged", Name: "已分流", Color: "#3B82F6", AutoAssignStatus: "triaged"},
+					{Ref: "fixing", Name: "修复中", Color: "#F59E0B", MaxTasksPerAssignee: 3, AutoAssignStatus: "fixing"},
+					{Ref: "verifying", Name: "待验证", Color: "#8B5CF6", AutoAssignStatus: "verifying"},
+					{Ref: "closed", Name: "已关闭", Color: "#10B981", IsCompleted: true},
+				},
+				Labels: []models.TemplateLabel{
+					{Name: "崩溃", Color: "#EF4444"},
+					{Name: "回归", Color: "#F97316"},
+					{Name: "界面", Color: "#3B82F6"},
+					{Name: "性能", Color: "#8B5CF6"},
+				},
+				Transitions: []models.TemplateTransition{
+					{FromStage: "new", ToStage: "fixing", Effect: models.TransitionDeny},
+					{FromStage: "verifying", ToStage: "closed", Effect: models.TransitionAllow},
+					{FromStage: "triaged", ToStage: "closed", Effect: models.TransitionAllow, RequiredRole: models.ProjectMemberRoleManager},
+				},
+				Automations: []models.TemplateAutomation{
+					{
+						Name:         "分流时崩溃类缺陷设为紧急",
+						Enabled:      true,
+						Trigger:      models.TriggerTaskMoved,
+						TriggerStage: "triaged",
+						Conditions: []models.AutomationCondition{
+							{Field: "label", Operator: "in", Values: []string{"崩溃"}},
+						},
+						Actions: []models.TemplateAction{{Type: "set_field", Field: "priority", Value: "P0"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// EnsureBuiltinTemplates 创建或更新内置模板，已有模板保持ID不变
+func (s *ProjectTemplateService) EnsureBuiltinTemplates(db *gorm.DB) error {
+	for _, template := range BuiltinTemplates() {
+		var existing models.ProjectTemplate
+		err := db.Where("builtin = ? AND builtin_key = ?", true, template.BuiltinKey).First(&existing).Error
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+		if err == nil {
+			template.ID = existing.ID
+			template.CreatedAt = existing.CreatedAt
+		}
+		template.Builtin = true
+		template.Shared = true
+		if err := db.Save(&template).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visibleCondition 用户可以使用的模板：内置、共享或自己保存的
+const visibleCondition = "builtin = ? OR shared = ? OR owner_id = ?"
+
+// VisibleTemplates 用户可以使用的模板，内置模板在前
+func (s *ProjectTemplateService) VisibleTemplates(db *gorm.DB, userID uint) ([]models.ProjectTemplate, error) {
+	var templates []models.ProjectTemplate
+	err := db.Where(visibleCondition, true, true, userID).Order("builtin DESC, name ASC, id ASC").Find(&templates).Error
+	return templates, err
+}
+
+// FindVisible 查找用户可以使用的模板
+func (s *ProjectTemplateService) FindVisible(db *gorm.DB, templateID, userID uint) (*models.ProjectTemplate, error) {
+	var template models.ProjectTemplate
+	if err := db.Where("id = ?", templateID).Where(visibleCondition, true, true, userID).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// ValidateDefinition 检查模板内容中的引用和取值，并为未设置 Ref 的阶段生成 Ref
+// 状态、优先级和阶段自动设置的状态在应用模板时按工作流检查
+func (s *ProjectTemplateService) ValidateDefinition(def *models.TemplateDefinition) error {
+	if len(def.Stages) == 0 {
+		return fmt.Errorf("template needs at least one stage")
+	}
+	refs := make(map[string]bool, len(def.Stages))
+	for i := range def.Stages {
+		stage := &def.Stages[i]
+		stage.Name = strings.TrimSpace(stage.Name)
+		if stage.Name == "" {
+			return fmt.Errorf("stage %d: name is required", i+1)
+		}
+		if stage.Ref == "" {
+			stage.Ref = "stage-" + strconv.Itoa(i+1)
+		}
+		if refs[stage.Ref] {
+			return fmt.Errorf("stage %d: duplicate ref %q", i+1, stage.Ref)
+		}
+		refs[stage.Ref] = true
+		if stage.MaxTasks < 0 || stage.MaxTasksPerAssignee < 0 {
+			return fmt.Errorf("stage %q: task limits must not be negative", stage.Ref)
+		}
+		if stage.WIPMode != "" && !ValidMode(stage.WIPMode) {
+			return fmt.Errorf("stage %q: invalid wip_mode %q", stage.Ref, stage.WIPMode)
+		}
+	}
+
+	labels := make(map[string]bool, len(def.Labels))
+	for i, label := range def.Labels {
+		name := strings.TrimSpace(label.Name)
+		if name == "" || len(name) > 50 {
+			return fmt.Errorf("label %d: name must be 1-50 characters", i+1)
+		}
+		if labels[name] {
+			return fmt.Errorf("label %d: duplicate name %q", i+1, name)
+		}
+		labels[name] = true
+		def.Labels[i].Name = name
+	}
+
+	for i, transition := range def.Transitions {
+		if !refs[transition.ToStage] {
+			return fmt.Errorf("transition %d: unknown to_stage %q", i+1, transition.ToStage)
+		}
+		if transition.FromStage != "" && !refs[transition.FromStage] {
+			return fmt.Errorf("transition %d: unknown from_stage %q", i+1, transition.FromStage)
+		}
+	}
+
+	for i, automation := range def.Automations {
+		if automation.TriggerStage != "" && !refs[automation.TriggerStage] {
+			return fmt.Errorf("automation %d: unknown trigger_stage %q", i+1, automation.TriggerStage)
+		}
+		for _, condition := range automation.Conditions {
+			if condition.Field != "stage_id" {
+				continue
+			}
+			for _, value := range condition.Values {
+				if !refs[value] {
+					return fmt.Errorf("automation %d: unknown stage %q in condition", i+1, value)
+				}
+			}
+		}
+		for j, action := range automation.Actions {
+			switch action.Type {
+			case "move_to_stage":
+				if !refs[action.Stage] {
+					return fmt.Errorf("automation %d action %d: unknown stage %q", i+1, j+1, action.Stage)
+				}
+			case "add_label", "remove_label":
+				if !labels[action.Label] {
+					return fmt.Errorf("automation %d action %d: unknown label %q", i+1, j+1, action.Label)
+				}
+			}
+		}
+	}
+
+	for i, task := range def.Tasks {
+		if strings.TrimSpace(task.Title) == "" {
+			return fmt.Errorf("task %d: title is required", i+1)
+		}
+		if !refs[task.Stage] {
+			return fmt.Errorf("task %d: unknown stage %q", i+1, task.Stage)
+		}
+		for _, label := range task.Labels {
+			if !labels[label] {
+				return fmt.Errorf("task %d: unknown label %q", i+1, label)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyTemplate 在新项目中按模板创建工作流、阶段、标签、流转规则、自动化规则和初始任务
+// 应在创建项目的事务中调用；项目应尚未有阶段
+func (s *ProjectTemplateService) ApplyTemplate(db *gorm.DB, project *models.Project, def *models.TemplateDefinition, userID uint) (*TemplateApplyResult, error) {
+	if err := s.ValidateDefinition(def); err != nil {
+		return nil, err
+	}
+
+	// 工作流
+	workflowService := NewWorkflowService()
+	if err := workflowService.EnsureWorkflow(db, project.ID); err != nil {
+		return nil, err
+	}
+	if len(def.Statuses) > 0 {
+		statuses := make([]models.WorkflowStatus, len(def.Statuses))
+		for i, status := range def.Statuses {
+			statuses[i] = models.WorkflowStatus{Key: status.Key, Name: status.Name, Category: status.Category, Color: status.Color, IsDefault: status.IsDefault}
+		}
+		if err := workflowService.UpdateStatuses(db, project.ID, statuses, nil); err != nil {
+			return nil, err
+		}
+	}
+	if len(def.Priorities) > 0 {
+		priorities := make([]models.WorkflowPriority, len(def.Priorities))
+		for i, priority := range def.Priorities {
+			priorities[i] = models.WorkflowPriority{Key: priority.Key, Name: priority.Name, Color: priority.Color, IsDefault: priority.IsDefault}
+		}
+		if err := workflowService.UpdatePriorities(db, project.ID, priorities, nil); err != nil {
+			return nil, err
+		}
+	}
+	workflow, err := workflowService.GetWorkflow(db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TemplateApplyResult{Stages: []models.Stage{}, Labels: []models.Label{}, Tasks: []models.Task{}}
+
+	// 阶段
+	stageIDs := make(map[string]uint, len(def.Stages))
+	stages := make(map[string]*models.Stage, len(def.Stages))
+	for i, item := range def.Stages {
+		if item.AutoAssignStatus != "" {
+			if err := workflow.ValidateStatus(item.AutoAssignStatus); err != nil {
+				return nil, fmt.Errorf("stage %q: %v", item.Ref, err)
+			}
+		}
+		stage := models.Stage{
+			ProjectID:           project.ID,
+			Name:                item.Name,
+			Description:         item.Description,
+			Color:               item.Color,
+			Position:            i,
+			IsCompleted:         item.IsCompleted,
+			CreatedBy:           userID,
+			AllowTaskCreation:   item.AllowTaskCreation == nil || *item.AllowTaskCreation,
+			MaxTasks:            item.MaxTasks,
+			MaxTasksPerAssignee: item.MaxTasksPerAssignee,
+			WIPMode:             item.WIPMode,
+			AllowTaskDeletion:   item.AllowTaskDeletion == nil || *item.AllowTaskDeletion,
+			AllowTaskMovement:   item.AllowTaskMovement == nil || *item.AllowTaskMovement,
+			NotificationEnabled: item.NotificationEnabled == nil || *item.NotificationEnabled,
+			AutoAssignStatus:    item.AutoAssignStatus,
+		}
+		if stage.Color == "" {
+			stage.Color = "#3B82F6"
+		}
+		if stage.WIPMode == "" {
+			stage.WIPMode = models.WIPModeHard
+		}
+		if err := db.Create(&stage).Error; err != nil {
+			return nil, err
+		}
+		// gorm 不写入零值，布尔设置为 false 时需要单独更新
+		if err := db.Model(&stage).Updates(map[string]interface{}{
+			"allow_task_creation":  stage.AllowTaskCreation,
+			"allow_task_deletion":  stage.AllowTaskDeletion,
+			"allow_task_movement":  stage.AllowTaskMovement,
+			"notification_enabled": stage.NotificationEnabled,
+		}).Error; err != nil {
+			return nil, err
+		}
+		result.Stages = append(result.Stages, stage)
+		stageIDs[item.Ref] = stage.ID
+	}
+	for i := range result.Stages {
+		stages[def.Stages[i].Ref] = &result.Stages[i]
+	}
+
+	// 标签
+	labelIDs := make(map[string]uint, len(def.Labels))
+	for _, item := range def.Labels {
+		label := models.Label{ProjectID: project.ID, Name: item.Name, Color: item.Color}
+		if label.Color == "" {
+			label.Color = "#909399"
+		}
+		if err := db.Create(&label).Error; err != nil {
+			return nil, err
+		}
+		result.Labels = append(result.Labels, label)
+		labelIDs[item.Name] = label.ID
+	}
+
+	// 流转规则
+	if len(def.Transitions) > 0 {
+		rules := make([]models.StageTransition, 0, len(def.Transitions))
+		for _, item := range def.Transitions {
+			rule := models.StageTransition{
+				ToStageID:      stageIDs[item.ToStage],
+				Effect:         item.Effect,
+				RequiredRole:   item.RequiredRole,
+				RequiredFields: item.RequiredFields,
+			}
+			if item.FromStage != "" {
+				from := stageIDs[item.FromStage]
+				rule.FromStageID = &from
+			}
+			rules = append(rules, rule)
+		}
+		if err := NewStageTransitionService().ReplaceRules(db, project.ID, rules); err != nil {
+			return nil, err
+		}
+		result.Transitions = len(rules)
+	}
+
+	// 自动化规则：引用的用户不是新项目成员等无法使用的规则跳过
+	automation := NewAutomationService()
+	for _, item := range def.Automations {
+		rule := models.AutomationRule{
+			ProjectID:  project.ID,
+			Name:       item.Name,
+			Enabled:    item.Enabled,
+			Trigger:    item.Trigger,
+			Conditions: make([]models.AutomationCondition, 0, len(item.Conditions)),
+			Actions:    make([]models.AutomationAction, 0, len(item.Actions)),
+			CreatedBy:  userID,
+		}
+		if item.TriggerStage != "" {
+			id := stageIDs[item.TriggerStage]
+			rule.TriggerStageID = &id
+		}
+		for _, condition := range item.Conditions {
+			if condition.Field == "stage_id" {
+				values := make([]string, len(condition.Values))
+				for i, ref := range condition.Values {
+					values[i] = strconv.FormatUint(uint64(stageIDs[ref]), 10)
+				}
+				condition.Values = values
+			}
+			rule.Conditions = append(rule.Conditions, condition)
+		}
+		for _, action := range item.Actions {
+			rule.Actions = append(rule.Actions, models.AutomationAction{
+				Type:    action.Type,
+				Field:   action.Field,
+				Value:   action.Value,
+				UserID:  action.UserID,
+				LabelID: labelIDs[action.Label],
+				StageID: stageIDs[action.Stage],
+				Content: action.Content,
+				URL:     action.URL,
+			})
+		}
+		if err := s.validateTemplateRule(db, automation, &rule); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("automation %q skipped: %v", item.Name, err))
+			continue
+		}
+		if err := db.Create(&rule).Error; err != nil {
+			return nil, err
+		}
+		result.Automations++
+	}
+
+	// 初始任务
+	rankService := NewTaskRankService()
+	keyService := NewTaskKeyService()
+	now := time.Now()
+	for i, item := range def.Tasks {
+		stage := stages[item.Stage]
+		status := item.Status
+		if status == "" {
+			status = stage.AutoAssignStatus
+		}
+		if status == "" {
+			status = workflow.DefaultStatus()
+		}
+		if err := workflow.ValidateStatus(status); err != nil {
+			return nil, fmt.Errorf("task %d: %v", i+1, err)
+		}
+		priority := item.Priority
+		if priority == "" {
+			priority = workflow.DefaultPriority()
+		}
+		if err := workflow.ValidatePriority(priority); err != nil {
+			return nil, fmt.Errorf("task %d: %v", i+1, err)
+		}
+		rank, err := rankService.RankForAppend(db, stage.ID)
+		if err != nil {
+			return nil, err
+		}
+		task := models.Task{
+			StageID:        stage.ID,
+			ProjectID:      project.ID,
+			Title:          strings.TrimSpace(item.Title),
+			Description:    item.Description,
+			Status:         status,
+			Priority:       priority,
+			EstimatedHours: item.EstimatedHours,
+			Rank:           rank,
+			CreatedBy:      userID,
+		}
+		if IsTaskDone(workflow, status, stage) {
+			task.CompletedAt = &now
+		}
+		if err := keyService.AssignTaskKey(db, &task); err != nil {
+			return nil, err
+		}
+		if err := db.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		for _, name := range item.Labels {
+			if err := db.Create(&models.TaskLabel{TaskID: task.ID, LabelID: labelIDs[name]}).Error; err != nil {
+				return nil, err
+			}
+		}
+		result.Tasks = append(result.Tasks, task)
+	}
+	return result, nil
+}
+
+// validateTemplateRule 检查按模板生成的自动化规则
+// 权限检查使用全局连接，在事务中调用会阻塞，因此 assign_user 的用户在事务内检查，其余部分交给 ValidateRule
+func (s *ProjectTemplateService) validateTemplateRule(db *gorm.DB, automation *AutomationService, rule *models.AutomationRule) error {
+	var project models.Project
+	if err := db.Select("id, owner_id").First(&project, rule.ProjectID).Error; err != nil {
+		return err
+	}
+	check := *rule
+	check.Actions = make([]models.AutomationAction, len(rule.Actions))
+	for i, action := range rule.Actions {
+		if action.Type == "assign_user" && action.UserID != nil {
+			var count int
+			db.Model(&models.ProjectMember{}).Where("project_id = ? AND user_id = ?", rule.ProjectID, *action.UserID).Count(&count)
+			if count == 0 && project.OwnerID != *action.UserID {
+				return fmt.Errorf("action %d: user %d is not a member of this project", i+1, *action.UserID)
+			}
+			action.UserID = nil
+		}
+		check.Actions[i] = action
+	}
+	return automation.ValidateRule(db, &check)
+}
+
+// DefinitionFromProject 把项目的工作流、阶段、标签、流转规则和自动化规则保存为模板内容
+// includeTasks 为 true 时未归档的任务作为初始任务（不包含负责人、日期和评论）
+func (s *ProjectTemplateService) DefinitionFromProject(db *gorm.DB, projectID uint, includeTasks bool) (*models.TemplateDefinition, error) {
+	def := &models.TemplateDefinition{}
+
+	workflow, err := NewWorkflowService().GetWorkflow(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range workflow.Statuses {
+		def.Statuses = append(def.Statuses, models.TemplateStatus{Key: status.Key, Name: status.Name, Category: status.Category, Color: status.Color, IsDefault: status.IsDefault})
+	}
+	for _, priority := range workflow.Priorities {
+		def.Priorities = append(def.Priorities, models.TemplatePriority{Key: priority.Key, Name: priority.Name, Color: priority.Color, IsDefault: priority.IsDefault})
+	}
+
+	var stages []models.Stage
+	if err := db.Where("project_id = ?", projectID).Order("position ASC, id ASC").Find(&stages).Error; err != nil {
+		return nil, err
+	}
+	stageRefs := make(map[uint]string, len(stages))
+	for i, stage := range stages {
+		ref := "stage-" + strconv.Itoa(i+1)
+		stageRefs[stage.ID] = ref
+		def.Stages = append(def.Stages, models.TemplateStage{
+			Ref:                 ref,
+			Name:                stage.Name,
+			Description:         stage.Description,
+			Color:               stage.Color,
+			IsCompleted:         stage.IsCompleted,
+			MaxTasks:            stage.MaxTasks,
+			MaxTasksPerAssignee: stage.MaxTasksPerAssignee,
+			WIPMode:             StageMode(&stage),
+			AllowTaskCreation:   boolPtr(stage.AllowTaskCreation),
+			AllowTaskDeletion:   boolPtr(stage.AllowTaskDeletion),
+			AllowTaskMovement:   boolPtr(stage.AllowTaskMovement),
+			NotificationEnabled: boolPtr(stage.NotificationEnabled),
+			AutoAssignStatus:    stage.AutoAssignStatus,
+		})
+	}
+
+	labels, err := NewLabelService().ProjectLabels(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	labelNames := make(map[uint]string, len(labels))
+	for _, label := range labels {
+		labelNames[label.ID] = label.Name
+		def.Labels = append(def.Labels, models.TemplateLabel{Name: label.Name, Color: label.Color})
+	}
+
+	var transitions []models.StageTransition
+	if err := db.Where("project_id = ?", projectID).Order("id ASC").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	for _, transition := range transitions {
+		item := models.TemplateTransition{
+			ToStage:        stageRefs[transition.ToStageID],
+			Effect:         transition.Effect,
+			RequiredRole:   transition.RequiredRole,
+			RequiredFields: transition.RequiredFields,
+		}
+		if transition.FromStageID != nil {
+			item.FromStage = stageRefs[*transition.FromStageID]
+		}
+		if item.ToStage == "" || (transition.FromStageID != nil && item.FromStage == "") {
+			continue
+		}
+		def.Transitions = append(def.Transitions, item)
+	}
+
+	var rules []models.AutomationRule
+	if err := db.Where("project_id = ?", projectID).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if item, ok := templateAutomation(&rule, stageRefs, labelNames); ok {
+			def.Automations = append(def.Automations, item)
+		}
+	}
+
+	if includeTasks {
+		var tasks []models.Task
+		if err := db.Table("tasks").Select("tasks.*").
+			Joins("JOIN stages ON stages.id = tasks.stage_id").
+			Where("tasks.project_id = ? AND "+TaskNotArchivedCondition, projectID).
+			Order("stages.position ASC, stages.id ASC, tasks.rank ASC, tasks.id ASC").
+			Find(&tasks).Error; err != nil {
+			return nil, err
+		}
+		taskLabels := make(map[uint][]string)
+		if len(tasks) > 0 {
+			var rows []models.TaskLabel
+			if err := db.Where("task_id IN (SELECT id FROM tasks WHERE project_id = ?)", projectID).Order("id ASC").Find(&rows).Error; err != nil {
+				return nil, err
+			}
+			for _, row := range rows {
+				if name, ok := labelNames[row.LabelID]; ok {
+					taskLabels[row.TaskID] = append(taskLabels[row.TaskID], name)
+				}
+			}
+		}
+		for _, task := range tasks {
+			def.Tasks = append(def.Tasks, models.TemplateTask{
+				Title:          task.Title,
+				Description:    task.Description,
+				Stage:          stageRefs[task.StageID],
+				Status:         task.Status,
+				Priority:       task.Priority,
+				EstimatedHours: task.EstimatedHours,
+				Labels:         taskLabels[task.ID],
+			})
+		}
+	}
+	return def, nil
+}
+
+// templateAutomation 把自动化规则中引用的阶段和标签转换为模板中的引用，引用已失效时返回 false
+func templateAutomation(rule *models.AutomationRule, stageRefs map[uint]string, labelNames map[uint]string) (models.TemplateAutomation, bool) {
+	item := models.TemplateAutomation{
+		Name:    rule.Name,
+		Enabled: rule.Enabled,
+		Trigger: rule.Trigger,
+	}
+	if rule.TriggerStageID != nil {
+		if item.TriggerStage = stageRefs[*rule.TriggerStageID]; item.TriggerStage == "" {
+			return item, false
+		}
+	}
+	for _, condition := range rule.Conditions {
+		switch condition.Field {
+		case "stage_id", "label":
+			values := make([]string, 0, len(condition.Values))
+			for _, value := range condition.Values {
+				id, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					if condition.Field == "stage_id" {
+						return item, false
+					}
+					values = append(values, value) // 标签条件可以直接使用名称
+					continue
+				}
+				ref := stageRefs[uint(id)]
+				if condition.Field == "label" {
+					ref = labelNames[uint(id)]
+				}
+				if ref == "" {
+					return item, false
+				}
+				values = append(values, ref)
+			}
+			condition.Values = values
+		}
+		item.Conditions = append(item.Conditions, condition)
+	}
+	for _, action := range rule.Actions {
+		templateAction := models.TemplateAction{
+			Type:    action.Type,
+			Field:   action.Field,
+			Value:   action.Value,
+			UserID:  action.UserID,
+			Content: action.Content,
+			URL:     action.URL,
+		}
+		switch action.Type {
+		case "move_to_stage":
+			if templateAction.Stage = stageRefs[action.StageID]; templateAction.Stage == "" {
+				return item, false
+			}
+		case "add_label", "remove_label":
+			if templateAction.Label = labelNames[action.LabelID]; templateAction.Label == "" {
+				return item, false
+			}
+		}
+		item.Actions = append(item.Actions, templateAction)
+	}
+	return item, true
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"project-manager-backend/models"
+	"project-manager-backend/utils"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ReminderService 截止日期提醒服务
+// 定时扫描未完成且设置了截止时间的任务：截止前按提前量提醒负责人，逾期后每天通知负责人，
+// 逾期超过 EscalationDays 天后通知项目管理员。每个提醒通过 TaskReminderLog 去重，只发送一次
+type ReminderService struct {
+	Offsets        []time.Duration // 截止前提醒的提前量
+	EscalationDays int             // 逾期多少天后升级，0 表示不升级
+}
+
+// reminderMu 防止后台调度和手动触发同时扫描导致重复提醒
+var reminderMu sync.Mutex
+
+// NewReminderService 创建截止日期提醒服务
+func NewReminderService(offsets []time.Duration, escalationDays int) *ReminderService {
+	sorted := append([]time.Duration(nil), offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+	return &ReminderService{Offsets: sorted, EscalationDays: escalationDays}
+}
+
+// ReminderRunResult 单次扫描结果
+type ReminderRunResult struct {
+	CheckedTasks int `json:"checked_tasks"`
+	Reminders    int `json:"reminders"`
+	Overdue      int `json:"overdue"`
+	Escalations  int `json:"escalations"`
+	Automations  int `json:"automations"` // 截止时间已过触发的自动化规则数
+}
+
+// Start 启动后台调度，每隔 interval 扫描一次，返回停止函数
+func (s *ReminderService) Start(db *gorm.DB, interval time.Duration) func() {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		s.runAndLog(db)
+		for {
+			select {
+			case <-ticker.C:
+				s.runAndLog(db)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (s *ReminderService) runAndLog(db *gorm.DB) {
+	result, err := s.Run(db, time.Now())
+	if err != nil {
+		log.Printf("Reminder scan failed: %v", err)
+		return
+	}
+	if result.Reminders+result.Overdue+result.Escalations > 0 {
+		log.Printf("Reminder scan sent %d reminders, %d overdue notifications and %d escalations",
+			result.Reminders, result.Overdue, result.Escalations)
+	}
+	if result.Automations > 0 {
+		log.Printf("Reminder scan ran %d due date automations", result.Automations)
+	}
+}
+
+// Run 以 now 为当前时间扫描一次所有任务并发送到期的提醒
+func (s *ReminderService) Run(db *gorm.DB, now time.Time) (*ReminderRunResult, error) {
+	reminderMu.Lock()
+	defer reminderMu.Unlock()
+
+	// 已完成阶段和关闭了通知的阶段中的任务、已归档的任务不提醒
+	var tasks []models.Task
+	if err := db.Preload("Project").Select("tasks.*").
+		Joins("JOIN stages ON stages.id = tasks.stage_id").
+		Where("tasks.due_date IS NOT NULL AND NOT "+TaskStatusCategoryCondition(models.StatusCategoryDone)).
+		Where(TaskNotArchivedCondition).
+		Where("(stages.is_completed IS NULL OR stages.is_completed = ?)", false).
+		Where("(stages.notification_enabled IS NULL OR stages.notification_enabled = ?)", true).
+		Order("tasks.id ASC").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %v", err)
+	}
+
+	result := &ReminderRunResult{CheckedTasks: len(tasks)}
+	timezones := make(map[[2]uint]*time.Location)
+	managers := make(map[uint][]uint)
+
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Project == nil || task.Project.Status == models.ProjectStatusArchived {
+			continue
+		}
+
+		if task.AssigneeID != nil {
+			assigneeID := *task.AssigneeID
+			key := [2]uint{assigneeID, task.ProjectID}
+			loc, ok := timezones[key]
+			if !ok {
+				loc = utils.TaskTimezone(assigneeID, task.ProjectID)
+				timezones[key] = loc
+			}
+
+			sent, err := s.remindBeforeDue(db, task, assigneeID, loc, now)
+			if err != nil {
+				return result, err
+			}
+			result.Reminders += sent
+
+			sent, err = s.notifyOverdue(db, task, assigneeID, loc, now)
+			if err != nil {
+				return result, err
+			}
+			result.Overdue += sent
+		}
+
+		if s.EscalationDays > 0 {
+			ids, ok := managers[task.ProjectID]
+			if !ok {
+				ids = s.projectManagers(db, task.Project)
+				managers[task.ProjectID] = ids
+			}
+			loc := utils.ResolveTimezone(task.Project.Timezone)
+			sent, err := s.escalate(db, task, ids, loc, now)
+			if err != nil {
+				return result, err
+			}
+			result.Escalations += sent
+		}
+	}
+
+	// 触发截止时间已过的自动化规则
+	runs, err := NewAutomationService().DispatchDueDatePassed(db, now)
+	if err != nil {
+		return result, fmt.Errorf("failed to run due date automations: %v", err)
+	}
+	result.Automations = runs
+
+	return result, nil
+}
+
+// remindBeforeDue 进入提前量窗口时提醒负责人
+// 错过了多个提前量时（例如任务创建时已不足1小时）只发送最近的一个，其余记为已发送
+func (s *ReminderService) remindBeforeDue(db *gorm.DB, task *models.Task, userID uint, loc *time.Location, now time.Time) (int, error) {
+	deadline := utils.DueDeadline(*task.DueDate, task.DueAllDay, loc)
+	if !now.Before(deadline) {
+		return 0, nil
+	}
+
+	var due []time.Duration
+	for _, offset := range s.Offsets {
+		if !now.Before(deadline.Add(-offset)) {
+			due = append(due, offset)
+		}
+	}
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	sent := 0
+	for i, offset := range due {
+		claimed, err := s.claim(db, task, userID, models.NotificationTypeDueReminder, offset.String())
+		if err != nil {
+			return sent, err
+		}
+		if !claimed || i != len(due)-1 {
+			continue
+		}
+		content := fmt.Sprintf("任务 %s「%s」将于 %s 到期", task.Key, task.Title, formatDeadline(task, loc))
+		if err := s.notify(db, task, userID, models.NotificationTypeDueReminder, "任务即将到期", content); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// notifyOverdue 任务逾期后每天通知负责人一次（按负责人时区的日期去重）
+func (s *ReminderService) notifyOverdue(db *gorm.DB, task *models.Task, userID uint, loc *time.Location, now time.Time) (int, error) {
+	if !utils.IsOverdue(*task.DueDate, task.DueAllDay, now, loc) {
+		return 0, nil
+	}
+
+	claimed, err := s.claim(db, task, userID, models.NotificationTypeOverdue, now.In(loc).Format(utils.DateLayout))
+	if err != nil || !claimed {
+		return 0, err
+	}
+	days := overdueDays(task, loc, now)
+	content := fmt.Sprintf("任务 %s「%s」已逾期 %d 天（截止时间 %s）", task.Key, task.Title, days, formatDeadline(task, loc))
+	if err := s.notify(db, task, userID, models.NotificationTypeOverdue, "任务已逾期", content); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// escalate 逾期超过 EscalationDays 天时通知项目所有者和管理员（每人只通知一次）
+func (s *ReminderService) escalate(db *gorm.DB, task *models.Task, managerIDs []uint, loc *time.Location, now time.Time) (int, error) {
+	deadline := utils.DueDeadline(*task.DueDate, task.DueAllDay, loc)
+	if now.Before(deadline.AddDate(0, 0, s.EscalationDays)) {
+		return 0, nil
+	}
+
+	sent := 0
+	for _, managerID := range managerIDs {
+		claimed, err := s.claim(db, task, managerID, models.NotificationTypeEscalation, "escalation")
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+		assignee := "未分配"
+		if task.AssigneeID != nil {
+			var user models.User
+			if err := db.Select("username").Where("id = ?", *task.AssigneeID).First(&user).Error; err == nil {
+				assignee = user.Username
+			}
+		}
+		content := fmt.Sprintf("任务 %s「%s」已逾期超过 %d 天，负责人：%s", task.Key, task.Title, s.EscalationDays, assignee)
+		if err := s.notify(db, task, managerID, models.NotificationTypeEscalation, "逾期任务升级", content); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// projectManagers 获取项目所有者和管理员
+func (s *ReminderService) projectManagers(db *gorm.DB, project *models.Project) []uint {
+	ids := []uint{project.OwnerID}
+	var members []models.ProjectMember
+	db.Where("project_id = ? AND role IN (?)", project.ID,
+		[]models.ProjectMemberRole{models.ProjectMemberRoleOwner, models.ProjectMemberRoleManager}).Find(&members)
+	for _, member := range members {
+		if member.UserID != project.OwnerID {
+			ids = append(ids, member.UserID)
+		}
+	}
+	return ids
+}
+
+// claim 记录提醒已发送，已经发送过时返回 false
+func (s *ReminderService) claim(db *gorm.DB, task *models.Task, userID uint, kind models.NotificationType, dedupKey string) (bool, error) {
+	var count int
+	if err := db.Model(&models.TaskReminderLog{}).
+		Where("task_id = ? AND user_id = ? AND type = ? AND dedup_key = ? AND due_date = ?", task.ID, userID, kind, dedupKey, task.DueDate.UTC()).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check reminder log: %v", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	entry := models.TaskReminderLog{TaskID: task.ID, UserID: userID, Type: kind, DedupKey: dedupKey, DueDate: task.DueDate.UTC()}
+	if err := db.Create(&entry).Error; err != nil {
+		return false, fmt.Errorf("failed to save reminder log: %v", err)
+	}
+	return true, nil
+}
+
+func (s *ReminderService) notify(db *gorm.DB, task *models.Task, userID uint, kind models.NotificationType, title, content string) error {
+	notification := models.Notification{
+		UserID:    userID,
+		ProjectID: task.ProjectID,
+		TaskID:    task.ID,
+		Type:      kind,
+		Title:     title,
+		Content:   content,
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		return fmt.Errorf("failed to create notification: %v", err)
+	}
+	return nil
+}
+
+// overdueDays 逾期天数（不足一天按一天计）
+func overdueDays(task *models.Task, loc *time.Location, now time.Time) int {
+	deadline := utils.DueDeadline(*task.DueDate, task.DueAllDay, loc)
+	return int(now.Sub(deadline).Hours()/24) + 1
+}
+
+// formatDeadline 以接收人时区显示截止时间
+func formatDeadline(task *models.Task, loc *time.Location) string {
+	if task.DueAllDay {
+		return task.DueDate.UTC().Format(utils.DateLayout)
+	}
+	return task.DueDate.In(loc).Format("2006-01-02 15:04 MST")
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 中文分词
+//
+// FTS5 自带的 unicode61 分词器按空白和标点切分，连续的中文会被当成一个词，无法搜索其中的片段。
+// 写入索引前先在 Go 中切分：中日韩文字按相邻两字（二元组）切分，并在每段末尾补上最后一个字，
+// 其他文字按字母数字切词并转为小写，切分结果用空格连接后交给 unicode61 分词。
+// 查询时用同样的方式切分，多字中文查询转为二元组短语，单字查询用前缀匹配。
+//
+// 例如 "修复登录问题" 切分为 "修复 复登 登录 录问 问题 题"，查询 "登录问题" 转为短语 "登录 录问 问题"。
+
+// isCJK 是否为需要按字切分的中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// textRun 连续的同类文字
+type textRun struct {
+	text string
+	cjk  bool
+}
+
+// splitTextRuns 将文本拆分为中日韩文字段和字母数字段，其余字符作为分隔符
+func splitTextRuns(text string) []textRun {
+	var runs []textRun
+	var current []rune
+	currentCJK := false
+
+	flush := func() {
+		if len(current) > 0 {
+			runs = append(runs, textRun{text: string(current), cjk: currentCJK})
+			current = current[:0]
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !currentCJK {
+				flush()
+			}
+			currentCJK = true
+			current = append(current, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if currentCJK {
+				flush()
+			}
+			currentCJK = false
+			current = append(current, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return runs
+}
+
+// cjkTokens 中文段的二元组，最后补上末尾的单字
+func cjkTokens(run string) []string {
+	chars := []rune(run)
+	tokens := make([]string, 0, len(chars))
+	for i := 0; i+1 < len(chars); i++ {
+		tokens = append(tokens, string(chars[i:i+2]))
+	}
+	return append(tokens, string(chars[len(chars)-1]))
+}
+
+// SegmentText 切分文本，返回写入全文索引的内容
+func SegmentText(text string) string {
+	var tokens []string
+	for _, run := range splitTextRuns(text) {
+		if run.cjk {
+			tokens = append(tokens, cjkTokens(run.text)...)
+		} else {
+			tokens = append(tokens, run.text)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// BuildMatchQuery 将用户输入转换为 FTS5 查询表达式，所有词都需要匹配
+// 同时返回用于高亮的词（小写），输入中没有可搜索的内容时返回空字符串
+func BuildMatchQuery(input string) (string, []string) {
+	var clauses []string
+	var terms []string
+	seen := make(map[string]bool)
+
+	for _, run := range splitTextRuns(input) {
+		if seen[run.text] {
+			continue
+		}
+		seen[run.text] = true
+		terms = append(terms, run.text)
+
+		chars := []rune(run.text)
+		if run.cjk && len(chars) > 1 {
+			// 去掉末尾补的单字，二元组按顺序组成短语
+			tokens := cjkTokens(run.text)
+			clauses = append(clauses, `"`+strings.Join(tokens[:len(tokens)-1], " ")+`"`)
+		} else {
+			clauses = append(clauses, `"`+run.text+`"*`)
+		}
+	}
+	return strings.Join(clauses, " AND "), terms
+}
@@ -0,0 +1,433 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"project-manager-backend/models"
+	"strings"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SearchService 全文搜索服务
+// 任务（编号、标题、描述）和评论内容保存在 FTS5 虚拟表 search_index 中，
+// 内容经过 SegmentText 切分后写入，创建、更新、删除任务和评论时需要同步更新索引
+type SearchService struct{}
+
+// NewSearchService 创建全文搜索服务
+func NewSearchService() *SearchService {
+	return &SearchService{}
+}
+
+// 索引文档类型
+const (
+	SearchDocTask    = "task"
+	SearchDocComment = "comment"
+)
+
+// ErrSearchUnavailable 当前 SQLite 不支持 FTS5 时无法搜索
+var ErrSearchUnavailable = errors.New("full-text search is not available")
+
+// searchIndexReady 索引表是否可用（EnsureIndex 成功后设置）
+var searchIndexReady bool
+
+// 文档的 rowid：任务为 id*2，评论为 id*2+1，便于按文档更新和删除
+func taskDocRowID(taskID uint) int64 {
+	return int64(taskID) * 2
+}
+
+func commentDocRowID(commentID uint) int64 {
+	return int64(commentID)*2 + 1
+}
+
+// SearchQuery 搜索条件
+type SearchQuery struct {
+	Text       string      // 用户输入
+	Type       string      // 文档类型，为空表示全部
+	ProjectIDs interface{} // 可见项目ID（子查询）
+	ProjectID  uint        // 限定项目，0 表示不限
+	Limit      int
+	Offset     int
+}
+
+// SearchHit 搜索结果
+type SearchHit struct {
+	Type      string  `json:"type"`
+	ID        uint    `json:"id"` // 任务ID或评论ID
+	TaskID    uint    `json:"task_id"`
+	TaskKey   string  `json:"task_key"`
+	ProjectID uint    `json:"project_id"`
+	Title     string  `json:"title"`   // 任务标题（评论为所属任务的标题）
+	Snippet   string  `json:"snippet"` // 匹配内容摘要，命中的词用 <mark> 标记
+	Score     float64 `json:"score"`   // 相关度，越大越相关
+}
+
+// SearchResult 搜索结果列表
+type SearchResult struct {
+	Hits  []SearchHit `json:"hits"`
+	Total int         `json:"total"`
+	Terms []string    `json:"terms"`
+}
+
+// EnsureIndex 创建索引表，索引为空时从现有任务和评论重建
+func (s *SearchService) EnsureIndex(db *gorm.DB) error {
+	if err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		title, body,
+		doc_type UNINDEXED, doc_id UNINDEXED, task_id UNINDEXED, project_id UNINDEXED,
+		tokenize = 'unicode61'
+	)`).Error; err != nil {
+		return fmt.Errorf("%w: %v", ErrSearchUnavailable, err)
+	}
+	searchIndexReady = true
+
+	var count int
+	if err := db.Raw("SELECT count(*) FROM search_index").Row().Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		indexed, err := s.Rebuild(db)
+		if err != nil {
+			return err
+		}
+		if indexed > 0 {
+			log.Printf("Search index built for %d documents", indexed)
+		}
+	}
+	return nil
+}
+
+// Rebuild 清空并重建索引，返回索引的文档数
+func (s *SearchService) Rebuild(db *gorm.DB) (int, error) {
+	if !searchIndexReady {
+		return 0, ErrSearchUnavailable
+	}
+
+	tx := db.Begin()
+	if err := tx.Exec("DELETE FROM search_index").Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var tasks []models.Task
+	if err := tx.Find(&tasks).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	for i := range tasks {
+		if err := s.insertTask(tx, &tasks[i]); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	projectIDs := make(map[uint]uint, len(tasks))
+	for _, task := range tasks {
+		projectIDs[task.ID] = task.ProjectID
+	}
+	var comments []models.Comment
+	if err := tx.Find(&comments).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	indexed := len(tasks)
+	for i := range comments {
+		projectID, ok := projectIDs[comments[i].TaskID]
+		if !ok {
+			continue // 所属任务已删除
+		}
+		if err := s.insertComment(tx, &comments[i], projectID); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		indexed++
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return indexed, nil
+}
+
+// IndexTask 更新任务的索引，并同步任务评论的所属项目
+func (s *SearchService) IndexTask(db *gorm.DB, task *models.Task) error {
+	if !searchIndexReady {
+		return nil
+	}
+	if err := db.Exec("DELETE FROM search_index WHERE rowid = ?", taskDocRowID(task.ID)).Error; err != nil {
+		return err
+	}
+	if err := s.insertTask(db, task); err != nil {
+		return err
+	}
+	return db.Exec("UPDATE search_index SET project_id = ? WHERE doc_type = ? AND task_id = ?",
+		task.ProjectID, SearchDocComment, task.ID).Error
+}
+
+// RemoveTask 删除任务及其评论的索引
+func (s *SearchService) RemoveTask(db *gorm.DB, taskID uint) error {
+	if !searchIndexReady {
+		return nil
+	}
+	return db.Exec("DELETE FROM search_index WHERE task_id = ?", taskID).Error
+}
+
+// RemoveStageTasks 删除阶段内所有任务及其评论的索引（需要在删除任务之前调用）
+func (s *SearchService) RemoveStageTasks(db *gorm.DB, stageID uint) error {
+	if !searchIndexReady {
+		return nil
+	}
+	return db.Exec("DELETE FROM search_index WHERE task_id IN (SELECT id FROM tasks WHERE stage_id = ?)", stageID).Error
+}
+
+// IndexComment 更新评论的索引
+func (s *SearchService) IndexComment(db *gorm.DB, comment *models.Comment) error {
+	if !searchIndexReady {
+		return nil
+	}
+	var task models.Task
+	if err := db.Select("id, project_id").First(&task, comment.TaskID).Error; err != nil {
+		return err
+	}
+	if err := db.Exec("DELETE FROM search_index WHERE rowid = ?", commentDocRowID(comment.ID)).Error; err != nil {
+		return err
+	}
+	return s.insertComment(db, comment, task.ProjectID)
+}
+
+// IndexTaskComments 重建任务所有评论的索引（例如复制任务时一并复制了评论）
+func (s *SearchService) IndexTaskComments(db *gorm.DB, task *models.Task) error {
+	if !searchIndexReady {
+		return nil
+	}
+	if err := db.Exec("DELETE FROM search_index WHERE doc_type = ? AND task_id = ?", SearchDocComment, task.ID).Error; err != nil {
+		return err
+	}
+	var comments []models.Comment
+	if err := db.Where("task_id = ?", task.ID).Find(&comments).Error; err != nil {
+		return err
+	}
+	for i := range comments {
+		if err := s.insertComment(db, &comments[i], task.ProjectID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveComment 删除评论及其回复的索引（需要在删除评论之前调用）
+func (s *SearchService) RemoveComment(db *gorm.DB, commentID uint) error {
+	if !searchIndexReady {
+		return nil
+	}
+	return db.Exec(`DELETE FROM search_index WHERE rowid = ? OR rowid IN (
+		SELECT id * 2 + 1 FROM comments WHERE parent_comment_id = ?)`, commentDocRowID(commentID), commentID).Error
+}
+
+func (s *SearchService) insertTask(db *gorm.DB, task *models.Task) error {
+	return db.Exec(`INSERT INTO search_index (rowid, title, body, doc_type, doc_id, task_id, project_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		taskDocRowID(task.ID), SegmentText(task.Key+" "+task.Title), SegmentText(task.Description),
+		SearchDocTask, task.ID, task.ID, task.ProjectID).Error
+}
+
+func (s *SearchService) insertComment(db *gorm.DB, comment *models.Comment, projectID uint) error {
+	return db.Exec(`INSERT INTO search_index (rowid, title, body, doc_type, doc_id, task_id, project_id)
+		VALUES (?, '', ?, ?, ?, ?, ?)`,
+		commentDocRowID(comment.ID), SegmentText(comment.Content),
+		SearchDocComment, comment.ID, comment.TaskID, projectID).Error
+}
+
+// searchRow 索引查询结果
+type searchRow struct {
+	DocType   string
+	DocID     uint
+	TaskID    uint
+	ProjectID uint
+	Score     float64
+}
+
+// Search 搜索任务和评论，按相关度排序（标题的权重高于内容）
+func (s *SearchService) Search(db *gorm.DB, query SearchQuery) (*SearchResult, error) {
+	if !searchIndexReady {
+		return nil, ErrSearchUnavailable
+	}
+
+	match, terms := BuildMatchQuery(query.Text)
+	result := &SearchResult{Hits: []SearchHit{}, Terms: terms}
+	if match == "" {
+		return result, nil
+	}
+
+	// 已删除和已归档任务的索引不会返回
+	base := db.Table("search_index").
+		Where("search_index MATCH ?", match).
+		Where("search_index.task_id IN (SELECT id FROM tasks WHERE "+TaskNotArchivedCondition+")").
+		Where("search_index.project_id IN ?", query.ProjectIDs)
+	if query.Type != "" {
+		base = base.Where("search_index.doc_type = ?", query.Type)
+	}
+	if query.ProjectID != 0 {
+		base = base.Where("search_index.project_id = ?", query.ProjectID)
+	}
+
+	if err := base.Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	var rows []searchRow
+	listQuery := base.Select("doc_type, doc_id, task_id, project_id, -bm25(search_index, 10.0, 1.0) AS score").
+		Order("score DESC, search_index.rowid ASC")
+	if query.Limit > 0 {
+		listQuery = listQuery.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		listQuery = listQuery.Offset(query.Offset)
+	}
+	if err := listQuery.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	// 加载命中的任务和评论原文，用于标题和摘要
+	var taskIDs, commentIDs []uint
+	for _, row := range rows {
+		taskIDs = append(taskIDs, row.TaskID)
+		if row.DocType == SearchDocComment {
+			commentIDs = append(commentIDs, row.DocID)
+		}
+	}
+	var tasks []models.Task
+	if err := db.Where("id IN (?)", taskIDs).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	taskMap := make(map[uint]*models.Task, len(tasks))
+	for i := range tasks {
+		taskMap[tasks[i].ID] = &tasks[i]
+	}
+	commentMap := make(map[uint]*models.Comment)
+	if len(commentIDs) > 0 {
+		var comments []models.Comment
+		if err := db.Where("id IN (?)", commentIDs).Find(&comments).Error; err != nil {
+			return nil, err
+		}
+		for i := range comments {
+			commentMap[comments[i].ID] = &comments[i]
+		}
+	}
+
+	for _, row := range rows {
+		task, ok := taskMap[row.TaskID]
+		if !ok {
+			continue
+		}
+		hit := SearchHit{
+			Type:      row.DocType,
+			ID:        row.DocID,
+			TaskID:    row.TaskID,
+			TaskKey:   task.Key,
+			ProjectID: row.ProjectID,
+			Title:     task.Title,
+			Score:     row.Score,
+		}
+		switch row.DocType {
+		case SearchDocComment:
+			comment, ok := commentMap[row.DocID]
+			if !ok {
+				continue
+			}
+			hit.Snippet = HighlightSnippet(comment.Content, terms, 120)
+		default:
+			text := task.Description
+			if !containsAnyTerm(text, terms) {
+				text = task.Title
+			}
+			hit.Snippet = HighlightSnippet(text, terms, 120)
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	return result, nil
+}
+
+// containsAnyTerm 文本中是否包含任一搜索词（不区分大小写）
+func containsAnyTerm(text string, terms []string) bool {
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		if strings.Contains(lower, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// HighlightSnippet 截取文本中第一个命中位置附近的摘要（最多 width 个字），命中的词用 <mark> 标记
+// 返回的摘要已做 HTML 转义
+func HighlightSnippet(text string, terms []string, width int) string {
+	original := []rune(strings.Join(strings.Fields(text), " "))
+	lower := make([]rune, len(original))
+	for i, r := range original {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// 标记所有命中的字符
+	marked := make([]bool, len(original))
+	first := -1
+	for _, term := range terms {
+		pattern := []rune(term)
+		if len(pattern) == 0 {
+			continue
+		}
+		for i := 0; i+len(pattern) <= len(lower); i++ {
+			if string(lower[i:i+len(pattern)]) != term {
+				continue
+			}
+			for j := i; j < i+len(pattern); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	// 命中位置放在摘要的前三分之一处
+	start := 0
+	if first > width/3 {
+		start = first - width/3
+	}
+	end := start + width
+	if end > len(original) {
+		end = len(original)
+		if end-width > 0 && end-width < start {
+			start = end - width
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marked[i] != inMark {
+			if marked[i] {
+				b.WriteString("<mark>")
+			} else {
+				b.WriteString("</mark>")
+			}
+			inMark = marked[i]
+		}
+		b.WriteString(html.EscapeString(string(original[i])))
+	}
+	if inMark {
+		b.WriteString("</mark>")
+	}
+	if end < len(original) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"project-manager-backend/models"
+	"time"
+)
+
+// CompletionChange 任务完成状态的变化
+type CompletionChange int
+
+const (
+	CompletionUnchanged CompletionChange = iota // 完成状态未变化
+	CompletionCompleted                         // 任务变为已完成
+	CompletionReopened                          // 任务重新打开
+)
+
+// StageEntry 任务进入阶段时需要同步修改的字段
+type StageEntry struct {
+	Updates    map[string]interface{} // 需要写入任务的字段（status、completed_at）
+	OldStatus  string
+	NewStatus  string
+	Completion CompletionChange
+}
+
+// StatusChanged 进入阶段是否修改了任务状态
+func (e *StageEntry) StatusChanged() bool {
+	return e.OldStatus != e.NewStatus
+}
+
+// IsTaskDone 任务是否处于完成状态：状态属于已完成分类，或位于已完成阶段
+func IsTaskDone(workflow *Workflow, status string, stage *models.Stage) bool {
+	if stage != nil && stage.IsCompleted {
+		return true
+	}
+	return workflow != nil && workflow.IsDone(status)
+}
+
+// ComputeStageEntry 计算任务从 from 阶段进入 to 阶段时的副作用
+// 目标阶段设置了 AutoAssignStatus 时改为该状态；任务状态不在工作流中时（如跨项目移动）改为默认状态。
+// 进入完成状态时记录完成时间，离开完成状态时清除完成时间。workflow 为目标项目的工作流。
+func ComputeStageEntry(workflow *Workflow, task *models.Task, from, to *models.Stage, now time.Time) *StageEntry {
+	entry := &StageEntry{
+		Updates:   make(map[string]interface{}),
+		OldStatus: task.Status,
+		NewStatus: task.Status,
+	}
+
+	if to.AutoAssignStatus != "" && workflow.Status(to.AutoAssignStatus) != nil {
+		entry.NewStatus = to.AutoAssignStatus
+	} else if workflow.Status(task.Status) == nil {
+		entry.NewStatus = workflow.DefaultStatus()
+	}
+	if entry.StatusChanged() {
+		entry.Updates["status"] = entry.NewStatus
+	}
+
+	wasDone := IsTaskDone(workflow, entry.OldStatus, from)
+	isDone := IsTaskDone(workflow, entry.NewStatus, to)
+	switch {
+	case !wasDone && isDone:
+		entry.Completion = CompletionCompleted
+		entry.Updates["completed_at"] = now
+	case wasDone && !isDone:
+		entry.Completion = CompletionReopened
+		entry.Updates["completed_at"] = nil
+	case isDone && task.CompletedAt == nil:
+		// 历史数据没有完成时间时补上
+		entry.Updates["completed_at"] = now
+	}
+	return entry
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"project-manager-backend/utils"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// StageTransitionService 阶段流转规则服务
+type StageTransitionService struct{}
+
+// NewStageTransitionService 创建阶段流转规则服务
+func NewStageTransitionService() *StageTransitionService {
+	return &StageTransitionService{}
+}
+
+// transitionFields 可以设为流转必填的任务字段及其是否已填写的判断
+var transitionFields = map[string]func(task *models.Task) bool{
+	"description":     func(task *models.Task) bool { return strings.TrimSpace(task.Description) != "" },
+	"assignee":        func(task *models.Task) bool { return task.AssigneeID != nil },
+	"start_date":      func(task *models.Task) bool { return task.StartDate != nil },
+	"due_date":        func(task *models.Task) bool { return task.DueDate != nil },
+	"estimated_hours": func(task *models.Task) bool { return task.EstimatedHours != nil },
+	"actual_hours":    func(task *models.Task) bool { return task.ActualHours != nil },
+}
+
+// TransitionRules 项目的阶段流转规则
+type TransitionRules struct {
+	ProjectID  uint
+	Rules      []models.StageTransition
+	stageNames map[uint]string
+	roleChecks map[string]bool // userID:role -> 是否满足，批量移动时避免重复查询
+}
+
+// TransitionViolation 移动不满足流转规则的原因
+type TransitionViolation struct {
+	TaskID        uint     `json:"task_id"`
+	FromStage     string   `json:"from_stage"`
+	ToStage       string   `json:"to_stage"`
+	Denied        bool     `json:"denied,omitempty"`         // 被禁止规则拒绝
+	AllowedFrom   []string `json:"allowed_from,omitempty"`   // 目标阶段只能从这些阶段进入
+	RequiredRole  string   `json:"required_role,omitempty"`  // 缺少的项目角色
+	MissingFields []string `json:"missing_fields,omitempty"` // 未填写的必填字段
+}
+
+func (v *TransitionViolation) Error() string {
+	var reasons []string
+	if v.Denied {
+		reasons = append(reasons, fmt.Sprintf("moving from %q is not allowed", v.FromStage))
+	}
+	if len(v.AllowedFrom) > 0 {
+		reasons = append(reasons, "can only be entered from: "+strings.Join(v.AllowedFrom, ", "))
+	}
+	if v.RequiredRole != "" {
+		reasons = append(reasons, "requires project role "+v.RequiredRole)
+	}
+	if len(v.MissingFields) > 0 {
+		reasons = append(reasons, "missing required fields: "+strings.Join(v.MissingFields, ", "))
+	}
+	return fmt.Sprintf("cannot move task %d to %q: %s", v.TaskID, v.ToStage, strings.Join(reasons, "; "))
+}
+
+// LoadRules 加载项目的流转规则
+func (s *StageTransitionService) LoadRules(db *gorm.DB, projectID uint) (*TransitionRules, error) {
+	rules := &TransitionRules{
+		ProjectID:  projectID,
+		stageNames: make(map[uint]string),
+		roleChecks: make(map[string]bool),
+	}
+	if err := db.Where("project_id = ?", projectID).Order("to_stage_id ASC, id ASC").Find(&rules.Rules).Error; err != nil {
+		return nil, err
+	}
+
+	var stages []models.Stage
+	if err := db.Select("id, name").Where("project_id = ?", projectID).Find(&stages).Error; err != nil {
+		return nil, err
+	}
+	for _, stage := range stages {
+		rules.stageNames[stage.ID] = stage.Name
+	}
+	return rules, nil
+}
+
+// Check 检查用户能否把任务从 fromStageID 移动到 toStageID，满足规则时返回 nil
+// 匹配的规则中：任一禁止规则生效即拒绝；目标阶段存在允许规则时，来源阶段必须被其中之一覆盖；
+// 所有匹配规则的角色和必填字段要求都需要满足
+func (r *TransitionRules) Check(task *models.Task, fromStageID, toStageID, userID uint) *TransitionViolation {
+	violation := &TransitionViolation{
+		TaskID:    task.ID,
+		FromStage: r.stageNames[fromStageID],
+		ToStage:   r.stageNames[toStageID],
+	}
+
+	hasAllowRules := false
+	allowed := false
+	var allowedFrom []string
+	var matched []models.StageTransition
+	for _, rule := range r.Rules {
+		if rule.ToStageID != toStageID {
+			continue
+		}
+		matches := rule.FromStageID == nil || *rule.FromStageID == fromStageID
+		if rule.Effect == models.TransitionDeny {
+			if matches {
+				violation.Denied = true
+			}
+			continue
+		}
+
+		hasAllowRules = true
+		if rule.FromStageID != nil {
+			allowedFrom = append(allowedFrom, r.stageNames[*rule.FromStageID])
+		}
+		if matches {
+			allowed = true
+			matched = append(matched, rule)
+		}
+	}
+	if hasAllowRules && !allowed && !violation.Denied {
+		violation.AllowedFrom = allowedFrom
+	}
+
+	// 只有允许进入时才检查附加要求，避免提示无法满足的条件
+	if !violation.Denied && violation.AllowedFrom == nil {
+		missing := make(map[string]bool)
+		for _, rule := range matched {
+			if rule.RequiredRole != "" && !r.hasRole(userID, rule.RequiredRole) {
+				violation.RequiredRole = string(rule.RequiredRole)
+			}
+			for _, field := range rule.RequiredFields {
+				if filled, ok := transitionFields[field]; ok && !filled(task) {
+					missing[field] = true
+				}
+			}
+		}
+		for field := range missing {
+			violation.MissingFields = append(violation.MissingFields, field)
+		}
+		sort.Strings(violation.MissingFields)
+	}
+
+	if !violation.Denied && violation.AllowedFrom == nil && violation.RequiredRole == "" && len(violation.MissingFields) == 0 {
+		return nil
+	}
+	return violation
+}
+
+// PreloadRoles 预先查询用户是否满足各规则要求的角色，之后 Check 不再访问数据库，可以在事务中调用
+func (r *TransitionRules) PreloadRoles(userID uint) {
+	for _, rule := range r.Rules {
+		if rule.RequiredRole != "" {
+			r.hasRole(userID, rule.RequiredRole)
+		}
+	}
+}
+
+// hasRole 用户在项目中的角色是否满足要求
+func (r *TransitionRules) hasRole(userID uint, role models.ProjectMemberRole) bool {
+	key := fmt.Sprintf("%d:%s", userID, role)
+	if ok, cached := r.roleChecks[key]; cached {
+		return ok
+	}
+	ok := utils.CheckProjectPermission(userID, r.ProjectID, role)
+	r.roleChecks[key] = ok
+	return ok
+}
+
+// ReplaceRules 用新的规则替换项目的全部流转规则
+func (s *StageTransitionService) ReplaceRules(db *gorm.DB, projectID uint, rules []models.StageTransition) error {
+	var stageIDs []uint
+	if err := db.Model(&models.Stage{}).Where("project_id = ?", projectID).Pluck("id", &stageIDs).Error; err != nil {
+		return err
+	}
+	stages := make(map[uint]bool, len(stageIDs))
+	for _, id := range stageIDs {
+		stages[id] = true
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !stages[rule.ToStageID] {
+			return fmt.Errorf("rule %d: to_stage_id %d is not a stage of this project", i+1, rule.ToStageID)
+		}
+		if rule.FromStageID != nil {
+			if !stages[*rule.FromStageID] {
+				return fmt.Errorf("rule %d: from_stage_id %d is not a stage of this project", i+1, *rule.FromStageID)
+			}
+			if *rule.FromStageID == rule.ToStageID {
+				return fmt.Errorf("rule %d: from_stage_id and to_stage_id must differ", i+1)
+			}
+		}
+
+		switch rule.Effect {
+		case "":
+			rule.Effect = models.TransitionAllow
+		case models.TransitionAllow, models.TransitionDeny:
+		default:
+			return fmt.Errorf("rule %d: invalid effect %q, must be allow or deny", i+1, rule.Effect)
+		}
+
+		switch rule.RequiredRole {
+		case "", models.ProjectMemberRoleOwner, models.ProjectMemberRoleManager, models.ProjectMemberRoleCollaborator:
+		default:
+			return fmt.Errorf("rule %d: invalid required_role %q", i+1, rule.RequiredRole)
+		}
+
+		for _, field := range rule.RequiredFields {
+			if _, ok := transitionFields[field]; !ok {
+				return fmt.Errorf("rule %d: unsupported required field %q, allowed: %s", i+1, field, strings.Join(TransitionFieldNames(), ", "))
+			}
+		}
+		if rule.Effect == models.TransitionDeny && (rule.RequiredRole != "" || len(rule.RequiredFields) > 0) {
+			return fmt.Errorf("rule %d: deny rules cannot have requirements", i+1)
+		}
+
+		rule.ID = 0
+		rule.ProjectID = projectID
+	}
+
+	if err := db.Where("project_id = ?", projectID).Delete(&models.StageTransition{}).Error; err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := db.Create(&rules[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveStageRules 删除与阶段相关的流转规则
+func (s *StageTransitionService) RemoveStageRules(db *gorm.DB, stageID uint) error {
+	return db.Where("to_stage_id = ? OR from_stage_id = ?", stageID, stageID).Delete(&models.StageTransition{}).Error
+}
+
+// TransitionFieldNames 可以设为必填的字段名
+func TransitionFieldNames() []string {
+	names := make([]string, 0, len(transitionFields))
+	for name := range transitionFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -3,11 +3,14 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"project-manager-backend/database"
 	"project-manager-backend/models"
+	"project-manager-backend/utils"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 )
 
 // TaskActivityService 任务活动记录服务
@@ -29,6 +32,12 @@ const (
 	ActivityTypeReopened     = "reopened"
 	ActivityTypeDeleted      = "deleted"
 	ActivityTypeCommentAdded = "comment_added"
+	ActivityTypeCloned       = "cloned"
+	ActivityTypeTransferred  = "transferred"
+	ActivityTypeLabeled      = "labeled"
+	ActivityTypeAutomation   = "automation"
+	ActivityTypeArchived     = "archived"
+	ActivityTypeUnarchived   = "unarchived"
 )
 
 // LogTaskActivity 记录任务活动
@@ -38,9 +47,12 @@ func (s *TaskActivityService) LogTaskActivity(
 	metadata map[string]interface{},
 	c *gin.Context,
 ) error {
-	// 获取客户端信息
-	ipAddress := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
+	// 获取客户端信息，后台任务（如自动化规则）没有请求上下文
+	var ipAddress, userAgent string
+	if c != nil {
+		ipAddress = c.ClientIP()
+		userAgent = c.GetHeader("User-Agent")
+	}
 
 	// 序列化元数据
 	var metadataJSON string
@@ -119,6 +131,26 @@ func translatePriorityToChinese(priority string) string {
 	return priority
 }
 
+// workflowStatusName 优先使用项目工作流中定义的状态名称
+func workflowStatusName(workflow *Workflow, status string) string {
+	if workflow != nil {
+		if definition := workflow.Status(status); definition != nil {
+			return definition.Name
+		}
+	}
+	return translateStatusToChinese(status)
+}
+
+// workflowPriorityName 优先使用项目工作流中定义的优先级名称
+func workflowPriorityName(workflow *Workflow, priority string) string {
+	if workflow != nil {
+		if definition := workflow.Priority(priority); definition != nil {
+			return definition.Name
+		}
+	}
+	return translatePriorityToChinese(priority)
+}
+
 // LogTaskUpdated 记录任务更新
 func (s *TaskActivityService) LogTaskUpdated(
 	taskID, userID, projectID uint,
@@ -132,17 +164,27 @@ func (s *TaskActivityService) LogTaskUpdated(
 	case "description":
 		description = "更新了任务描述"
 	case "priority":
-		oldValueCN := translatePriorityToChinese(oldValue)
-		newValueCN := translatePriorityToChinese(newValue)
+		workflow, _ := NewWorkflowService().GetWorkflow(database.DB, projectID)
+		oldValueCN := workflowPriorityName(workflow, oldValue)
+		newValueCN := workflowPriorityName(workflow, newValue)
 		description = fmt.Sprintf("将优先级从 \"%s\" 修改为 \"%s\"", oldValueCN, newValueCN)
 	case "status":
-		oldValueCN := translateStatusToChinese(oldValue)
-		newValueCN := translateStatusToChinese(newValue)
+		workflow, _ := NewWorkflowService().GetWorkflow(database.DB, projectID)
+		oldValueCN := workflowStatusName(workflow, oldValue)
+		newValueCN := workflowStatusName(workflow, newValue)
 		description = fmt.Sprintf("将状态从 \"%s\" 修改为 \"%s\"", oldValueCN, newValueCN)
 	case "due_date":
 		description = fmt.Sprintf("将截止日期从 \"%s\" 修改为 \"%s\"", oldValue, newValue)
+	case "due_all_day":
+		if newValue == "true" {
+			description = "将截止时间改为全天"
+		} else {
+			description = "将截止时间改为具体时刻"
+		}
 	case "estimated_hours":
 		description = fmt.Sprintf("将预估工时从 \"%s\" 修改为 \"%s\"", oldValue, newValue)
+	case "actual_hours":
+		description = fmt.Sprintf("将实际工时从 \"%s\" 修改为 \"%s\"", oldValue, newValue)
 	default:
 		description = fmt.Sprintf("更新了 %s", fieldName)
 	}
@@ -161,6 +203,56 @@ func (s *TaskActivityService) LogTaskUpdated(
 	)
 }
 
+// LogTaskLabeled 记录任务添加或移除标签
+func (s *TaskActivityService) LogTaskLabeled(
+	taskID, userID, projectID uint,
+	labelName string, added bool,
+	c *gin.Context,
+) error {
+	description := fmt.Sprintf("添加了标签 \"%s\"", labelName)
+	oldValue, newValue := "", labelName
+	if !added {
+		description = fmt.Sprintf("移除了标签 \"%s\"", labelName)
+		oldValue, newValue = labelName, ""
+	}
+
+	return s.LogTaskActivity(
+		taskID,
+		userID,
+		projectID,
+		ActivityTypeLabeled,
+		description,
+		"labels",
+		oldValue,
+		newValue,
+		nil,
+		c,
+	)
+}
+
+// LogAutomation 记录自动化规则对任务的修改，记在规则创建者名下并在元数据中注明规则
+func (s *TaskActivityService) LogAutomation(
+	taskID, projectID uint,
+	rule *models.AutomationRule,
+	description, fieldName, oldValue, newValue string,
+) error {
+	return s.LogTaskActivity(
+		taskID,
+		rule.CreatedBy,
+		projectID,
+		ActivityTypeAutomation,
+		fmt.Sprintf("自动化规则 \"%s\" %s", rule.Name, description),
+		fieldName,
+		oldValue,
+		newValue,
+		map[string]interface{}{
+			"automation_rule_id":   rule.ID,
+			"automation_rule_name": rule.Name,
+		},
+		nil,
+	)
+}
+
 // LogTaskMoved 记录任务移动
 func (s *TaskActivityService) LogTaskMoved(
 	taskID, userID, projectID uint,
@@ -239,6 +331,61 @@ func (s *TaskActivityService) LogTaskUnassigned(
 	)
 }
 
+// LogStageEntry 记录任务进入阶段引起的状态变化、完成和重新打开活动，记录失败只写日志
+func (s *TaskActivityService) LogStageEntry(task *models.Task, userID uint, entry *StageEntry, c *gin.Context) {
+	if entry == nil {
+		return
+	}
+
+	if entry.StatusChanged() {
+		if err := s.LogTaskUpdated(
+			task.ID, userID, task.ProjectID,
+			"status", entry.OldStatus, entry.NewStatus,
+			c,
+		); err != nil {
+			log.Printf("Failed to log task status activity: %v", err)
+		}
+	}
+
+	var err error
+	switch entry.Completion {
+	case CompletionCompleted:
+		err = s.LogTaskCompleted(task.ID, userID, task.ProjectID, c)
+	case CompletionReopened:
+		err = s.LogTaskReopened(task.ID, userID, task.ProjectID, c)
+	}
+	if err != nil {
+		log.Printf("Failed to log task completion activity: %v", err)
+	}
+}
+
+// LogTaskArchived 记录任务归档或恢复
+func (s *TaskActivityService) LogTaskArchived(
+	taskID, userID, projectID uint,
+	archived bool,
+	c *gin.Context,
+) error {
+	actionType, description := ActivityTypeArchived, "归档了任务"
+	oldValue, newValue := "", "archived"
+	if !archived {
+		actionType, description = ActivityTypeUnarchived, "恢复了已归档的任务"
+		oldValue, newValue = "archived", ""
+	}
+
+	return s.LogTaskActivity(
+		taskID,
+		userID,
+		projectID,
+		actionType,
+		description,
+		"archived",
+		oldValue,
+		newValue,
+		nil,
+		c,
+	)
+}
+
 // LogTaskCompleted 记录任务完成
 func (s *TaskActivityService) LogTaskCompleted(
 	taskID, userID, projectID uint,
@@ -303,81 +450,183 @@ func (s *TaskActivityService) LogTaskDeleted(
 	)
 }
 
-// GetTaskActivities 获取任务活动记录
-func (s *TaskActivityService) GetTaskActivities(
-	taskID uint,
-	limit, offset int,
-) ([]models.TaskActivity, error) {
-	var activities []models.TaskActivity
+// LogTaskCloned 记录任务复制（记录在新任务所在项目中）
+func (s *TaskActivityService) LogTaskCloned(
+	task *models.Task, userID uint,
+	sourceTaskID, sourceProjectID uint,
+	c *gin.Context,
+) error {
+	description := fmt.Sprintf("从任务 #%d 复制创建了任务 \"%s\"", sourceTaskID, task.Title)
+	metadata := map[string]interface{}{
+		"source_task_id":    sourceTaskID,
+		"source_project_id": sourceProjectID,
+	}
 
-	query := database.DB.Where("task_id = ?", taskID).
-		Preload("User").
-		Order("created_at DESC")
+	return s.LogTaskActivity(
+		task.ID,
+		userID,
+		task.ProjectID,
+		ActivityTypeCloned,
+		description,
+		"",
+		"",
+		"",
+		metadata,
+		c,
+	)
+}
 
-	if limit > 0 {
-		query = query.Limit(limit)
-	}
-	if offset > 0 {
-		query = query.Offset(offset)
+// LogTaskTransferred 记录任务跨项目移动
+// 分别在原项目和目标项目中各记录一条，保证两个项目的活动流都能看到这次移动
+func (s *TaskActivityService) LogTaskTransferred(
+	taskID, userID uint,
+	oldProjectID, newProjectID uint,
+	oldProjectName, newProjectName string,
+	oldStageName, newStageName string,
+	c *gin.Context,
+) error {
+	metadata := map[string]interface{}{
+		"old_project_id":   oldProjectID,
+		"new_project_id":   newProjectID,
+		"old_project_name": oldProjectName,
+		"new_project_name": newProjectName,
+		"old_stage_name":   oldStageName,
+		"new_stage_name":   newStageName,
 	}
 
-	if err := query.Find(&activities).Error; err != nil {
-		return nil, fmt.Errorf("failed to get task activities: %v", err)
+	outDescription := fmt.Sprintf("将任务移动到项目 \"%s\" 的 \"%s\"", newProjectName, newStageName)
+	if err := s.LogTaskActivity(
+		taskID,
+		userID,
+		oldProjectID,
+		ActivityTypeTransferred,
+		outDescription,
+		"project_id",
+		fmt.Sprintf("%d", oldProjectID),
+		fmt.Sprintf("%d", newProjectID),
+		metadata,
+		c,
+	); err != nil {
+		return err
 	}
 
-	return activities, nil
+	inDescription := fmt.Sprintf("将任务从项目 \"%s\" 移入 \"%s\"", oldProjectName, newStageName)
+	return s.LogTaskActivity(
+		taskID,
+		userID,
+		newProjectID,
+		ActivityTypeTransferred,
+		inDescription,
+		"project_id",
+		fmt.Sprintf("%d", oldProjectID),
+		fmt.Sprintf("%d", newProjectID),
+		metadata,
+		c,
+	)
+}
+
+// ActivitySort 活动记录列表的排序（按时间倒序）
+const ActivitySort = "created_at:desc"
+
+// activityKeyset 活动记录分页排序列
+var activityKeyset = utils.Keyset{
+	Table: "task_activities",
+	Columns: []utils.SortColumn{
+		{Expr: "task_activities.created_at", Desc: true},
+		{Expr: "task_activities.id", Desc: true},
+	},
+}
+
+// GetTaskActivities 获取任务活动记录
+// 传入游标时按游标分页，否则使用 offset（兼容旧客户端）
+func (s *TaskActivityService) GetTaskActivities(
+	taskID uint,
+	page *utils.PageParams,
+	offset int,
+) ([]models.TaskActivity, utils.PageInfo, error) {
+	query := database.DB.Where("task_id = ?", taskID).
+		Preload("User")
+
+	activities, info, err := s.listActivities(query, page, offset)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to get task activities: %w", err)
+	}
+	return activities, info, nil
 }
 
 // GetProjectActivities 获取项目活动记录
 func (s *TaskActivityService) GetProjectActivities(
 	projectID uint,
-	limit, offset int,
-) ([]models.TaskActivity, error) {
-	var activities []models.TaskActivity
-
+	page *utils.PageParams,
+	offset int,
+) ([]models.TaskActivity, utils.PageInfo, error) {
 	query := database.DB.Where("project_id = ?", projectID).
 		Preload("User").
-		Preload("Task").
-		Order("created_at DESC")
-
-	if limit > 0 {
-		query = query.Limit(limit)
-	}
-	if offset > 0 {
-		query = query.Offset(offset)
-	}
+		Preload("Task")
 
-	if err := query.Find(&activities).Error; err != nil {
-		return nil, fmt.Errorf("failed to get project activities: %v", err)
+	activities, info, err := s.listActivities(query, page, offset)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to get project activities: %w", err)
 	}
-
-	return activities, nil
+	return activities, info, nil
 }
 
 // GetUserActivities 获取用户活动记录
 func (s *TaskActivityService) GetUserActivities(
 	userID uint,
-	limit, offset int,
-) ([]models.TaskActivity, error) {
-	var activities []models.TaskActivity
-
+	page *utils.PageParams,
+	offset int,
+) ([]models.TaskActivity, utils.PageInfo, error) {
 	query := database.DB.Where("user_id = ?", userID).
 		Preload("Task").
-		Preload("Project").
-		Order("created_at DESC")
+		Preload("Project")
+
+	activities, info, err := s.listActivities(query, page, offset)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to get user activities: %w", err)
+	}
+	return activities, info, nil
+}
+
+// listActivities 按时间倒序分页查询活动记录
+func (s *TaskActivityService) listActivities(
+	query *gorm.DB,
+	page *utils.PageParams,
+	offset int,
+) ([]models.TaskActivity, utils.PageInfo, error) {
+	var total *int
+	if page.WithTotal {
+		var count int
+		if err := query.Model(&models.TaskActivity{}).Count(&count).Error; err != nil {
+			return nil, utils.PageInfo{}, err
+		}
+		total = &count
+	}
 
-	if limit > 0 {
-		query = query.Limit(limit)
+	listQuery, err := page.Apply(query, activityKeyset)
+	if err != nil {
+		return nil, utils.PageInfo{}, err
 	}
-	if offset > 0 {
-		query = query.Offset(offset)
+	if offset > 0 && !page.HasCursor() {
+		listQuery = listQuery.Offset(offset)
 	}
 
-	if err := query.Find(&activities).Error; err != nil {
-		return nil, fmt.Errorf("failed to get user activities: %v", err)
+	var activities []models.TaskActivity
+	if err := listQuery.Find(&activities).Error; err != nil {
+		return nil, utils.PageInfo{}, err
 	}
 
-	return activities, nil
+	n, hasMore := page.Trim(len(activities))
+	activities = activities[:n]
+	var lastID interface{}
+	if n > 0 {
+		lastID = activities[n-1].ID
+	}
+	info, err := page.Info(database.DB, activityKeyset, hasMore, lastID, total)
+	if err != nil {
+		return nil, info, err
+	}
+	return activities, info, nil
 }
 
 // GetActivityStats 获取活动统计
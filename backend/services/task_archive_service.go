@@ -0,0 +1,100 @@
+package services
+
+import (
+	"project-manager-backend/models"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TaskNotArchivedCondition 未归档任务的查询条件
+// 归档的任务保留所在阶段，但不出现在看板、列表、搜索、统计和提醒中，也不占用阶段的在制品上限
+const TaskNotArchivedCondition = "tasks.archived_at IS NULL"
+
+// TaskArchiveService 任务归档和彻底删除服务
+type TaskArchiveService struct{}
+
+// NewTaskArchiveService 创建任务归档服务
+func NewTaskArchiveService() *TaskArchiveService {
+	return &TaskArchiveService{}
+}
+
+// ArchiveTasks 归档任务，已归档的任务保持原归档时间
+func (s *TaskArchiveService) ArchiveTasks(db *gorm.DB, taskIDs []uint, now time.Time) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	return db.Model(&models.Task{}).
+		Where("id IN (?) AND "+TaskNotArchivedCondition, taskIDs).
+		UpdateColumn("archived_at", now).Error
+}
+
+// TaskRelationCounts 任务关联数据的数量
+type TaskRelationCounts struct {
+	Tasks        int `json:"tasks"`
+	Comments     int `json:"comments"`
+	Activities   int `json:"activities"`
+	Labels       int `json:"labels"`
+	Dependencies int `json:"dependencies"`
+}
+
+// taskRelations 随任务一起彻底删除的关联表，counter 为空的不计入统计
+var taskRelations = []struct {
+	model     interface{}
+	condition string
+	counter   func(*TaskRelationCounts) *int
+}{
+	{&models.Comment{}, "task_id IN (?)", func(c *TaskRelationCounts) *int { return &c.Comments }},
+	{&models.TaskActivity{}, "task_id IN (?)", func(c *TaskRelationCounts) *int { return &c.Activities }},
+	{&models.TaskLabel{}, "task_id IN (?)", func(c *TaskRelationCounts) *int { return &c.Labels }},
+	{&models.TaskDependency{}, "task_id IN (?) OR depends_on_task_id IN (?)", func(c *TaskRelationCounts) *int { return &c.Dependencies }},
+	{&models.TaskKeyAlias{}, "task_id IN (?)", nil},
+	{&models.TaskPermission{}, "task_id IN (?)", nil},
+	{&models.TaskReminderLog{}, "task_id IN (?)", nil},
+	{&models.Notification{}, "task_id IN (?)", nil},
+}
+
+// CountTaskRelations 统计彻底删除任务时会一并删除的数据
+func (s *TaskArchiveService) CountTaskRelations(db *gorm.DB, taskIDs []uint) (*TaskRelationCounts, error) {
+	counts := &TaskRelationCounts{Tasks: len(taskIDs)}
+	if len(taskIDs) == 0 {
+		return counts, nil
+	}
+	for _, relation := range taskRelations {
+		if relation.counter == nil {
+			continue
+		}
+		if err := db.Model(relation.model).Where(relation.condition, relationArgs(relation.condition, taskIDs)...).Count(relation.counter(counts)).Error; err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// DeleteTasksCascade 彻底删除任务及其评论、活动记录、标签、依赖、编号别名、权限、提醒记录、通知和搜索索引
+func (s *TaskArchiveService) DeleteTasksCascade(db *gorm.DB, taskIDs []uint) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+	if searchIndexReady {
+		if err := db.Exec("DELETE FROM search_index WHERE task_id IN (?)", taskIDs).Error; err != nil {
+			return err
+		}
+	}
+	for _, relation := range taskRelations {
+		if err := db.Where(relation.condition, relationArgs(relation.condition, taskIDs)...).Delete(relation.model).Error; err != nil {
+			return err
+		}
+	}
+	return db.Where("id IN (?)", taskIDs).Delete(&models.Task{}).Error
+}
+
+// relationArgs 条件中的每个占位符都绑定任务ID列表
+func relationArgs(condition string, taskIDs []uint) []interface{} {
+	args := make([]interface{}, strings.Count(condition, "?"))
+	for i := range args {
+		args[i] = taskIDs
+	}
+	return args
+}
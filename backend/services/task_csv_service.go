@@ -0,0 +1,654 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"project-manager-backend/models"
+	"project-manager-backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TaskCSVMaxRows 一次导入的最大行数（不含表头）
+const TaskCSVMaxRows = 5000
+
+// taskCSVLabelSeparator 标签列中多个标签的分隔符
+const taskCSVLabelSeparator = ";"
+
+// TaskCSVColumns 导出支持的列
+var TaskCSVColumns = []string{
+	"key", "title", "description", "stage", "status", "priority",
+	"assignee", "assignee_username", "labels",
+	"start_date", "due_date", "estimated_hours", "actual_hours",
+	"created_by", "created_at", "updated_at", "completed_at",
+}
+
+// DefaultTaskCSVColumns 未指定列时导出的列
+var DefaultTaskCSVColumns = []string{
+	"key", "title", "stage", "status", "priority", "assignee", "labels",
+	"start_date", "due_date", "estimated_hours", "actual_hours",
+}
+
+// TaskCSVImportFields 导入时可以映射的任务字段，assignee 可以是邮箱或用户名
+var TaskCSVImportFields = []string{
+	"title", "description", "stage", "status", "priority", "assignee", "labels",
+	"start_date", "due_date", "estimated_hours", "actual_hours",
+}
+
+// TaskCSVService 任务 CSV 导入和导出服务
+type TaskCSVService struct{}
+
+// NewTaskCSVService 创建任务 CSV 导入和导出服务
+func NewTaskCSVService() *TaskCSVService {
+	return &TaskCSVService{}
+}
+
+// ParseTaskCSVColumns 解析逗号分隔的导出列，为空时使用默认列
+func ParseTaskCSVColumns(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultTaskCSVColumns, nil
+	}
+	var columns []string
+	seen := make(map[string]bool)
+	for _, column := range strings.Split(spec, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if column == "" || seen[column] {
+			continue
+		}
+		if !containsString(TaskCSVColumns, column) {
+			return nil, fmt.Errorf("unknown column %q (supported: %s)", column, strings.Join(TaskCSVColumns, ", "))
+		}
+		seen[column] = true
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return DefaultTaskCSVColumns, nil
+	}
+	return columns, nil
+}
+
+// WriteTasksCSV 按 columns 把任务写成 CSV，tasks 需要预加载 Stage 和 Assignee
+// 截止时间与接口一致：全天任务只输出日期，否则输出 RFC 3339（UTC）
+func (s *TaskCSVService) WriteTasksCSV(db *gorm.DB, w io.Writer, tasks []models.Task, columns []string) error {
+	taskIDs := make([]uint, 0, len(tasks))
+	creatorIDs := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+		creatorIDs = append(creatorIDs, task.CreatedBy)
+	}
+
+	labels := make(map[uint][]string)
+	if containsString(columns, "labels") && len(taskIDs) > 0 {
+		var rows []struct {
+			TaskID uint
+			Name   string
+		}
+		if err := db.Table("task_labels").Select("task_labels.task_id, labels.name").
+			Joins("JOIN labels ON labels.id = task_labels.label_id").
+			Where("task_labels.task_id IN (?)", taskIDs).
+			Order("labels.name ASC").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			labels[row.TaskID] = append(labels[row.TaskID], row.Name)
+		}
+	}
+	creators := make(map[uint]string)
+	if containsString(columns, "created_by") && len(creatorIDs) > 0 {
+		var users []models.User
+		if err := db.Select("id, email").Where("id IN (?)", creatorIDs).Find(&users).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			creators[user.ID] = user.Email
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for _, task := range tasks {
+		for i, column := range columns {
+			record[i] = escapeCSVFormula(taskCSVValue(&task, column, labels[task.ID], creators))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// taskCSVValue 任务在某一列的值
+func taskCSVValue(task *models.Task, column string, labels []string, creators map[uint]string) string {
+	switch column {
+	case "key":
+		return task.Key
+	case "title":
+		return task.Title
+	case "description":
+		return task.Description
+	case "stage":
+		if task.Stage != nil {
+			return task.Stage.Name
+		}
+	case "status":
+		return task.Status
+	case "priority":
+		return task.Priority
+	case "assignee":
+		if task.Assignee != nil {
+			return task.Assignee.Email
+		}
+	case "assignee_username":
+		if task.Assignee != nil {
+			return task.Assignee.Username
+		}
+	case "labels":
+		return strings.Join(labels, taskCSVLabelSeparator+" ")
+	case "start_date":
+		if task.StartDate != nil {
+			return task.StartDate.UTC().Format(utils.DateLayout)
+		}
+	case "due_date":
+		if task.DueDate != nil {
+			return utils.FormatDueDate(*task.DueDate, task.DueAllDay)
+		}
+	case "estimated_hours":
+		return formatCSVHours(task.EstimatedHours)
+	case "actual_hours":
+		return formatCSVHours(task.ActualHours)
+	case "created_by":
+		return creators[task.CreatedBy]
+	case "created_at":
+		return task.CreatedAt.UTC().Format(time.RFC3339)
+	case "updated_at":
+		return task.UpdatedAt.UTC().Format(time.RFC3339)
+	case "completed_at":
+		if task.CompletedAt != nil {
+			return task.CompletedAt.UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+func formatCSVHours(hours *float64) string {
+	if hours == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*hours, 'f', -1, 64)
+}
+
+// TaskCSVImportOptions 导入选项
+type TaskCSVImportOptions struct {
+	Mapping      map[string]string // 任务字段到 CSV 表头的映射，为空时按表头名称自动匹配
+	CreateStages bool              // 找不到阶段时按名称创建
+	Location     *time.Location    // 解析不带时区偏移的截止时间
+}
+
+// TaskCSVRow 导入预览中的一行
+type TaskCSVRow struct {
+	Row        int      `json:"row"` // CSV 中的行号，表头为第1行
+	Title      string   `json:"title"`
+	Stage      string   `json:"stage"`
+	NewStage   bool     `json:"new_stage,omitempty"` // 阶段不存在，导入时创建
+	Status     string   `json:"status"`
+	Priority   string   `json:"priority"`
+	AssigneeID *uint    `json:"assignee_id,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+	Errors     []string `json:"errors,omitempty"`
+	TaskID     uint     `json:"task_id,omitempty"` // 导入后的任务ID
+
+	task     models.Task
+	stage    *models.Stage
+	labelIDs []uint
+}
+
+// TaskCSVImportReport 导入结果；试运行时只包含预览和错误
+type TaskCSVImportReport struct {
+	DryRun    bool              `json:"dry_run"`
+	Mapping   map[string]string `json:"mapping"` // 实际使用的字段映射
+	Rows      int               `json:"rows"`
+	Valid     int               `json:"valid"`
+	Invalid   int               `json:"invalid"`
+	Created   int               `json:"created"`
+	NewStages []string          `json:"new_stages"` // 需要创建（导入后为已创建）的阶段
+	Results   []*TaskCSVRow     `json:"results"`
+
+	project  *models.Project
+	workflow *Workflow
+	stages   map[string]*models.Stage // 按名称（小写）索引，包含待创建的阶段
+}
+
+// ReadTaskCSV 读取 CSV 内容，返回表头和数据行（去掉 Excel 写入的 UTF-8 BOM 和导出时防止公式执行的单引号）
+func ReadTaskCSV(r io.Reader) ([]string, [][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("CSV is empty")
+	}
+	header := records[0]
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	rows := records[1:]
+	if len(rows) > TaskCSVMaxRows {
+		return nil, nil, fmt.Errorf("CSV has %d rows, at most %d rows can be imported at once", len(rows), TaskCSVMaxRows)
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = unescapeCSVFormula(row[i])
+		}
+	}
+	return header, rows, nil
+}
+
+// csvFormulaPrefixes 电子表格会把以这些字符开头的单元格当作公式执行
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// escapeCSVFormula 导出时在可能被当作公式的单元格前加单引号，防止打开 CSV 时执行任务内容中的公式
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVFormula 去掉导出时为防止公式执行而加的单引号
+func unescapeCSVFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// ResolveTaskCSVMapping 确定任务字段对应的列序号
+// 未指定映射时按表头名称匹配字段（忽略大小写，空格视为下划线），title 列必须存在
+func ResolveTaskCSVMapping(header []string, mapping map[string]string) (map[string]int, map[string]string, error) {
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		key := normalizeCSVHeader(name)
+		if _, ok := index[key]; !ok {
+			index[key] = i
+		}
+	}
+
+	columns := make(map[string]int)
+	used := make(map[string]string)
+	if len(mapping) == 0 {
+		for _, field := range TaskCSVImportFields {
+			if i, ok := index[field]; ok {
+				columns[field] = i
+				used[field] = header[i]
+			}
+		}
+	} else {
+		for field, name := range mapping {
+			field = strings.ToLower(strings.TrimSpace(field))
+			if !containsString(TaskCSVImportFields, field) {
+				return nil, nil, fmt.Errorf("unknown field %q in mapping (supported: %s)", field, strings.Join(TaskCSVImportFields, ", "))
+			}
+			i, ok := index[normalizeCSVHeader(name)]
+			if !ok {
+				return nil, nil, fmt.Errorf("column %q mapped to %q not found in CSV header", name, field)
+			}
+			columns[field] = i
+			used[field] = header[i]
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, nil, fmt.Errorf("no column mapped to title")
+	}
+	return columns, used, nil
+}
+
+func normalizeCSVHeader(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+// PlanImport 校验每一行并生成导入预览，不写入数据
+// 阶段、负责人、标签、状态和优先级都在这里解析，出错的行记录行级错误
+func (s *TaskCSVService) PlanImport(db *gorm.DB, project *models.Project, header []string, rows [][]string, opts TaskCSVImportOptions) (*TaskCSVImportReport, error) {
+	columns, used, err := ResolveTaskCSVMapping(header, opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	workflow, err := NewWorkflowService().GetWorkflow(db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	var stages []models.Stage
+	if err := db.Where("project_id = ?", project.ID).Order("position ASC, id ASC").Find(&stages).Error; err != nil {
+		return nil, err
+	}
+	report := &TaskCSVImportReport{
+		Mapping:   used,
+		Rows:      len(rows),
+		NewStages: []string{},
+		Results:   make([]*TaskCSVRow, 0, len(rows)),
+		project:   project,
+		workflow:  workflow,
+		stages:    make(map[string]*models.Stage, len(stages)),
+	}
+	for i := range stages {
+		key := strings.ToLower(stages[i].Name)
+		if _, ok := report.stages[key]; !ok {
+			report.stages[key] = &stages[i]
+		}
+	}
+	var defaultStage *models.Stage
+	if len(stages) > 0 {
+		defaultStage = &stages[0]
+	}
+
+	labels, err := NewLabelService().ProjectLabels(db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	labelIDs := make(map[string]uint, len(labels))
+	for _, label := range labels {
+		labelIDs[strings.ToLower(label.Name)] = label.ID
+	}
+	users, err := s.projectUsers(db, project)
+	if err != nil {
+		return nil, err
+	}
+
+	for n, record := range rows {
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if strings.Join(record, "") == "" {
+			report.Rows--
+			continue
+		}
+
+		row := &TaskCSVRow{Row: n + 2, Title: value("title")}
+		fail := func(format string, args ...interface{}) {
+			row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+		}
+		if row.Title == "" {
+			fail("title is required")
+		} else if len([]rune(row.Title)) > 255 {
+			fail("title is longer than 255 characters")
+		}
+
+		// 阶段：按名称查找（忽略大小写），为空时使用第一个阶段
+		row.Stage = value("stage")
+		if row.Stage == "" {
+			if defaultStage == nil {
+				fail("project has no stages")
+			} else {
+				row.stage = defaultStage
+				row.Stage = defaultStage.Name
+			}
+		} else if stage, ok := report.stages[strings.ToLower(row.Stage)]; ok {
+			row.stage = stage
+			row.NewStage = stage.ID == 0
+		} else if opts.CreateStages {
+			row.stage = &models.Stage{ProjectID: project.ID, Name: row.Stage}
+			row.NewStage = true
+			report.stages[strings.ToLower(row.Stage)] = row.stage
+			report.NewStages = append(report.NewStages, row.Stage)
+		} else {
+			fail("stage %q not found", row.Stage)
+		}
+		if row.stage != nil && row.stage.ID != 0 && !row.stage.AllowTaskCreation {
+			fail("task creation is not allowed in stage %q", row.stage.Name)
+		}
+
+		// 状态和优先级接受取值或显示名称
+		row.Status = matchWorkflowStatus(workflow, value("status"))
+		if value("status") != "" && row.Status == "" {
+			fail("unknown status %q", value("status"))
+		}
+		if row.Status == "" {
+			row.Status = workflow.DefaultStatus()
+		}
+		row.Priority = matchWorkflowPriority(workflow, value("priority"))
+		if value("priority") != "" && row.Priority == "" {
+			fail("unknown priority %q", value("priority"))
+		}
+		if row.Priority == "" {
+			row.Priority = workflow.DefaultPriority()
+		}
+
+		if assignee := value("assignee"); assignee != "" {
+			if id, ok := users[strings.ToLower(assignee)]; ok {
+				row.AssigneeID = &id
+			} else {
+				fail("assignee %q is not a member of this project", assignee)
+			}
+		}
+
+		for _, name := range strings.Split(value("labels"), taskCSVLabelSeparator) {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			id, ok := labelIDs[strings.ToLower(name)]
+			if !ok {
+				fail("label %q not found", name)
+				continue
+			}
+			row.Labels = append(row.Labels, name)
+			row.labelIDs = append(row.labelIDs, id)
+		}
+
+		var startDate, dueDate *time.Time
+		dueAllDay := false
+		if raw := value("start_date"); raw != "" {
+			if date, err := time.Parse(utils.DateLayout, raw); err != nil {
+				fail("invalid start date %q, use YYYY-MM-DD", raw)
+			} else {
+				startDate = &date
+			}
+		}
+		if raw := value("due_date"); raw != "" {
+			if due, allDay, err := utils.ParseDueDate(raw, nil, opts.Location); err != nil {
+				fail("invalid due date %q", raw)
+			} else {
+				dueDate, dueAllDay = &due, allDay
+			}
+		}
+		if startDate != nil && dueDate != nil && startDate.After(utils.DueDay(*dueDate, dueAllDay, opts.Location)) {
+			fail("start date must not be later than due date")
+		}
+		estimated, err := parseCSVHours(value("estimated_hours"))
+		if err != nil {
+			fail("invalid estimated hours %q", value("estimated_hours"))
+		}
+		actual, err := parseCSVHours(value("actual_hours"))
+		if err != nil {
+			fail("invalid actual hours %q", value("actual_hours"))
+		}
+
+		row.task = models.Task{
+			ProjectID:      project.ID,
+			Title:          row.Title,
+			Description:    value("description"),
+			Priority:       row.Priority,
+			AssigneeID:     row.AssigneeID,
+			StartDate:      startDate,
+			DueDate:        dueDate,
+			DueAllDay:      dueAllDay,
+			EstimatedHours: estimated,
+			ActualHours:    actual,
+		}
+
+		if len(row.Errors) > 0 {
+			report.Invalid++
+		} else {
+			report.Valid++
+		}
+		report.Results = append(report.Results, row)
+	}
+	return report, nil
+}
+
+// ExecuteImport 创建预览中没有错误的行，应在事务中调用；返回创建的任务
+func (s *TaskCSVService) ExecuteImport(db *gorm.DB, report *TaskCSVImportReport, userID uint) ([]models.Task, error) {
+	project := report.project
+
+	// 按首次出现的顺序在阶段末尾创建缺少的阶段
+	var maxPosition int
+	if err := db.Model(&models.Stage{}).Where("project_id = ?", project.ID).Select("COALESCE(MAX(position), 0)").Row().Scan(&maxPosition); err != nil {
+		return nil, err
+	}
+	created := []string{}
+	for _, name := range report.NewStages {
+		stage := report.stages[strings.ToLower(name)]
+		if !s.stageUsed(report, stage) {
+			continue
+		}
+		maxPosition++
+		stage.Position = maxPosition
+		stage.Color = "#3B82F6"
+		stage.WIPMode = models.WIPModeHard
+		stage.CreatedBy = userID
+		if err := db.Create(stage).Error; err != nil {
+			return nil, err
+		}
+		created = append(created, name)
+	}
+	report.NewStages = created
+
+	rankService := NewTaskRankService()
+	keyService := NewTaskKeyService()
+	now := time.Now()
+	tasks := make([]models.Task, 0, report.Valid)
+	for _, row := range report.Results {
+		if len(row.Errors) > 0 {
+			continue
+		}
+		rank, err := rankService.RankForAppend(db, row.stage.ID)
+		if err != nil {
+			return nil, err
+		}
+		task := row.task
+		task.StageID = row.stage.ID
+		task.Status = row.Status
+		task.Rank = rank
+		task.CreatedBy = userID
+		// 导入到已完成阶段或已完成状态的任务记录完成时间
+		if IsTaskDone(report.workflow, task.Status, row.stage) {
+			task.CompletedAt = &now
+		}
+		if err := keyService.AssignTaskKey(db, &task); err != nil {
+			return nil, err
+		}
+		if err := db.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		for _, labelID := range row.labelIDs {
+			if err := db.Create(&models.TaskLabel{TaskID: task.ID, LabelID: labelID}).Error; err != nil {
+				return nil, err
+			}
+		}
+		row.TaskID = task.ID
+		tasks = append(tasks, task)
+	}
+	report.Created = len(tasks)
+	return tasks, nil
+}
+
+// stageUsed 待创建的阶段是否被没有错误的行使用
+func (s *TaskCSVService) stageUsed(report *TaskCSVImportReport, stage *models.Stage) bool {
+	for _, row := range report.Results {
+		if row.stage == stage && len(row.Errors) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// projectUsers 项目所有者和成员，按小写的邮箱和用户名索引
+func (s *TaskCSVService) projectUsers(db *gorm.DB, project *models.Project) (map[string]uint, error) {
+	var users []models.User
+	if err := db.Select("id, username, email").
+		Where("id = ? OR id IN (SELECT user_id FROM project_members WHERE project_id = ?)", project.OwnerID, project.ID).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	// 用户名与其他用户的邮箱相同时以邮箱为准
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	result := make(map[string]uint, len(users)*2)
+	for _, user := range users {
+		if user.Username != "" {
+			result[strings.ToLower(user.Username)] = user.ID
+		}
+	}
+	for _, user := range users {
+		if user.Email != "" {
+			result[strings.ToLower(user.Email)] = user.ID
+		}
+	}
+	return result, nil
+}
+
+// matchWorkflowStatus 按取值或显示名称（忽略大小写）查找状态，找不到时返回空字符串
+func matchWorkflowStatus(workflow *Workflow, value string) string {
+	if value == "" {
+		return ""
+	}
+	for _, status := range workflow.Statuses {
+		if strings.EqualFold(status.Key, value) || strings.EqualFold(status.Name, value) {
+			return status.Key
+		}
+	}
+	return ""
+}
+
+// matchWorkflowPriority 按取值或显示名称（忽略大小写）查找优先级，找不到时返回空字符串
+func matchWorkflowPriority(workflow *Workflow, value string) string {
+	if value == "" {
+		return ""
+	}
+	for _, priority := range workflow.Priorities {
+		if strings.EqualFold(priority.Key, value) || strings.EqualFold(priority.Name, value) {
+			return priority.Key
+		}
+	}
+	return ""
+}
+
+// parseCSVHours 解析工时，空值返回 nil
+func parseCSVHours(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	hours, err := strconv.ParseFloat(value, 64)
+	if err != nil || hours < 0 {
+		return nil, fmt.Errorf("invalid hours %q", value)
+	}
+	return &hours, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
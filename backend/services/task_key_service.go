@@ -0,0 +1,290 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project-manager-backend/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 任务编号由项目前缀和项目内序号组成，例如 WEB-123
+var (
+	keyPrefixPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,9}$`)
+	taskKeyPattern   = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9]{1,9})-([0-9]+)$`)
+)
+
+// 默认前缀（项目名称中没有可用的英文字母时使用）
+const defaultKeyPrefix = "PRJ"
+
+// ErrInvalidKeyPrefix 编号前缀格式无效
+var ErrInvalidKeyPrefix = errors.New("key prefix must be 2-10 uppercase letters or digits and start with a letter")
+
+// ErrKeyPrefixTaken 编号前缀已被其他项目使用
+var ErrKeyPrefixTaken = errors.New("key prefix is already used by another project")
+
+// TaskKeyService 任务编号服务
+type TaskKeyService struct{}
+
+// NewTaskKeyService 创建任务编号服务
+func NewTaskKeyService() *TaskKeyService {
+	return &TaskKeyService{}
+}
+
+// FormatTaskKey 拼接任务编号
+func FormatTaskKey(prefix string, number int) string {
+	return fmt.Sprintf("%s-%d", prefix, number)
+}
+
+// IsTaskKey 判断字符串是否是任务编号格式
+func IsTaskKey(value string) bool {
+	return taskKeyPattern.MatchString(value)
+}
+
+// NormalizeKeyPrefix 规范化编号前缀（去除空白并转为大写）
+func NormalizeKeyPrefix(prefix string) string {
+	return strings.ToUpper(strings.TrimSpace(prefix))
+}
+
+// ValidateKeyPrefix 校验编号前缀格式以及是否被其他项目占用
+func (s *TaskKeyService) ValidateKeyPrefix(db *gorm.DB, prefix string, projectID uint) error {
+	if !keyPrefixPattern.MatchString(prefix) {
+		return ErrInvalidKeyPrefix
+	}
+	if s.prefixTaken(db, prefix, projectID) {
+		return ErrKeyPrefixTaken
+	}
+	return nil
+}
+
+func (s *TaskKeyService) prefixTaken(db *gorm.DB, prefix string, projectID uint) bool {
+	var count int
+	db.Model(&models.Project{}).Where("key_prefix = ? AND id <> ?", prefix, projectID).Count(&count)
+	if count > 0 {
+		return true
+	}
+	// 旧前缀仍被别名使用时也不能复用，否则旧编号会指向新项目的任务
+	db.Model(&models.TaskKeyAlias{}).Where("alias_key LIKE ? AND project_id <> ?", prefix+"-%", projectID).Count(&count)
+	return count > 0
+}
+
+// GenerateKeyPrefix 根据项目名称生成未被占用的编号前缀
+// 多个英文单词取首字母（Web Portal -> WP），单个单词取前3个字符（Website -> WEB）
+func (s *TaskKeyService) GenerateKeyPrefix(db *gorm.DB, name string, projectID uint) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+
+	base := ""
+	if len(words) > 1 {
+		for _, word := range words {
+			base += word[:1]
+		}
+	}
+	if len(base) < 2 && len(words) > 0 {
+		base = words[0]
+		if len(base) > 3 {
+			base = base[:3]
+		}
+	}
+	base = strings.ToUpper(base)
+	if len(base) > 6 {
+		base = base[:6]
+	}
+	if !keyPrefixPattern.MatchString(base) {
+		base = defaultKeyPrefix
+	}
+
+	prefix := base
+	for i := 2; s.prefixTaken(db, prefix, projectID); i++ {
+		prefix = base + strconv.Itoa(i)
+	}
+	return prefix
+}
+
+// AssignTaskKey 为任务分配所属项目的下一个序号和编号
+// 需要在创建任务或修改任务所属项目的事务中调用，调用方负责保存任务
+func (s *TaskKeyService) AssignTaskKey(db *gorm.DB, task *models.Task) error {
+	var project models.Project
+	if err := db.First(&project, task.ProjectID).Error; err != nil {
+		return fmt.Errorf("project %d not found", task.ProjectID)
+	}
+
+	if project.KeyPrefix == "" {
+		project.KeyPrefix = s.GenerateKeyPrefix(db, project.Name, project.ID)
+		if err := db.Model(&models.Project{}).Where("id = ?", project.ID).
+			UpdateColumn("key_prefix", project.KeyPrefix).Error; err != nil {
+			return fmt.Errorf("failed to set key prefix: %v", err)
+		}
+	}
+
+	// 先自增再读取，保证同一项目内序号不重复
+	if err := db.Model(&models.Project{}).Where("id = ?", project.ID).
+		UpdateColumn("task_seq", gorm.Expr("task_seq + 1")).Error; err != nil {
+		return fmt.Errorf("failed to allocate task number: %v", err)
+	}
+	var seq int
+	if err := db.Model(&models.Project{}).Where("id = ?", project.ID).Select("task_seq").Row().Scan(&seq); err != nil {
+		return fmt.Errorf("failed to allocate task number: %v", err)
+	}
+
+	task.Number = seq
+	task.Key = FormatTaskKey(project.KeyPrefix, seq)
+	return nil
+}
+
+// MoveTaskKey 任务移动到其他项目时保留旧编号作为别名，并分配新项目的编号
+// task.ProjectID 应已设置为新项目，调用方负责保存任务
+func (s *TaskKeyService) MoveTaskKey(db *gorm.DB, task *models.Task, oldProjectID uint) error {
+	if err := s.addAlias(db, task.ID, oldProjectID, task.Key); err != nil {
+		return err
+	}
+	return s.AssignTaskKey(db, task)
+}
+
+// ChangeKeyPrefix 修改项目编号前缀，所有任务重新生成编号，旧编号保留为别名
+func (s *TaskKeyService) ChangeKeyPrefix(db *gorm.DB, project *models.Project, prefix string) error {
+	prefix = NormalizeKeyPrefix(prefix)
+	if prefix == project.KeyPrefix {
+		return nil
+	}
+	if err := s.ValidateKeyPrefix(db, prefix, project.ID); err != nil {
+		return err
+	}
+
+	var tasks []models.Task
+	if err := db.Select("id, number, task_key").Where("project_id = ? AND number > 0", project.ID).Find(&tasks).Error; err != nil {
+		return fmt.Errorf("failed to load project tasks: %v", err)
+	}
+	for _, task := range tasks {
+		if err := s.addAlias(db, task.ID, project.ID, task.Key); err != nil {
+			return err
+		}
+		// 新编号之前可能是本项目的别名（改回旧前缀），不再需要保留
+		newKey := FormatTaskKey(prefix, task.Number)
+		if err := db.Where("alias_key = ?", newKey).Delete(&models.TaskKeyAlias{}).Error; err != nil {
+			return fmt.Errorf("failed to update task key aliases: %v", err)
+		}
+		if err := db.Model(&models.Task{}).Where("id = ?", task.ID).UpdateColumn("task_key", newKey).Error; err != nil {
+			return fmt.Errorf("failed to update task key: %v", err)
+		}
+	}
+
+	if err := db.Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumn("key_prefix", prefix).Error; err != nil {
+		return fmt.Errorf("failed to update key prefix: %v", err)
+	}
+	project.KeyPrefix = prefix
+	return nil
+}
+
+func (s *TaskKeyService) addAlias(db *gorm.DB, taskID, projectID uint, key string) error {
+	if key == "" {
+		return nil
+	}
+	alias := models.TaskKeyAlias{Key: key, TaskID: taskID, ProjectID: projectID}
+	if err := db.Where("alias_key = ?", key).Assign(alias).FirstOrCreate(&alias).Error; err != nil {
+		return fmt.Errorf("failed to save task key alias: %v", err)
+	}
+	return nil
+}
+
+// ResolveTaskKey 根据任务编号（当前编号或别名，不区分大小写）查找任务ID
+func (s *TaskKeyService) ResolveTaskKey(db *gorm.DB, key string) (uint, error) {
+	key = strings.ToUpper(strings.TrimSpace(key))
+	if !IsTaskKey(key) {
+		return 0, fmt.Errorf("invalid task key: %s", key)
+	}
+
+	var task models.Task
+	if err := db.Select("id").Where("task_key = ?", key).First(&task).Error; err == nil {
+		return task.ID, nil
+	}
+
+	var alias models.TaskKeyAlias
+	if err := db.Where("alias_key = ?", key).First(&alias).Error; err != nil {
+		return 0, fmt.Errorf("task %s not found", key)
+	}
+	return alias.TaskID, nil
+}
+
+// ResolveTaskRef 解析任务引用，支持数字ID和任务编号
+func (s *TaskKeyService) ResolveTaskRef(db *gorm.DB, ref string) (uint, error) {
+	if id, err := strconv.ParseUint(ref, 10, 32); err == nil {
+		return uint(id), nil
+	}
+	return s.ResolveTaskKey(db, ref)
+}
+
+// TaskRef 请求体中的任务引用，JSON 中可以是数字ID，也可以是任务编号（如 "WEB-123"）
+type TaskRef string
+
+// UnmarshalJSON 同时接受数字和字符串
+func (r *TaskRef) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*r = TaskRef(strings.TrimSpace(value))
+		return nil
+	}
+	var id uint64
+	if err := json.Unmarshal(data, &id); err != nil {
+		return fmt.Errorf("task reference must be a task ID or task key")
+	}
+	*r = TaskRef(strconv.FormatUint(id, 10))
+	return nil
+}
+
+// ResolveTaskRefs 批量解析任务引用，保持顺序
+func (s *TaskKeyService) ResolveTaskRefs(db *gorm.DB, refs []TaskRef) ([]uint, error) {
+	ids := make([]uint, len(refs))
+	for i, ref := range refs {
+		id, err := s.ResolveTaskRef(db, string(ref))
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// MigrateTaskKeys 为没有编号前缀的项目生成前缀，并按创建顺序为没有编号的任务分配编号
+func (s *TaskKeyService) MigrateTaskKeys(db *gorm.DB) error {
+	var projects []models.Project
+	if err := db.Where("key_prefix IS NULL OR key_prefix = ''").Order("id ASC").Find(&projects).Error; err != nil {
+		return fmt.Errorf("failed to load projects: %v", err)
+	}
+	for _, project := range projects {
+		prefix := s.GenerateKeyPrefix(db, project.Name, project.ID)
+		if err := db.Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumn("key_prefix", prefix).Error; err != nil {
+			return fmt.Errorf("failed to set key prefix of project %d: %v", project.ID, err)
+		}
+	}
+
+	var tasks []models.Task
+	if err := db.Select("id, project_id").Where("number IS NULL OR number = 0").Order("id ASC").Find(&tasks).Error; err != nil {
+		return fmt.Errorf("failed to load tasks: %v", err)
+	}
+	for i := range tasks {
+		if err := s.AssignTaskKey(db, &tasks[i]); err != nil {
+			log.Printf("Skipped assigning key to task %d: %v", tasks[i].ID, err)
+			continue
+		}
+		if err := db.Model(&models.Task{}).Where("id = ?", tasks[i].ID).
+			UpdateColumns(map[string]interface{}{"number": tasks[i].Number, "task_key": tasks[i].Key}).Error; err != nil {
+			return fmt.Errorf("failed to set key of task %d: %v", tasks[i].ID, err)
+		}
+	}
+
+	if len(projects) > 0 || len(tasks) > 0 {
+		log.Printf("Assigned key prefixes to %d projects and keys to %d tasks", len(projects), len(tasks))
+	}
+	return nil
+}
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"project-manager-backend/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 任务查询语言
+//
+// 查询由空格分隔的条件组成，所有条件之间为 AND 关系：
+//
+//	assignee:me priority:P0,P1 due<2026-11-01 -status:done text:"login bug" created>7d
+//
+//   - field:value       等于，逗号分隔多个值表示任意一个（OR）
+//   - field<value       比较，支持 < <= > >=，用于日期字段
+//   - -field:value      取反
+//   - 不带字段的单词或 "带引号的短语" 按 text 处理（匹配标题和描述）
+//
+// 日期值支持 YYYY-MM-DD、RFC 3339、today/yesterday/tomorrow/now，以及相对时间：
+// 7d 表示 7 天前，+7d 表示 7 天后（单位 h/d/w）。created>7d 即最近 7 天内创建。
+// 截止日期和开始日期的 YYYY-MM-DD 按日历日期比较（与全天任务的存储方式一致），
+// 创建和更新时间的日期按用户时区解释。
+
+// TaskQueryError 查询语法错误，Position 为出错位置（从1开始的字符序号）
+type TaskQueryError struct {
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+func (e *TaskQueryError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Position, e.Message)
+}
+
+// TaskQueryTerm 单个查询条件
+type TaskQueryTerm struct {
+	Field    string   `json:"field"`
+	Operator string   `json:"operator"` // : < <= > >=
+	Values   []string `json:"values"`
+	Negated  bool     `json:"negated"`
+	Position int      `json:"position"`
+}
+
+// TaskQuery 解析后的任务查询
+type TaskQuery struct {
+	Raw   string          `json:"raw"`
+	Terms []TaskQueryTerm `json:"terms"`
+}
+
+// TaskQueryContext 执行查询所需的上下文
+type TaskQueryContext struct {
+	UserID   uint           // 当前用户，用于 me
+	Now      time.Time      // 当前时间，用于相对日期
+	Location *time.Location // 用户时区，用于 today 等日期
+}
+
+type taskQueryFieldKind int
+
+const (
+	queryKindList    taskQueryFieldKind = iota // 精确匹配
+	queryKindUser                              // 用户：me、none、用户名或ID
+	queryKindDate                              // 日期比较
+	queryKindText                              // 模糊匹配标题和描述
+	queryKindStage                             // 阶段名称或ID
+	queryKindProject                           // 项目编号前缀或ID
+	queryKindIs                                // 预定义状态
+	queryKindLabel                             // 标签名称或ID
+)
+
+type taskQueryField struct {
+	kind    taskQueryFieldKind
+	column  string
+	dayOnly bool // 日期按 UTC 日历日期存储（截止日期、开始日期）
+}
+
+var taskQueryFields = map[string]taskQueryField{
+	"assignee": {kind: queryKindUser, column: "tasks.assignee_id"},
+	"creator":  {kind: queryKindUser, column: "tasks.created_by"},
+	"priority": {kind: queryKindList, column: "tasks.priority"},
+	"status":   {kind: queryKindList, column: "tasks.status"},
+	"key":      {kind: queryKindList, column: "tasks.task_key"},
+	"stage":    {kind: queryKindStage, column: "tasks.stage_id"},
+	"project":  {kind: queryKindProject, column: "tasks.project_id"},
+	"text":     {kind: queryKindText},
+	"due":      {kind: queryKindDate, column: "tasks.due_date", dayOnly: true},
+	"start":    {kind: queryKindDate, column: "tasks.start_date", dayOnly: true},
+	"created":  {kind: queryKindDate, column: "tasks.created_at"},
+	"updated":  {kind: queryKindDate, column: "tasks.updated_at"},
+	"is":       {kind: queryKindIs},
+	"label":    {kind: queryKindLabel},
+}
+
+// is: 支持的值
+var taskQueryIsValues = map[string]bool{
+	"overdue":    true, // 已逾期且未完成
+	"unassigned": true, // 没有负责人
+	"completed":  true, // 状态为 done 或位于已完成阶段
+	"open":       true, // 未完成
+	"archived":   true, // 已归档（不使用时查询结果不包含已归档的任务）
+}
+
+var relativeDatePattern = regexp.MustCompile(`^([+-]?)([0-9]+)([hdw])$`)
+
+// ParseTaskQuery 解析查询字符串
+func ParseTaskQuery(input string) (*TaskQuery, error) {
+	query := &TaskQuery{Raw: input, Terms: []TaskQueryTerm{}}
+	runes := []rune(input)
+	i := 0
+
+	for {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		if i >= len(runes) {
+			break
+		}
+		start := i
+
+		term := TaskQueryTerm{Position: start + 1}
+		if runes[i] == '-' {
+			term.Negated = true
+			i++
+		}
+
+		// 带引号的短语直接作为 text 条件
+		if i < len(runes) && runes[i] == '"' {
+			value, next, err := readQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+			term.Field, term.Operator, term.Values = "text", ":", []string{value}
+			query.Terms = append(query.Terms, term)
+			continue
+		}
+
+		// 读取字段名
+		nameStart := i
+		for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+			i++
+		}
+		name := strings.ToLower(string(runes[nameStart:i]))
+
+		operator := ""
+		if i < len(runes) {
+			switch runes[i] {
+			case ':':
+				operator = ":"
+				i++
+			case '<', '>':
+				operator = string(runes[i])
+				i++
+				if i < len(runes) && runes[i] == '=' {
+					operator += "="
+					i++
+				}
+			}
+		}
+
+		// 没有运算符：整个单词作为 text 条件
+		if operator == "" || name == "" {
+			i = nameStart
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				i++
+			}
+			word := string(runes[nameStart:i])
+			if word == "" {
+				return nil, &TaskQueryError{Position: start + 1, Message: "expected a search term after '-'"}
+			}
+			term.Field, term.Operator, term.Values = "text", ":", []string{word}
+			query.Terms = append(query.Terms, term)
+			continue
+		}
+
+		field, ok := taskQueryFields[name]
+		if !ok {
+			return nil, &TaskQueryError{Position: nameStart + 1, Message: fmt.Sprintf("unknown field %q", name)}
+		}
+		if operator != ":" && field.kind != queryKindDate {
+			return nil, &TaskQueryError{Position: nameStart + 1, Message: fmt.Sprintf("field %q only supports ':'", name)}
+		}
+
+		// 读取值（逗号分隔，每个值可以带引号）
+		valueStart := i
+		var values []string
+		for {
+			var value string
+			if i < len(runes) && runes[i] == '"' {
+				quoted, next, err := readQuoted(runes, i)
+				if err != nil {
+					return nil, err
+				}
+				value, i = quoted, next
+			} else {
+				from := i
+				for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != ',' {
+					i++
+				}
+				value = string(runes[from:i])
+			}
+			if value == "" {
+				return nil, &TaskQueryError{Position: i + 1, Message: fmt.Sprintf("missing value for %q", name)}
+			}
+			values = append(values, value)
+			if i < len(runes) && runes[i] == ',' {
+				i++
+				continue
+			}
+			break
+		}
+		if i < len(runes) && !unicode.IsSpace(runes[i]) {
+			return nil, &TaskQueryError{Position: i + 1, Message: fmt.Sprintf("unexpected character %q", runes[i])}
+		}
+
+		term.Field, term.Operator, term.Values = name, operator, values
+		if err := validateQueryTerm(field, &term, valueStart+1); err != nil {
+			return nil, err
+		}
+		query.Terms = append(query.Terms, term)
+	}
+
+	return query, nil
+}
+
+// readQuoted 读取从 runes[i]（双引号）开始的带引号字符串，支持 \" 转义
+func readQuoted(runes []rune, i int) (string, int, error) {
+	start := i
+	i++
+	var b strings.Builder
+	for i < len(runes) {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) {
+				b.WriteRune(runes[i+1])
+				i += 2
+				continue
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	return "", 0, &TaskQueryError{Position: start + 1, Message: "unterminated quoted string"}
+}
+
+// validateQueryTerm 校验值的格式，日期中的相对时间在执行时才计算
+func validateQueryTerm(field taskQueryField, term *TaskQueryTerm, position int) error {
+	switch field.kind {
+	case queryKindDate:
+		if term.Operator != ":" && len(term.Values) > 1 {
+			return &TaskQueryError{Position: position, Message: fmt.Sprintf("%q with %s accepts a single date", term.Field, term.Operator)}
+		}
+		for _, value := range term.Values {
+			lower := strings.ToLower(value)
+			if term.Operator == ":" && (lower == "none" || lower == "any") {
+				continue
+			}
+			if _, _, err := parseQueryDate(value, time.Now(), time.UTC, field.dayOnly); err != nil {
+				return &TaskQueryError{Position: position, Message: fmt.Sprintf("invalid date %q for %q", value, term.Field)}
+			}
+		}
+	case queryKindIs:
+		for i, value := range term.Values {
+			term.Values[i] = strings.ToLower(value)
+			if !taskQueryIsValues[term.Values[i]] {
+				return &TaskQueryError{Position: position, Message: fmt.Sprintf("unknown value %q for \"is\" (use overdue, unassigned, completed or open)", value)}
+			}
+		}
+	case queryKindList:
+		if term.Field == "priority" || term.Field == "key" {
+			for i, value := range term.Values {
+				term.Values[i] = strings.ToUpper(value)
+			}
+		}
+	}
+	return nil
+}
+
+// parseQueryDate 解析日期值，返回时刻以及是否是整天（用于 : 匹配当天）
+func parseQueryDate(value string, now time.Time, loc *time.Location, dayOnly bool) (time.Time, bool, error) {
+	lower := strings.ToLower(value)
+	dayIn := loc
+	if dayOnly {
+		dayIn = time.UTC
+	}
+	localToday := now.In(loc)
+	today := time.Date(localToday.Year(), localToday.Month(), localToday.Day(), 0, 0, 0, 0, dayIn)
+
+	switch lower {
+	case "now":
+		return now, false, nil
+	case "today":
+		return today, true, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true, nil
+	}
+
+	if m := relativeDatePattern.FindStringSubmatch(lower); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		if m[1] != "+" {
+			n = -n
+		}
+		switch m[3] {
+		case "h":
+			return now.Add(time.Duration(n) * time.Hour), false, nil
+		case "w":
+			return now.AddDate(0, 0, 7*n), false, nil
+		default:
+			return now.AddDate(0, 0, n), false, nil
+		}
+	}
+
+	if date, err := time.ParseInLocation(utils.DateLayout, value, dayIn); err == nil {
+		return date, true, nil
+	}
+	if instant, err := time.Parse(time.RFC3339, value); err == nil {
+		return instant, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+}
+
+// IncludesArchived 查询是否要求已归档的任务（is:archived）
+func (q *TaskQuery) IncludesArchived() bool {
+	if q == nil {
+		return false
+	}
+	for _, term := range q.Terms {
+		if term.Field != "is" || term.Negated {
+			continue
+		}
+		for _, value := range term.Values {
+			if value == "archived" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply 将查询条件加到 db（tasks 表）上，所有值都以参数传入
+func (q *TaskQuery) Apply(db *gorm.DB, ctx TaskQueryContext) (*gorm.DB, error) {
+	if ctx.Location == nil {
+		ctx.Location = time.UTC
+	}
+	if ctx.Now.IsZero() {
+		ctx.Now = time.Now()
+	}
+
+	for _, term := range q.Terms {
+		sql, args, err := q.termSQL(term, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if term.Negated {
+			// 取反时 NULL 值（例如没有负责人）也算不匹配原条件
+			sql = "NOT COALESCE((" + sql + "), 0)"
+		}
+		db = db.Where(sql, args...)
+	}
+	return db, nil
+}
+
+func (q *TaskQuery) termSQL(term TaskQueryTerm, ctx TaskQueryContext) (string, []interface{}, error) {
+	field := taskQueryFields[term.Field]
+
+	switch field.kind {
+	case queryKindList:
+		return field.column + " IN (?)", []interface{}{term.Values}, nil
+
+	case queryKindText:
+		var parts []string
+		var args []interface{}
+		for _, value := range term.Values {
+			pattern := "%" + escapeLike(value) + "%"
+			parts = append(parts, `(tasks.title LIKE ? ESCAPE '\' OR tasks.description LIKE ? ESCAPE '\')`)
+			args = append(args, pattern, pattern)
+		}
+		return strings.Join(parts, " OR "), args, nil
+
+	case queryKindUser:
+		var ids []uint
+		var names []string
+		includeNone := false
+		for _, value := range term.Values {
+			switch strings.ToLower(value) {
+			case "me":
+				ids = append(ids, ctx.UserID)
+			case "none":
+				includeNone = true
+			default:
+				if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+					ids = append(ids, uint(id))
+				} else {
+					names = append(names, value)
+				}
+			}
+		}
+		var parts []string
+		var args []interface{}
+		if len(ids) > 0 {
+			parts = append(parts, field.column+" IN (?)")
+			args = append(args, ids)
+		}
+		if len(names) > 0 {
+			parts = append(parts, field.column+" IN (SELECT id FROM users WHERE username IN (?))")
+			args = append(args, names)
+		}
+		if includeNone {
+			parts = append(parts, field.column+" IS NULL")
+		}
+		return strings.Join(parts, " OR "), args, nil
+
+	case queryKindStage:
+		ids, names := splitIDsAndNames(term.Values)
+		return field.column + " IN (SELECT id FROM stages WHERE id IN (?) OR name IN (?))", []interface{}{ids, names}, nil
+
+	case queryKindProject:
+		ids, prefixes := splitIDsAndNames(term.Values)
+		for i := range prefixes {
+			prefixes[i] = strings.ToUpper(prefixes[i])
+		}
+		return field.column + " IN (SELECT id FROM projects WHERE id IN (?) OR key_prefix IN (?))", []interface{}{ids, prefixes}, nil
+
+	case queryKindDate:
+		return dateTermSQL(field, term, ctx)
+
+	case queryKindLabel:
+		ids, names := splitIDsAndNames(term.Values)
+		return "tasks.id IN (SELECT task_labels.task_id FROM task_labels JOIN labels ON labels.id = task_labels.label_id " +
+			"WHERE labels.project_id = tasks.project_id AND (labels.id IN (?) OR labels.name IN (?)))", []interface{}{ids, names}, nil
+
+	case queryKindIs:
+		var parts []string
+		var args []interface{}
+		for _, value := range term.Values {
+			switch value {
+			case "overdue":
+				// 全天任务在用户时区的截止日期结束后逾期，其余任务超过截止时刻即逾期
+				today := utils.DueDay(ctx.Now, false, ctx.Location)
+				parts = append(parts, "(NOT "+TaskStatusCategoryCondition(models.StatusCategoryDone)+" AND tasks.due_date IS NOT NULL AND "+
+					"((tasks.due_all_day = 1 AND tasks.due_date < ?) OR (COALESCE(tasks.due_all_day, 0) = 0 AND tasks.due_date < ?)))")
+				args = append(args, today, ctx.Now.UTC().Round(0))
+			case "unassigned":
+				parts = append(parts, "tasks.assignee_id IS NULL")
+			case "completed":
+				parts = append(parts, "("+TaskStatusCategoryCondition(models.StatusCategoryDone)+" OR tasks.stage_id IN (SELECT id FROM stages WHERE is_completed = 1))")
+			case "open":
+				parts = append(parts, "(NOT "+TaskStatusCategoryCondition(models.StatusCategoryDone)+" AND tasks.stage_id NOT IN (SELECT id FROM stages WHERE is_completed = 1))")
+			case "archived":
+				parts = append(parts, "tasks.archived_at IS NOT NULL")
+			}
+		}
+		return strings.Join(parts, " OR "), args, nil
+	}
+
+	return "", nil, &TaskQueryError{Position: term.Position, Message: fmt.Sprintf("unsupported field %q", term.Field)}
+}
+
+// dateTermSQL 生成日期比较条件
+// 数据库中时间按写入时的时区保存为文本，比较参数需要使用相同时区：
+// 截止/开始日期统一为 UTC，创建/更新时间为服务器本地时区
+func dateTermSQL(field taskQueryField, term TaskQueryTerm, ctx TaskQueryContext) (string, []interface{}, error) {
+	normalize := func(t time.Time) time.Time {
+		if field.dayOnly {
+			return t.UTC().Round(0)
+		}
+		return t.In(time.Local).Round(0)
+	}
+
+	var parts []string
+	var args []interface{}
+	for _, value := range term.Values {
+		switch strings.ToLower(value) {
+		case "none":
+			parts = append(parts, field.column+" IS NULL")
+			continue
+		case "any":
+			parts = append(parts, field.column+" IS NOT NULL")
+			continue
+		}
+
+		t, isDay, err := parseQueryDate(value, ctx.Now, ctx.Location, field.dayOnly)
+		if err != nil {
+			return "", nil, &TaskQueryError{Position: term.Position, Message: err.Error()}
+		}
+		next := t
+		if isDay {
+			next = t.AddDate(0, 0, 1)
+		}
+
+		switch term.Operator {
+		case ":":
+			if !isDay {
+				return "", nil, &TaskQueryError{Position: term.Position, Message: fmt.Sprintf("%q with ':' needs a date, use < or > for times", term.Field)}
+			}
+			parts = append(parts, "("+field.column+" >= ? AND "+field.column+" < ?)")
+			args = append(args, normalize(t), normalize(next))
+		case "<":
+			parts = append(parts, field.column+" < ?")
+			args = append(args, normalize(t))
+		case "<=":
+			parts = append(parts, field.column+" < ?")
+			args = append(args, normalize(next))
+		case ">":
+			parts = append(parts, field.column+" >= ?")
+			args = append(args, normalize(next))
+		case ">=":
+			parts = append(parts, field.column+" >= ?")
+			args = append(args, normalize(t))
+		}
+	}
+	return strings.Join(parts, " OR "), args, nil
+}
+
+// splitIDsAndNames 将值分为数字ID和名称；列表为空时返回占位值，避免生成 IN () 语句
+func splitIDsAndNames(values []string) ([]uint, []string) {
+	ids := []uint{0}
+	names := []string{""}
+	for _, value := range values {
+		if id, err := strconv.ParseUint(value, 10, 32); err == nil {
+			ids = append(ids, uint(id))
+		} else {
+			names = append(names, value)
+		}
+	}
+	return ids, names
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
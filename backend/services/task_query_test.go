@@ -0,0 +1,382 @@
+package services
+
+import (
+	"database/sql"
+	"project-manager-backend/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "modernc.org/sqlite"
+)
+
+func TestParseTaskQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []TaskQueryTerm
+	}{
+		{input: "", want: []TaskQueryTerm{}},
+		{input: "   ", want: []TaskQueryTerm{}},
+		{
+			input: "assignee:me priority:p0,P1",
+			want: []TaskQueryTerm{
+				{Field: "assignee", Operator: ":", Values: []string{"me"}, Position: 1},
+				{Field: "priority", Operator: ":", Values: []string{"P0", "P1"}, Position: 13},
+			},
+		},
+		{
+			input: "-status:done",
+			want:  []TaskQueryTerm{{Field: "status", Operator: ":", Values: []string{"done"}, Negated: true, Position: 1}},
+		},
+		{
+			input: "login bug",
+			want: []TaskQueryTerm{
+				{Field: "text", Operator: ":", Values: []string{"login"}, Position: 1},
+				{Field: "text", Operator: ":", Values: []string{"bug"}, Position: 7},
+			},
+		},
+		{
+			input: `"login bug"`,
+			want:  []TaskQueryTerm{{Field: "text", Operator: ":", Values: []string{"login bug"}, Position: 1}},
+		},
+		{
+			input: `-"login bug"`,
+			want:  []TaskQueryTerm{{Field: "text", Operator: ":", Values: []string{"login bug"}, Negated: true, Position: 1}},
+		},
+		{
+			input: `text:"say \"hi\""`,
+			want:  []TaskQueryTerm{{Field: "text", Operator: ":", Values: []string{`say "hi"`}, Position: 1}},
+		},
+		{
+			input: `label:"needs review",urgent`,
+			want:  []TaskQueryTerm{{Field: "label", Operator: ":", Values: []string{"needs review", "urgent"}, Position: 1}},
+		},
+		{
+			input: "due<=2026-11-01 created>7d start>=+1w",
+			want: []TaskQueryTerm{
+				{Field: "due", Operator: "<=", Values: []string{"2026-11-01"}, Position: 1},
+				{Field: "created", Operator: ">", Values: []string{"7d"}, Position: 17},
+				{Field: "start", Operator: ">=", Values: []string{"+1w"}, Position: 28},
+			},
+		},
+		{
+			input: "due:today,tomorrow due:none",
+			want: []TaskQueryTerm{
+				{Field: "due", Operator: ":", Values: []string{"today", "tomorrow"}, Position: 1},
+				{Field: "due", Operator: ":", Values: []string{"none"}, Position: 20},
+			},
+		},
+		{
+			input: "is:Archived -is:open",
+			want: []TaskQueryTerm{
+				{Field: "is", Operator: ":", Values: []string{"archived"}, Position: 1},
+				{Field: "is", Operator: ":", Values: []string{"open"}, Negated: true, Position: 13},
+			},
+		},
+		{
+			input: "Key:web-1",
+			want:  []TaskQueryTerm{{Field: "key", Operator: ":", Values: []string{"WEB-1"}, Position: 1}},
+		},
+		{
+			input: "登录 assignee:none",
+			want: []TaskQueryTerm{
+				{Field: "text", Operator: ":", Values: []string{"登录"}, Position: 1},
+				{Field: "assignee", Operator: ":", Values: []string{"none"}, Position: 4},
+			},
+		},
+		{
+			input: "a-b :x",
+			want: []TaskQueryTerm{
+				{Field: "text", Operator: ":", Values: []string{"a-b"}, Position: 1},
+				{Field: "text", Operator: ":", Values: []string{":x"}, Position: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			query, err := ParseTaskQuery(tt.input)
+			if err != nil {
+				t.Fatalf("ParseTaskQuery(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(query.Terms, tt.want) {
+				t.Errorf("ParseTaskQuery(%q) terms = %+v, want %+v", tt.input, query.Terms, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTaskQueryErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		position int
+		message  string
+	}{
+		{input: "-", position: 1, message: "expected a search term"},
+		{input: "bug -", position: 5, message: "expected a search term"},
+		{input: "foo:bar", position: 1, message: `unknown field "foo"`},
+		{input: "a -bogus:x", position: 4, message: `unknown field "bogus"`},
+		{input: "登录 foo:x", position: 4, message: `unknown field "foo"`},
+		{input: "priority<P1", position: 1, message: `field "priority" only supports ':'`},
+		{input: "status:", position: 8, message: `missing value for "status"`},
+		{input: "status:a,", position: 10, message: `missing value for "status"`},
+		{input: "status:a,,b", position: 10, message: `missing value for "status"`},
+		{input: `text:"abc`, position: 6, message: "unterminated quoted string"},
+		{input: `bug "abc`, position: 5, message: "unterminated quoted string"},
+		{input: `status:"a"b`, position: 11, message: "unexpected character 'b'"},
+		{input: "due:someday", position: 5, message: `invalid date "someday" for "due"`},
+		{input: "created>7x", position: 9, message: `invalid date "7x" for "created"`},
+		{input: "due<today,tomorrow", position: 5, message: "accepts a single date"},
+		{input: "is:done", position: 4, message: `unknown value "done" for "is"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseTaskQuery(tt.input)
+			queryErr, ok := err.(*TaskQueryError)
+			if !ok {
+				t.Fatalf("ParseTaskQuery(%q) error = %v, want *TaskQueryError", tt.input, err)
+			}
+			if queryErr.Position != tt.position {
+				t.Errorf("ParseTaskQuery(%q) position = %d, want %d (%s)", tt.input, queryErr.Position, tt.position, queryErr.Message)
+			}
+			if !strings.Contains(queryErr.Message, tt.message) {
+				t.Errorf("ParseTaskQuery(%q) message = %q, want it to contain %q", tt.input, queryErr.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestTaskQueryIncludesArchived(t *testing.T) {
+	tests := map[string]bool{
+		"":                  false,
+		"is:archived":       true,
+		"is:open,archived":  true,
+		"-is:archived":      false,
+		"text:is:archived":  false,
+		"status:archived":   false,
+		"bug is:ARCHIVED":   true,
+		`"is:archived" bug`: false,
+	}
+	for input, want := range tests {
+		query, err := ParseTaskQuery(input)
+		if err != nil {
+			t.Fatalf("ParseTaskQuery(%q) error: %v", input, err)
+		}
+		if got := query.IncludesArchived(); got != want {
+			t.Errorf("ParseTaskQuery(%q).IncludesArchived() = %v, want %v", input, got, want)
+		}
+	}
+
+	var query *TaskQuery
+	if query.IncludesArchived() {
+		t.Error("nil query should not include archived tasks")
+	}
+}
+
+func TestParseQueryDate(t *testing.T) {
+	// 20:00 UTC 在上海已经是第二天
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("time zone data not available")
+	}
+	now := time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		dayOnly bool
+		want    time.Time
+		isDay   bool
+	}{
+		{value: "now", want: now},
+		{value: "today", want: time.Date(2026, 10, 20, 0, 0, 0, 0, shanghai), isDay: true},
+		{value: "TODAY", dayOnly: true, want: time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), isDay: true},
+		{value: "yesterday", dayOnly: true, want: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), isDay: true},
+		{value: "tomorrow", want: time.Date(2026, 10, 21, 0, 0, 0, 0, shanghai), isDay: true},
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "-7d", want: now.AddDate(0, 0, -7)},
+		{value: "+2w", want: now.AddDate(0, 0, 14)},
+		{value: "3h", want: now.Add(-3 * time.Hour)},
+		{value: "2026-11-01", dayOnly: true, want: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), isDay: true},
+		{value: "2026-11-01", want: time.Date(2026, 11, 1, 0, 0, 0, 0, shanghai), isDay: true},
+		{value: "2026-11-01T09:30:00+02:00", want: time.Date(2026, 11, 1, 7, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, isDay, err := parseQueryDate(tt.value, now, shanghai, tt.dayOnly)
+		if err != nil {
+			t.Errorf("parseQueryDate(%q, dayOnly=%v) error: %v", tt.value, tt.dayOnly, err)
+			continue
+		}
+		if !got.Equal(tt.want) || isDay != tt.isDay {
+			t.Errorf("parseQueryDate(%q, dayOnly=%v) = %v, %v, want %v, %v", tt.value, tt.dayOnly, got, isDay, tt.want, tt.isDay)
+		}
+	}
+
+	for _, value := range []string{"", "someday", "7", "7m", "2026-13-01", "2026/11/01"} {
+		if _, _, err := parseQueryDate(value, now, shanghai, false); err == nil {
+			t.Errorf("parseQueryDate(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"plain":    "plain",
+		"100%":     `100\%`,
+		"snake_id": `snake\_id`,
+		`a\b`:      `a\\b`,
+		`\%_`:      `\\\%\_`,
+	}
+	for input, want := range tests {
+		if got := escapeLike(input); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// openTaskQueryTestDB 创建内存数据库并写入查询测试用的任务
+func openTaskQueryTestDB(t *testing.T, now time.Time) *gorm.DB {
+	t.Helper()
+	sqlDB, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1) // 内存数据库只存在于单个连接中
+	db, err := gorm.Open("sqlite3", sqlDB)
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.AutoMigrate(&models.User{}, &models.Project{}, &models.Stage{}, &models.Task{},
+		&models.Label{}, &models.TaskLabel{}, &models.WorkflowStatus{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	day := func(y int, m time.Month, d int) *time.Time {
+		value := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &value
+	}
+	alice, bob := uint(1), uint(2)
+	dueAt := now.Add(-time.Hour)
+	archivedAt := now.AddDate(0, 0, -1)
+
+	fixtures := []interface{}{
+		&models.User{ID: alice, Username: "alice", Email: "alice@example.com", PasswordHash: "x"},
+		&models.User{ID: bob, Username: "bob", Email: "bob@example.com", PasswordHash: "x"},
+		&models.Project{ID: 1, Name: "Web", OwnerID: alice, KeyPrefix: "WEB"},
+		&models.Stage{ID: 1, ProjectID: 1, Name: "Todo"},
+		&models.Stage{ID: 2, ProjectID: 1, Name: "Shipped", IsCompleted: true},
+		&models.WorkflowStatus{ProjectID: 1, Key: "todo", Category: models.StatusCategoryTodo},
+		&models.WorkflowStatus{ProjectID: 1, Key: "doing", Category: models.StatusCategoryInProgress},
+		&models.WorkflowStatus{ProjectID: 1, Key: "done", Category: models.StatusCategoryDone},
+		&models.Task{ID: 1, ProjectID: 1, StageID: 1, Key: "WEB-1", Title: "Fix login bug", Status: "todo", Priority: "P0",
+			AssigneeID: &alice, DueDate: day(2026, 10, 19), DueAllDay: true},
+		&models.Task{ID: 2, ProjectID: 1, StageID: 1, Key: "WEB-2", Title: "100% coverage", Status: "doing", Priority: "P1",
+			AssigneeID: &bob, DueDate: day(2026, 10, 10), DueAllDay: true},
+		&models.Task{ID: 3, ProjectID: 1, StageID: 2, Key: "WEB-3", Title: "1000 users", Status: "done", Priority: "P2",
+			DueDate: day(2026, 10, 10), DueAllDay: true},
+		&models.Task{ID: 4, ProjectID: 1, StageID: 1, Key: "WEB-4", Title: "snake_case names", Description: "login flow",
+			Status: "todo", Priority: "P2", AssigneeID: &alice, ArchivedAt: &archivedAt},
+		&models.Task{ID: 5, ProjectID: 1, StageID: 2, Key: "WEB-5", Title: "Deploy", Status: "todo", Priority: "P3",
+			DueDate: &dueAt},
+		&models.Label{ID: 1, ProjectID: 1, Name: "bug"},
+		&models.TaskLabel{TaskID: 1, LabelID: 1},
+	}
+	for _, fixture := range fixtures {
+		if err := db.Create(fixture).Error; err != nil {
+			t.Fatalf("create %T: %v", fixture, err)
+		}
+	}
+
+	// 创建时间由 BeforeCreate 写入，之后再改成固定值
+	for id, createdAt := range map[uint]time.Time{
+		1: now.AddDate(0, 0, -1),
+		2: now.AddDate(0, -2, 0),
+		3: now.AddDate(0, -2, 0),
+		4: now.AddDate(0, -2, 0),
+		5: now.AddDate(0, -2, 0),
+	} {
+		if err := db.Model(&models.Task{}).Where("id = ?", id).UpdateColumn("created_at", createdAt.In(time.Local)).Error; err != nil {
+			t.Fatalf("set created_at of task %d: %v", id, err)
+		}
+	}
+	return db
+}
+
+func TestTaskQueryApply(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	db := openTaskQueryTestDB(t, now)
+	ctx := TaskQueryContext{UserID: 1, Now: now, Location: time.UTC}
+
+	tests := []struct {
+		input string
+		want  []uint
+	}{
+		{input: "", want: []uint{1, 2, 3, 4, 5}},
+		{input: "assignee:me", want: []uint{1, 4}},
+		{input: "-assignee:me", want: []uint{2, 3, 5}},
+		{input: "assignee:none", want: []uint{3, 5}},
+		{input: "assignee:bob,none", want: []uint{2, 3, 5}},
+		{input: "assignee:2", want: []uint{2}},
+		{input: "priority:p0,p1", want: []uint{1, 2}},
+		{input: "-priority:P0", want: []uint{2, 3, 4, 5}},
+		{input: "key:web-3", want: []uint{3}},
+		{input: "stage:Shipped", want: []uint{3, 5}},
+		{input: "-stage:1", want: []uint{3, 5}},
+		{input: "project:web", want: []uint{1, 2, 3, 4, 5}},
+		{input: "label:bug", want: []uint{1}},
+		{input: "-label:bug", want: []uint{2, 3, 4, 5}},
+		{input: "login", want: []uint{1, 4}},
+		{input: `-"login"`, want: []uint{2, 3, 5}},
+		{input: "login -is:archived", want: []uint{1}},
+		{input: `text:"100%"`, want: []uint{2}},
+		{input: "0_u", want: []uint{}},
+		{input: "e_c", want: []uint{4}},
+		{input: "is:archived", want: []uint{4}},
+		{input: "is:overdue", want: []uint{2, 5}},
+		{input: "is:completed", want: []uint{3, 5}},
+		{input: "is:open", want: []uint{1, 2, 4}},
+		{input: "is:unassigned,archived", want: []uint{3, 4, 5}},
+		{input: "due:today", want: []uint{1, 5}},
+		{input: "due<today", want: []uint{2, 3}},
+		{input: "due<=2026-10-10", want: []uint{2, 3}},
+		{input: "due>2026-10-10", want: []uint{1, 5}},
+		{input: "due:none", want: []uint{4}},
+		{input: "-due:none", want: []uint{1, 2, 3, 5}},
+		{input: "due<now", want: []uint{1, 2, 3, 5}},
+		{input: "created>7d", want: []uint{1}},
+		{input: "created<7d", want: []uint{2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			query, err := ParseTaskQuery(tt.input)
+			if err != nil {
+				t.Fatalf("ParseTaskQuery(%q) error: %v", tt.input, err)
+			}
+			scoped, err := query.Apply(db.Model(&models.Task{}), ctx)
+			if err != nil {
+				t.Fatalf("Apply(%q) error: %v", tt.input, err)
+			}
+			ids := []uint{}
+			if err := scoped.Order("tasks.id ASC").Pluck("tasks.id", &ids).Error; err != nil {
+				t.Fatalf("Apply(%q) query error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("Apply(%q) = %v, want %v", tt.input, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskQueryApplyTimeOnDateField(t *testing.T) {
+	query, err := ParseTaskQuery("due:now")
+	if err != nil {
+		t.Fatalf("ParseTaskQuery error: %v", err)
+	}
+	_, err = query.Apply(&gorm.DB{}, TaskQueryContext{})
+	queryErr, ok := err.(*TaskQueryError)
+	if !ok || queryErr.Position != 1 {
+		t.Fatalf("Apply(due:now) error = %v, want *TaskQueryError at position 1", err)
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"project-manager-backend/models"
+	"project-manager-backend/utils"
+	"sort"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TaskRankService 任务排序键服务
+// 负责计算任务在阶段内的排序键，移动任务时只需要写入被移动的任务
+type TaskRankService struct{}
+
+// NewTaskRankService 创建任务排序键服务
+func NewTaskRankService() *TaskRankService {
+	return &TaskRankService{}
+}
+
+// stageRanks 按顺序读取阶段内任务的排序键（排除指定任务）
+func (s *TaskRankService) stageRanks(db *gorm.DB, stageID, excludeTaskID uint) ([]string, error) {
+	var ranks []string
+	if err := db.Model(&models.Task{}).
+		Where("stage_id = ? AND id <> ? AND "+TaskNotArchivedCondition, stageID, excludeTaskID).
+		Order("rank ASC, id ASC").
+		Pluck("rank", &ranks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load stage ranks: %v", err)
+	}
+	return ranks, nil
+}
+
+// RankForAppend 计算追加到阶段末尾的排序键，排序键过长时先重新平衡阶段
+func (s *TaskRankService) RankForAppend(db *gorm.DB, stageID uint) (string, error) {
+	rank, err := s.rankAfterLast(db, stageID)
+	if err != nil || !s.NeedsRebalance(rank) {
+		return rank, err
+	}
+
+	if err := s.RebalanceStage(db, stageID); err != nil {
+		return "", err
+	}
+	return s.rankAfterLast(db, stageID)
+}
+
+// rankAfterLast 计算阶段内最大排序键之后的排序键
+func (s *TaskRankService) rankAfterLast(db *gorm.DB, stageID uint) (string, error) {
+	var lastRank string
+	row := db.Model(&models.Task{}).Where("stage_id = ?", stageID).Select("COALESCE(MAX(rank), '')").Row()
+	if err := row.Scan(&lastRank); err != nil {
+		return "", fmt.Errorf("failed to load last rank: %v", err)
+	}
+	return utils.RankBetween(lastRank, "")
+}
+
+// RankAtIndex 计算插入到阶段第 index 个位置（从0开始）的排序键，用于兼容按位置移动的请求
+// index 小于0或超出范围时追加到末尾
+func (s *TaskRankService) RankAtIndex(db *gorm.DB, stageID uint, index int, excludeTaskID uint) (string, error) {
+	ranks, err := s.stageRanks(db, stageID, excludeTaskID)
+	if err != nil {
+		return "", err
+	}
+	if index < 0 || index > len(ranks) {
+		index = len(ranks)
+	}
+
+	prev, next := "", ""
+	if index > 0 {
+		prev = ranks[index-1]
+	}
+	if index < len(ranks) {
+		next = ranks[index]
+	}
+	return utils.RankBetween(prev, next)
+}
+
+// RankBetweenTasks 计算插入到 afterTaskID 之后、beforeTaskID 之前的排序键
+// 两者可以只传一个，另一侧的相邻任务会自动查找
+func (s *TaskRankService) RankBetweenTasks(db *gorm.DB, stageID uint, afterTaskID, beforeTaskID *uint, excludeTaskID uint) (string, error) {
+	prev, next := "", ""
+
+	if afterTaskID != nil {
+		var after models.Task
+		if err := db.Where("id = ? AND stage_id = ?", *afterTaskID, stageID).First(&after).Error; err != nil {
+			return "", fmt.Errorf("task %d is not in the target stage", *afterTaskID)
+		}
+		prev = after.Rank
+	}
+	if beforeTaskID != nil {
+		var before models.Task
+		if err := db.Where("id = ? AND stage_id = ?", *beforeTaskID, stageID).First(&before).Error; err != nil {
+			return "", fmt.Errorf("task %d is not in the target stage", *beforeTaskID)
+		}
+		next = before.Rank
+	}
+
+	// 查找另一侧紧邻的任务；两者都给出但不相邻时（例如客户端数据已过期）以 afterTaskID 为准
+	if afterTaskID != nil {
+		var neighbour models.Task
+		if err := db.Where("stage_id = ? AND rank > ? AND id NOT IN (?)", stageID, prev, []uint{excludeTaskID, *afterTaskID}).
+			Order("rank ASC, id ASC").First(&neighbour).Error; err == nil {
+			if beforeTaskID == nil || neighbour.Rank < next {
+				next = neighbour.Rank
+			}
+		}
+	} else if beforeTaskID != nil {
+		var neighbour models.Task
+		if err := db.Where("stage_id = ? AND rank < ? AND id NOT IN (?)", stageID, next, []uint{excludeTaskID, *beforeTaskID}).
+			Order("rank DESC, id DESC").First(&neighbour).Error; err == nil {
+			prev = neighbour.Rank
+		}
+	}
+
+	if next != "" && prev == next {
+		return "", utils.ErrInvalidRankRange
+	}
+	if next != "" && prev > next {
+		return "", fmt.Errorf("task %d must be ordered before task %d", *afterTaskID, *beforeTaskID)
+	}
+	return utils.RankBetween(prev, next)
+}
+
+// IndexOfRank 返回排序键在阶段内的位置（从0开始）
+func (s *TaskRankService) IndexOfRank(db *gorm.DB, stageID uint, rank string) (int, error) {
+	var count int
+	if err := db.Model(&models.Task{}).Where("stage_id = ? AND rank < ? AND "+TaskNotArchivedCondition, stageID, rank).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// NeedsRebalance 判断排序键是否过长，需要重新平衡阶段
+func (s *TaskRankService) NeedsRebalance(rank string) bool {
+	return len(rank) > utils.RankMaxLength
+}
+
+// RebalanceStage 按当前顺序为阶段内所有任务重新均匀分配排序键
+func (s *TaskRankService) RebalanceStage(db *gorm.DB, stageID uint) error {
+	var tasks []models.Task
+	if err := db.Select("id, rank, position").Where("stage_id = ?", stageID).
+		Order("rank ASC, position ASC, id ASC").Find(&tasks).Error; err != nil {
+		return fmt.Errorf("failed to load stage tasks: %v", err)
+	}
+
+	ranks := utils.RankSequence(len(tasks))
+	for i, task := range tasks {
+		if task.Rank == ranks[i] {
+			continue
+		}
+		if err := db.Model(&models.Task{}).Where("id = ?", task.ID).UpdateColumn("rank", ranks[i]).Error; err != nil {
+			return fmt.Errorf("failed to update rank of task %d: %v", task.ID, err)
+		}
+	}
+
+	log.Printf("Rebalanced ranks of %d tasks in stage %d", len(tasks), stageID)
+	return nil
+}
+
+// MigratePositionsToRanks 为还没有排序键的任务按原有 position 生成排序键，
+// 并重新平衡排序键过长的阶段
+func (s *TaskRankService) MigratePositionsToRanks(db *gorm.DB) error {
+	var stageIDs []uint
+	if err := db.Model(&models.Task{}).
+		Where("rank IS NULL OR rank = '' OR LENGTH(rank) > ?", utils.RankMaxLength).
+		Group("stage_id").
+		Pluck("stage_id", &stageIDs).Error; err != nil {
+		return fmt.Errorf("failed to find stages to migrate: %v", err)
+	}
+
+	for _, stageID := range stageIDs {
+		var unranked int
+		if err := db.Model(&models.Task{}).Where("stage_id = ? AND (rank IS NULL OR rank = '')", stageID).
+			Count(&unranked).Error; err != nil {
+			return fmt.Errorf("failed to count unranked tasks: %v", err)
+		}
+
+		// 只是排序键过长时保持现有顺序
+		if unranked == 0 {
+			if err := s.RebalanceStage(db, stageID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 存在旧数据时按原有 position 生成排序键
+		var tasks []models.Task
+		if err := db.Select("id, position").Where("stage_id = ?", stageID).
+			Order("position ASC, id ASC").Find(&tasks).Error; err != nil {
+			return fmt.Errorf("failed to load stage tasks: %v", err)
+		}
+
+		ranks := utils.RankSequence(len(tasks))
+		for i, task := range tasks {
+			if err := db.Model(&models.Task{}).Where("id = ?", task.ID).UpdateColumn("rank", ranks[i]).Error; err != nil {
+				return fmt.Errorf("failed to migrate rank of task %d: %v", task.ID, err)
+			}
+		}
+	}
+
+	if len(stageIDs) > 0 {
+		log.Printf("Migrated task ranks for %d stages", len(stageIDs))
+	}
+	return nil
+}
+
+// RankForMove 计算任务移动到目标阶段后的排序键
+// 给出 afterTaskID/beforeTaskID 时插入到两者之间，否则插入到 index 位置（小于0表示追加）；
+// 排序键过长或相邻排序键重复时先重新平衡阶段再计算
+func (s *TaskRankService) RankForMove(db *gorm.DB, stageID, taskID uint, afterTaskID, beforeTaskID *uint, index int) (string, error) {
+	compute := func() (string, error) {
+		if afterTaskID != nil || beforeTaskID != nil {
+			return s.RankBetweenTasks(db, stageID, afterTaskID, beforeTaskID, taskID)
+		}
+		return s.RankAtIndex(db, stageID, index, taskID)
+	}
+
+	rank, err := compute()
+	if err == nil && !s.NeedsRebalance(rank) {
+		return rank, nil
+	}
+	if err != nil && err != utils.ErrInvalidRankRange {
+		return "", err
+	}
+
+	if err := s.RebalanceStage(db, stageID); err != nil {
+		return "", err
+	}
+	return compute()
+}
+
+// rankedTask 阶段内任务的排序信息
+type rankedTask struct {
+	ID   uint
+	Rank string
+}
+
+// ReorderStage 按指定位置（从0开始）依次移动阶段内的任务，只写入被移动的任务
+// positions 为任务ID到目标位置的映射，位置越小的任务越先处理
+func (s *TaskRankService) ReorderStage(db *gorm.DB, stageID uint, positions map[uint]int) error {
+	plan := func() (map[uint]string, error) {
+		var tasks []rankedTask
+		if err := db.Model(&models.Task{}).Select("id, rank").Where("stage_id = ? AND "+TaskNotArchivedCondition, stageID).
+			Order("rank ASC, id ASC").Scan(&tasks).Error; err != nil {
+			return nil, fmt.Errorf("failed to load stage tasks: %v", err)
+		}
+		return planReorder(tasks, stageID, positions)
+	}
+
+	updates, err := plan()
+	if err == utils.ErrInvalidRankRange || (err == nil && s.hasLongRank(updates)) {
+		// 存在重复或过长的排序键时，重新平衡后再计算
+		if err := s.RebalanceStage(db, stageID); err != nil {
+			return err
+		}
+		updates, err = plan()
+	}
+	if err != nil {
+		return err
+	}
+
+	for taskID, rank := range updates {
+		if err := db.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("rank", rank).Error; err != nil {
+			return fmt.Errorf("failed to update rank of task %d: %v", taskID, err)
+		}
+	}
+	return nil
+}
+
+// planReorder 在内存中模拟移动，返回需要更新的任务排序键
+// tasks 为阶段内按顺序排列的任务，会被修改
+func planReorder(tasks []rankedTask, stageID uint, positions map[uint]int) (map[uint]string, error) {
+	taskIDs := make([]uint, 0, len(positions))
+	for taskID := range positions {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Slice(taskIDs, func(i, j int) bool {
+		if positions[taskIDs[i]] != positions[taskIDs[j]] {
+			return positions[taskIDs[i]] < positions[taskIDs[j]]
+		}
+		return taskIDs[i] < taskIDs[j]
+	})
+
+	updates := make(map[uint]string)
+	for _, taskID := range taskIDs {
+		current := -1
+		for i, task := range tasks {
+			if task.ID == taskID {
+				current = i
+				break
+			}
+		}
+		if current < 0 {
+			return nil, fmt.Errorf("task %d is not in stage %d", taskID, stageID)
+		}
+		moved := tasks[current]
+		tasks = append(tasks[:current], tasks[current+1:]...)
+
+		index := positions[taskID]
+		if index < 0 || index > len(tasks) {
+			index = len(tasks)
+		}
+		prev, next := "", ""
+		if index > 0 {
+			prev = tasks[index-1].Rank
+		}
+		if index < len(tasks) {
+			next = tasks[index].Rank
+		}
+		rank, err := utils.RankBetween(prev, next)
+		if err != nil {
+			return nil, err
+		}
+
+		moved.Rank = rank
+		tasks = append(tasks[:index], append([]rankedTask{moved}, tasks[index:]...)...)
+		updates[taskID] = rank
+	}
+	return updates, nil
+}
+
+func (s *TaskRankService) hasLongRank(updates map[uint]string) bool {
+	for _, rank := range updates {
+		if s.NeedsRebalance(rank) {
+			return true
+		}
+	}
+	return false
+}
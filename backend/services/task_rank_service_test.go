@@ -0,0 +1,137 @@
+package services
+
+import (
+	"project-manager-backend/utils"
+	"testing"
+)
+
+// stageOrder 应用 planReorder 的结果后按排序键返回任务ID顺序
+func stageOrder(tasks []rankedTask, updates map[uint]string) []uint {
+	ranks := make(map[uint]string, len(tasks))
+	order := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		ranks[task.ID] = task.Rank
+		if rank, ok := updates[task.ID]; ok {
+			ranks[task.ID] = rank
+		}
+		order = append(order, task.ID)
+	}
+	for i := 1; i < len(order); i++ {
+		for j := i; j > 0 && ranks[order[j]] < ranks[order[j-1]]; j-- {
+			order[j], order[j-1] = order[j-1], order[j]
+		}
+	}
+	return order
+}
+
+func TestPlanReorder(t *testing.T) {
+	stage := func() []rankedTask {
+		return []rankedTask{{ID: 1, Rank: "F"}, {ID: 2, Rank: "V"}, {ID: 3, Rank: "k"}, {ID: 4, Rank: "u"}}
+	}
+	tests := []struct {
+		name        string
+		tasks       []rankedTask
+		positions   map[uint]int
+		wantOrder   []uint
+		wantUpdated []uint
+		wantErr     bool
+		wantErrIs   error
+	}{
+		{
+			name:        "move to front",
+			tasks:       stage(),
+			positions:   map[uint]int{3: 0},
+			wantOrder:   []uint{3, 1, 2, 4},
+			wantUpdated: []uint{3},
+		},
+		{
+			name:        "move to end",
+			tasks:       stage(),
+			positions:   map[uint]int{1: 3},
+			wantOrder:   []uint{2, 3, 4, 1},
+			wantUpdated: []uint{1},
+		},
+		{
+			name:        "out of range appends",
+			tasks:       stage(),
+			positions:   map[uint]int{2: 99},
+			wantOrder:   []uint{1, 3, 4, 2},
+			wantUpdated: []uint{2},
+		},
+		{
+			name:        "negative appends",
+			tasks:       stage(),
+			positions:   map[uint]int{1: -1},
+			wantOrder:   []uint{2, 3, 4, 1},
+			wantUpdated: []uint{1},
+		},
+		{
+			name:        "several moves in position order",
+			tasks:       stage(),
+			positions:   map[uint]int{4: 0, 3: 1},
+			wantOrder:   []uint{4, 3, 1, 2},
+			wantUpdated: []uint{3, 4},
+		},
+		{
+			name:        "same position ordered by id",
+			tasks:       stage(),
+			positions:   map[uint]int{4: 1, 3: 1},
+			wantOrder:   []uint{1, 4, 3, 2},
+			wantUpdated: []uint{3, 4},
+		},
+		{
+			name:        "unchanged position still rewrites only that task",
+			tasks:       stage(),
+			positions:   map[uint]int{2: 1},
+			wantOrder:   []uint{1, 2, 3, 4},
+			wantUpdated: []uint{2},
+		},
+		{
+			name:      "task not in stage",
+			tasks:     stage(),
+			positions: map[uint]int{9: 0},
+			wantErr:   true,
+		},
+		{
+			name:      "duplicate neighbour ranks",
+			tasks:     []rankedTask{{ID: 1, Rank: "V"}, {ID: 2, Rank: "V"}, {ID: 3, Rank: "k"}},
+			positions: map[uint]int{3: 1},
+			wantErr:   true,
+			wantErrIs: utils.ErrInvalidRankRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := append([]rankedTask(nil), tt.tasks...)
+			updates, err := planReorder(tt.tasks, 1, tt.positions)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("planReorder() = %v, want error", updates)
+				}
+				if tt.wantErrIs != nil && err != tt.wantErrIs {
+					t.Fatalf("planReorder() error = %v, want %v", err, tt.wantErrIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planReorder() error: %v", err)
+			}
+
+			if len(updates) != len(tt.wantUpdated) {
+				t.Fatalf("planReorder() updated %d tasks, want %d: %v", len(updates), len(tt.wantUpdated), updates)
+			}
+			for _, id := range tt.wantUpdated {
+				if _, ok := updates[id]; !ok {
+					t.Errorf("planReorder() did not update task %d", id)
+				}
+			}
+
+			order := stageOrder(original, updates)
+			for i := range tt.wantOrder {
+				if order[i] != tt.wantOrder[i] {
+					t.Fatalf("order after reorder = %v, want %v", order, tt.wantOrder)
+				}
+			}
+		})
+	}
+}
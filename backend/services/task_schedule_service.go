@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"project-manager-backend/models"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ErrDependencyCycle 添加依赖后会形成循环
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// TaskScheduleService 任务排期服务（依赖关系、时间线和日期平移）
+type TaskScheduleService struct{}
+
+// NewTaskScheduleService 创建任务排期服务
+func NewTaskScheduleService() *TaskScheduleService {
+	return &TaskScheduleService{}
+}
+
+// TimelineTask 时间线中的任务条
+type TimelineTask struct {
+	ID          uint      `json:"id"`
+	Key         string    `json:"key"`
+	Title       string    `json:"title"`
+	Status      string    `json:"status"`
+	Priority    string    `json:"priority"`
+	AssigneeID  *uint     `json:"assignee_id"`
+	StageID     uint      `json:"stage_id"`
+	StageName   string    `json:"stage_name"`
+	StageColor  string    `json:"stage_color"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	HasStart    bool      `json:"has_start"`    // 是否设置了开始日期（未设置时开始日期取截止日期）
+	HasDueDate  bool      `json:"has_due_date"` // 是否设置了截止日期（未设置时截止日期取开始日期）
+	DueAllDay   bool      `json:"due_all_day"`
+	IsCompleted bool      `json:"is_completed"`
+}
+
+// TimelineEdge 时间线中的依赖连线
+type TimelineEdge struct {
+	ID       uint   `json:"id"`
+	From     uint   `json:"from"` // 前置任务
+	To       uint   `json:"to"`   // 后续任务
+	Type     string `json:"type"`
+	Violated bool   `json:"violated"` // 后续任务在前置任务结束前开始
+}
+
+// Timeline 项目时间线
+type Timeline struct {
+	ProjectID   uint           `json:"project_id"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Tasks       []TimelineTask `json:"tasks"`
+	Edges       []TimelineEdge `json:"edges"`
+	Unscheduled int            `json:"unscheduled"` // 没有任何日期的任务数量
+}
+
+// BuildTimeline 获取项目在 [from, to] 时间窗口内的任务条和依赖连线
+func (s *TaskScheduleService) BuildTimeline(db *gorm.DB, projectID uint, from, to time.Time) (*Timeline, error) {
+	var tasks []models.Task
+	if err := db.Preload("Stage").Where("project_id = ? AND "+TaskNotArchivedCondition, projectID).
+		Where("start_date IS NOT NULL OR due_date IS NOT NULL").Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %v", err)
+	}
+
+	timeline := &Timeline{
+		ProjectID: projectID,
+		From:      from,
+		To:        to,
+		Tasks:     []TimelineTask{},
+		Edges:     []TimelineEdge{},
+	}
+	if err := db.Model(&models.Task{}).Where("project_id = ? AND start_date IS NULL AND due_date IS NULL AND "+TaskNotArchivedCondition, projectID).
+		Count(&timeline.Unscheduled).Error; err != nil {
+		return nil, fmt.Errorf("failed to count unscheduled tasks: %v", err)
+	}
+
+	included := make(map[uint]TimelineTask)
+	for _, task := range tasks {
+		item := TimelineTask{
+			ID:         task.ID,
+			Key:        task.Key,
+			Title:      task.Title,
+			Status:     task.Status,
+			Priority:   task.Priority,
+			AssigneeID: task.AssigneeID,
+			StageID:    task.StageID,
+			HasStart:   task.StartDate != nil,
+			HasDueDate: task.DueDate != nil,
+			DueAllDay:  task.DueAllDay,
+		}
+		if task.StartDate != nil {
+			item.Start = *task.StartDate
+		} else {
+			item.Start = *task.DueDate
+		}
+		if task.DueDate != nil {
+			item.End = *task.DueDate
+		} else {
+			item.End = *task.StartDate
+		}
+		if task.Stage != nil {
+			item.StageName = task.Stage.Name
+			item.StageColor = task.Stage.Color
+			item.IsCompleted = task.Stage.IsCompleted
+		}
+
+		// 只返回与时间窗口有交集的任务
+		if item.End.Before(from) || item.Start.After(to) {
+			continue
+		}
+		timeline.Tasks = append(timeline.Tasks, item)
+		included[item.ID] = item
+	}
+	sort.SliceStable(timeline.Tasks, func(i, j int) bool {
+		return timeline.Tasks[i].Start.Before(timeline.Tasks[j].Start)
+	})
+
+	var dependencies []models.TaskDependency
+	if err := db.Where("project_id = ?", projectID).Order("id ASC").Find(&dependencies).Error; err != nil {
+		return nil, fmt.Errorf("failed to load dependencies: %v", err)
+	}
+	for _, dep := range dependencies {
+		from, hasFrom := included[dep.DependsOnTaskID]
+		to, hasTo := included[dep.TaskID]
+		if !hasFrom || !hasTo {
+			continue
+		}
+		timeline.Edges = append(timeline.Edges, TimelineEdge{
+			ID:       dep.ID,
+			From:     dep.DependsOnTaskID,
+			To:       dep.TaskID,
+			Type:     string(dep.Type),
+			Violated: to.Start.Before(from.End),
+		})
+	}
+
+	return timeline, nil
+}
+
+// AddDependency 添加依赖：task 依赖 dependsOn
+func (s *TaskScheduleService) AddDependency(db *gorm.DB, task, dependsOn *models.Task, userID uint) (*models.TaskDependency, error) {
+	if task.ID == dependsOn.ID {
+		return nil, errors.New("a task cannot depend on itself")
+	}
+	if task.ProjectID != dependsOn.ProjectID {
+		return nil, errors.New("dependencies must be within the same project")
+	}
+
+	var count int
+	db.Model(&models.TaskDependency{}).Where("task_id = ? AND depends_on_task_id = ?", task.ID, dependsOn.ID).Count(&count)
+	if count > 0 {
+		return nil, errors.New("dependency already exists")
+	}
+
+	// dependsOn 已经（间接）依赖 task 时会形成循环
+	upstream, err := s.collect(db, dependsOn.ID, "task_id", "depends_on_task_id")
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range upstream {
+		if id == task.ID {
+			return nil, ErrDependencyCycle
+		}
+	}
+
+	dependency := models.TaskDependency{
+		ProjectID:       task.ProjectID,
+		TaskID:          task.ID,
+		DependsOnTaskID: dependsOn.ID,
+		Type:            models.TaskDependencyFinishToStart,
+		CreatedBy:       userID,
+	}
+	if err := db.Create(&dependency).Error; err != nil {
+		return nil, fmt.Errorf("failed to create dependency: %v", err)
+	}
+	return &dependency, nil
+}
+
+// Dependents 获取（直接和间接）依赖该任务的所有后续任务ID
+func (s *TaskScheduleService) Dependents(db *gorm.DB, taskID uint) ([]uint, error) {
+	return s.collect(db, taskID, "depends_on_task_id", "task_id")
+}
+
+// collect 沿依赖关系广度优先遍历，from/to 为遍历方向上的列名
+func (s *TaskScheduleService) collect(db *gorm.DB, startID uint, fromColumn, toColumn string) ([]uint, error) {
+	visited := map[uint]bool{startID: true}
+	queue := []uint{startID}
+	var result []uint
+
+	for len(queue) > 0 {
+		var next []uint
+		if err := db.Model(&models.TaskDependency{}).Where(fromColumn+" IN (?)", queue).
+			Pluck(toColumn, &next).Error; err != nil {
+			return nil, fmt.Errorf("failed to load dependencies: %v", err)
+		}
+		queue = queue[:0]
+		for _, id := range next {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			result = append(result, id)
+			queue = append(queue, id)
+		}
+	}
+	return result, nil
+}
+
+// DateShift 单个任务的日期平移结果
+type DateShift struct {
+	TaskID       uint       `json:"task_id"`
+	Key          string     `json:"key"`
+	OldStartDate *time.Time `json:"old_start_date"`
+	NewStartDate *time.Time `json:"new_start_date"`
+	OldDueDate   *time.Time `json:"old_due_date"`
+	NewDueDate   *time.Time `json:"new_due_date"`
+}
+
+// ShiftViolation 平移后超出项目结束日期的任务
+type ShiftViolation struct {
+	TaskID  uint      `json:"task_id"`
+	Key     string    `json:"key"`
+	Date    time.Time `json:"date"`
+	EndDate time.Time `json:"project_end_date"`
+}
+
+// PlanShift 计算将任务（及可选的所有后续任务）平移 days 天后的日期
+// 没有任何日期的任务不受影响；向后平移后超出项目结束日期的任务以 violations 返回
+func (s *TaskScheduleService) PlanShift(db *gorm.DB, task *models.Task, project *models.Project, days int, includeDependents bool) ([]DateShift, []ShiftViolation, error) {
+	taskIDs := []uint{task.ID}
+	if includeDependents {
+		dependents, err := s.Dependents(db, task.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		taskIDs = append(taskIDs, dependents...)
+	}
+
+	var tasks []models.Task
+	if err := db.Where("id IN (?)", taskIDs).Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load tasks: %v", err)
+	}
+
+	shifts := []DateShift{}
+	violations := []ShiftViolation{}
+	for _, t := range tasks {
+		if t.StartDate == nil && t.DueDate == nil {
+			continue
+		}
+
+		shift := DateShift{TaskID: t.ID, Key: t.Key, OldStartDate: t.StartDate, OldDueDate: t.DueDate}
+		if t.StartDate != nil {
+			newStart := t.StartDate.AddDate(0, 0, days)
+			shift.NewStartDate = &newStart
+		}
+		if t.DueDate != nil {
+			newDue := t.DueDate.AddDate(0, 0, days)
+			shift.NewDueDate = &newDue
+		}
+		shifts = append(shifts, shift)
+
+		// 只有向后平移才会新增超期；已经超出结束日期的任务向前平移是在改善，不算违规
+		if project.EndDate != nil && days > 0 {
+			latest := shift.NewDueDate
+			if latest == nil {
+				latest = shift.NewStartDate
+			}
+			if latest.After(endOfDay(*project.EndDate)) {
+				violations = append(violations, ShiftViolation{TaskID: t.ID, Key: t.Key, Date: *latest, EndDate: *project.EndDate})
+			}
+		}
+	}
+	return shifts, violations, nil
+}
+
+// ApplyShift 写入平移后的日期
+func (s *TaskScheduleService) ApplyShift(db *gorm.DB, shifts []DateShift) error {
+	for _, shift := range shifts {
+		updates := map[string]interface{}{}
+		if shift.NewStartDate != nil {
+			updates["start_date"] = *shift.NewStartDate
+		}
+		if shift.NewDueDate != nil {
+			updates["due_date"] = *shift.NewDueDate
+		}
+		if err := db.Model(&models.Task{}).Where("id = ?", shift.TaskID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to shift task %d: %v", shift.TaskID, err)
+		}
+	}
+	return nil
+}
+
+// endOfDay 返回当天最后一刻，项目结束日期当天仍视为有效
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
+}
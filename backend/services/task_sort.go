@@ -0,0 +1,124 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/utils"
+	"strings"
+)
+
+// TaskSortKey 任务排序键
+type TaskSortKey struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// 可排序字段对应的排序表达式（不能为 NULL，以便用于游标分页）
+// 时间在数据库中以文本保存，空值用 '9999' 或 ” 替换，使其无论升序还是降序都排在最后
+var taskSortColumns = map[string]string{
+	"position":    "COALESCE(tasks.rank, '')", // 阶段内顺序，先按阶段顺序分组
+	"due_date":    "tasks.due_date",
+	"start_date":  "tasks.start_date",
+	"priority":    TaskPriorityLevelExpr, // 按项目定义的优先级级别，升序即紧急的在前
+	"updated_at":  "COALESCE(tasks.updated_at, '')",
+	"created_at":  "COALESCE(tasks.created_at, '')",
+	"title":       "COALESCE(tasks.title, '')",
+	"key":         "COALESCE(tasks.number, 0)",
+	"archived_at": "tasks.archived_at",
+}
+
+// 可以为空的列，空值总是排在最后
+var taskSortNullable = map[string]bool{
+	"due_date":    true,
+	"start_date":  true,
+	"archived_at": true,
+}
+
+// DefaultTaskSort 默认排序：按阶段内顺序
+const DefaultTaskSort = "position"
+
+// ParseTaskSort 解析排序字符串，多个键用逗号分隔
+// 每个键为 field、field:asc、field:desc 或 -field（降序），例如 due_date:asc,-priority
+func ParseTaskSort(spec string) ([]TaskSortKey, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = DefaultTaskSort
+	}
+
+	var keys []TaskSortKey
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := TaskSortKey{}
+		if strings.HasPrefix(part, "-") {
+			key.Desc = true
+			part = part[1:]
+		}
+		if i := strings.Index(part, ":"); i >= 0 {
+			switch strings.ToLower(part[i+1:]) {
+			case "asc":
+			case "desc":
+				key.Desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction %q, use asc or desc", part[i+1:])
+			}
+			part = part[:i]
+		}
+		key.Field = strings.ToLower(part)
+		if _, ok := taskSortColumns[key.Field]; !ok {
+			return nil, fmt.Errorf("unknown sort field %q", key.Field)
+		}
+		if seen[key.Field] {
+			continue
+		}
+		seen[key.Field] = true
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty sort")
+	}
+	return keys, nil
+}
+
+// FormatTaskSort 将排序键格式化为字符串
+func FormatTaskSort(keys []TaskSortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		direction := "asc"
+		if key.Desc {
+			direction = "desc"
+		}
+		parts[i] = key.Field + ":" + direction
+	}
+	return strings.Join(parts, ",")
+}
+
+// TaskSortColumns 将排序键转换为排序列，最后按任务ID保证顺序稳定
+func TaskSortColumns(keys []TaskSortKey) []utils.SortColumn {
+	var columns []utils.SortColumn
+	for _, key := range keys {
+		column := taskSortColumns[key.Field]
+		if key.Field == "position" {
+			columns = append(columns,
+				utils.SortColumn{Expr: "COALESCE((SELECT position FROM stages WHERE stages.id = tasks.stage_id), 0)", Desc: key.Desc},
+				utils.SortColumn{Expr: "tasks.stage_id", Desc: key.Desc},
+			)
+		}
+		if taskSortNullable[key.Field] {
+			if key.Desc {
+				column = "COALESCE(" + column + ", '')"
+			} else {
+				column = "COALESCE(" + column + ", '9999')"
+			}
+		}
+		columns = append(columns, utils.SortColumn{Expr: column, Desc: key.Desc})
+	}
+	return append(columns, utils.SortColumn{Expr: "tasks.id"})
+}
+
+// TaskKeyset 任务列表的分页排序列
+func TaskKeyset(keys []TaskSortKey) utils.Keyset {
+	return utils.Keyset{Table: "tasks", Columns: TaskSortColumns(keys)}
+}
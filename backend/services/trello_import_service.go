@@ -0,0 +1,609 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// TrelloImportService Trello 看板导入服务
+type TrelloImportService struct{}
+
+// NewTrelloImportService 创建 Trello 看板导入服务
+func NewTrelloImportService() *TrelloImportService {
+	return &TrelloImportService{}
+}
+
+// TrelloBoard Trello 看板导出的 JSON（只包含导入用到的字段）
+type TrelloBoard struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Desc       string            `json:"desc"`
+	Lists      []TrelloList      `json:"lists"`
+	Cards      []TrelloCard      `json:"cards"`
+	Labels     []TrelloLabel     `json:"labels"`
+	Checklists []TrelloChecklist `json:"checklists"`
+	Members    []TrelloMember    `json:"members"`
+	Actions    []TrelloAction    `json:"actions"`
+}
+
+// TrelloList Trello 列表
+type TrelloList struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Closed bool    `json:"closed"`
+	Pos    float64 `json:"pos"`
+}
+
+// TrelloCard Trello 卡片
+type TrelloCard struct {
+	ID               string     `json:"id"`
+	IDShort          int        `json:"idShort"` // 看板内的卡片序号
+	Name             string     `json:"name"`
+	Desc             string     `json:"desc"`
+	Closed           bool       `json:"closed"`
+	IDList           string     `json:"idList"`
+	Pos              float64    `json:"pos"`
+	Start            *time.Time `json:"start"`
+	Due              *time.Time `json:"due"`
+	DueComplete      bool       `json:"dueComplete"`
+	IDMembers        []string   `json:"idMembers"`
+	IDLabels         []string   `json:"idLabels"`
+	DateLastActivity *time.Time `json:"dateLastActivity"`
+}
+
+// TrelloLabel Trello 标签，名称可以为空（只有颜色）
+type TrelloLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// TrelloChecklist Trello 检查项清单
+type TrelloChecklist struct {
+	ID         string            `json:"id"`
+	IDCard     string            `json:"idCard"`
+	Name       string            `json:"name"`
+	Pos        float64           `json:"pos"`
+	CheckItems []TrelloCheckItem `json:"checkItems"`
+}
+
+// TrelloCheckItem Trello 检查项
+type TrelloCheckItem struct {
+	Name  string  `json:"name"`
+	State string  `json:"state"` // complete 或 incomplete
+	Pos   float64 `json:"pos"`
+}
+
+// TrelloMember Trello 成员
+type TrelloMember struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"fullName"`
+}
+
+// TrelloAction Trello 操作记录，只导入评论（commentCard）
+type TrelloAction struct {
+	ID              string       `json:"id"`
+	Type            string       `json:"type"`
+	Date            time.Time    `json:"date"`
+	IDMemberCreator string       `json:"idMemberCreator"`
+	MemberCreator   TrelloMember `json:"memberCreator"`
+	Data            struct {
+		Text string `json:"text"`
+		Card struct {
+			ID string `json:"id"`
+		} `json:"card"`
+	} `json:"data"`
+}
+
+// TrelloMemberMatch Trello 成员与本地用户的匹配结果
+type TrelloMemberMatch struct {
+	TrelloID string `json:"trello_id"`
+	Username string `json:"username"`
+	FullName string `json:"full_name"`
+	UserID   *uint  `json:"user_id"`  // 为空表示没有匹配到本地用户
+	Cards    int    `json:"cards"`    // 作为负责人的卡片数
+	Comments int    `json:"comments"` // 发表的评论数
+}
+
+// TrelloImportSummary 导入摘要；试运行时为看板中的数量
+type TrelloImportSummary struct {
+	DryRun         bool                 `json:"dry_run"`
+	Board          string               `json:"board"`
+	Stages         int                  `json:"stages"`
+	Labels         int                  `json:"labels"`
+	Tasks          int                  `json:"tasks"`
+	ArchivedTasks  int                  `json:"archived_tasks"`
+	Checklists     int                  `json:"checklists"`
+	ChecklistItems int                  `json:"checklist_items"`
+	Comments       int                  `json:"comments"`
+	Members        []*TrelloMemberMatch `json:"members"`
+	TaskIDs        map[string]uint      `json:"task_ids,omitempty"` // Trello 卡片ID到新任务ID
+	Warnings       []string             `json:"warnings,omitempty"`
+}
+
+// matchedUsers 匹配到本地用户的 Trello 成员ID到用户ID
+func (s *TrelloImportSummary) matchedUsers() map[string]uint {
+	users := make(map[string]uint)
+	for _, member := range s.Members {
+		if member.UserID != nil {
+			users[member.TrelloID] = *member.UserID
+		}
+	}
+	return users
+}
+
+// trelloLabelColors Trello 标签颜色对应的色值
+var trelloLabelColors = map[string]string{
+	"green":  "#61BD4F",
+	"yellow": "#F2D600",
+	"orange": "#FF9F1A",
+	"red":    "#EB5A46",
+	"purple": "#C377E0",
+	"blue":   "#0079BF",
+	"sky":    "#00C2E0",
+	"lime":   "#51E898",
+	"pink":   "#FF78CB",
+	"black":  "#344563",
+}
+
+// ValidateBoard 检查看板数据是否完整
+func (s *TrelloImportService) ValidateBoard(board *TrelloBoard) error {
+	if strings.TrimSpace(board.Name) == "" {
+		return fmt.Errorf("board name is required")
+	}
+	if len(board.Lists) == 0 {
+		return fmt.Errorf("board has no lists")
+	}
+	lists := make(map[string]bool, len(board.Lists))
+	for _, list := range board.Lists {
+		if list.ID == "" || strings.TrimSpace(list.Name) == "" {
+			return fmt.Errorf("list %q: id and name are required", list.ID)
+		}
+		lists[list.ID] = true
+	}
+	for _, card := range board.Cards {
+		if !lists[card.IDList] {
+			return fmt.Errorf("card %q: unknown list %q", card.Name, card.IDList)
+		}
+	}
+	return nil
+}
+
+// MatchMembers 匹配 Trello 成员和本地用户，并统计每个成员的卡片和评论数
+// userMap 可以把 Trello 用户名或成员ID映射到本地用户的邮箱或用户名，未映射的按相同用户名匹配
+func (s *TrelloImportService) MatchMembers(db *gorm.DB, board *TrelloBoard, userMap map[string]string) (*TrelloImportSummary, error) {
+	summary := &TrelloImportSummary{
+		Board:   board.Name,
+		Members: []*TrelloMemberMatch{},
+	}
+	members := make(map[string]*TrelloMemberMatch)
+	add := func(member TrelloMember) *TrelloMemberMatch {
+		if member.ID == "" {
+			return nil
+		}
+		if match, ok := members[member.ID]; ok {
+			return match
+		}
+		match := &TrelloMemberMatch{TrelloID: member.ID, Username: member.Username, FullName: member.FullName}
+		members[member.ID] = match
+		summary.Members = append(summary.Members, match)
+		return match
+	}
+	for _, member := range board.Members {
+		add(member)
+	}
+	for _, card := range board.Cards {
+		for _, id := range card.IDMembers {
+			if match := add(TrelloMember{ID: id}); match != nil {
+				match.Cards++
+			}
+		}
+	}
+	for _, action := range board.Actions {
+		if action.Type != "commentCard" {
+			continue
+		}
+		creator := action.MemberCreator
+		if creator.ID == "" {
+			creator.ID = action.IDMemberCreator
+		}
+		match := add(creator)
+		if match == nil {
+			continue
+		}
+		if match.Username == "" {
+			match.Username, match.FullName = creator.Username, creator.FullName
+		}
+		match.Comments++
+	}
+
+	mapped := make(map[string]string, len(userMap))
+	for key, value := range userMap {
+		mapped[strings.ToLower(strings.TrimSpace(key))] = strings.ToLower(strings.TrimSpace(value))
+	}
+	targetName := func(match *TrelloMemberMatch) string {
+		name := strings.ToLower(match.Username)
+		if target, ok := mapped[strings.ToLower(match.TrelloID)]; ok {
+			return target
+		}
+		if target, ok := mapped[name]; ok {
+			return target
+		}
+		return name
+	}
+	var names []string
+	for _, match := range summary.Members {
+		if name := targetName(match); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return summary, nil
+	}
+
+	var users []models.User
+	if err := db.Select("id, username, email").Where("LOWER(username) IN (?) OR LOWER(email) IN (?)", names, names).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	local := make(map[string]uint, len(users)*2)
+	for _, user := range users {
+		local[strings.ToLower(user.Username)] = user.ID
+	}
+	for _, user := range users {
+		local[strings.ToLower(user.Email)] = user.ID
+	}
+	for _, match := range summary.Members {
+		name := targetName(match)
+		if id, ok := local[name]; ok && name != "" {
+			match.UserID = &id
+		}
+	}
+	return summary, nil
+}
+
+// DryRun 不写入数据，按看板内容填写摘要中的数量
+func (s *TrelloImportService) DryRun(board *TrelloBoard, summary *TrelloImportSummary) {
+	summary.DryRun = true
+	summary.Stages = len(board.Lists)
+	summary.Labels = len(s.labelDefinitions(board))
+	summary.Tasks = len(board.Cards)
+	closedLists := s.closedLists(board)
+	for _, card := range board.Cards {
+		if card.Closed || closedLists[card.IDList] {
+			summary.ArchivedTasks++
+		}
+	}
+	summary.Checklists = len(board.Checklists)
+	for _, checklist := range board.Checklists {
+		summary.ChecklistItems += len(checklist.CheckItems)
+	}
+	for _, action := range board.Actions {
+		if action.Type == "commentCard" {
+			summary.Comments++
+		}
+	}
+}
+
+// ImportBoard 把看板导入到刚创建的 project 中，应在创建项目的事务中调用
+// 列表按顺序创建为阶段，卡片按列表内顺序创建为任务并保留 Trello 的卡片序号；
+// 检查项清单写入任务描述，已归档的卡片和已归档列表中的卡片导入为已归档的任务
+func (s *TrelloImportService) ImportBoard(db *gorm.DB, board *TrelloBoard, project *models.Project, summary *TrelloImportSummary, userID uint) ([]models.Task, error) {
+	summary.TaskIDs = make(map[string]uint, len(board.Cards))
+	users := summary.matchedUsers()
+
+	// 匹配到的成员加入项目
+	memberIDs := map[uint]bool{project.OwnerID: true}
+	for _, member := range summary.Members {
+		if member.UserID == nil || memberIDs[*member.UserID] {
+			continue
+		}
+		if err := db.Create(&models.ProjectMember{
+			ProjectID: project.ID,
+			UserID:    *member.UserID,
+			Role:      models.ProjectMemberRoleCollaborator,
+			InvitedBy: &userID,
+		}).Error; err != nil {
+			return nil, err
+		}
+		memberIDs[*member.UserID] = true
+	}
+
+	// 列表和标签按模板的方式创建
+	lists := append([]TrelloList(nil), board.Lists...)
+	sort.SliceStable(lists, func(i, j int) bool { return lists[i].Pos < lists[j].Pos })
+	def := &models.TemplateDefinition{Labels: s.labelDefinitions(board)}
+	for _, list := range lists {
+		def.Stages = append(def.Stages, models.TemplateStage{Ref: list.ID, Name: strings.TrimSpace(list.Name)})
+		if list.Closed {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("list %q is archived in Trello, its cards are imported as archived tasks", list.Name))
+		}
+	}
+	applied, err := NewProjectTemplateService().ApplyTemplate(db, project, def, userID)
+	if err != nil {
+		return nil, err
+	}
+	summary.Stages = len(applied.Stages)
+	summary.Labels = len(applied.Labels)
+	stages := make(map[string]*models.Stage, len(applied.Stages))
+	for i := range applied.Stages {
+		stages[lists[i].ID] = &applied.Stages[i]
+	}
+	labelIDs := make(map[string]uint, len(applied.Labels))
+	for _, label := range applied.Labels {
+		labelIDs[label.Name] = label.ID
+	}
+	trelloLabels := make(map[string]uint, len(board.Labels))
+	for _, label := range board.Labels {
+		trelloLabels[label.ID] = labelIDs[trelloLabelName(label)]
+	}
+
+	workflow, err := NewWorkflowService().GetWorkflow(db, project.ID)
+	if err != nil {
+		return nil, err
+	}
+	doneStatus := ""
+	for _, status := range workflow.Statuses {
+		if workflow.IsDone(status.Key) {
+			doneStatus = status.Key
+			break
+		}
+	}
+
+	checklists := make(map[string][]TrelloChecklist)
+	for _, checklist := range board.Checklists {
+		checklists[checklist.IDCard] = append(checklists[checklist.IDCard], checklist)
+	}
+
+	// 卡片序号在看板内唯一，重复或缺失时在最大序号之后编号
+	cards := append([]TrelloCard(nil), board.Cards...)
+	listOrder := make(map[string]int, len(lists))
+	for i, list := range lists {
+		listOrder[list.ID] = i
+	}
+	sort.SliceStable(cards, func(i, j int) bool {
+		if listOrder[cards[i].IDList] != listOrder[cards[j].IDList] {
+			return listOrder[cards[i].IDList] < listOrder[cards[j].IDList]
+		}
+		return cards[i].Pos < cards[j].Pos
+	})
+	maxNumber := 0
+	for _, card := range cards {
+		if card.IDShort > maxNumber {
+			maxNumber = card.IDShort
+		}
+	}
+	numbers := make(map[int]bool, len(cards))
+
+	closedLists := s.closedLists(board)
+	rankService := NewTaskRankService()
+	now := time.Now()
+	tasks := make([]models.Task, 0, len(cards))
+	for _, card := range cards {
+		stage := stages[card.IDList]
+		rank, err := rankService.RankForAppend(db, stage.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		number := card.IDShort
+		if number <= 0 || numbers[number] {
+			maxNumber++
+			number = maxNumber
+		}
+		numbers[number] = true
+
+		var assigneeID *uint
+		for _, id := range card.IDMembers {
+			if userID, ok := users[id]; ok {
+				assigneeID = &userID
+				break
+			}
+		}
+		if len(card.IDMembers) > 1 {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("card %q has %d members, only one is kept as assignee", card.Name, len(card.IDMembers)))
+		}
+
+		description, items := trelloCardDescription(card, checklists[card.ID])
+		summary.Checklists += len(checklists[card.ID])
+		summary.ChecklistItems += items
+
+		task := models.Task{
+			StageID:     stage.ID,
+			ProjectID:   project.ID,
+			Title:       strings.TrimSpace(card.Name),
+			Description: description,
+			Status:      workflow.DefaultStatus(),
+			Priority:    workflow.DefaultPriority(),
+			AssigneeID:  assigneeID,
+			StartDate:   trelloDay(card.Start),
+			DueDate:     card.Due,
+			Rank:        rank,
+			Number:      number,
+			Key:         FormatTaskKey(project.KeyPrefix, number),
+			CreatedBy:   userID,
+		}
+		if task.Title == "" {
+			task.Title = "Untitled card"
+		}
+		if task.DueDate != nil {
+			due := task.DueDate.UTC()
+			task.DueDate = &due
+		}
+		lastActivity := now
+		if card.DateLastActivity != nil {
+			lastActivity = *card.DateLastActivity
+		}
+		if card.DueComplete && doneStatus != "" {
+			task.Status = doneStatus
+		}
+		if IsTaskDone(workflow, task.Status, stage) {
+			task.CompletedAt = &lastActivity
+		}
+		if card.Closed || closedLists[card.IDList] {
+			task.ArchivedAt = &lastActivity
+			summary.ArchivedTasks++
+		}
+		if err := db.Create(&task).Error; err != nil {
+			return nil, err
+		}
+		createdAt := trelloIDTime(card.ID)
+		if err := restoreTimestamps(db, &task, createdAt, lastActivity); err != nil {
+			return nil, err
+		}
+		if !createdAt.IsZero() {
+			task.CreatedAt, task.UpdatedAt = createdAt, lastActivity
+		}
+
+		added := make(map[uint]bool)
+		for _, id := range card.IDLabels {
+			labelID := trelloLabels[id]
+			if labelID == 0 || added[labelID] {
+				continue
+			}
+			added[labelID] = true
+			if err := db.Create(&models.TaskLabel{TaskID: task.ID, LabelID: labelID}).Error; err != nil {
+				return nil, err
+			}
+		}
+		summary.TaskIDs[card.ID] = task.ID
+		tasks = append(tasks, task)
+	}
+	summary.Tasks = len(tasks)
+	if err := db.Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumn("task_seq", maxNumber).Error; err != nil {
+		return nil, err
+	}
+
+	// 评论按时间顺序导入，没有匹配到本地用户的评论记为导入人发表并注明原作者
+	actions := append([]TrelloAction(nil), board.Actions...)
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Date.Before(actions[j].Date) })
+	for _, action := range actions {
+		if action.Type != "commentCard" {
+			continue
+		}
+		taskID, ok := summary.TaskIDs[action.Data.Card.ID]
+		if !ok || strings.TrimSpace(action.Data.Text) == "" {
+			continue
+		}
+		creator := action.MemberCreator.ID
+		if creator == "" {
+			creator = action.IDMemberCreator
+		}
+		comment := models.Comment{TaskID: taskID, UserID: userID, Content: action.Data.Text}
+		if id, ok := users[creator]; ok {
+			comment.UserID = id
+		} else {
+			author := action.MemberCreator.FullName
+			if author == "" {
+				author = action.MemberCreator.Username
+			}
+			if author == "" {
+				author = "unknown"
+			}
+			comment.Content = fmt.Sprintf("[Trello: %s] %s", author, action.Data.Text)
+		}
+		if err := db.Create(&comment).Error; err != nil {
+			return nil, err
+		}
+		if err := restoreTimestamps(db, &comment, action.Date, action.Date); err != nil {
+			return nil, err
+		}
+		summary.Comments++
+	}
+	return tasks, nil
+}
+
+// labelDefinitions 看板标签转为模板标签，只有颜色的标签以颜色命名，同名标签合并
+func (s *TrelloImportService) labelDefinitions(board *TrelloBoard) []models.TemplateLabel {
+	var labels []models.TemplateLabel
+	seen := make(map[string]bool)
+	for _, label := range board.Labels {
+		name := trelloLabelName(label)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		labels = append(labels, models.TemplateLabel{Name: name, Color: trelloLabelColors[label.Color]})
+	}
+	return labels
+}
+
+// closedLists 已归档的列表
+func (s *TrelloImportService) closedLists(board *TrelloBoard) map[string]bool {
+	closed := make(map[string]bool)
+	for _, list := range board.Lists {
+		if list.Closed {
+			closed[list.ID] = true
+		}
+	}
+	return closed
+}
+
+// trelloLabelName 标签名称，不超过 50 字节
+func trelloLabelName(label TrelloLabel) string {
+	name := strings.TrimSpace(label.Name)
+	if name == "" {
+		name = label.Color
+	}
+	if name == "" {
+		name = "label"
+	}
+	for len(name) > 50 {
+		runes := []rune(name)
+		name = string(runes[:len(runes)-1])
+	}
+	return name
+}
+
+// trelloCardDescription 卡片描述加上检查项清单（Markdown 任务列表），返回描述和检查项数量
+func trelloCardDescription(card TrelloCard, checklists []TrelloChecklist) (string, int) {
+	sort.SliceStable(checklists, func(i, j int) bool { return checklists[i].Pos < checklists[j].Pos })
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(card.Desc))
+	items := 0
+	for _, checklist := range checklists {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString("### " + strings.TrimSpace(checklist.Name) + "\n")
+		checkItems := append([]TrelloCheckItem(nil), checklist.CheckItems...)
+		sort.SliceStable(checkItems, func(i, j int) bool { return checkItems[i].Pos < checkItems[j].Pos })
+		for _, item := range checkItems {
+			mark := " "
+			if item.State == "complete" {
+				mark = "x"
+			}
+			b.WriteString("\n- [" + mark + "] " + strings.TrimSpace(item.Name))
+			items++
+		}
+	}
+	return b.String(), items
+}
+
+// trelloDay 开始日期只保留日期（UTC 零点），与任务开始日期的存储方式一致
+func trelloDay(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	day := time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+	return &day
+}
+
+// trelloIDTime Trello ID 的前 8 位十六进制是创建时间的 Unix 秒数，解析失败时返回零值
+func trelloIDTime(id string) time.Time {
+	if len(id) < 8 {
+		return time.Time{}
+	}
+	seconds, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
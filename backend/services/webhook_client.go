@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// webhook 地址限制
+//
+// 自动化规则的 webhook 由项目管理员配置，请求从服务器发出。为防止借此访问服务器所在的内网
+// （包括云服务器的元数据地址 169.254.169.254），默认拒绝回环、私有、链路本地、未指定和组播地址。
+// 检查在建立连接时针对实际连接的地址进行，重定向和 DNS 重绑定都无法绕过；
+// 确实需要调用内网服务时，可以通过 AUTOMATION_WEBHOOK_ALLOWLIST 放行指定的地址或网段。
+
+// webhookBlockedNets 除 net.IP 自带判断外额外拒绝的网段
+var webhookBlockedNets = mustParseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15")
+
+var (
+	webhookAllowlistMu sync.RWMutex
+	webhookAllowlist   []*net.IPNet
+)
+
+// SetWebhookAllowlist 设置 webhook 允许访问的内网地址或网段（如 10.0.0.5、10.1.0.0/16）
+func SetWebhookAllowlist(entries []string) error {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return fmt.Errorf("invalid webhook allowlist entry %q", entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return fmt.Errorf("invalid webhook allowlist entry %q", entry)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	webhookAllowlistMu.Lock()
+	webhookAllowlist = nets
+	webhookAllowlistMu.Unlock()
+	return nil
+}
+
+// webhookAddressAllowed webhook 能否连接该地址
+func webhookAddressAllowed(ip net.IP) bool {
+	webhookAllowlistMu.RLock()
+	for _, ipNet := range webhookAllowlist {
+		if ipNet.Contains(ip) {
+			webhookAllowlistMu.RUnlock()
+			return true
+		}
+	}
+	webhookAllowlistMu.RUnlock()
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, ipNet := range webhookBlockedNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckWebhookURL 校验 webhook 地址格式，并拒绝指向或解析到受限地址的主机
+// 创建规则时用于提前提示，发送时仍会在连接阶段再次检查
+func CheckWebhookURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("invalid webhook url %q", raw)
+	}
+
+	host := parsed.Hostname()
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		// 暂时无法解析的主机在发送时检查
+		ips, _ = net.LookupIP(host)
+	}
+	for _, ip := range ips {
+		if !webhookAddressAllowed(ip) {
+			return fmt.Errorf("webhook url %q points to a private or local address %s", raw, ip)
+		}
+	}
+	return nil
+}
+
+// webhookDialControl 在建立连接前检查实际连接的地址
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !webhookAddressAllowed(ip) {
+		return fmt.Errorf("webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+// newWebhookClient 创建发送 webhook 的 HTTP 客户端：不使用代理，连接受限地址时失败，最多跟随 3 次重定向
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: webhookDialControl}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			return nil
+		},
+	}
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = ipNet
+	}
+	return nets
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 在制品（WIP）限制
+//
+// 阶段的 max_tasks 限制阶段内（未归档）的任务总数，max_tasks_per_assignee 限制阶段内同一负责人的任务数。
+// 硬限制（hard）下超出上限的操作被拒绝，软限制（soft）下操作照常完成并返回警告。
+// 两种情况都会写入 wip_violations，用于违规报表。
+
+// WIPService 在制品限制服务
+type WIPService struct{}
+
+// NewWIPService 创建在制品限制服务
+func NewWIPService() *WIPService {
+	return &WIPService{}
+}
+
+// WIPResult 在制品限制检查结果
+type WIPResult struct {
+	Mode       models.WIPMode        `json:"mode"`
+	Violations []models.WIPViolation `json:"violations"`
+}
+
+// Blocked 是否因硬限制拒绝操作
+func (r *WIPResult) Blocked() bool {
+	return r != nil && r.Mode == models.WIPModeHard && len(r.Violations) > 0
+}
+
+// Warnings 软限制下放行的违规，没有时为 nil
+func (r *WIPResult) Warnings() []models.WIPViolation {
+	if r == nil || r.Blocked() || len(r.Violations) == 0 {
+		return nil
+	}
+	return r.Violations
+}
+
+// Error 第一条违规的说明
+func (r *WIPResult) Error() string {
+	if r == nil || len(r.Violations) == 0 {
+		return ""
+	}
+	message := WIPViolationMessage(&r.Violations[0])
+	if len(r.Violations) > 1 {
+		message = fmt.Sprintf("%s (and %d more)", message, len(r.Violations)-1)
+	}
+	return message
+}
+
+// WIPViolationMessage 违规说明
+func WIPViolationMessage(v *models.WIPViolation) string {
+	if v.Scope == models.WIPScopeAssignee {
+		return fmt.Sprintf("Assignee has reached maximum task limit in this stage (%d/%d)", v.Count, v.Limit)
+	}
+	return fmt.Sprintf("Stage has reached maximum task limit (%d/%d)", v.Count, v.Limit)
+}
+
+// StageMode 阶段的限制模式，未设置时按硬限制处理
+func StageMode(stage *models.Stage) models.WIPMode {
+	if stage.WIPMode == models.WIPModeSoft {
+		return models.WIPModeSoft
+	}
+	return models.WIPModeHard
+}
+
+// ValidMode 检查限制模式取值
+func ValidMode(mode models.WIPMode) bool {
+	return mode == models.WIPModeHard || mode == models.WIPModeSoft
+}
+
+// CheckEntry 检查一批任务进入阶段后是否超出上限
+// assignees 为进入阶段的每个任务的负责人（未分配为 nil），调用方应排除已在该阶段的任务
+func (s *WIPService) CheckEntry(db *gorm.DB, stage *models.Stage, assignees []*uint, action string, userID uint) (*WIPResult, error) {
+	result := &WIPResult{Mode: StageMode(stage)}
+	if len(assignees) == 0 {
+		return result, nil
+	}
+
+	if stage.MaxTasks > 0 {
+		var count int
+		if err := db.Model(&models.Task{}).Where("stage_id = ? AND "+TaskNotArchivedCondition, stage.ID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count+len(assignees) > stage.MaxTasks {
+			result.Violations = append(result.Violations, s.violation(stage, models.WIPScopeStage, nil, count+len(assignees), action, userID))
+		}
+	}
+
+	incoming := make(map[uint]int)
+	var order []uint
+	for _, assigneeID := range assignees {
+		if assigneeID == nil || *assigneeID == 0 {
+			continue
+		}
+		if incoming[*assigneeID] == 0 {
+			order = append(order, *assigneeID)
+		}
+		incoming[*assigneeID]++
+	}
+	for _, assigneeID := range order {
+		violation, err := s.checkAssignee(db, stage, assigneeID, incoming[assigneeID], action, userID)
+		if err != nil {
+			return nil, err
+		}
+		if violation != nil {
+			result.Violations = append(result.Violations, *violation)
+		}
+	}
+	return result, nil
+}
+
+// CheckAssign 检查阶段内的任务改派给负责人后是否超出负责人上限
+func (s *WIPService) CheckAssign(db *gorm.DB, stage *models.Stage, assigneeID *uint, action string, userID uint) (*WIPResult, error) {
+	result := &WIPResult{Mode: StageMode(stage)}
+	if assigneeID == nil || *assigneeID == 0 {
+		return result, nil
+	}
+	violation, err := s.checkAssignee(db, stage, *assigneeID, 1, action, userID)
+	if err != nil {
+		return nil, err
+	}
+	if violation != nil {
+		result.Violations = append(result.Violations, *violation)
+	}
+	return result, nil
+}
+
+func (s *WIPService) checkAssignee(db *gorm.DB, stage *models.Stage, assigneeID uint, adding int, action string, userID uint) (*models.WIPViolation, error) {
+	if stage.MaxTasksPerAssignee <= 0 {
+		return nil, nil
+	}
+	var count int
+	if err := db.Model(&models.Task{}).Where("stage_id = ? AND assignee_id = ? AND "+TaskNotArchivedCondition, stage.ID, assigneeID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count+adding <= stage.MaxTasksPerAssignee {
+		return nil, nil
+	}
+	id := assigneeID
+	violation := s.violation(stage, models.WIPScopeAssignee, &id, count+adding, action, userID)
+	return &violation, nil
+}
+
+func (s *WIPService) violation(stage *models.Stage, scope models.WIPScope, assigneeID *uint, count int, action string, userID uint) models.WIPViolation {
+	limit := stage.MaxTasks
+	if scope == models.WIPScopeAssignee {
+		limit = stage.MaxTasksPerAssignee
+	}
+	return models.WIPViolation{
+		ProjectID:  stage.ProjectID,
+		StageID:    stage.ID,
+		AssigneeID: assigneeID,
+		Scope:      scope,
+		Mode:       StageMode(stage),
+		Limit:      limit,
+		Count:      count,
+		Action:     action,
+		UserID:     userID,
+	}
+}
+
+// Record 保存检查结果中的违规；taskID 为 0 表示不关联任务
+func (s *WIPService) Record(db *gorm.DB, result *WIPResult, taskID uint) error {
+	if result == nil {
+		return nil
+	}
+	blocked := result.Blocked()
+	for i := range result.Violations {
+		violation := result.Violations[i]
+		violation.Blocked = blocked
+		if taskID != 0 {
+			id := taskID
+			violation.TaskID = &id
+		}
+		if err := db.Create(&violation).Error; err != nil {
+			return err
+		}
+		result.Violations[i] = violation
+	}
+	return nil
+}
+
+// StageWIP 阶段当前的在制品情况
+type StageWIP struct {
+	StageID             uint           `json:"stage_id"`
+	StageName           string         `json:"stage_name"`
+	Mode                models.WIPMode `json:"wip_mode"`
+	MaxTasks            int            `json:"max_tasks"`
+	MaxTasksPerAssignee int            `json:"max_tasks_per_assignee"`
+	TaskCount           int            `json:"task_count"`
+	OverLimit           bool           `json:"over_limit"`
+	Assignees           []AssigneeWIP  `json:"assignees"`
+	History             WIPHistory     `json:"history"`
+}
+
+// AssigneeWIP 阶段内负责人的在制品情况
+type AssigneeWIP struct {
+	AssigneeID uint   `json:"assignee_id"`
+	Username   string `json:"username"`
+	TaskCount  int    `json:"task_count"`
+	OverLimit  bool   `json:"over_limit"`
+}
+
+// WIPHistory 阶段的历史违规统计
+type WIPHistory struct {
+	Total           int        `json:"total"`
+	Blocked         int        `json:"blocked"`
+	Warned          int        `json:"warned"`
+	LastViolationAt *time.Time `json:"last_violation_at"`
+}
+
+// StageReport 项目各阶段的当前在制品情况和 since 之后的历史违规统计
+func (s *WIPService) StageReport(db *gorm.DB, projectID uint, since *time.Time) ([]StageWIP, error) {
+	var stages []models.Stage
+	if err := db.Where("project_id = ?", projectID).Order("position ASC").Find(&stages).Error; err != nil {
+		return nil, err
+	}
+
+	var stageCounts []struct {
+		StageID uint
+		Count   int
+	}
+	if err := db.Model(&models.Task{}).Select("stage_id, COUNT(*) AS count").
+		Where("project_id = ? AND "+TaskNotArchivedCondition, projectID).Group("stage_id").Scan(&stageCounts).Error; err != nil {
+		return nil, err
+	}
+	countByStage := make(map[uint]int, len(stageCounts))
+	for _, row := range stageCounts {
+		countByStage[row.StageID] = row.Count
+	}
+
+	var assigneeCounts []struct {
+		StageID    uint
+		AssigneeID uint
+		Username   string
+		Count      int
+	}
+	if err := db.Table("tasks").
+		Select("tasks.stage_id, tasks.assignee_id, users.username, COUNT(*) AS count").
+		Joins("LEFT JOIN users ON users.id = tasks.assignee_id").
+		Where("tasks.project_id = ? AND tasks.assignee_id IS NOT NULL AND "+TaskNotArchivedCondition, projectID).
+		Group("tasks.stage_id, tasks.assignee_id, users.username").
+		Order("count DESC, tasks.assignee_id ASC").
+		Scan(&assigneeCounts).Error; err != nil {
+		return nil, err
+	}
+
+	// 历史违规按阶段汇总
+	var violations []models.WIPViolation
+	query := db.Where("project_id = ?", projectID)
+	if since != nil {
+		query = query.Where("created_at >= ?", *since)
+	}
+	if err := query.Select("stage_id, blocked, created_at").Find(&violations).Error; err != nil {
+		return nil, err
+	}
+	history := make(map[uint]*WIPHistory)
+	for i := range violations {
+		v := &violations[i]
+		h := history[v.StageID]
+		if h == nil {
+			h = &WIPHistory{}
+			history[v.StageID] = h
+		}
+		h.Total++
+		if v.Blocked {
+			h.Blocked++
+		} else {
+			h.Warned++
+		}
+		if h.LastViolationAt == nil || v.CreatedAt.After(*h.LastViolationAt) {
+			at := v.CreatedAt
+			h.LastViolationAt = &at
+		}
+	}
+
+	report := make([]StageWIP, 0, len(stages))
+	for i := range stages {
+		stage := &stages[i]
+		item := StageWIP{
+			StageID:             stage.ID,
+			StageName:           stage.Name,
+			Mode:                StageMode(stage),
+			MaxTasks:            stage.MaxTasks,
+			MaxTasksPerAssignee: stage.MaxTasksPerAssignee,
+			TaskCount:           countByStage[stage.ID],
+			Assignees:           []AssigneeWIP{},
+		}
+		item.OverLimit = stage.MaxTasks > 0 && item.TaskCount > stage.MaxTasks
+		for _, row := range assigneeCounts {
+			if row.StageID != stage.ID {
+				continue
+			}
+			over := stage.MaxTasksPerAssignee > 0 && row.Count > stage.MaxTasksPerAssignee
+			item.OverLimit = item.OverLimit || over
+			item.Assignees = append(item.Assignees, AssigneeWIP{
+				AssigneeID: row.AssigneeID,
+				Username:   row.Username,
+				TaskCount:  row.Count,
+				OverLimit:  over,
+			})
+		}
+		if h := history[stage.ID]; h != nil {
+			item.History = *h
+		}
+		report = append(report, item)
+	}
+	return report, nil
+}
+
+// RecentViolations 项目最近的违规记录，可按阶段过滤
+func (s *WIPService) RecentViolations(db *gorm.DB, projectID, stageID uint, since *time.Time, limit int) ([]models.WIPViolation, error) {
+	query := db.Where("project_id = ?", projectID)
+	if stageID != 0 {
+		query = query.Where("stage_id = ?", stageID)
+	}
+	if since != nil {
+		query = query.Where("created_at >= ?", *since)
+	}
+	var violations []models.WIPViolation
+	err := query.Order("id DESC").Limit(limit).Find(&violations).Error
+	return violations, err
+}
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"fmt"
+	"project-manager-backend/models"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// WorkflowService 项目工作流服务
+// 每个项目定义自己的任务状态（带分类）和优先级（带级别和颜色），
+// 任务的 Status/Priority 保存定义中的 key，统计和完成判断按状态分类进行
+type WorkflowService struct{}
+
+// NewWorkflowService 创建工作流服务
+func NewWorkflowService() *WorkflowService {
+	return &WorkflowService{}
+}
+
+// DefaultWorkflowStatuses 默认任务状态（与旧版本固定使用的取值一致）
+func DefaultWorkflowStatuses() []models.WorkflowStatus {
+	return []models.WorkflowStatus{
+		{Key: "todo", Name: "待办", Category: models.StatusCategoryTodo, Color: "#909399", IsDefault: true},
+		{Key: "in_progress", Name: "进行中", Category: models.StatusCategoryInProgress, Color: "#409EFF"},
+		{Key: "review", Name: "审核中", Category: models.StatusCategoryInProgress, Color: "#E6A23C"},
+		{Key: "done", Name: "已完成", Category: models.StatusCategoryDone, Color: "#67C23A"},
+	}
+}
+
+// DefaultWorkflowPriorities 默认任务优先级
+func DefaultWorkflowPriorities() []models.WorkflowPriority {
+	return []models.WorkflowPriority{
+		{Key: "P0", Name: "紧急", Color: "#F56C6C"},
+		{Key: "P1", Name: "高", Color: "#E6A23C"},
+		{Key: "P2", Name: "中", Color: "#409EFF", IsDefault: true},
+		{Key: "P3", Name: "低", Color: "#909399"},
+	}
+}
+
+// Workflow 项目的任务状态和优先级定义
+type Workflow struct {
+	ProjectID  uint                      `json:"project_id"`
+	Statuses   []models.WorkflowStatus   `json:"statuses"`
+	Priorities []models.WorkflowPriority `json:"priorities"`
+}
+
+// Status 查找状态定义
+func (w *Workflow) Status(key string) *models.WorkflowStatus {
+	for i := range w.Statuses {
+		if w.Statuses[i].Key == key {
+			return &w.Statuses[i]
+		}
+	}
+	return nil
+}
+
+// Priority 查找优先级定义
+func (w *Workflow) Priority(key string) *models.WorkflowPriority {
+	for i := range w.Priorities {
+		if w.Priorities[i].Key == key {
+			return &w.Priorities[i]
+		}
+	}
+	return nil
+}
+
+// DefaultStatus 新任务的默认状态
+func (w *Workflow) DefaultStatus() string {
+	for _, status := range w.Statuses {
+		if status.IsDefault {
+			return status.Key
+		}
+	}
+	if len(w.Statuses) > 0 {
+		return w.Statuses[0].Key
+	}
+	return "todo"
+}
+
+// DefaultPriority 新任务的默认优先级
+func (w *Workflow) DefaultPriority() string {
+	for _, priority := range w.Priorities {
+		if priority.IsDefault {
+			return priority.Key
+		}
+	}
+	if len(w.Priorities) > 0 {
+		return w.Priorities[0].Key
+	}
+	return "P2"
+}
+
+// Category 状态所属分类，未定义的状态按未开始处理
+func (w *Workflow) Category(status string) models.StatusCategory {
+	if definition := w.Status(status); definition != nil {
+		return definition.Category
+	}
+	return models.StatusCategoryTodo
+}
+
+// IsDone 状态是否属于已完成分类
+func (w *Workflow) IsDone(status string) bool {
+	return w.Category(status) == models.StatusCategoryDone
+}
+
+// ValidateStatus 检查状态是否为项目定义的状态
+func (w *Workflow) ValidateStatus(status string) error {
+	if w.Status(status) == nil {
+		return fmt.Errorf("invalid status %q, allowed: %s", status, strings.Join(w.statusKeys(), ", "))
+	}
+	return nil
+}
+
+// ValidatePriority 检查优先级是否为项目定义的优先级
+func (w *Workflow) ValidatePriority(priority string) error {
+	if w.Priority(priority) == nil {
+		keys := make([]string, len(w.Priorities))
+		for i, p := range w.Priorities {
+			keys[i] = p.Key
+		}
+		return fmt.Errorf("invalid priority %q, allowed: %s", priority, strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+func (w *Workflow) statusKeys() []string {
+	keys := make([]string, len(w.Statuses))
+	for i, s := range w.Statuses {
+		keys[i] = s.Key
+	}
+	return keys
+}
+
+// TaskStatusCategoryCondition 任务状态属于指定分类的 SQL 条件（作用于 tasks 表）
+// 未定义的状态按未开始处理，与 Workflow.Category 一致
+func TaskStatusCategoryCondition(category models.StatusCategory) string {
+	if category == models.StatusCategoryTodo {
+		return "tasks.status NOT IN (SELECT status_key FROM workflow_statuses WHERE workflow_statuses.project_id = tasks.project_id AND workflow_statuses.category IN ('in_progress', 'done'))"
+	}
+	return fmt.Sprintf("tasks.status IN (SELECT status_key FROM workflow_statuses WHERE workflow_statuses.project_id = tasks.project_id AND workflow_statuses.category = '%s')", category)
+}
+
+// TaskPriorityLevelExpr 任务优先级级别的 SQL 表达式，未定义的优先级排在最后
+const TaskPriorityLevelExpr = "COALESCE((SELECT level FROM workflow_priorities WHERE workflow_priorities.project_id = tasks.project_id AND workflow_priorities.priority_key = tasks.priority), 1000)"
+
+// GetWorkflow 获取项目工作流，项目还没有定义时创建默认定义
+func (s *WorkflowService) GetWorkflow(db *gorm.DB, projectID uint) (*Workflow, error) {
+	if err := s.EnsureWorkflow(db, projectID); err != nil {
+		return nil, err
+	}
+
+	workflow := &Workflow{ProjectID: projectID}
+	if err := db.Where("project_id = ?", projectID).Order("position ASC, id ASC").Find(&workflow.Statuses).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("project_id = ?", projectID).Order("level ASC, id ASC").Find(&workflow.Priorities).Error; err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// EnsureWorkflow 项目没有状态或优先级定义时创建默认定义
+// 项目任务中已经使用、但不在默认定义中的取值也会加入定义，保证现有数据有效
+func (s *WorkflowService) EnsureWorkflow(db *gorm.DB, projectID uint) error {
+	var count int
+	if err := db.Model(&models.WorkflowStatus{}).Where("project_id = ?", projectID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		statuses := DefaultWorkflowStatuses()
+		var used []string
+		if err := db.Model(&models.Task{}).Where("project_id = ? AND status IS NOT NULL AND status <> ''", projectID).
+			Order("status").Pluck("DISTINCT status", &used).Error; err != nil {
+			return err
+		}
+		for _, key := range used {
+			if !containsStatus(statuses, key) {
+				statuses = append(statuses, models.WorkflowStatus{Key: key, Name: translateStatusToChinese(key), Category: legacyStatusCategory(key)})
+			}
+		}
+		for i := range statuses {
+			statuses[i].ProjectID = projectID
+			statuses[i].Position = i
+			if err := db.Create(&statuses[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := db.Model(&models.WorkflowPriority{}).Where("project_id = ?", projectID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		priorities := DefaultWorkflowPriorities()
+		var used []string
+		if err := db.Model(&models.Task{}).Where("project_id = ? AND priority IS NOT NULL AND priority <> ''", projectID).
+			Order("priority").Pluck("DISTINCT priority", &used).Error; err != nil {
+			return err
+		}
+		for _, key := range used {
+			if !containsPriority(priorities, key) {
+				priorities = append(priorities, models.WorkflowPriority{Key: key, Name: key})
+			}
+		}
+		for i := range priorities {
+			priorities[i].ProjectID = projectID
+			priorities[i].Level = i
+			if err := db.Create(&priorities[i]).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// legacyStatusCategory 推断历史数据中自定义状态的分类，无法识别的按待办处理
+func legacyStatusCategory(status string) models.StatusCategory {
+	switch status {
+	case "completed", "closed", "resolved", "cancelled":
+		return models.StatusCategoryDone
+	case "testing", "in_review", "doing":
+		return models.StatusCategoryInProgress
+	}
+	return models.StatusCategoryTodo
+}
+
+// MigrateWorkflows 为还没有工作流定义的项目创建默认定义
+func (s *WorkflowService) MigrateWorkflows(db *gorm.DB) error {
+	var projectIDs []uint
+	if err := db.Model(&models.Project{}).Where(
+		"id NOT IN (SELECT project_id FROM workflow_statuses) OR id NOT IN (SELECT project_id FROM workflow_priorities)",
+	).Pluck("id", &projectIDs).Error; err != nil {
+		return err
+	}
+	for _, projectID := range projectIDs {
+		if err := s.EnsureWorkflow(db, projectID); err != nil {
+			return fmt.Errorf("failed to create workflow for project %d: %v", projectID, err)
+		}
+	}
+	return nil
+}
+
+// WorkflowInUseError 要删除的状态或优先级仍被任务使用，需要提供替换值
+type WorkflowInUseError struct {
+	Field  string         `json:"field"`
+	Values map[string]int `json:"values"` // 取值 -> 使用的任务数
+}
+
+func (e *WorkflowInUseError) Error() string {
+	keys := make([]string, 0, len(e.Values))
+	for key := range e.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return fmt.Sprintf("%s values still used by tasks: %s", e.Field, strings.Join(keys, ", "))
+}
+
+// UpdateStatuses 替换项目的状态定义（按给定顺序）
+// replacements 为被删除状态到新状态的映射，仍被任务使用的状态必须提供替换值
+func (s *WorkflowService) UpdateStatuses(db *gorm.DB, projectID uint, statuses []models.WorkflowStatus, replacements map[string]string) error {
+	if len(statuses) == 0 {
+		return fmt.Errorf("at least one status is required")
+	}
+
+	keys := make(map[string]bool)
+	hasTodo, hasDone := false, false
+	defaults := 0
+	for i := range statuses {
+		status := &statuses[i]
+		status.Key = strings.TrimSpace(status.Key)
+		status.Name = strings.TrimSpace(status.Name)
+		if status.Key == "" || len(status.Key) > 50 {
+			return fmt.Errorf("status key must be 1-50 characters")
+		}
+		if keys[status.Key] {
+			return fmt.Errorf("duplicate status %q", status.Key)
+		}
+		keys[status.Key] = true
+		if status.Name == "" {
+			status.Name = status.Key
+		}
+		switch status.Category {
+		case models.StatusCategoryTodo:
+			hasTodo = true
+		case models.StatusCategoryInProgress:
+		case models.StatusCategoryDone:
+			hasDone = true
+		default:
+			return fmt.Errorf("invalid category %q for status %q, must be todo, in_progress or done", status.Category, status.Key)
+		}
+		if status.IsDefault {
+			defaults++
+		}
+	}
+	if !hasTodo || !hasDone {
+		return fmt.Errorf("workflow needs at least one todo status and one done status")
+	}
+	if defaults > 1 {
+		return fmt.Errorf("only one status can be the default")
+	}
+	if defaults == 0 {
+		for i := range statuses {
+			if statuses[i].Category == models.StatusCategoryTodo {
+				statuses[i].IsDefault = true
+				break
+			}
+		}
+	}
+
+	if err := s.replaceRemovedValues(db, projectID, "status", keys, replacements); err != nil {
+		return err
+	}
+
+	// 阶段自动设置的状态被删除时，改为替换值或清空
+	var stages []models.Stage
+	if err := db.Where("project_id = ? AND auto_assign_status <> ''", projectID).Find(&stages).Error; err != nil {
+		return err
+	}
+	for _, stage := range stages {
+		if keys[stage.AutoAssignStatus] {
+			continue
+		}
+		if err := db.Model(&models.Stage{}).Where("id = ?", stage.ID).
+			Update("auto_assign_status", replacements[stage.AutoAssignStatus]).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.Where("project_id = ?", projectID).Delete(&models.WorkflowStatus{}).Error; err != nil {
+		return err
+	}
+	for i := range statuses {
+		statuses[i].ID = 0
+		statuses[i].ProjectID = projectID
+		statuses[i].Position = i
+		if err := db.Create(&statuses[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdatePriorities 替换项目的优先级定义，顺序即级别（越靠前越紧急）
+// replacements 为被删除优先级到新优先级的映射，仍被任务使用的优先级必须提供替换值
+func (s *WorkflowService) UpdatePriorities(db *gorm.DB, projectID uint, priorities []models.WorkflowPriority, replacements map[string]string) error {
+	if len(priorities) == 0 {
+		return fmt.Errorf("at least one priority is required")
+	}
+
+	keys := make(map[string]bool)
+	defaults := 0
+	for i := range priorities {
+		priority := &priorities[i]
+		priority.Key = strings.TrimSpace(priority.Key)
+		priority.Name = strings.TrimSpace(priority.Name)
+		if priority.Key == "" || len(priority.Key) > 50 {
+			return fmt.Errorf("priority key must be 1-50 characters")
+		}
+		if keys[priority.Key] {
+			return fmt.Errorf("duplicate priority %q", priority.Key)
+		}
+		keys[priority.Key] = true
+		if priority.Name == "" {
+			priority.Name = priority.Key
+		}
+		if priority.IsDefault {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return fmt.Errorf("only one priority can be the default")
+	}
+	if defaults == 0 {
+		priorities[len(priorities)/2].IsDefault = true
+	}
+
+	if err := s.replaceRemovedValues(db, projectID, "priority", keys, replacements); err != nil {
+		return err
+	}
+
+	if err := db.Where("project_id = ?", projectID).Delete(&models.WorkflowPriority{}).Error; err != nil {
+		return err
+	}
+	for i := range priorities {
+		priorities[i].ID = 0
+		priorities[i].ProjectID = projectID
+		priorities[i].Level = i
+		if err := db.Create(&priorities[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceRemovedValues 将任务中不在新定义里的取值替换为 replacements 中的值
+func (s *WorkflowService) replaceRemovedValues(db *gorm.DB, projectID uint, column string, keys map[string]bool, replacements map[string]string) error {
+	type usage struct {
+		Value string
+		Count int
+	}
+	var usages []usage
+	if err := db.Model(&models.Task{}).Select(column+" AS value, count(*) AS count").
+		Where("project_id = ?", projectID).Group(column).Scan(&usages).Error; err != nil {
+		return err
+	}
+
+	missing := make(map[string]int)
+	for _, u := range usages {
+		if u.Value == "" || keys[u.Value] {
+			continue
+		}
+		replacement, ok := replacements[u.Value]
+		if !ok || !keys[replacement] {
+			missing[u.Value] = u.Count
+			continue
+		}
+		if err := db.Model(&models.Task{}).Where("project_id = ? AND "+column+" = ?", projectID, u.Value).
+			UpdateColumn(column, replacement).Error; err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		return &WorkflowInUseError{Field: column, Values: missing}
+	}
+	return nil
+}
+
+func containsStatus(statuses []models.WorkflowStatus, key string) bool {
+	for _, status := range statuses {
+		if status.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPriority(priorities []models.WorkflowPriority, key string) bool {
+	for _, priority := range priorities {
+		if priority.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// 游标分页
+//
+// 列表接口接受以下查询参数：
+//   - limit       每页数量
+//   - cursor      上一页返回的 next_cursor
+//   - with_total  为 true 时返回符合条件的总数
+//
+// 游标记录上一页最后一行的排序键取值（按数据库中的原始值保存），下一页从严格大于（或小于）
+// 这些取值的行开始，因此翻页期间插入或删除数据不会导致重复或遗漏。
+// 游标与列表和排序方式绑定，使用其他列表或不同排序的游标会返回错误。
+
+// ErrInvalidCursor 游标无效、不属于当前列表或与当前排序不匹配
+var ErrInvalidCursor = errors.New("invalid or expired cursor")
+
+// SortColumn 排序列，Expr 不能为 NULL（可为空的列需要用 COALESCE 包装）
+type SortColumn struct {
+	Expr string
+	Desc bool
+}
+
+// Keyset 分页使用的排序列，最后一列必须唯一（通常为主键）
+type Keyset struct {
+	Table   string
+	Columns []SortColumn
+}
+
+// PageParams 分页参数
+type PageParams struct {
+	Limit     int  // 0 表示不分页
+	WithTotal bool // 是否返回总数
+	Paginated bool // 请求中带了 limit 或 cursor
+
+	sort        string
+	cursor      []interface{}
+	cursorTable string
+}
+
+// PageInfo 分页元数据
+type PageInfo struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+	Sort       string `json:"sort,omitempty"`
+}
+
+type cursorPayload struct {
+	Table  string        `json:"t"`
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// ParsePageParams 解析分页参数
+// defaultLimit 为未传 limit 时的每页数量（0 表示不传 limit 时返回全部），maxLimit 为上限
+func ParsePageParams(c *gin.Context, sort string, defaultLimit, maxLimit int) (*PageParams, error) {
+	params := &PageParams{Limit: defaultLimit, sort: sort}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		params.Limit = limit
+		params.Paginated = true
+	}
+	if params.Limit > maxLimit {
+		params.Limit = maxLimit
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		payload, err := decodeCursor(value, sort)
+		if err != nil {
+			return nil, err
+		}
+		params.cursor = payload.Values
+		params.cursorTable = payload.Table
+		params.Paginated = true
+		if params.Limit == 0 {
+			params.Limit = maxLimit
+		}
+	}
+
+	switch strings.ToLower(c.Query("with_total")) {
+	case "true", "1":
+		params.WithTotal = true
+	}
+	return params, nil
+}
+
+// HasCursor 是否从游标位置继续
+func (p *PageParams) HasCursor() bool {
+	return p.cursor != nil
+}
+
+// Apply 添加排序、游标条件和数量限制（多取一行用于判断是否还有下一页）
+// 游标不属于该列表或取值个数与排序列不一致时返回 ErrInvalidCursor
+func (p *PageParams) Apply(db *gorm.DB, keyset Keyset) (*gorm.DB, error) {
+	if p.cursor != nil && (p.cursorTable != keyset.Table || len(p.cursor) != len(keyset.Columns)) {
+		return nil, ErrInvalidCursor
+	}
+
+	db = OrderByColumns(db, keyset.Columns)
+
+	if p.cursor != nil {
+		// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ...
+		var clauses []string
+		var args []interface{}
+		for i, column := range keyset.Columns {
+			var parts []string
+			for j := 0; j < i; j++ {
+				parts = append(parts, keyset.Columns[j].Expr+" = ?")
+				args = append(args, p.cursor[j])
+			}
+			op := " > ?"
+			if column.Desc {
+				op = " < ?"
+			}
+			parts = append(parts, column.Expr+op)
+			args = append(args, p.cursor[i])
+			clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+		}
+		db = db.Where("("+strings.Join(clauses, " OR ")+")", args...)
+	}
+
+	if p.Limit > 0 {
+		db = db.Limit(p.Limit + 1)
+	}
+	return db, nil
+}
+
+// Trim 返回本页实际数量以及是否还有下一页
+func (p *PageParams) Trim(count int) (int, bool) {
+	if p.Limit > 0 && count > p.Limit {
+		return p.Limit, true
+	}
+	return count, false
+}
+
+// Info 生成分页元数据，hasMore 时根据本页最后一行（lastID 为最后一列的取值）生成下一页游标
+func (p *PageParams) Info(db *gorm.DB, keyset Keyset, hasMore bool, lastID interface{}, total *int) (PageInfo, error) {
+	info := PageInfo{Limit: p.Limit, HasMore: hasMore, Total: total, Sort: p.sort}
+	if !hasMore {
+		return info, nil
+	}
+
+	// 从数据库读取原始取值，避免时间等类型在 Go 中转换后与存储的文本不一致
+	// 一元 + 不改变取值，但结果不再带列的声明类型，驱动不会把时间列解析为 time.Time
+	exprs := make([]string, len(keyset.Columns))
+	for i, column := range keyset.Columns {
+		exprs[i] = "+(" + column.Expr + ")"
+	}
+	last := keyset.Columns[len(keyset.Columns)-1]
+	values := make([]interface{}, len(exprs))
+	pointers := make([]interface{}, len(exprs))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := db.Table(keyset.Table).Select(strings.Join(exprs, ", ")).Where(last.Expr+" = ?", lastID).
+		Limit(1).Row().Scan(pointers...); err != nil {
+		return info, fmt.Errorf("failed to build cursor: %v", err)
+	}
+	for i, value := range values {
+		if b, ok := value.([]byte); ok {
+			values[i] = string(b)
+		}
+	}
+
+	cursor, err := encodeCursor(keyset.Table, p.sort, values)
+	if err != nil {
+		return info, err
+	}
+	info.NextCursor = cursor
+	return info, nil
+}
+
+// ParseSort 解析通用列表的排序字符串，fields 为可排序字段到排序表达式的映射
+// 每个键为 field、field:asc、field:desc 或 -field，多个键用逗号分隔；最后按 idExpr 保证顺序稳定
+// 返回排序列和规范化后的排序字符串
+func ParseSort(spec, defaultSpec string, fields map[string]string, idExpr string) ([]SortColumn, string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = defaultSpec
+	}
+
+	var columns []SortColumn
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		desc := false
+		if strings.HasPrefix(part, "-") {
+			desc = true
+			part = part[1:]
+		}
+		if i := strings.Index(part, ":"); i >= 0 {
+			switch strings.ToLower(part[i+1:]) {
+			case "asc":
+			case "desc":
+				desc = true
+			default:
+				return nil, "", fmt.Errorf("invalid sort direction %q, use asc or desc", part[i+1:])
+			}
+			part = part[:i]
+		}
+		field := strings.ToLower(part)
+		expr, ok := fields[field]
+		if !ok {
+			return nil, "", fmt.Errorf("unknown sort field %q", field)
+		}
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+
+		direction := "asc"
+		if desc {
+			direction = "desc"
+		}
+		columns = append(columns, SortColumn{Expr: expr, Desc: desc})
+		normalized = append(normalized, field+":"+direction)
+	}
+	if len(columns) == 0 {
+		return nil, "", fmt.Errorf("empty sort")
+	}
+	return append(columns, SortColumn{Expr: idExpr}), strings.Join(normalized, ","), nil
+}
+
+// OrderByColumns 按排序列排序
+func OrderByColumns(db *gorm.DB, columns []SortColumn) *gorm.DB {
+	for _, column := range columns {
+		if column.Desc {
+			db = db.Order(column.Expr + " DESC")
+		} else {
+			db = db.Order(column.Expr + " ASC")
+		}
+	}
+	return db
+}
+
+func encodeCursor(table, sort string, values []interface{}) (string, error) {
+	data, err := json.Marshal(cursorPayload{Table: table, Sort: sort, Values: values})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(value, sort string) (*cursorPayload, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var payload cursorPayload
+	if err := decoder.Decode(&payload); err != nil || len(payload.Values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if payload.Sort != sort {
+		return nil, fmt.Errorf("cursor was created with sort %q, not %q", payload.Sort, sort)
+	}
+
+	// JSON 数字还原为整数或浮点数，保证与数据库中的数值比较
+	for i, v := range payload.Values {
+		if n, ok := v.(json.Number); ok {
+			if integer, err := n.Int64(); err == nil {
+				payload.Values[i] = integer
+			} else if float, err := n.Float64(); err == nil {
+				payload.Values[i] = float
+			}
+		}
+	}
+	return &payload, nil
+}
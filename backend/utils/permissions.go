@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"net/http"
 	"project-manager-backend/database"
 	"project-manager-backend/models"
+
+	"github.com/gin-gonic/gin"
 )
 
 // CheckProjectPermission 检查用户在项目中的权限
@@ -89,3 +92,30 @@ func CanManageProject(userID uint, projectID uint) bool {
 func CanInviteMembers(userID uint, projectID uint) bool {
 	return CheckProjectMember(userID, projectID) || CheckProjectOwner(userID, projectID)
 }
+
+// IsProjectArchived 检查项目是否已归档
+func IsProjectArchived(projectID uint) bool {
+	var project models.Project
+	if err := database.DB.Select("id, status").First(&project, projectID).Error; err != nil {
+		return false
+	}
+	return project.Status == models.ProjectStatusArchived
+}
+
+// CheckProjectWritable 检查项目是否可以修改，已归档的项目只读
+// 项目已归档时返回 409 并返回 false
+func CheckProjectWritable(c *gin.Context, projectID uint) bool {
+	if IsProjectArchived(projectID) {
+		Error(c, http.StatusConflict, "Project is archived, unarchive it before making changes")
+		return false
+	}
+	return true
+}
+
+// SameUserID 两个可为空的用户ID（如负责人）是否相同，都为空也视为相同
+func SameUserID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// 任务排序键（rank）
+// 排序键是 base62 字符串，按字典序比较即可得到任务顺序。把字符串看作 (0, 1) 区间内的
+// 62 进制小数，两个排序键之间总能找到一个新的排序键，所以移动任务时只需要改写被移动的那一行。
+// 排序键不以 '0' 结尾，空字符串分别表示下界和上界。
+//
+// 追加到末尾时没有上界，不能取到 1 的中点（每次追加剩余空间减半，长度线性增长），
+// 而是把开头连续的 'z' 个数 k 看作层级：第 k 层的排序键是 k 个 'z' 加上 k+1 位、首位不是 'z' 的数字，
+// 追加时在当前层加一，当前层用完时进入下一层。层级越高排序键越大，长度随追加次数按对数增长。
+
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// rankLastDigit 最大的数字，追加时用作层级前缀
+const rankLastDigit = 'z'
+
+// RankMaxLength 排序键超过该长度时应重新均匀分配整个阶段的排序键
+const RankMaxLength = 24
+
+// ErrInvalidRankRange 排序区间无效（下界不小于上界）
+var ErrInvalidRankRange = errors.New("invalid rank range")
+
+// RankBetween 生成一个位于 prev 和 next 之间的排序键
+// prev 为空表示插入到最前，next 为空表示插入到最后
+func RankBetween(prev, next string) (string, error) {
+	if next != "" && prev >= next {
+		return "", ErrInvalidRankRange
+	}
+	if strings.HasSuffix(prev, "0") || strings.HasSuffix(next, "0") {
+		return "", errors.New("rank must not end with '0'")
+	}
+	if next == "" {
+		return rankAfter(prev), nil
+	}
+	return rankMidpoint(prev, next, true), nil
+}
+
+// rankAfter 生成大于 prev 的排序键，用于追加到末尾（见文件开头的说明）
+func rankAfter(prev string) string {
+	if prev == "" {
+		return string(rankDigits[len(rankDigits)/2])
+	}
+
+	level := 0
+	for level < len(prev) && prev[level] == rankLastDigit {
+		level++
+	}
+	prefix := prev[:level]
+	body := prev[level:]
+	width := level + 1
+	if body == "" {
+		// 全部是 'z'（旧数据），直接使用该层的第一个排序键
+		return prefix + strings.Repeat("0", width-1) + "1"
+	}
+
+	// 截断到该层的位数后加一，结果一定大于 prev
+	digits := make([]byte, width)
+	for i := range digits {
+		digits[i] = rankDigitAt(body, i)
+	}
+	carry := true
+	for i := width - 1; i >= 0 && carry; i-- {
+		if digits[i] == rankLastDigit {
+			digits[i] = '0'
+			continue
+		}
+		digits[i] = rankDigits[strings.IndexByte(rankDigits, digits[i])+1]
+		carry = false
+	}
+	if carry || digits[0] == rankLastDigit {
+		// 当前层已用完，进入下一层
+		return prefix + string(rankLastDigit) + strings.Repeat("0", width) + "1"
+	}
+	return prefix + strings.TrimRight(string(digits), "0")
+}
+
+// rankMidpoint 计算两个小数的中点，hasUpper 为 false 时上界为 1
+func rankMidpoint(a, b string, hasUpper bool) string {
+	if hasUpper {
+		// 跳过公共前缀（a 在长度不足时按 '0' 补齐）
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + rankMidpoint(safeSuffix(a, n), b[n:], true)
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(rankDigits, a[0])
+	}
+	digitB := len(rankDigits)
+	if hasUpper {
+		digitB = strings.IndexByte(rankDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(rankDigits[(digitA+digitB+1)/2])
+	}
+	if hasUpper && len(b) > 1 {
+		return b[:1]
+	}
+	return string(rankDigits[digitA]) + rankMidpoint(safeSuffix(a, 1), "", false)
+}
+
+// RankSequence 生成 n 个均匀分布且递增的排序键，用于迁移和重新平衡
+func RankSequence(n int) []string {
+	ranks := make([]string, 0, n)
+	if n <= 0 {
+		return ranks
+	}
+
+	// 选择足够的位数，使 n 个键之间保留足够的插入空间
+	width := 1
+	capacity := len(rankDigits)
+	for capacity < (n+1)*8 {
+		width++
+		capacity *= len(rankDigits)
+	}
+
+	step := capacity / (n + 1)
+	for i := 1; i <= n; i++ {
+		value := step * i
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = rankDigits[value%len(rankDigits)]
+			value /= len(rankDigits)
+		}
+		ranks = append(ranks, strings.TrimRight(string(key), "0"))
+	}
+	return ranks
+}
+
+func rankDigitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return '0'
+}
+
+func safeSuffix(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+	return s[n:]
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string
+		want       string
+		wantErr    bool
+	}{
+		{name: "empty stage", want: "V"},
+		{name: "before first", next: "V", want: "G"},
+		{name: "between distant digits", prev: "1", next: "3", want: "2"},
+		{name: "between adjacent digits", prev: "V", next: "W", want: "VV"},
+		{name: "common prefix", prev: "V1", next: "V3", want: "V2"},
+		{name: "shorter prev", prev: "V", next: "V2", want: "V1"},
+		{name: "next longer than one digit", prev: "1", next: "2V", want: "2"},
+		{name: "append after single digit", prev: "V", want: "W"},
+		{name: "append truncates fraction", prev: "VV", want: "W"},
+		{name: "append fills level zero", prev: "x", want: "y"},
+		{name: "append leaves level zero", prev: "y", want: "z01"},
+		{name: "append within level one", prev: "z01", want: "z02"},
+		{name: "append carries within level", prev: "z0z", want: "z1"},
+		{name: "append leaves level one", prev: "zyz", want: "zz001"},
+		{name: "append after legacy z", prev: "z", want: "z01"},
+		{name: "append after legacy zz", prev: "zz", want: "zz001"},
+		{name: "equal bounds", prev: "V", next: "V", wantErr: true},
+		{name: "reversed bounds", prev: "W", next: "V", wantErr: true},
+		{name: "trailing zero", prev: "V0", next: "W", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RankBetween(tt.prev, tt.next)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("RankBetween(%q, %q) = %q, want error", tt.prev, tt.next, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RankBetween(%q, %q) error: %v", tt.prev, tt.next, err)
+			}
+			if got != tt.want {
+				t.Errorf("RankBetween(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+			}
+			if got <= tt.prev || (tt.next != "" && got >= tt.next) {
+				t.Errorf("RankBetween(%q, %q) = %q is out of range", tt.prev, tt.next, got)
+			}
+		})
+	}
+}
+
+func TestRankBetweenRepeatedAppend(t *testing.T) {
+	prev := ""
+	for i := 0; i < 100000; i++ {
+		rank, err := RankBetween(prev, "")
+		if err != nil {
+			t.Fatalf("append %d after %q: %v", i, prev, err)
+		}
+		if rank <= prev {
+			t.Fatalf("append %d: %q is not after %q", i, rank, prev)
+		}
+		if strings.HasSuffix(rank, "0") {
+			t.Fatalf("append %d: %q ends with '0'", i, rank)
+		}
+		prev = rank
+	}
+	if len(prev) > 5 {
+		t.Errorf("rank after 100000 appends is %q (%d characters), want at most 5", prev, len(prev))
+	}
+}
+
+func TestRankBetweenRepeatedInsert(t *testing.T) {
+	// 反复插入到同一位置时排序键变长，超过 RankMaxLength 后由调用方重新平衡
+	prev, next := "V", "W"
+	for i := 0; i < 50; i++ {
+		rank, err := RankBetween(prev, next)
+		if err != nil {
+			t.Fatalf("insert %d between %q and %q: %v", i, prev, next, err)
+		}
+		if rank <= prev || rank >= next {
+			t.Fatalf("insert %d: %q is not between %q and %q", i, rank, prev, next)
+		}
+		next = rank
+	}
+}
+
+func TestRankSequence(t *testing.T) {
+	tests := []struct {
+		n         int
+		wantWidth int
+	}{
+		{n: 0},
+		{n: 1, wantWidth: 1},
+		{n: 6, wantWidth: 1},
+		{n: 7, wantWidth: 2},
+		{n: 1000, wantWidth: 3},
+		{n: 100000, wantWidth: 4},
+	}
+	for _, tt := range tests {
+		ranks := RankSequence(tt.n)
+		if len(ranks) != tt.n {
+			t.Fatalf("RankSequence(%d) returned %d ranks", tt.n, len(ranks))
+		}
+		for i, rank := range ranks {
+			if rank == "" || strings.HasSuffix(rank, "0") {
+				t.Fatalf("RankSequence(%d)[%d] = %q is not a valid rank", tt.n, i, rank)
+			}
+			if len(rank) > tt.wantWidth {
+				t.Fatalf("RankSequence(%d)[%d] = %q is longer than %d", tt.n, i, rank, tt.wantWidth)
+			}
+			if i > 0 {
+				if rank <= ranks[i-1] {
+					t.Fatalf("RankSequence(%d) is not increasing at %d: %q <= %q", tt.n, i, rank, ranks[i-1])
+				}
+				// 相邻排序键之间必须还能插入
+				if _, err := RankBetween(ranks[i-1], rank); err != nil {
+					t.Fatalf("RankSequence(%d): no room between %q and %q", tt.n, ranks[i-1], rank)
+				}
+			}
+		}
+	}
+}
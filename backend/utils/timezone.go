@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"errors"
+	"project-manager-backend/database"
+	"project-manager-backend/models"
+	"strings"
+	"time"
+)
+
+// 截止时间格式
+const (
+	DateLayout      = "2006-01-02"          // 全天任务，只有日期
+	localTimeLayout = "2006-01-02T15:04:05" // 不带时区偏移的时刻，按用户时区解释
+	localMinLayout  = "2006-01-02T15:04"
+)
+
+// ErrInvalidDueDate 截止时间格式无效
+var ErrInvalidDueDate = errors.New("due date must be YYYY-MM-DD or an RFC 3339 date-time")
+
+// IsValidTimezone 判断是否是有效的 IANA 时区名称（如 Asia/Shanghai），空字符串表示不设置
+func IsValidTimezone(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// LoadTimezone 加载时区，名称为空或无效时使用 UTC
+func LoadTimezone(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ResolveTimezone 按顺序取第一个有效的时区名称，全部为空时使用 UTC
+func ResolveTimezone(names ...string) *time.Location {
+	for _, name := range names {
+		if name != "" && IsValidTimezone(name) {
+			return LoadTimezone(name)
+		}
+	}
+	return time.UTC
+}
+
+// UserTimezone 获取用户设置的时区名称
+func UserTimezone(userID uint) string {
+	var user models.User
+	if err := database.DB.Select("timezone").Where("id = ?", userID).First(&user).Error; err != nil {
+		return ""
+	}
+	return user.Timezone
+}
+
+// ProjectTimezone 获取项目设置的时区名称
+func ProjectTimezone(projectID uint) string {
+	var project models.Project
+	if err := database.DB.Select("timezone").Where("id = ?", projectID).First(&project).Error; err != nil {
+		return ""
+	}
+	return project.Timezone
+}
+
+// TaskTimezone 用户查看或编辑项目任务时使用的时区：用户时区 > 项目时区 > UTC
+// 解析和显示截止时间、判断今天到期和逾期都按这个顺序，同一任务在各处的结果一致；
+// projectID 为 0（跨项目）时只看用户时区
+func TaskTimezone(userID, projectID uint) *time.Location {
+	if projectID == 0 {
+		return ResolveTimezone(UserTimezone(userID))
+	}
+	return ResolveTimezone(UserTimezone(userID), ProjectTimezone(projectID))
+}
+
+// ParseDueDate 解析截止时间
+// YYYY-MM-DD 为全天任务，保存为该日期的 UTC 零点；RFC 3339 为具体时刻，统一保存为 UTC；
+// 不带时区偏移的时刻按 loc 解释。allDay 不为空时覆盖根据格式推断的结果
+func ParseDueDate(value string, allDay *bool, loc *time.Location) (time.Time, bool, error) {
+	value = strings.TrimSpace(value)
+
+	if date, err := time.Parse(DateLayout, value); err == nil {
+		if allDay != nil && !*allDay {
+			// 明确指定为非全天时，取当地时间当天零点
+			return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc).UTC(), false, nil
+		}
+		return date, true, nil
+	}
+
+	var (
+		instant time.Time
+		err     error
+	)
+	if instant, err = time.Parse(time.RFC3339, value); err != nil {
+		if instant, err = time.ParseInLocation(localTimeLayout, value, loc); err != nil {
+			if instant, err = time.ParseInLocation(localMinLayout, value, loc); err != nil {
+				return time.Time{}, false, ErrInvalidDueDate
+			}
+		}
+	}
+	if allDay != nil && *allDay {
+		// 带时刻但标记为全天时，取该时刻在其自身时区中的日期
+		return time.Date(instant.Year(), instant.Month(), instant.Day(), 0, 0, 0, 0, time.UTC), true, nil
+	}
+	return instant.UTC(), false, nil
+}
+
+// ConvertDueDate 切换已有截止时间的全天标记
+// 全天转为具体时刻时取 loc 中当天零点；具体时刻转为全天时取其在 loc 中的日期
+func ConvertDueDate(due time.Time, toAllDay bool, loc *time.Location) time.Time {
+	if toAllDay {
+		return DueDay(due, false, loc)
+	}
+	due = due.UTC()
+	return time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, loc).UTC()
+}
+
+// DueDay 返回截止时间在 loc 中所在的日期（以该日期的 UTC 零点表示，与全天任务的存储方式一致）
+func DueDay(due time.Time, allDay bool, loc *time.Location) time.Time {
+	if allDay {
+		due = due.UTC()
+	} else {
+		due = due.In(loc)
+	}
+	return time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// FormatDueDate 格式化截止时间：全天任务只输出日期，否则输出 RFC 3339（UTC）
+func FormatDueDate(due time.Time, allDay bool) string {
+	if allDay {
+		return due.UTC().Format(DateLayout)
+	}
+	return due.UTC().Format(time.RFC3339)
+}
+
+// DueDeadline 返回截止时刻，超过该时刻即为逾期
+// 全天任务在 loc 中截止日期结束时（次日零点）到期
+func DueDeadline(due time.Time, allDay bool, loc *time.Location) time.Time {
+	if !allDay {
+		return due
+	}
+	due = due.UTC()
+	return time.Date(due.Year(), due.Month(), due.Day()+1, 0, 0, 0, 0, loc)
+}
+
+// IsOverdue 判断在 now 时刻任务是否已逾期
+func IsOverdue(due time.Time, allDay bool, now time.Time, loc *time.Location) bool {
+	return !now.Before(DueDeadline(due, allDay, loc))
+}
+
+// IsDueToday 判断截止时间是否落在 loc 中 now 所在的日期
+func IsDueToday(due time.Time, allDay bool, now time.Time, loc *time.Location) bool {
+	return DueDay(due, allDay, loc).Equal(DueDay(now, false, loc))
+}